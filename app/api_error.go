@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	model "goapm/domain"
+	"goapm/logger"
+)
+
+// statusClientClosedRequest non standard status(nginx) used when the request context was cancelled
+const statusClientClosedRequest = 499
+
+// ApiErrorResponse error envelope returned by all api routes
+type ApiErrorResponse struct {
+	Status    string          `json:"status"`
+	ErrorType model.ErrorType `json:"errorType"`
+	Error     string          `json:"error"`
+	RequestId string          `json:"requestId,omitempty"`
+}
+
+var errorTypeStatuses = map[model.ErrorType]int{
+	model.ErrorBadData:         fasthttp.StatusBadRequest,
+	model.ErrorNotFound:        fasthttp.StatusNotFound,
+	model.ErrorTooManyRequests: fasthttp.StatusTooManyRequests,
+	model.ErrorCanceled:        statusClientClosedRequest,
+	model.ErrorExec:            fasthttp.StatusInternalServerError,
+	model.ErrorInternal:        fasthttp.StatusInternalServerError,
+	model.ErrorNotImplemented:  fasthttp.StatusNotImplemented,
+	model.ErrorUnavailable:     fasthttp.StatusServiceUnavailable,
+	model.ErrorTimeout:         fasthttp.StatusGatewayTimeout,
+}
+
+var statusErrorTypes = map[int]model.ErrorType{
+	fasthttp.StatusBadRequest:            model.ErrorBadData,
+	fasthttp.StatusNotFound:              model.ErrorNotFound,
+	fasthttp.StatusMethodNotAllowed:      model.ErrorNotFound,
+	fasthttp.StatusRequestEntityTooLarge: model.ErrorBadData,
+	fasthttp.StatusServiceUnavailable:    model.ErrorUnavailable,
+	fasthttp.StatusRequestTimeout:        model.ErrorTimeout,
+}
+
+// badDataError invalid request parameters, message is returned to the client as is
+func badDataError(err error) error {
+	return &model.ApiError{Typ: model.ErrorBadData, Err: err}
+}
+
+// apiErrorHandler fiber ErrorHandler rendering errors returned by handlers as ApiErrorResponse,
+// errors which aren't ApiError or fiber.Error are logged and reported as internal without details
+func apiErrorHandler(ctx *fiber.Ctx, err error) error {
+	response := ApiErrorResponse{
+		Status:    "error",
+		ErrorType: model.ErrorInternal,
+		Error:     "internal server error",
+	}
+	if requestId, ok := ctx.Locals("requestid").(string); ok {
+		response.RequestId = requestId
+	}
+
+	status := fasthttp.StatusInternalServerError
+	var apiError *model.ApiError
+	var fiberError *fiber.Error
+	if errors.As(err, &apiError) {
+		response.ErrorType = apiError.Typ
+		response.Error = apiError.Error()
+		if typeStatus, ok := errorTypeStatuses[apiError.Typ]; ok {
+			status = typeStatus
+		}
+	} else if errors.As(err, &fiberError) {
+		status = fiberError.Code
+		response.Error = fiberError.Message
+		if errorType, ok := statusErrorTypes[fiberError.Code]; ok {
+			response.ErrorType = errorType
+		}
+	} else {
+		logger.LOGGER.Error("unhandled error in request ", ctx.Path(), ", request id = ", response.RequestId, ", error = ", err)
+	}
+
+	return ctx.Status(status).JSON(response)
+}
@@ -1,44 +1,102 @@
 package controllers
 
 import (
+	"context"
+	"goapm/clickhouse"
+	"goapm/migrations"
+	"goapm/utils"
 	"goapm/logger"
+	"goapm/services"
 	"goapm/web"
+	"errors"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/spf13/viper"
 	"github.com/valyala/fasthttp"
 	model "goapm/domain"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
 func InitControllers(appName string, version string, build string, commit string, buildTime string) {
 
+	web.ErrorHandler = apiErrorHandler
 	app := web.InitApp(appName, version, build, commit, buildTime)
 	InitHealthCheck()
+	if viper.GetBool("CLICKHOUSE_MIGRATE_ON_STARTUP") {
+		if err := migrations.NewMigrationService(clickhouse.NewClickhouseConnectionService()).Up(context.Background()); err != nil {
+			logger.LOGGER.Error("unable to migrate clickhouse schema, error = ", err)
+		}
+	}
 	InitServices()
 
 	ticker := time.NewTicker(1 * time.Minute)
 	go func() {
 		for range ticker.C {
 			go traceFilterJob.Run()
+			go serviceDependencyJob.Run()
+			go alertEvaluator.Run()
+			go alertNotifier.Run()
+			go anomalyDetector.Run()
 		}
 	}()
 
+	registerRoutes(app)
+
+	stopped := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+		received := <-signals
+		logger.LOGGER.Info("received ", received, ", shutting down")
+		ticker.Stop()
+		shutdown(app)
+		close(stopped)
+	}()
+
+	if err := app.Listen(":" + viper.GetString("server.port")); err != nil {
+		logger.LOGGER.Fatal(err.Error())
+	}
+	//logger.LOGGER.Fatal(app.Listen(":7778").Error())
+	<-stopped
+}
+
+// shutdown stop accepting requests and wait for running ones, then flush span writer, so spans already acknowledged
+// to clients are written or spilled to disk before the process exits
+func shutdown(app *fiber.App) {
+	if err := app.Shutdown(); err != nil {
+		logger.LOGGER.Error("unable to shut down server ", err)
+	}
+	spanBatchWriter.Close()
+}
+
+// registerRoutes register api middlewares and routes, handlers return errors which are rendered by apiErrorHandler
+func registerRoutes(app *fiber.App) {
+	app.Use(requestid.New())
+
 	app.Use(func(c *fiber.Ctx) error {
 		c.Path(strings.ReplaceAll(c.Path(), "//", "/"))
 		return c.Next()
 	})
 
+	queryTimeout := time.Duration(utils.GetOrDefaultInt(viper.GetString("QUERY_TIMEOUT_SECONDS"), 60)) * time.Second
+	app.Use("/api/v1", queryTimeoutMiddleware(queryTimeout))
+	app.Use("/api/v2", queryTimeoutMiddleware(queryTimeout))
+
 	app.Get("/api/v1/services", func(ctx *fiber.Ctx) error {
 		query, err := parseGetServicesRequest(ctx)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return badDataError(err)
 		}
 
 		result, err := apmService.GetServices(ctx.UserContext(), query)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return err
 		}
 
 		if len(*result) == 0 {
@@ -50,7 +108,7 @@ func InitControllers(appName string, version string, build string, commit string
 	app.Get("/api/v1/services/list", func(ctx *fiber.Ctx) error {
 		result, err := apmService.GetServicesList(ctx.UserContext())
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return err
 		}
 		return ctx.JSON(result)
 	})
@@ -58,11 +116,11 @@ func InitControllers(appName string, version string, build string, commit string
 	app.Get("/api/v1/service/overview", func(ctx *fiber.Ctx) error {
 		query, err := parseGetServiceOverviewRequest(ctx)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return badDataError(err)
 		}
 		result, err := apmService.GetServiceOverview(ctx.UserContext(), query)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return err
 		}
 
 		if len(*result) == 0 {
@@ -74,11 +132,11 @@ func InitControllers(appName string, version string, build string, commit string
 	app.Get("/api/v1/service/dbOverview", func(ctx *fiber.Ctx) error {
 		query, err := parseGetServiceOverviewRequest(ctx)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return badDataError(err)
 		}
 		result, err := apmService.GetServiceDBOverview(ctx.UserContext(), query)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return err
 		}
 
 		if len(*result) == 0 {
@@ -87,45 +145,50 @@ func InitControllers(appName string, version string, build string, commit string
 		return ctx.JSON(result)
 	})
 
-	app.Get("/api/v1/service/externalAvgDuration", func(ctx *fiber.Ctx) error {
-		query, err := parseGetServiceOverviewRequest(ctx)
+	app.Get("/api/v1/service/latencyHistogram", func(ctx *fiber.Ctx) error {
+		query, err := parseLatencyHistogramRequest(ctx, false)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return badDataError(err)
 		}
-		result, err := apmService.GetServiceExternalAvgDuration(ctx.UserContext(), query)
+		result, err := apmService.GetLatencyHistogram(ctx.UserContext(), query)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return err
 		}
+		return ctx.JSON(result)
+	})
 
-		if len(*result) == 0 {
-			return ctx.JSON([]model.ServiceExternalItem{})
+	app.Get("/api/v1/service/latencyHeatmap", func(ctx *fiber.Ctx) error {
+		query, err := parseLatencyHistogramRequest(ctx, true)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := apmService.GetLatencyHeatmap(ctx.UserContext(), query)
+		if err != nil {
+			return err
 		}
 		return ctx.JSON(result)
 	})
 
-	app.Get("/api/v1/service/externalErrors", func(ctx *fiber.Ctx) error {
-		query, err := parseGetServiceOverviewRequest(ctx)
+	app.Get("/api/v1/service/dbStatements", func(ctx *fiber.Ctx) error {
+		query, err := parseGetDBStatementsRequest(ctx)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return badDataError(err)
 		}
-		result, err := apmService.GetServiceExternalErrors(ctx.UserContext(), query)
+		result, err := databaseService.GetDBStatements(ctx.UserContext(), query)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
-		}
-		if len(*result) == 0 {
-			return ctx.JSON([]model.ServiceExternalItem{})
+			return err
 		}
 		return ctx.JSON(result)
 	})
 
 	app.Get("/api/v1/service/external", func(ctx *fiber.Ctx) error {
-		query, err := parseGetServiceOverviewRequest(ctx)
+		query, err := parseGetServiceExternalRequest(ctx)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return badDataError(err)
 		}
 		result, err := apmService.GetServiceExternal(ctx.UserContext(), query)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return err
 		}
 		if len(*result) == 0 {
 			return ctx.JSON([]model.ServiceExternalItem{})
@@ -135,14 +198,12 @@ func InitControllers(appName string, version string, build string, commit string
 
 	app.Get("/api/v1/service/:service/operations", func(ctx *fiber.Ctx) error {
 		serviceName := ctx.Params("service")
-		var err error
 		if len(serviceName) == 0 {
-			err = fmt.Errorf("service param not found")
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return badDataError(fmt.Errorf("service param not found"))
 		}
 		result, err := apmService.GetOperations(ctx.UserContext(), serviceName)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return err
 		}
 
 		if len(*result) == 0 {
@@ -154,11 +215,11 @@ func InitControllers(appName string, version string, build string, commit string
 	app.Get("/api/v1/service/top_endpoints", func(ctx *fiber.Ctx) error {
 		query, err := parseGetTopEndpointsRequest(ctx)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return badDataError(err)
 		}
 		result, err := apmService.GetTopEndpoints(ctx.UserContext(), query)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return err
 		}
 
 		if len(*result) == 0 {
@@ -170,15 +231,11 @@ func InitControllers(appName string, version string, build string, commit string
 	app.Get("/api/v1/spans", func(ctx *fiber.Ctx) error {
 		query, err := parseSpanSearchRequest(ctx)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return badDataError(err)
 		}
 		result, err := apmService.SearchSpans(ctx.UserContext(), query)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
-		}
-
-		if len(*result) == 0 {
-			return ctx.JSON([]model.SearchSpansResult{})
+			return err
 		}
 		return ctx.JSON(result)
 	})
@@ -186,11 +243,18 @@ func InitControllers(appName string, version string, build string, commit string
 	app.Get("/api/v1/spans/aggregates", func(ctx *fiber.Ctx) error {
 		query, err := parseSearchSpanAggregatesRequest(ctx)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return badDataError(err)
+		}
+		if len(query.GroupBy) != 0 {
+			series, err := apmService.SearchSpansAggregateGroups(ctx.UserContext(), query)
+			if err != nil {
+				return err
+			}
+			return ctx.JSON(series)
 		}
 		result, err := apmService.SearchSpansAggregate(ctx.UserContext(), query)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return err
 		}
 
 		if len(result) == 0 {
@@ -203,7 +267,7 @@ func InitControllers(appName string, version string, build string, commit string
 		serviceName := ctx.Query("service")
 		result, err := apmService.GetTags(ctx.UserContext(), serviceName)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return err
 		}
 
 		if len(*result) == 0 {
@@ -212,27 +276,384 @@ func InitControllers(appName string, version string, build string, commit string
 		return ctx.JSON(result)
 	})
 
+	app.Get("/api/v1/tags/:key/values", func(ctx *fiber.Ctx) error {
+		query, err := parseGetTagValuesRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := apmService.GetTagValues(ctx.UserContext(), query)
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
 	app.Get("/api/v1/traces/:traceID", func(ctx *fiber.Ctx) error {
 		traceId := ctx.Params("traceID")
 		result, err := apmService.SearchTraces(ctx.UserContext(), traceId)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return err
 		}
 
-		if len(*result) == 0 {
-			return ctx.JSON([]model.SearchSpansResult{})
+		if len(*result) == 0 || len((*result)[0].Events) == 0 {
+			return &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("trace %s not found", traceId)}
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Get("/api/v2/traces", func(ctx *fiber.Ctx) error {
+		query, err := parseTraceListRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := apmService.SearchTraceList(ctx.UserContext(), query)
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Get("/api/v2/traces/:traceID/tree", func(ctx *fiber.Ctx) error {
+		traceId := ctx.Params("traceID")
+		result, err := apmService.GetTraceTree(ctx.UserContext(), traceId)
+		if err != nil {
+			return err
+		}
+
+		if result.SpanCount == 0 {
+			return &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("trace %s not found", traceId)}
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Get("/api/v1/errors", func(ctx *fiber.Ctx) error {
+		query, err := parseGetErrorsRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := errorService.GetErrorGroups(ctx.UserContext(), query)
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Get("/api/v1/errors/:fingerprint", func(ctx *fiber.Ctx) error {
+		query, err := parseGetErrorRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := errorService.GetErrorGroup(ctx.UserContext(), query)
+		if err != nil {
+			return err
+		}
+
+		if result.Count == 0 {
+			return &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("error %s not found", query.Fingerprint)}
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Get("/api/v1/rules", func(ctx *fiber.Ctx) error {
+		result, err := alertService.GetRules(ctx.UserContext())
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Post("/api/v1/rules", func(ctx *fiber.Ctx) error {
+		rule, err := parseAlertRuleRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := alertService.CreateRule(ctx.UserContext(), rule)
+		if err != nil {
+			return err
+		}
+		return ctx.Status(fasthttp.StatusCreated).JSON(result)
+	})
+
+	app.Get("/api/v1/rules/:id", func(ctx *fiber.Ctx) error {
+		result, err := alertService.GetRule(ctx.UserContext(), ctx.Params("id"))
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Put("/api/v1/rules/:id", func(ctx *fiber.Ctx) error {
+		rule, err := parseAlertRuleRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := alertService.UpdateRule(ctx.UserContext(), rule)
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Delete("/api/v1/rules/:id", func(ctx *fiber.Ctx) error {
+		err := alertService.DeleteRule(ctx.UserContext(), ctx.Params("id"))
+		if err != nil {
+			return err
+		}
+		return ctx.SendStatus(fasthttp.StatusNoContent)
+	})
+
+	app.Get("/api/v1/rules/:id/history", func(ctx *fiber.Ctx) error {
+		limit, err := parseAlertHistoryLimit(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := alertService.GetRuleHistory(ctx.UserContext(), ctx.Params("id"), limit)
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Get("/api/v1/channels", func(ctx *fiber.Ctx) error {
+		result, err := channelService.GetChannels(ctx.UserContext())
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Post("/api/v1/channels", func(ctx *fiber.Ctx) error {
+		channel, err := parseNotificationChannelRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := channelService.CreateChannel(ctx.UserContext(), channel)
+		if err != nil {
+			return err
+		}
+		return ctx.Status(fasthttp.StatusCreated).JSON(result)
+	})
+
+	app.Get("/api/v1/channels/:id", func(ctx *fiber.Ctx) error {
+		result, err := channelService.GetChannel(ctx.UserContext(), ctx.Params("id"))
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Put("/api/v1/channels/:id", func(ctx *fiber.Ctx) error {
+		channel, err := parseNotificationChannelRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := channelService.UpdateChannel(ctx.UserContext(), channel)
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Delete("/api/v1/channels/:id", func(ctx *fiber.Ctx) error {
+		err := channelService.DeleteChannel(ctx.UserContext(), ctx.Params("id"))
+		if err != nil {
+			return err
+		}
+		return ctx.SendStatus(fasthttp.StatusNoContent)
+	})
+
+	app.Post("/api/v1/channels/:id/test", func(ctx *fiber.Ctx) error {
+		err := channelService.TestChannel(ctx.UserContext(), ctx.Params("id"))
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(map[string]string{"status": "sent"})
+	})
+
+	app.Get("/api/v1/silences", func(ctx *fiber.Ctx) error {
+		result, err := silenceService.GetSilences(ctx.UserContext())
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Post("/api/v1/silences", func(ctx *fiber.Ctx) error {
+		silence, err := parseSilenceRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := silenceService.CreateSilence(ctx.UserContext(), silence)
+		if err != nil {
+			return err
+		}
+		return ctx.Status(fasthttp.StatusCreated).JSON(result)
+	})
+
+	app.Get("/api/v1/silences/:id", func(ctx *fiber.Ctx) error {
+		result, err := silenceService.GetSilence(ctx.UserContext(), ctx.Params("id"))
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Put("/api/v1/silences/:id", func(ctx *fiber.Ctx) error {
+		silence, err := parseSilenceRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := silenceService.UpdateSilence(ctx.UserContext(), silence)
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Delete("/api/v1/silences/:id", func(ctx *fiber.Ctx) error {
+		err := silenceService.DeleteSilence(ctx.UserContext(), ctx.Params("id"))
+		if err != nil {
+			return err
+		}
+		return ctx.SendStatus(fasthttp.StatusNoContent)
+	})
+
+	app.Get("/api/v1/maintenanceWindows", func(ctx *fiber.Ctx) error {
+		result, err := silenceService.GetMaintenanceWindows(ctx.UserContext())
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Post("/api/v1/maintenanceWindows", func(ctx *fiber.Ctx) error {
+		window, err := parseMaintenanceWindowRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := silenceService.CreateMaintenanceWindow(ctx.UserContext(), window)
+		if err != nil {
+			return err
+		}
+		return ctx.Status(fasthttp.StatusCreated).JSON(result)
+	})
+
+	app.Get("/api/v1/maintenanceWindows/:id", func(ctx *fiber.Ctx) error {
+		result, err := silenceService.GetMaintenanceWindow(ctx.UserContext(), ctx.Params("id"))
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Put("/api/v1/maintenanceWindows/:id", func(ctx *fiber.Ctx) error {
+		window, err := parseMaintenanceWindowRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := silenceService.UpdateMaintenanceWindow(ctx.UserContext(), window)
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Delete("/api/v1/maintenanceWindows/:id", func(ctx *fiber.Ctx) error {
+		err := silenceService.DeleteMaintenanceWindow(ctx.UserContext(), ctx.Params("id"))
+		if err != nil {
+			return err
+		}
+		return ctx.SendStatus(fasthttp.StatusNoContent)
+	})
+
+	app.Get("/api/v1/slos", func(ctx *fiber.Ctx) error {
+		result, err := sloService.GetSLOs(ctx.UserContext())
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Post("/api/v1/slos", func(ctx *fiber.Ctx) error {
+		slo, err := parseSLORequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := sloService.CreateSLO(ctx.UserContext(), slo)
+		if err != nil {
+			return err
+		}
+		return ctx.Status(fasthttp.StatusCreated).JSON(result)
+	})
+
+	app.Get("/api/v1/slos/:id", func(ctx *fiber.Ctx) error {
+		result, err := sloService.GetSLOStatus(ctx.UserContext(), ctx.Params("id"))
+		if err != nil {
+			return err
 		}
 		return ctx.JSON(result)
 	})
 
+	app.Put("/api/v1/slos/:id", func(ctx *fiber.Ctx) error {
+		slo, err := parseSLORequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := sloService.UpdateSLO(ctx.UserContext(), slo)
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Delete("/api/v1/slos/:id", func(ctx *fiber.Ctx) error {
+		err := sloService.DeleteSLO(ctx.UserContext(), ctx.Params("id"))
+		if err != nil {
+			return err
+		}
+		return ctx.SendStatus(fasthttp.StatusNoContent)
+	})
+
+	app.Get("/api/v1/anomalies", func(ctx *fiber.Ctx) error {
+		params, err := parseGetAnomaliesRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		result, err := anomalyService.GetAnomalies(ctx.UserContext(), params)
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Get("/api/v1/settings/apdex", func(ctx *fiber.Ctx) error {
+		result, err := settingsService.GetApdexSettings(ctx.UserContext())
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(result)
+	})
+
+	app.Put("/api/v1/settings/apdex/:service", func(ctx *fiber.Ctx) error {
+		setting, err := parseApdexSettingRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		err = settingsService.SetApdexSetting(ctx.UserContext(), setting)
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(setting)
+	})
+
 	app.Get("/api/v1/usage", func(ctx *fiber.Ctx) error {
 		query, err := parseGetUsageRequest(ctx)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return badDataError(err)
 		}
 		result, err := apmService.GetUsage(ctx.UserContext(), query)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return err
 		}
 
 		if len(*result) == 0 {
@@ -242,13 +663,13 @@ func InitControllers(appName string, version string, build string, commit string
 	})
 
 	app.Get("/api/v1/serviceMapDependencies", func(ctx *fiber.Ctx) error {
-		query, err := parseGetServicesRequest(ctx)
+		query, err := parseGetServiceMapRequest(ctx)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return badDataError(err)
 		}
 		result, err := apmService.GetServiceMapDependencies(ctx.UserContext(), query)
 		if err != nil {
-			return ctx.Status(fasthttp.StatusBadRequest).SendString(fasthttp.StatusMessage(fasthttp.StatusBadRequest))
+			return err
 		}
 		if len(*result) == 0 {
 			return ctx.JSON([]model.GetServicesParams{})
@@ -256,6 +677,69 @@ func InitControllers(appName string, version string, build string, commit string
 		return ctx.JSON(result)
 	})
 
-	logger.LOGGER.Fatal(app.Listen(":" + viper.GetString("server.port")).Error())
-	//logger.LOGGER.Fatal(app.Listen(":7778").Error())
+	app.Post("/v1/traces", func(ctx *fiber.Ctx) error {
+		spans, isJson, err := parseOtlpTracesRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		err = spanIngestionService.IngestSpans(ctx.UserContext(), spans)
+		if err != nil {
+			return ingestionError(ctx, err)
+		}
+
+		if isJson {
+			return ctx.JSON(fiber.Map{})
+		}
+		ctx.Set(fiber.HeaderContentType, "application/x-protobuf")
+		return ctx.Send([]byte{})
+	})
+
+	app.Post("/api/v2/spans", func(ctx *fiber.Ctx) error {
+		spans, err := parseZipkinSpansRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		err = spanIngestionService.IngestSpans(ctx.UserContext(), spans)
+		if err != nil {
+			return ingestionError(ctx, err)
+		}
+		return ctx.SendStatus(fasthttp.StatusAccepted)
+	})
+
+	app.Post("/api/traces", func(ctx *fiber.Ctx) error {
+		spans, err := parseJaegerBatchRequest(ctx)
+		if err != nil {
+			return badDataError(err)
+		}
+		err = spanIngestionService.IngestSpans(ctx.UserContext(), spans)
+		if err != nil {
+			return ingestionError(ctx, err)
+		}
+		return ctx.SendStatus(fasthttp.StatusAccepted)
+	})
+
+	// fiber doesn't pass unmatched routes to the error handler, keep the error envelope for unknown api routes
+	app.Use("/api", func(ctx *fiber.Ctx) error {
+		return &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("route %s %s not found", ctx.Method(), ctx.Path())}
+	})
+}
+
+// queryTimeoutMiddleware bound every api request with timeout, user context is passed down to clickhouse queries
+// so slow queries are cancelled instead of running after the client is gone
+func queryTimeoutMiddleware(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userContext, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+		c.SetUserContext(userContext)
+		return c.Next()
+	}
+}
+
+// ingestionError full span writer is reported as 429 with Retry-After so clients back off and resend
+func ingestionError(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrSpanQueueFull) {
+		ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(spanIngestionService.RetryAfter().Seconds())))
+		return &model.ApiError{Typ: model.ErrorTooManyRequests, Err: err}
+	}
+	return &model.ApiError{Typ: model.ErrorUnavailable, Err: fmt.Errorf("unable to store spans")}
 }
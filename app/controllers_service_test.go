@@ -0,0 +1,512 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"goapm/dao"
+	model "goapm/domain"
+	"goapm/services"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newControllersTest() (*fiber.App, *services.MockApmService, *services.MockSpanIngestionService) {
+	apmServiceMock := new(services.MockApmService)
+	spanIngestionServiceMock := new(services.MockSpanIngestionService)
+	apmService = apmServiceMock
+	spanIngestionService = spanIngestionServiceMock
+
+	app := fiber.New(fiber.Config{ErrorHandler: apiErrorHandler})
+	registerRoutes(app)
+	return app, apmServiceMock, spanIngestionServiceMock
+}
+
+func doRequest(t *testing.T, app *fiber.App, request *http.Request) (*http.Response, ApiErrorResponse) {
+	response, err := app.Test(request)
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(response.Body)
+	assert.Nil(t, err)
+	var errorResponse ApiErrorResponse
+	_ = json.Unmarshal(body, &errorResponse)
+	return response, errorResponse
+}
+
+func TestBadRequestReturnsBadDataEnvelope(t *testing.T) {
+	app, _, _ := newControllersTest()
+
+	response, errorResponse := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/service/overview?start=1&end=2", nil))
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, "error", errorResponse.Status)
+	assert.Equal(t, model.ErrorBadData, errorResponse.ErrorType)
+	assert.Equal(t, "step param missing in query", errorResponse.Error)
+	assert.NotEmpty(t, errorResponse.RequestId)
+	assert.Equal(t, response.Header.Get(fiber.HeaderXRequestID), errorResponse.RequestId)
+}
+
+func TestStorageErrorsAreMappedByType(t *testing.T) {
+	app, apmServiceMock, _ := newControllersTest()
+	apmServiceMock.On("GetOperations", mock.Anything, "unavailable").
+		Return(nil, &model.ApiError{Typ: model.ErrorUnavailable, Err: errors.New("storage is unavailable")})
+	apmServiceMock.On("GetOperations", mock.Anything, "slow").
+		Return(nil, &model.ApiError{Typ: model.ErrorTimeout, Err: errors.New("query timed out")})
+	apmServiceMock.On("GetOperations", mock.Anything, "broken").
+		Return(nil, errors.New("clickhouse exception: code 62, syntax error"))
+
+	response, errorResponse := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/service/unavailable/operations", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+	assert.Equal(t, model.ErrorUnavailable, errorResponse.ErrorType)
+
+	response, errorResponse = doRequest(t, app, httptest.NewRequest("GET", "/api/v1/service/slow/operations", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, response.StatusCode)
+	assert.Equal(t, model.ErrorTimeout, errorResponse.ErrorType)
+
+	response, errorResponse = doRequest(t, app, httptest.NewRequest("GET", "/api/v1/service/broken/operations", nil))
+	assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+	assert.Equal(t, model.ErrorInternal, errorResponse.ErrorType)
+	assert.Equal(t, "internal server error", errorResponse.Error)
+}
+
+func TestMissingTraceReturnsNotFound(t *testing.T) {
+	app, apmServiceMock, _ := newControllersTest()
+	apmServiceMock.On("SearchTraces", mock.Anything, "abc").
+		Return(&[]model.SearchSpansResult{{Events: [][]interface{}{}}}, nil)
+
+	response, errorResponse := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/traces/abc", nil))
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.Equal(t, model.ErrorNotFound, errorResponse.ErrorType)
+	assert.Equal(t, "trace abc not found", errorResponse.Error)
+}
+
+func TestUnknownRouteReturnsNotFoundEnvelope(t *testing.T) {
+	app, _, _ := newControllersTest()
+
+	response, errorResponse := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.Equal(t, model.ErrorNotFound, errorResponse.ErrorType)
+}
+
+func TestSuccessfulRequestReturnsResult(t *testing.T) {
+	app, apmServiceMock, _ := newControllersTest()
+	apmServiceMock.On("GetOperations", mock.Anything, "frontend").Return(&[]string{"GET /"}, nil)
+
+	response, err := app.Test(httptest.NewRequest("GET", "/api/v1/service/frontend/operations", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, `["GET /"]`, string(body))
+}
+
+func TestFullSpanQueueReturnsTooManyRequests(t *testing.T) {
+	app, _, spanIngestionServiceMock := newControllersTest()
+	spanIngestionServiceMock.On("IngestSpans", mock.Anything, mock.Anything).Return(services.ErrSpanQueueFull)
+	spanIngestionServiceMock.On("RetryAfter").Return(2 * time.Second)
+
+	request := httptest.NewRequest("POST", "/v1/traces", strings.NewReader(`{"resourceSpans":[]}`))
+	request.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	response, errorResponse := doRequest(t, app, request)
+	assert.Equal(t, http.StatusTooManyRequests, response.StatusCode)
+	assert.Equal(t, "2", response.Header.Get(fiber.HeaderRetryAfter))
+	assert.Equal(t, model.ErrorTooManyRequests, errorResponse.ErrorType)
+}
+
+func TestSpanSearchPaginationParams(t *testing.T) {
+	app, apmServiceMock, _ := newControllersTest()
+	apmServiceMock.On("SearchSpans", mock.Anything, mock.Anything).Return(&model.TraceResult{Total: 1, Limit: 10}, nil)
+	cursor := (&model.SpanSearchCursor{OrderParam: model.SpanOrderParamDuration, Order: model.SpanOrderAscending, Value: 5, SpanID: "a"}).Encode()
+
+	response, _ := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/spans?start=1&end=2&limit=10&orderParam=duration&order=ascending&cursor="+cursor, nil))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	params := apmServiceMock.Calls[0].Arguments.Get(1).(*model.SpanSearchParams)
+	assert.Equal(t, int64(10), params.Limit)
+	assert.Equal(t, model.SpanOrderParamDuration, params.OrderParam)
+	assert.Equal(t, "a", params.Cursor.SpanID)
+
+	for _, query := range []string{"limit=0", "limit=5000", "offset=-1", "order=sideways", "orderParam=name", "cursor=" + cursor, "cursor=not-a-cursor", "offset=10&cursor=" + cursor} {
+		response, errorResponse := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/spans?start=1&end=2&"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode, query)
+		assert.Equal(t, model.ErrorBadData, errorResponse.ErrorType)
+	}
+}
+
+func TestTraceListParams(t *testing.T) {
+	app, apmServiceMock, _ := newControllersTest()
+	apmServiceMock.On("SearchTraceList", mock.Anything, mock.Anything).Return(&model.TraceResult{Data: []interface{}{}, Total: 0, Limit: 20}, nil)
+
+	response, _ := doRequest(t, app, httptest.NewRequest("GET", "/api/v2/traces?start=1&end=2&service=frontend&limit=20&offset=40", nil))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	params := apmServiceMock.Calls[0].Arguments.Get(1).(*model.SpanSearchParams)
+	assert.Equal(t, "frontend", params.ServiceName)
+	assert.Equal(t, int64(40), params.Offset)
+
+	response, errorResponse := doRequest(t, app, httptest.NewRequest("GET", "/api/v2/traces?start=1&end=2&cursor=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, model.ErrorBadData, errorResponse.ErrorType)
+}
+
+func TestTraceTreeNotFound(t *testing.T) {
+	app, apmServiceMock, _ := newControllersTest()
+	apmServiceMock.On("GetTraceTree", mock.Anything, "missing").Return(&model.TraceTree{TraceID: "missing"}, nil)
+	apmServiceMock.On("GetTraceTree", mock.Anything, "t1").Return(&model.TraceTree{TraceID: "t1", SpanCount: 1}, nil)
+
+	response, errorResponse := doRequest(t, app, httptest.NewRequest("GET", "/api/v2/traces/missing/tree", nil))
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.Equal(t, model.ErrorNotFound, errorResponse.ErrorType)
+
+	response, _ = doRequest(t, app, httptest.NewRequest("GET", "/api/v2/traces/t1/tree", nil))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
+func TestSpanAggregatesGroupByParams(t *testing.T) {
+	app, apmServiceMock, _ := newControllersTest()
+	apmServiceMock.On("SearchSpansAggregateGroups", mock.Anything, mock.Anything).Return([]model.SpanSearchAggregatesSeries{}, nil)
+
+	path := "/api/v1/spans/aggregates?start=1&end=2&step=60&dimension=duration&aggregation_option=p90"
+	response, _ := doRequest(t, app, httptest.NewRequest("GET", path+"&groupBy=tag:http.method", nil))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	params := apmServiceMock.Calls[0].Arguments.Get(1).(*model.SpanSearchAggregatesParams)
+	assert.Equal(t, "tag:http.method", params.GroupBy)
+	assert.Equal(t, 10, params.GroupLimit)
+
+	for _, query := range []string{"&groupBy=host", "&groupBy=tag:", "&groupBy=service&groupLimit=0", "&groupBy=service&groupLimit=1000"} {
+		response, errorResponse := doRequest(t, app, httptest.NewRequest("GET", path+query, nil))
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode, query)
+		assert.Equal(t, model.ErrorBadData, errorResponse.ErrorType)
+	}
+}
+
+func TestTagValuesParams(t *testing.T) {
+	app, apmServiceMock, _ := newControllersTest()
+	apmServiceMock.On("GetTagValues", mock.Anything, mock.Anything).Return(&model.TagValuesResult{Values: []model.TagValueItem{}}, nil)
+
+	response, _ := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/tags/http.status_code/values?start=1&end=2&prefix=5&service=frontend", nil))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	params := apmServiceMock.Calls[0].Arguments.Get(1).(*model.GetTagValuesParams)
+	assert.Equal(t, "http.status_code", params.Key)
+	assert.Equal(t, "5", params.Prefix)
+	assert.Equal(t, "frontend", params.ServiceName)
+	assert.Equal(t, 10, params.Limit)
+
+	response, errorResponse := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/tags/http.status_code/values?start=1&end=2&limit=500", nil))
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, model.ErrorBadData, errorResponse.ErrorType)
+}
+
+func TestErrorsRoutes(t *testing.T) {
+	app, _, _ := newControllersTest()
+	errorServiceMock := new(services.MockErrorService)
+	errorService = errorServiceMock
+	errorServiceMock.On("GetErrorGroups", mock.Anything, mock.Anything).Return(&model.ErrorGroupsResult{Items: []model.ErrorGroupItem{}, Limit: 50}, nil)
+	errorServiceMock.On("GetErrorGroup", mock.Anything, mock.Anything).Return(&model.ErrorDetail{}, nil)
+
+	response, _ := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/errors?start=1&end=2&offset=50", nil))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	params := errorServiceMock.Calls[0].Arguments.Get(1).(*model.GetErrorsParams)
+	assert.Equal(t, 50, params.Limit)
+	assert.Equal(t, 50, params.Offset)
+
+	response, errorResponse := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/errors/abc?start=1&end=2&step=300", nil))
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.Equal(t, model.ErrorNotFound, errorResponse.ErrorType)
+	assert.Equal(t, 300, errorServiceMock.Calls[1].Arguments.Get(1).(*model.GetErrorParams).StepSeconds)
+
+	response, _ = doRequest(t, app, httptest.NewRequest("GET", "/api/v1/errors/abc?start=1&end=2&step=10", nil))
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestServiceExternalParams(t *testing.T) {
+	app, apmServiceMock, _ := newControllersTest()
+	apmServiceMock.On("GetServiceExternal", mock.Anything, mock.Anything).Return(&[]model.ServiceExternalItem{}, nil)
+
+	response, _ := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/service/external?start=1&end=2&step=60&service=orders", nil))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, model.ExternalGroupHost, apmServiceMock.Calls[0].Arguments.Get(1).(*model.GetServiceExternalParams).GroupBy)
+
+	response, _ = doRequest(t, app, httptest.NewRequest("GET", "/api/v1/service/external?start=1&end=2&step=60&service=orders&groupBy=path", nil))
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+	response, _ = doRequest(t, app, httptest.NewRequest("GET", "/api/v1/service/externalErrors?start=1&end=2&step=60&service=orders", nil))
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestCompareToParam(t *testing.T) {
+	app, apmServiceMock, _ := newControllersTest()
+	apmServiceMock.On("GetTopEndpoints", mock.Anything, mock.Anything).Return(&[]model.TopEndpointsItem{}, nil)
+
+	response, _ := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/service/top_endpoints?start=1&end=2&service=orders&compareTo=7d", nil))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, 7*24*time.Hour, apmServiceMock.Calls[0].Arguments.Get(1).(*model.GetTopEndpointsParams).CompareTo)
+
+	for _, compareTo := range []string{"7", "0d", "-1d", "7m", "100d", "99999999999999w"} {
+		response, errorResponse := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/service/top_endpoints?start=1&end=2&service=orders&compareTo="+compareTo, nil))
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode, compareTo)
+		assert.Equal(t, model.ErrorBadData, errorResponse.ErrorType)
+	}
+}
+
+func TestLatencyHistogramParams(t *testing.T) {
+	app, apmServiceMock, _ := newControllersTest()
+	apmServiceMock.On("GetLatencyHistogram", mock.Anything, mock.Anything).Return(&model.LatencyHistogram{}, nil)
+	apmServiceMock.On("GetLatencyHeatmap", mock.Anything, mock.Anything).Return(&model.LatencyHeatmap{}, nil)
+
+	response, _ := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/service/latencyHistogram?start=1&end=2&service=orders&boundaries=0.5,10,250", nil))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	params := apmServiceMock.Calls[0].Arguments.Get(1).(*model.GetLatencyHistogramParams)
+	assert.Equal(t, []int64{500000, 10000000, 250000000}, params.Boundaries)
+	assert.Equal(t, "orders", params.Filters.ServiceName)
+
+	response, _ = doRequest(t, app, httptest.NewRequest("GET", "/api/v1/service/latencyHeatmap?start=1&end=2&step=300", nil))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	params = apmServiceMock.Calls[1].Arguments.Get(1).(*model.GetLatencyHistogramParams)
+	assert.Equal(t, model.DefaultLatencyBoundaries(), params.Boundaries)
+	assert.Equal(t, 300, params.StepSeconds)
+
+	for _, query := range []string{"latencyHistogram?start=1&end=2&boundaries=10,5", "latencyHistogram?start=1&end=2&boundaries=a", "latencyHeatmap?start=1&end=2"} {
+		response, _ = doRequest(t, app, httptest.NewRequest("GET", "/api/v1/service/"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode, query)
+	}
+}
+
+func TestApdexSettingRoute(t *testing.T) {
+	app, _, _ := newControllersTest()
+	settingsServiceMock := new(services.MockSettingsService)
+	settingsService = settingsServiceMock
+	settingsServiceMock.On("SetApdexSetting", mock.Anything, mock.Anything).Return(nil)
+
+	response, _ := doRequest(t, app, httptest.NewRequest("PUT", "/api/v1/settings/apdex/order%20service", strings.NewReader(`{"threshold": 0.25}`)))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, &model.ApdexSetting{ServiceName: "order service", Threshold: 0.25}, settingsServiceMock.Calls[0].Arguments.Get(1))
+
+	response, errorResponse := doRequest(t, app, httptest.NewRequest("PUT", "/api/v1/settings/apdex/orders", strings.NewReader(`{"threshold": 0}`)))
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, model.ErrorBadData, errorResponse.ErrorType)
+	response, _ = doRequest(t, app, httptest.NewRequest("PUT", "/api/v1/settings/apdex/orders", strings.NewReader(`{"threshold": 61}`)))
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, 1, len(settingsServiceMock.Calls))
+
+	// thresholds not counted by the rollup table are counted from raw spans
+	response, _ = doRequest(t, app, httptest.NewRequest("PUT", "/api/v1/settings/apdex/orders", strings.NewReader(`{"threshold": 0.4}`)))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, &model.ApdexSetting{ServiceName: "orders", Threshold: 0.4}, settingsServiceMock.Calls[1].Arguments.Get(1))
+}
+
+func TestDBStatementsParams(t *testing.T) {
+	app, _, _ := newControllersTest()
+	databaseServiceMock := new(services.MockDatabaseService)
+	databaseService = databaseServiceMock
+	databaseServiceMock.On("GetDBStatements", mock.Anything, mock.Anything).Return(&model.DBStatementsResult{}, nil)
+
+	response, _ := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/service/dbStatements?start=1&end=61&service=orders&dbSystem=redis&operation=GET", nil))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	params := databaseServiceMock.Calls[0].Arguments.Get(1).(*model.GetDBStatementsParams)
+	assert.Equal(t, "redis", params.DBSystem)
+	assert.Equal(t, "GET", params.DBOperation)
+	assert.Equal(t, 50, params.Limit)
+
+	response, _ = doRequest(t, app, httptest.NewRequest("GET", "/api/v1/service/dbStatements?start=1&end=61", nil))
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestServiceMapParams(t *testing.T) {
+	app, apmServiceMock, _ := newControllersTest()
+	apmServiceMock.On("GetServiceMapDependencies", mock.Anything, mock.Anything).Return(&[]model.ServiceMapDependencyResponseItem{{Parent: "a", Child: "b"}}, nil)
+
+	response, _ := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/serviceMapDependencies?start=1&end=2&environment=prod&service=orders&hops=2", nil))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	params := apmServiceMock.Calls[0].Arguments.Get(1).(*model.GetServiceMapParams)
+	assert.Equal(t, "prod", params.Environment)
+	assert.Equal(t, "orders", params.FocusService)
+	assert.Equal(t, 2, params.Hops)
+
+	response, _ = doRequest(t, app, httptest.NewRequest("GET", "/api/v1/serviceMapDependencies?start=1&end=2&hops=9", nil))
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestShutdownFlushesBufferedSpans(t *testing.T) {
+	viper.Set("SPAN_WRITER_FLUSH_INTERVAL_MILLIS", "60000")
+	viper.Set("SPAN_WRITER_SPILL_FILE", filepath.Join(t.TempDir(), "spans.spill"))
+	spanDaoMock := new(dao.MockSpanDao)
+	spanDaoMock.On("InsertSpans", mock.Anything, mock.Anything).Return(nil)
+	spanBatchWriter = services.NewSpanBatchWriter(spanDaoMock)
+	app, _, _ := newControllersTest()
+
+	assert.Nil(t, spanBatchWriter.Write([]model.Span{{TraceID: "t1", SpanID: "s1"}}))
+	spanDaoMock.AssertNotCalled(t, "InsertSpans", mock.Anything, mock.Anything)
+	shutdown(app)
+	spanDaoMock.AssertNumberOfCalls(t, "InsertSpans", 1)
+}
+
+func TestAlertRuleRoutes(t *testing.T) {
+	app, _, _ := newControllersTest()
+	alertServiceMock := new(services.MockAlertService)
+	alertService = alertServiceMock
+	alertServiceMock.On("UpdateRule", mock.Anything, mock.Anything).Return(&model.AlertRule{}, nil)
+	alertServiceMock.On("GetRule", mock.Anything, "missing").
+		Return(nil, &model.ApiError{Typ: model.ErrorNotFound, Err: errors.New("rule missing not found")})
+
+	response, _ := doRequest(t, app, httptest.NewRequest("PUT", "/api/v1/rules/r1",
+		strings.NewReader(`{"name": "apdex", "metric": "apdex", "serviceName": "orders", "threshold": 0.8}`)))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	rule := alertServiceMock.Calls[0].Arguments.Get(1).(*model.AlertRule)
+	assert.Equal(t, "r1", rule.ID)
+	assert.Equal(t, model.AlertOperatorBelow, rule.Operator)
+	assert.Equal(t, 300, rule.WindowSeconds)
+
+	response, errorResponse := doRequest(t, app, httptest.NewRequest("POST", "/api/v1/rules",
+		strings.NewReader(`{"name": "errors", "metric": "error_count", "serviceName": "orders", "threshold": 5}`)))
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, model.ErrorBadData, errorResponse.ErrorType)
+
+	response, _ = doRequest(t, app, httptest.NewRequest("POST", "/api/v1/rules",
+		strings.NewReader(`{"name": "errors", "metric": "error_rate", "serviceName": "orders", "threshold": 5, "windowSeconds": 10}`)))
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+	response, errorResponse = doRequest(t, app, httptest.NewRequest("GET", "/api/v1/rules/missing", nil))
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.Equal(t, model.ErrorNotFound, errorResponse.ErrorType)
+	assert.Equal(t, 2, len(alertServiceMock.Calls))
+}
+
+func TestNotificationChannelRoutes(t *testing.T) {
+	app, _, _ := newControllersTest()
+	channelServiceMock := new(services.MockChannelService)
+	channelService = channelServiceMock
+	channelServiceMock.On("CreateChannel", mock.Anything, mock.Anything).Return(&model.NotificationChannel{}, nil)
+	channelServiceMock.On("TestChannel", mock.Anything, "c1").
+		Return(&model.ApiError{Typ: model.ErrorUnavailable, Err: errors.New("unable to notify channel c1")})
+
+	response, _ := doRequest(t, app, httptest.NewRequest("POST", "/api/v1/channels",
+		strings.NewReader(`{"name": "ops", "type": "email", "recipients": ["Ops <ops@example.com>"], "groupBy": ["service"]}`)))
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	channel := channelServiceMock.Calls[0].Arguments.Get(1).(*model.NotificationChannel)
+	assert.Equal(t, []string{"ops@example.com"}, channel.Recipients)
+	assert.Equal(t, model.DefaultRepeatIntervalSeconds, channel.RepeatIntervalSeconds)
+
+	for _, body := range []string{
+		`{"name": "ops", "type": "email", "recipients": ["ops"]}`,
+		`{"name": "ops", "type": "slack", "url": "file:///etc/passwd"}`,
+		`{"name": "ops", "type": "webhook", "url": "http://hooks", "repeatIntervalSeconds": 10}`,
+		`{"name": "ops", "type": "pager"}`,
+	} {
+		response, errorResponse := doRequest(t, app, httptest.NewRequest("POST", "/api/v1/channels", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode, body)
+		assert.Equal(t, model.ErrorBadData, errorResponse.ErrorType)
+	}
+
+	response, errorResponse := doRequest(t, app, httptest.NewRequest("POST", "/api/v1/channels/c1/test", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+	assert.Equal(t, model.ErrorUnavailable, errorResponse.ErrorType)
+	assert.Equal(t, 2, len(channelServiceMock.Calls))
+}
+
+func TestSilenceRoutes(t *testing.T) {
+	app, _, _ := newControllersTest()
+	silenceServiceMock := new(services.MockSilenceService)
+	silenceService = silenceServiceMock
+	silenceServiceMock.On("CreateSilence", mock.Anything, mock.Anything).Return(&model.Silence{}, nil)
+	silenceServiceMock.On("UpdateMaintenanceWindow", mock.Anything, mock.Anything).Return(&model.MaintenanceWindow{}, nil)
+	endsAt := time.Now().Add(time.Hour).UnixNano()
+
+	response, _ := doRequest(t, app, httptest.NewRequest("POST", "/api/v1/silences", strings.NewReader(
+		fmt.Sprintf(`{"matchers": {"service": "cart", "severity": "warning"}, "endsAt": %d, "createdBy": "jane", "comment": "deploy"}`, endsAt))))
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	silence := silenceServiceMock.Calls[0].Arguments.Get(1).(*model.Silence)
+	assert.NotZero(t, silence.StartsAt)
+	assert.Equal(t, "warning", silence.Matchers["severity"])
+
+	for _, body := range []string{
+		fmt.Sprintf(`{"matchers": {}, "endsAt": %d, "createdBy": "jane"}`, endsAt),
+		fmt.Sprintf(`{"matchers": {"service": "cart"}, "endsAt": %d}`, endsAt),
+		`{"matchers": {"service": "cart"}, "startsAt": 1, "endsAt": 2, "createdBy": "jane"}`,
+	} {
+		response, errorResponse := doRequest(t, app, httptest.NewRequest("POST", "/api/v1/silences", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode, body)
+		assert.Equal(t, model.ErrorBadData, errorResponse.ErrorType)
+	}
+
+	response, _ = doRequest(t, app, httptest.NewRequest("PUT", "/api/v1/maintenanceWindows/w1", strings.NewReader(
+		`{"name": "weekly deploy", "schedule": "0 2 * * sat", "durationSeconds": 3600, "createdBy": "jane"}`)))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	window := silenceServiceMock.Calls[1].Arguments.Get(1).(*model.MaintenanceWindow)
+	assert.Equal(t, "w1", window.ID)
+	assert.Equal(t, map[string]string{}, window.Matchers)
+
+	response, _ = doRequest(t, app, httptest.NewRequest("POST", "/api/v1/maintenanceWindows", strings.NewReader(
+		`{"name": "weekly deploy", "schedule": "0 2 * * saturday", "durationSeconds": 3600, "createdBy": "jane"}`)))
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, 2, len(silenceServiceMock.Calls))
+}
+
+func TestSLORoutes(t *testing.T) {
+	app, _, _ := newControllersTest()
+	sloServiceMock := new(services.MockSLOService)
+	sloService = sloServiceMock
+	sloServiceMock.On("CreateSLO", mock.Anything, mock.Anything).Return(&model.SLO{}, nil)
+	alertServiceMock := new(services.MockAlertService)
+	alertService = alertServiceMock
+	alertServiceMock.On("CreateRule", mock.Anything, mock.Anything).Return(&model.AlertRule{}, nil)
+
+	response, _ := doRequest(t, app, httptest.NewRequest("POST", "/api/v1/slos", strings.NewReader(
+		`{"name": "checkout latency", "serviceName": "cart", "operation": "POST /checkout", "sliType": "latency", "latencyThresholdMs": 250, "target": 99.5}`)))
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	slo := sloServiceMock.Calls[0].Arguments.Get(1).(*model.SLO)
+	assert.Equal(t, int64(250), slo.LatencyThresholdMs)
+	assert.Equal(t, model.MaxSLOWindowDays, slo.WindowDays)
+
+	for _, body := range []string{
+		`{"name": "latency", "serviceName": "cart", "sliType": "latency", "latencyThresholdMs": 333, "target": 99}`,
+		`{"name": "availability", "serviceName": "cart", "sliType": "availability", "target": 100}`,
+		`{"name": "availability", "serviceName": "cart", "sliType": "availability", "target": 99, "windowDays": 90}`,
+		`{"name": "availability", "serviceName": "cart", "sliType": "throughput", "target": 99}`,
+	} {
+		response, errorResponse := doRequest(t, app, httptest.NewRequest("POST", "/api/v1/slos", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode, body)
+		assert.Equal(t, model.ErrorBadData, errorResponse.ErrorType)
+	}
+	assert.Equal(t, 1, len(sloServiceMock.Calls))
+
+	response, _ = doRequest(t, app, httptest.NewRequest("POST", "/api/v1/rules",
+		strings.NewReader(`{"name": "cart slo", "metric": "slo_burn_rate", "sloID": "s1"}`)))
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	rule := alertServiceMock.Calls[0].Arguments.Get(1).(*model.AlertRule)
+	assert.Equal(t, 1.0, rule.Threshold)
+	assert.Equal(t, model.AlertOperatorAbove, rule.Operator)
+
+	response, _ = doRequest(t, app, httptest.NewRequest("POST", "/api/v1/rules",
+		strings.NewReader(`{"name": "cart slo", "metric": "slo_burn_rate"}`)))
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestAnomaliesParams(t *testing.T) {
+	app, _, _ := newControllersTest()
+	anomalyServiceMock := new(services.MockAnomalyService)
+	anomalyService = anomalyServiceMock
+	anomalyServiceMock.On("GetAnomalies", mock.Anything, mock.Anything).Return([]model.Anomaly{}, nil)
+
+	response, _ := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/anomalies?start=1600000000000000000&end=1600003600000000000&service=cart&metric=p99", nil))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	params := anomalyServiceMock.Calls[0].Arguments.Get(1).(*model.GetAnomaliesParams)
+	assert.Equal(t, "cart", params.ServiceName)
+	assert.Equal(t, model.AnomalyMetricP99, params.Metric)
+	assert.Equal(t, 100, params.Limit)
+
+	for _, query := range []string{
+		"end=1600003600000000000",
+		"start=1600000000000000000&end=1600003600000000000&metric=apdex",
+		"start=1600000000000000000&end=1600003600000000000&limit=5000",
+	} {
+		response, errorResponse := doRequest(t, app, httptest.NewRequest("GET", "/api/v1/anomalies?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode, query)
+		assert.Equal(t, model.ErrorBadData, errorResponse.ErrorType)
+	}
+	assert.Equal(t, 1, len(anomalyServiceMock.Calls))
+}
@@ -2,13 +2,28 @@ package controllers
 
 import (
 	"goapm/clickhouse"
+	"goapm/http"
 	redis_factory "goapm/redis"
 	"goapm/dao"
 	"goapm/services"
 )
 
-var apmService *services.ApmServiceImpl
+var apmService services.ApmService
 var traceFilterJob *services.TraceFilterJob
+var serviceDependencyJob *services.ServiceDependencyJob
+var spanIngestionService services.SpanIngestionService
+var spanBatchWriter *services.SpanBatchWriter
+var errorService services.ErrorService
+var databaseService services.DatabaseService
+var settingsService services.SettingsService
+var alertService services.AlertService
+var alertEvaluator *services.AlertEvaluator
+var channelService services.ChannelService
+var alertNotifier *services.AlertNotifier
+var silenceService services.SilenceService
+var sloService services.SLOService
+var anomalyService services.AnomalyService
+var anomalyDetector *services.AnomalyDetector
 
 func InitHealthCheck() {
 	clickhouse.NewClickhouseConnectionService()
@@ -19,7 +34,35 @@ func InitHealthCheck() {
 func InitServices() {
 	apmDao := dao.NewApmDao(clickhouse.NewClickhouseConnectionService())
 	apmService = services.NewApmServiceImpl(apmDao)
+	databaseService = services.NewDatabaseServiceImpl(apmDao)
+
+	errorService = services.NewErrorServiceImpl(dao.NewErrorDao(clickhouse.NewClickhouseConnectionService()))
+	settingsService = services.NewSettingsServiceImpl(dao.NewSettingsDao(clickhouse.NewClickhouseConnectionService()))
+
+	spanDao := dao.NewSpanDao(clickhouse.NewClickhouseConnectionService())
+	spanBatchWriter = services.NewSpanBatchWriter(spanDao)
+	spanIngestionService = services.NewSpanIngestionServiceImpl(spanBatchWriter)
+
+	sloDao := dao.NewSLODao(clickhouse.NewClickhouseConnectionService())
+	sloService = services.NewSLOServiceImpl(sloDao, apmDao)
+
+	alertDao := dao.NewAlertDao(clickhouse.NewClickhouseConnectionService())
+	alertService = services.NewAlertServiceImpl(alertDao, sloDao)
+	alertEvaluator = services.NewAlertEvaluator(alertDao, apmDao, sloDao, redis_factory.NewSpecificRedisService())
+
+	channelDao := dao.NewChannelDao(clickhouse.NewClickhouseConnectionService())
+	notificationSender := services.NewNotificationSender(http.NewRestClient())
+	channelService = services.NewChannelServiceImpl(channelDao, notificationSender)
+	silenceDao := dao.NewSilenceDao(clickhouse.NewClickhouseConnectionService())
+	silenceService = services.NewSilenceServiceImpl(silenceDao)
+	alertNotifier = services.NewAlertNotifier(alertDao, channelDao, silenceDao, redis_factory.NewSpecificRedisService(), notificationSender)
+
+	anomalyDao := dao.NewAnomalyDao(clickhouse.NewClickhouseConnectionService())
+	anomalyService = services.NewAnomalyServiceImpl(anomalyDao)
+	anomalyDetector = services.NewAnomalyDetector(apmDao, anomalyDao, redis_factory.NewSpecificRedisService())
 
 	traceFilterJob = services.NewTraceFilterJob(clickhouse.NewClickhouseConnectionService(),
 		redis_factory.NewSpecificRedisService())
+	serviceDependencyJob = services.NewServiceDependencyJob(dao.NewServiceDependencyDao(clickhouse.NewClickhouseConnectionService()),
+		redis_factory.NewSpecificRedisService())
 }
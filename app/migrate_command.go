@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"goapm/clickhouse"
+	"goapm/migrations"
+	"goapm/web"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = "usage: goapm migrate up|down [steps]|status"
+
+// RunMigrateCommand handle "goapm migrate up|down [steps]|status", returns process exit code
+func RunMigrateCommand(appName string, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	web.InitConfiguration(appName)
+	migrationService := migrations.NewMigrationService(clickhouse.NewClickhouseConnectionService())
+	ctx := context.Background()
+
+	var err error
+	switch args[0] {
+	case "up":
+		err = migrationService.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+		}
+		err = migrationService.Down(ctx, steps)
+	case "status":
+		var statuses []migrations.MigrationStatus
+		statuses, err = migrationService.Status(ctx)
+		if err == nil {
+			printMigrationStatuses(statuses)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "migrate "+args[0]+" failed: "+err.Error())
+		return 1
+	}
+	return 0
+}
+
+func printMigrationStatuses(statuses []migrations.MigrationStatus) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "HOST\tVERSION\tNAME\tSTATUS")
+	for _, status := range statuses {
+		state := "pending"
+		if status.Applied {
+			state = "applied"
+		}
+		fmt.Fprintf(writer, "%s\t%06d\t%s\t%s\n", status.Host, status.Version, status.Name, state)
+	}
+	_ = writer.Flush()
+}
@@ -6,8 +6,14 @@ import (
 	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
+	"goapm/cron"
 	model "goapm/domain"
+	"goapm/receivers"
+	"math"
+	"net/mail"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -35,6 +41,11 @@ func parseGetServiceOverviewRequest(ctx *fiber.Ctx) (*model.GetServiceOverviewPa
 		return nil, errors.New("serviceName param missing in query")
 	}
 
+	compareTo, err := parseCompareTo(ctx.Query("compareTo"))
+	if err != nil {
+		return nil, err
+	}
+
 	getServiceOverviewParams := model.GetServiceOverviewParams{
 		Start:       startTime,
 		StartTime:   startTime.Format(time.RFC3339Nano),
@@ -43,6 +54,8 @@ func parseGetServiceOverviewRequest(ctx *fiber.Ctx) (*model.GetServiceOverviewPa
 		ServiceName: serviceName,
 		Period:      fmt.Sprintf("PT%dM", stepInt/60),
 		StepSeconds: stepInt,
+		CompareTo:   compareTo,
+		Apdex:       ctx.Query("apdex") == "true",
 	}
 
 	return &getServiceOverviewParams, nil
@@ -60,17 +73,606 @@ func parseGetServicesRequest(ctx *fiber.Ctx) (*model.GetServicesParams, error) {
 		return nil, err
 	}
 
+	compareTo, err := parseCompareTo(ctx.Query("compareTo"))
+	if err != nil {
+		return nil, err
+	}
+
 	getServicesParams := model.GetServicesParams{
 		Start:     startTime,
 		StartTime: startTime.Format(time.RFC3339Nano),
 		End:       endTime,
 		EndTime:   endTime.Format(time.RFC3339Nano),
 		Period:    int(endTime.Unix() - startTime.Unix()),
+		CompareTo: compareTo,
 	}
 	return &getServicesParams, nil
 
 }
 
+const defaultAlertWindowSeconds = 300
+const maxAlertSeconds = 86400
+const defaultAlertHistoryLimit = 100
+const maxAlertHistoryLimit = 1000
+
+// alertMetricOperators default operator of every alert metric, latency and error rate alert when above threshold
+var alertMetricOperators = map[string]string{
+	model.AlertMetricErrorRate:    model.AlertOperatorAbove,
+	model.AlertMetricP99Latency:   model.AlertOperatorAbove,
+	model.AlertMetricCallRateDrop: model.AlertOperatorAbove,
+	model.AlertMetricApdex:        model.AlertOperatorBelow,
+	model.AlertMetricSLOBurnRate:  model.AlertOperatorAbove,
+}
+
+// parseAlertRuleRequest rule from json body, id is taken from path when rule is updated.
+// Slo burn rate rule gets service from its slo and fires above threshold 1 by default
+func parseAlertRuleRequest(ctx *fiber.Ctx) (*model.AlertRule, error) {
+	var body struct {
+		Name          string            `json:"name"`
+		Metric        string            `json:"metric"`
+		ServiceName   string            `json:"serviceName"`
+		Operator      string            `json:"operator"`
+		Threshold     *float64          `json:"threshold"`
+		WindowSeconds *int              `json:"windowSeconds"`
+		ForSeconds    int               `json:"forSeconds"`
+		Labels        map[string]string `json:"labels"`
+		SLOID         string            `json:"sloID"`
+		Disabled      bool              `json:"disabled"`
+	}
+	if err := json.Unmarshal(ctx.Body(), &body); err != nil {
+		return nil, errors.New("body is not a valid json")
+	}
+
+	rule := &model.AlertRule{
+		ID:            ctx.Params("id"),
+		Name:          strings.TrimSpace(body.Name),
+		Metric:        body.Metric,
+		ServiceName:   body.ServiceName,
+		Operator:      body.Operator,
+		WindowSeconds: defaultAlertWindowSeconds,
+		ForSeconds:    body.ForSeconds,
+		Labels:        body.Labels,
+		SLOID:         body.SLOID,
+		Disabled:      body.Disabled,
+	}
+	if len(rule.Name) == 0 {
+		return nil, errors.New("name is missing")
+	}
+	defaultOperator, ok := alertMetricOperators[rule.Metric]
+	if !ok {
+		return nil, fmt.Errorf("metric must be one of %s, %s, %s, %s, %s", model.AlertMetricErrorRate, model.AlertMetricP99Latency,
+			model.AlertMetricCallRateDrop, model.AlertMetricApdex, model.AlertMetricSLOBurnRate)
+	}
+	if rule.Metric == model.AlertMetricSLOBurnRate {
+		if len(rule.SLOID) == 0 {
+			return nil, errors.New("sloID is missing")
+		}
+		if body.Threshold == nil {
+			defaultThreshold := 1.0
+			body.Threshold = &defaultThreshold
+		}
+	} else if len(rule.ServiceName) == 0 {
+		return nil, errors.New("serviceName is missing")
+	}
+	if len(rule.Operator) == 0 {
+		rule.Operator = defaultOperator
+	}
+	if rule.Operator != model.AlertOperatorAbove && rule.Operator != model.AlertOperatorBelow {
+		return nil, fmt.Errorf("operator must be %s or %s", model.AlertOperatorAbove, model.AlertOperatorBelow)
+	}
+	if body.Threshold == nil {
+		return nil, errors.New("threshold is missing")
+	}
+	rule.Threshold = *body.Threshold
+	if body.WindowSeconds != nil {
+		rule.WindowSeconds = *body.WindowSeconds
+	}
+	if rule.WindowSeconds < 60 || rule.WindowSeconds > maxAlertSeconds {
+		return nil, fmt.Errorf("windowSeconds must be between 60 and %d", maxAlertSeconds)
+	}
+	if rule.ForSeconds < 0 || rule.ForSeconds > maxAlertSeconds {
+		return nil, fmt.Errorf("forSeconds must be between 0 and %d", maxAlertSeconds)
+	}
+	if rule.Labels == nil {
+		rule.Labels = map[string]string{}
+	}
+	return rule, nil
+}
+
+func parseAlertHistoryLimit(ctx *fiber.Ctx) (int, error) {
+	limitStr := ctx.Query("limit")
+	if len(limitStr) == 0 {
+		return defaultAlertHistoryLimit, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > maxAlertHistoryLimit {
+		return 0, fmt.Errorf("limit param is not in correct format, must be between 1 and %d", maxAlertHistoryLimit)
+	}
+	return limit, nil
+}
+
+// parseSLORequest slo from json body, latency threshold has to be one of thresholds counted by rollup table
+func parseSLORequest(ctx *fiber.Ctx) (*model.SLO, error) {
+	var body struct {
+		Name               string            `json:"name"`
+		ServiceName        string            `json:"serviceName"`
+		Operation          string            `json:"operation"`
+		SLIType            string            `json:"sliType"`
+		LatencyThresholdMs int64             `json:"latencyThresholdMs"`
+		Target             float64           `json:"target"`
+		WindowDays         int               `json:"windowDays"`
+		Labels             map[string]string `json:"labels"`
+	}
+	if err := json.Unmarshal(ctx.Body(), &body); err != nil {
+		return nil, errors.New("body is not a valid json")
+	}
+
+	slo := &model.SLO{
+		ID:          ctx.Params("id"),
+		Name:        strings.TrimSpace(body.Name),
+		ServiceName: body.ServiceName,
+		Operation:   body.Operation,
+		SLIType:     body.SLIType,
+		Target:      body.Target,
+		WindowDays:  body.WindowDays,
+		Labels:      body.Labels,
+	}
+	if len(slo.Name) == 0 {
+		return nil, errors.New("name is missing")
+	}
+	if len(slo.ServiceName) == 0 {
+		return nil, errors.New("serviceName is missing")
+	}
+	switch slo.SLIType {
+	case model.SLITypeAvailability:
+	case model.SLITypeLatency:
+		for _, threshold := range model.LatencyThresholdsMillis {
+			if threshold == body.LatencyThresholdMs {
+				slo.LatencyThresholdMs = threshold
+			}
+		}
+		if slo.LatencyThresholdMs == 0 {
+			return nil, fmt.Errorf("latencyThresholdMs must be one of %v", model.LatencyThresholdsMillis)
+		}
+	default:
+		return nil, fmt.Errorf("sliType must be %s or %s", model.SLITypeAvailability, model.SLITypeLatency)
+	}
+	// 100% leaves no error budget
+	if slo.Target <= 0 || slo.Target >= 100 {
+		return nil, errors.New("target must be a percentage between 0 and 100, exclusive")
+	}
+	if slo.WindowDays == 0 {
+		slo.WindowDays = model.MaxSLOWindowDays
+	}
+	if slo.WindowDays < 1 || slo.WindowDays > model.MaxSLOWindowDays {
+		return nil, fmt.Errorf("windowDays must be between 1 and %d", model.MaxSLOWindowDays)
+	}
+	if slo.Labels == nil {
+		slo.Labels = map[string]string{}
+	}
+	return slo, nil
+}
+
+const minRepeatIntervalSeconds = 300
+const maxRepeatIntervalSeconds = 7 * 24 * 60 * 60
+
+// parseNotificationChannelRequest channel from json body, id is taken from path when channel is updated.
+// Webhook template is validated by channel service as it needs the notification data to render
+func parseNotificationChannelRequest(ctx *fiber.Ctx) (*model.NotificationChannel, error) {
+	var body struct {
+		Name                  string            `json:"name"`
+		Type                  string            `json:"type"`
+		URL                   string            `json:"url"`
+		Headers               map[string]string `json:"headers"`
+		Template              string            `json:"template"`
+		Recipients            []string          `json:"recipients"`
+		Matchers              map[string]string `json:"matchers"`
+		GroupBy               []string          `json:"groupBy"`
+		RepeatIntervalSeconds *int              `json:"repeatIntervalSeconds"`
+		Disabled              bool              `json:"disabled"`
+	}
+	if err := json.Unmarshal(ctx.Body(), &body); err != nil {
+		return nil, errors.New("body is not a valid json")
+	}
+
+	channel := &model.NotificationChannel{
+		ID:                    ctx.Params("id"),
+		Name:                  strings.TrimSpace(body.Name),
+		Type:                  body.Type,
+		Headers:               map[string]string{},
+		Recipients:            []string{},
+		Matchers:              body.Matchers,
+		GroupBy:               []string{},
+		RepeatIntervalSeconds: model.DefaultRepeatIntervalSeconds,
+		Disabled:              body.Disabled,
+	}
+	if len(channel.Name) == 0 {
+		return nil, errors.New("name is missing")
+	}
+	switch channel.Type {
+	case model.ChannelTypeWebhook, model.ChannelTypeSlack:
+		parsedUrl, err := url.Parse(body.URL)
+		if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || len(parsedUrl.Host) == 0 {
+			return nil, errors.New("url must be an absolute http or https url")
+		}
+		channel.URL = body.URL
+		if channel.Type == model.ChannelTypeWebhook {
+			channel.Template = body.Template
+			for key, value := range body.Headers {
+				channel.Headers[key] = value
+			}
+		}
+	case model.ChannelTypeEmail:
+		if len(body.Recipients) == 0 {
+			return nil, errors.New("recipients are missing")
+		}
+		for _, recipient := range body.Recipients {
+			address, err := mail.ParseAddress(recipient)
+			if err != nil {
+				return nil, fmt.Errorf("recipient %q is not a valid email address", recipient)
+			}
+			channel.Recipients = append(channel.Recipients, address.Address)
+		}
+	default:
+		return nil, fmt.Errorf("type must be one of %s, %s, %s", model.ChannelTypeWebhook, model.ChannelTypeSlack, model.ChannelTypeEmail)
+	}
+	if channel.Matchers == nil {
+		channel.Matchers = map[string]string{}
+	}
+	for _, label := range body.GroupBy {
+		if len(label) == 0 {
+			return nil, errors.New("groupBy can't contain empty label")
+		}
+		channel.GroupBy = append(channel.GroupBy, label)
+	}
+	if body.RepeatIntervalSeconds != nil {
+		channel.RepeatIntervalSeconds = *body.RepeatIntervalSeconds
+	}
+	if channel.RepeatIntervalSeconds < minRepeatIntervalSeconds || channel.RepeatIntervalSeconds > maxRepeatIntervalSeconds {
+		return nil, fmt.Errorf("repeatIntervalSeconds must be between %d and %d", minRepeatIntervalSeconds, maxRepeatIntervalSeconds)
+	}
+	return channel, nil
+}
+
+// parseSilenceRequest silence from json body, silence starts now when startsAt is missing
+func parseSilenceRequest(ctx *fiber.Ctx) (*model.Silence, error) {
+	var body struct {
+		Matchers  map[string]string `json:"matchers"`
+		StartsAt  int64             `json:"startsAt"`
+		EndsAt    int64             `json:"endsAt"`
+		CreatedBy string            `json:"createdBy"`
+		Comment   string            `json:"comment"`
+	}
+	if err := json.Unmarshal(ctx.Body(), &body); err != nil {
+		return nil, errors.New("body is not a valid json")
+	}
+
+	silence := &model.Silence{
+		ID:        ctx.Params("id"),
+		Matchers:  body.Matchers,
+		StartsAt:  body.StartsAt,
+		EndsAt:    body.EndsAt,
+		CreatedBy: strings.TrimSpace(body.CreatedBy),
+		Comment:   body.Comment,
+	}
+	// silence without matchers would mute every alert
+	if len(silence.Matchers) == 0 {
+		return nil, errors.New("matchers are missing")
+	}
+	if err := validateMatchers(silence.Matchers); err != nil {
+		return nil, err
+	}
+	if len(silence.CreatedBy) == 0 {
+		return nil, errors.New("createdBy is missing")
+	}
+	now := time.Now().UnixNano()
+	if silence.StartsAt == 0 {
+		silence.StartsAt = now
+	}
+	if silence.EndsAt <= silence.StartsAt {
+		return nil, errors.New("endsAt must be after startsAt")
+	}
+	if silence.EndsAt <= now {
+		return nil, errors.New("endsAt must be in the future")
+	}
+	return silence, nil
+}
+
+const maxMaintenanceWindowSeconds = 7 * 24 * 60 * 60
+
+// parseMaintenanceWindowRequest window from json body, schedule is five field cron expression in UTC
+func parseMaintenanceWindowRequest(ctx *fiber.Ctx) (*model.MaintenanceWindow, error) {
+	var body struct {
+		Name            string            `json:"name"`
+		Matchers        map[string]string `json:"matchers"`
+		Schedule        string            `json:"schedule"`
+		DurationSeconds int               `json:"durationSeconds"`
+		CreatedBy       string            `json:"createdBy"`
+		Comment         string            `json:"comment"`
+		Disabled        bool              `json:"disabled"`
+	}
+	if err := json.Unmarshal(ctx.Body(), &body); err != nil {
+		return nil, errors.New("body is not a valid json")
+	}
+
+	window := &model.MaintenanceWindow{
+		ID:              ctx.Params("id"),
+		Name:            strings.TrimSpace(body.Name),
+		Matchers:        body.Matchers,
+		Schedule:        strings.TrimSpace(body.Schedule),
+		DurationSeconds: body.DurationSeconds,
+		CreatedBy:       strings.TrimSpace(body.CreatedBy),
+		Comment:         body.Comment,
+		Disabled:        body.Disabled,
+	}
+	if len(window.Name) == 0 {
+		return nil, errors.New("name is missing")
+	}
+	if window.Matchers == nil {
+		window.Matchers = map[string]string{}
+	}
+	if err := validateMatchers(window.Matchers); err != nil {
+		return nil, err
+	}
+	if _, err := cron.Parse(window.Schedule); err != nil {
+		return nil, fmt.Errorf("schedule is not valid: %w", err)
+	}
+	if window.DurationSeconds < 60 || window.DurationSeconds > maxMaintenanceWindowSeconds {
+		return nil, fmt.Errorf("durationSeconds must be between 60 and %d", maxMaintenanceWindowSeconds)
+	}
+	if len(window.CreatedBy) == 0 {
+		return nil, errors.New("createdBy is missing")
+	}
+	return window, nil
+}
+
+func validateMatchers(matchers map[string]string) error {
+	for key := range matchers {
+		if len(key) == 0 {
+			return errors.New("matchers can't contain empty label")
+		}
+	}
+	return nil
+}
+
+const defaultAnomaliesLimit = 100
+const maxAnomaliesLimit = 1000
+
+// parseGetAnomaliesRequest time range with optional service and metric filters
+func parseGetAnomaliesRequest(ctx *fiber.Ctx) (*model.GetAnomaliesParams, error) {
+	startTime, err := parseTime(ctx.Query("start"))
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := parseTime(ctx.Query("end"))
+	if err != nil {
+		return nil, err
+	}
+	params := &model.GetAnomaliesParams{
+		ServiceName: ctx.Query("service"),
+		Metric:      ctx.Query("metric"),
+		Start:       startTime,
+		End:         endTime,
+		Limit:       defaultAnomaliesLimit,
+	}
+	if len(params.Metric) > 0 && !DoesExistInSlice(params.Metric, model.AnomalyMetrics) {
+		return nil, fmt.Errorf("metric param must be one of %s", strings.Join(model.AnomalyMetrics, ", "))
+	}
+	limitStr := ctx.Query("limit")
+	if len(limitStr) != 0 {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxAnomaliesLimit {
+			return nil, fmt.Errorf("limit param is not in correct format, must be between 1 and %d", maxAnomaliesLimit)
+		}
+		params.Limit = limit
+	}
+	return params, nil
+}
+
+const maxLatencyBoundaries = 50
+
+// parseLatencyHistogramRequest histogram takes span search filters and optional boundaries, comma separated ascending
+// milliseconds, log scale boundaries are used without them. Heatmap additionally requires step
+func parseLatencyHistogramRequest(ctx *fiber.Ctx, heatmap bool) (*model.GetLatencyHistogramParams, error) {
+	startTime, err := parseTime(ctx.Query("start"))
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := parseTime(ctx.Query("end"))
+	if err != nil {
+		return nil, err
+	}
+
+	filters := &model.SpanSearchParams{
+		ServiceName:   ctx.Query("service"),
+		OperationName: ctx.Query("operation"),
+		Kind:          ctx.Query("kind"),
+		Start:         startTime,
+		End:           endTime,
+	}
+	minDuration, err := parseTimestamp(ctx.Query("minDuration"))
+	if err == nil {
+		filters.MinDuration = *minDuration
+	}
+	maxDuration, err := parseTimestamp(ctx.Query("maxDuration"))
+	if err == nil {
+		filters.MaxDuration = *maxDuration
+	}
+	tags, err := parseTags(ctx.Query("tags"))
+	if err != nil {
+		return nil, err
+	}
+	if len(*tags) != 0 {
+		filters.Tags = *tags
+	}
+
+	params := &model.GetLatencyHistogramParams{Filters: filters, Boundaries: model.DefaultLatencyBoundaries()}
+	boundariesStr := ctx.Query("boundaries")
+	if len(boundariesStr) != 0 {
+		values := strings.Split(boundariesStr, ",")
+		if len(values) > maxLatencyBoundaries {
+			return nil, fmt.Errorf("boundaries param can have at most %d values", maxLatencyBoundaries)
+		}
+		params.Boundaries = make([]int64, len(values))
+		for i, value := range values {
+			millis, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || millis <= 0 || millis > float64(math.MaxInt64/int64(time.Millisecond)) {
+				return nil, errors.New("boundaries param must be comma separated positive milliseconds")
+			}
+			params.Boundaries[i] = int64(millis * float64(time.Millisecond))
+			if i > 0 && params.Boundaries[i] <= params.Boundaries[i-1] {
+				return nil, errors.New("boundaries param must be in ascending order")
+			}
+		}
+	}
+
+	if heatmap {
+		step, err := strconv.Atoi(ctx.Query("step"))
+		if err != nil || step < 60 {
+			return nil, errors.New("step param must be at least 60 seconds")
+		}
+		params.StepSeconds = step
+	}
+	return params, nil
+}
+
+const maxCompareTo = 90 * 24 * time.Hour
+
+var compareToUnits = map[byte]time.Duration{'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+
+// parseCompareTo offset of comparison period like 1h, 1d or 7d, empty value means no comparison
+func parseCompareTo(compareToStr string) (time.Duration, error) {
+	if len(compareToStr) == 0 {
+		return 0, nil
+	}
+	invalid := errors.New("compareTo param is not in correct format, expected number with h, d or w unit e.g. 7d")
+	unit, ok := compareToUnits[compareToStr[len(compareToStr)-1]]
+	if !ok {
+		return 0, invalid
+	}
+	amount, err := strconv.Atoi(compareToStr[:len(compareToStr)-1])
+	if err != nil || amount < 1 {
+		return 0, invalid
+	}
+	if time.Duration(amount) > maxCompareTo/unit {
+		return 0, errors.New("compareTo param can't be more than 90 days")
+	}
+	return time.Duration(amount) * unit, nil
+}
+
+func parseGetServiceExternalRequest(ctx *fiber.Ctx) (*model.GetServiceExternalParams, error) {
+	overviewParams, err := parseGetServiceOverviewRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if overviewParams.StepSeconds < 60 {
+		return nil, errors.New("step param must be at least 60 seconds")
+	}
+
+	groupBy := ctx.Query("groupBy", model.ExternalGroupHost)
+	if groupBy != model.ExternalGroupHost && groupBy != model.ExternalGroupURL {
+		return nil, fmt.Errorf("groupBy param must be %s or %s", model.ExternalGroupHost, model.ExternalGroupURL)
+	}
+	return &model.GetServiceExternalParams{
+		ServiceName: overviewParams.ServiceName,
+		GroupBy:     groupBy,
+		StepSeconds: overviewParams.StepSeconds,
+		Start:       overviewParams.Start,
+		End:         overviewParams.End,
+	}, nil
+}
+
+const maxApdexThreshold = 60
+
+func parseApdexSettingRequest(ctx *fiber.Ctx) (*model.ApdexSetting, error) {
+	serviceName, err := url.PathUnescape(ctx.Params("service"))
+	if err != nil || len(serviceName) == 0 {
+		return nil, errors.New("service param missing in path")
+	}
+
+	var body struct {
+		Threshold *float64 `json:"threshold"`
+	}
+	if err := json.Unmarshal(ctx.Body(), &body); err != nil {
+		return nil, errors.New("body is not a valid json")
+	}
+	if body.Threshold == nil || *body.Threshold <= 0 || *body.Threshold > maxApdexThreshold {
+		return nil, fmt.Errorf("threshold must be seconds greater than 0 and at most %d", maxApdexThreshold)
+	}
+	return &model.ApdexSetting{ServiceName: serviceName, Threshold: *body.Threshold}, nil
+}
+
+const defaultDBStatementsLimit = 50
+const maxDBStatementsLimit = 500
+
+func parseGetDBStatementsRequest(ctx *fiber.Ctx) (*model.GetDBStatementsParams, error) {
+	startTime, err := parseTime(ctx.Query("start"))
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := parseTime(ctx.Query("end"))
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := ctx.Query("service")
+	if len(serviceName) == 0 {
+		return nil, errors.New("serviceName param missing in query")
+	}
+
+	params := &model.GetDBStatementsParams{
+		ServiceName: serviceName,
+		DBSystem:    ctx.Query("dbSystem"),
+		DBName:      ctx.Query("dbName"),
+		DBOperation: ctx.Query("operation"),
+		Limit:       defaultDBStatementsLimit,
+		Period:      int(endTime.Unix() - startTime.Unix()),
+		Start:       startTime,
+		End:         endTime,
+	}
+	limitStr := ctx.Query("limit")
+	if len(limitStr) != 0 {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxDBStatementsLimit {
+			return nil, fmt.Errorf("limit param is not in correct format, must be between 1 and %d", maxDBStatementsLimit)
+		}
+		params.Limit = limit
+	}
+	return params, nil
+}
+
+const defaultServiceMapHops = 1
+const maxServiceMapHops = 5
+
+func parseGetServiceMapRequest(ctx *fiber.Ctx) (*model.GetServiceMapParams, error) {
+	startTime, err := parseTime(ctx.Query("start"))
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := parseTime(ctx.Query("end"))
+	if err != nil {
+		return nil, err
+	}
+
+	params := &model.GetServiceMapParams{
+		Period:       int(endTime.Unix() - startTime.Unix()),
+		Environment:  ctx.Query("environment"),
+		FocusService: ctx.Query("service"),
+		Hops:         defaultServiceMapHops,
+		Start:        startTime,
+		End:          endTime,
+	}
+	hopsStr := ctx.Query("hops")
+	if len(hopsStr) != 0 {
+		hops, err := strconv.Atoi(hopsStr)
+		if err != nil || hops < 1 || hops > maxServiceMapHops {
+			return nil, fmt.Errorf("hops param is not in correct format, must be between 1 and %d", maxServiceMapHops)
+		}
+		params.Hops = hops
+	}
+	return params, nil
+}
+
 func parseGetTopEndpointsRequest(ctx *fiber.Ctx) (*model.GetTopEndpointsParams, error) {
 	startTime, err := parseTime(ctx.Query("start"))
 	if err != nil {
@@ -86,18 +688,138 @@ func parseGetTopEndpointsRequest(ctx *fiber.Ctx) (*model.GetTopEndpointsParams,
 		return nil, errors.New("serviceName param missing in query")
 	}
 
+	compareTo, err := parseCompareTo(ctx.Query("compareTo"))
+	if err != nil {
+		return nil, err
+	}
+
 	getTopEndpointsParams := model.GetTopEndpointsParams{
 		StartTime:   startTime.Format(time.RFC3339Nano),
 		EndTime:     endTime.Format(time.RFC3339Nano),
 		ServiceName: serviceName,
 		Start:       startTime,
 		End:         endTime,
+		CompareTo:   compareTo,
 	}
 
 	return &getTopEndpointsParams, nil
 
 }
 
+const defaultTagValuesLimit = 10
+const maxTagValuesLimit = 100
+
+func parseGetTagValuesRequest(ctx *fiber.Ctx) (*model.GetTagValuesParams, error) {
+	key, err := url.PathUnescape(ctx.Params("key"))
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("key param missing in path")
+	}
+	startTime, err := parseTime(ctx.Query("start"))
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := parseTime(ctx.Query("end"))
+	if err != nil {
+		return nil, err
+	}
+
+	params := &model.GetTagValuesParams{
+		Key:           key,
+		Prefix:        ctx.Query("prefix"),
+		ServiceName:   ctx.Query("service"),
+		OperationName: ctx.Query("operation"),
+		Limit:         defaultTagValuesLimit,
+		Start:         startTime,
+		End:           endTime,
+	}
+	limitStr := ctx.Query("limit")
+	if len(limitStr) != 0 {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxTagValuesLimit {
+			return nil, fmt.Errorf("limit param is not in correct format, must be between 1 and %d", maxTagValuesLimit)
+		}
+		params.Limit = limit
+	}
+	return params, nil
+}
+
+const defaultErrorsLimit = 50
+const maxErrorsLimit = 500
+
+func parseGetErrorsRequest(ctx *fiber.Ctx) (*model.GetErrorsParams, error) {
+	startTime, err := parseTime(ctx.Query("start"))
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := parseTime(ctx.Query("end"))
+	if err != nil {
+		return nil, err
+	}
+
+	params := &model.GetErrorsParams{
+		ServiceName: ctx.Query("service"),
+		Limit:       defaultErrorsLimit,
+		Start:       startTime,
+		End:         endTime,
+	}
+	limitStr := ctx.Query("limit")
+	if len(limitStr) != 0 {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxErrorsLimit {
+			return nil, fmt.Errorf("limit param is not in correct format, must be between 1 and %d", maxErrorsLimit)
+		}
+		params.Limit = limit
+	}
+	offsetStr := ctx.Query("offset")
+	if len(offsetStr) != 0 {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			return nil, errors.New("offset param is not in correct format")
+		}
+		params.Offset = offset
+	}
+	return params, nil
+}
+
+func parseGetErrorRequest(ctx *fiber.Ctx) (*model.GetErrorParams, error) {
+	fingerprint := ctx.Params("fingerprint")
+	if len(fingerprint) == 0 {
+		return nil, errors.New("fingerprint param missing in path")
+	}
+	startTime, err := parseTime(ctx.Query("start"))
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := parseTime(ctx.Query("end"))
+	if err != nil {
+		return nil, err
+	}
+
+	params := &model.GetErrorParams{
+		Fingerprint: fingerprint,
+		ServiceName: ctx.Query("service"),
+		StepSeconds: 60,
+		Start:       startTime,
+		End:         endTime,
+	}
+	stepStr := ctx.Query("step")
+	if len(stepStr) != 0 {
+		step, err := strconv.Atoi(stepStr)
+		if err != nil || step < 60 {
+			return nil, errors.New("step param is not in correct format, must be at least 60 seconds")
+		}
+		params.StepSeconds = step
+	}
+	return params, nil
+}
+
+const maxSpanSearchLimit = 1000
+
+const defaultAggregateGroupLimit = 10
+const maxAggregateGroupLimit = 100
+
+var allowedAggregateGroups = []string{model.AggregateGroupService, model.AggregateGroupOperation, model.AggregateGroupStatusCode}
+
 func parseSpanSearchRequest(ctx *fiber.Ctx) (*model.SpanSearchParams, error) {
 
 	startTime, err := parseTime(ctx.Query("start"))
@@ -149,8 +871,8 @@ func parseSpanSearchRequest(ctx *fiber.Ctx) (*model.SpanSearchParams, error) {
 	limitStr := ctx.Query("limit")
 	if len(limitStr) != 0 {
 		limit, err := strconv.ParseInt(limitStr, 10, 64)
-		if err != nil {
-			return nil, errors.New("Limit param is not in correct format")
+		if err != nil || limit <= 0 || limit > maxSpanSearchLimit {
+			return nil, fmt.Errorf("Limit param is not in correct format, must be between 1 and %d", maxSpanSearchLimit)
 		}
 		params.Limit = limit
 	} else {
@@ -160,12 +882,37 @@ func parseSpanSearchRequest(ctx *fiber.Ctx) (*model.SpanSearchParams, error) {
 	offsetStr := ctx.Query("offset")
 	if len(offsetStr) != 0 {
 		offset, err := strconv.ParseInt(offsetStr, 10, 64)
-		if err != nil {
+		if err != nil || offset < 0 {
 			return nil, errors.New("Offset param is not in correct format")
 		}
 		params.Offset = offset
 	}
 
+	params.Order = ctx.Query("order", model.SpanOrderDescending)
+	if params.Order != model.SpanOrderAscending && params.Order != model.SpanOrderDescending {
+		return nil, errors.New("order param must be ascending or descending")
+	}
+	params.OrderParam = ctx.Query("orderParam", model.SpanOrderParamTimestamp)
+	if params.OrderParam != model.SpanOrderParamTimestamp && params.OrderParam != model.SpanOrderParamDuration {
+		return nil, errors.New("orderParam param must be timestamp or duration")
+	}
+
+	// cursor continues from the last span of the previous page, it replaces offset so deep pages don't scan skipped rows
+	cursorStr := ctx.Query("cursor")
+	if len(cursorStr) != 0 {
+		if params.Offset != 0 {
+			return nil, errors.New("cursor and offset params can't be used together")
+		}
+		cursor, err := model.DecodeSpanSearchCursor(cursorStr)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Order != params.Order || cursor.OrderParam != params.OrderParam {
+			return nil, errors.New("cursor doesn't match order and orderParam params")
+		}
+		params.Cursor = cursor
+	}
+
 	tags, err := parseTags(ctx.Query("tags"))
 	if err != nil {
 		return nil, err
@@ -177,6 +924,14 @@ func parseSpanSearchRequest(ctx *fiber.Ctx) (*model.SpanSearchParams, error) {
 	return params, nil
 }
 
+// parseTraceListRequest trace list takes span search filters, pages are requested only with offset
+func parseTraceListRequest(ctx *fiber.Ctx) (*model.SpanSearchParams, error) {
+	if len(ctx.Query("cursor")) != 0 {
+		return nil, errors.New("cursor param is not supported for traces, use offset")
+	}
+	return parseSpanSearchRequest(ctx)
+}
+
 func DoesExistInSlice(item string, list []string) bool {
 	for _, element := range list {
 		if item == element {
@@ -191,8 +946,8 @@ func parseSearchSpanAggregatesRequest(ctx *fiber.Ctx) (*model.SpanSearchAggregat
 	var allowedDimensions = []string{"calls", "duration"}
 
 	var allowedAggregations = map[string][]string{
-		"calls":    {"count", "rate_per_sec"},
-		"duration": {"avg", "p50", "p95", "p99"},
+		"calls":    {"count", "rate_per_sec", "count_distinct"},
+		"duration": {"avg", "min", "max", "p50", "p90", "p95", "p99"},
 	}
 
 	startTime, err := parseTime(ctx.Query("start"))
@@ -282,13 +1037,30 @@ func parseSearchSpanAggregatesRequest(ctx *fiber.Ctx) (*model.SpanSearchAggregat
 		params.Tags = *tags
 	}
 
+	groupBy := ctx.Query("groupBy")
+	if len(groupBy) != 0 {
+		if !DoesExistInSlice(groupBy, allowedAggregateGroups) && (!strings.HasPrefix(groupBy, model.AggregateGroupTagPrefix) || len(groupBy) == len(model.AggregateGroupTagPrefix)) {
+			return nil, fmt.Errorf("groupBy param must be one of %s or %s<tag key>", strings.Join(allowedAggregateGroups, ", "), model.AggregateGroupTagPrefix)
+		}
+		params.GroupBy = groupBy
+		params.GroupLimit = defaultAggregateGroupLimit
+	}
+	groupLimitStr := ctx.Query("groupLimit")
+	if len(groupLimitStr) != 0 {
+		groupLimit, err := strconv.Atoi(groupLimitStr)
+		if err != nil || groupLimit < 1 || groupLimit > maxAggregateGroupLimit {
+			return nil, fmt.Errorf("groupLimit param is not in correct format, must be between 1 and %d", maxAggregateGroupLimit)
+		}
+		params.GroupLimit = groupLimit
+	}
+
 	return params, nil
 }
 
 func parseTime(timeStr string) (*time.Time, error) {
 
 	if len(timeStr) == 0 {
-		return nil, fmt.Errorf("%s param missing in query", timeStr)
+		return nil, fmt.Errorf("time param missing in query")
 	}
 
 	timeUnix, err := strconv.ParseInt(timeStr, 10, 64)
@@ -384,3 +1156,49 @@ func parseTags(tagsStr string) (*[]model.TagQuery, error) {
 
 	return tags, nil
 }
+
+// parseOtlpTracesRequest decode OTLP/HTTP body, protobuf is the default encoding, json is used for application/json content type
+func parseOtlpTracesRequest(ctx *fiber.Ctx) ([]model.Span, bool, error) {
+	body, err := getRequestBody(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	isJson := strings.HasPrefix(string(ctx.Request().Header.ContentType()), fiber.MIMEApplicationJSON)
+	var spans []model.Span
+	if isJson {
+		spans, err = receivers.ParseOtlpJson(body)
+	} else {
+		spans, err = receivers.ParseOtlpProtobuf(body)
+	}
+	return spans, isJson, err
+}
+
+// parseZipkinSpansRequest decode zipkin v2 json span list
+func parseZipkinSpansRequest(ctx *fiber.Ctx) ([]model.Span, error) {
+	body, err := getRequestBody(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return receivers.ParseZipkinJson(body)
+}
+
+// parseJaegerBatchRequest decode jaeger thrift binary batch, as sent by jaeger clients HTTP sender
+func parseJaegerBatchRequest(ctx *fiber.Ctx) ([]model.Span, error) {
+	body, err := getRequestBody(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return receivers.ParseJaegerThrift(body)
+}
+
+// getRequestBody get request body, gzip bodies are decompressed
+func getRequestBody(ctx *fiber.Ctx) ([]byte, error) {
+	if strings.EqualFold(string(ctx.Request().Header.Peek(fiber.HeaderContentEncoding)), "gzip") {
+		body, err := ctx.Request().BodyGunzip()
+		if err != nil {
+			return nil, fmt.Errorf("error in decompressing request body")
+		}
+		return body, nil
+	}
+	return ctx.Body(), nil
+}
@@ -1,17 +1,23 @@
 package clickhouse
 
 import (
+	"context"
 	"goapm/ds_utils"
 	logger2 "goapm/logger"
 	"goapm/utils"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"github.com/ClickHouse/clickhouse-go"
 	_ "github.com/ClickHouse/clickhouse-go"
 	"github.com/jmoiron/sqlx"
 	"github.com/spf13/viper"
+	"io"
+	"math"
+	"net"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -25,6 +31,12 @@ var dataSources = ds_utils.NewSyncedHashSet()
 
 const emptyResult = "sql: no rows in result set"
 
+// ErrUnavailable returned when none of the data sources could be reached
+var ErrUnavailable = errors.New("clickhouse is unavailable")
+
+// clickhouse TIMEOUT_EXCEEDED error code, returned when max_execution_time is reached
+const timeoutExceededCode = 159
+
 type stmt func(stmt *sql.Stmt) error
 
 type ClickhouseConnectionServiceImpl struct {
@@ -83,66 +95,118 @@ func (services *ClickhouseConnectionServiceImpl) GetConnectionMap() *ds_utils.Co
 }
 
 func (services *ClickhouseConnectionServiceImpl) ExecuteInsertFunction(query string, statement stmt) error {
+	return services.ExecuteInsertFunctionContext(context.Background(), query, statement)
+}
+
+// ExecuteInsertFunctionContext same as ExecuteInsertFunction, transaction is rolled back when ctx is done
+func (services *ClickhouseConnectionServiceImpl) ExecuteInsertFunctionContext(ctx context.Context, query string, statement stmt) error {
 	if dataSources.Size() == 0 {
 		Connect()
 	}
 	for _, s := range dataSources.Values() {
+		if ctx.Err() != nil {
+			return fmt.Errorf("couldn't insert data, %w", ctx.Err())
+		}
 		connectVal, _ := connectionsMap.Get(s)
 		connect := connectVal.(*sqlx.DB)
-		var (
-			tx, _             = connect.Begin()
-			preparedStmt, err = tx.Prepare(query)
-		)
+		tx, err := connect.BeginTx(ctx, nil)
+		if err != nil {
+			logger.Error("an error occurred while begin transaction for query = "+query+", error = ", err, ", stack trace = ", string(debug.Stack()))
+			continue
+		}
+		preparedStmt, err := tx.Prepare(query)
 		if err != nil {
 			logger.Error("an error occurred while insert query = "+query+", error = ", err, ", stack trace = ", string(debug.Stack()))
+			_ = tx.Rollback()
+			continue
 		}
-		defer preparedStmt.Close()
 		err = statement(preparedStmt)
 		if err != nil {
 			logger.Error("an error occurred while insert query = "+query+", error = ", err, ", stack trace = ", string(debug.Stack()))
 			_ = tx.Rollback()
 		} else if err = tx.Commit(); err != nil {
 			logger.Error("an error occurred while insert query = "+query+", error = ", err, ", stack trace = ", string(debug.Stack()))
-		} else {
+		}
+		_ = preparedStmt.Close()
+		if err == nil {
 			return nil
 		}
 	}
 	logger.Error("couldn't execute method for all data sources, query = "+query, ", stack trace = ", string(debug.Stack()))
-	return errors.New("couldn't insert data, query = " + query)
+	return fmt.Errorf("couldn't insert data, query = %s, %w", query, ErrUnavailable)
 }
 
 func (services *ClickhouseConnectionServiceImpl) ExecuteSelectFunction(isArray bool, dest interface{}, query string, args []interface{}) error {
+	return services.ExecuteSelectFunctionContext(context.Background(), isArray, dest, query, args)
+}
+
+// ExecuteSelectFunctionContext run select with SETTINGS max_execution_time taken from ctx deadline(or CLICKHOUSE_MAX_EXECUTION_TIME),
+// query is cancelled when ctx is done. Timeouts are returned wrapping context.DeadlineExceeded
+func (services *ClickhouseConnectionServiceImpl) ExecuteSelectFunctionContext(ctx context.Context, isArray bool, dest interface{}, query string, args []interface{}) error {
 	if dataSources.Size() == 0 {
 		Connect()
 	}
+	query = query + " SETTINGS max_execution_time = " + strconv.Itoa(maxExecutionTimeSeconds(ctx))
 	var err error
 	dataSourcesReversed := Reverse(dataSources)
 	for _, s := range dataSourcesReversed {
 		connectVal, _ := connectionsMap.Get(s)
 		connect := connectVal.(*sqlx.DB)
-		if args != nil {
-			if isArray {
-				err = connect.Select(dest, query, args...)
-			} else {
-				err = connect.Get(dest, query, args...)
-			}
+		if isArray {
+			err = connect.SelectContext(ctx, dest, query, args...)
 		} else {
-			if isArray {
-				err = connect.Select(dest, query)
-			} else {
-				err = connect.Get(dest, query)
-			}
+			err = connect.GetContext(ctx, dest, query, args...)
 		}
 		if err != nil && !strings.EqualFold(err.Error(), emptyResult) {
 			logger.Error("an error occurred while select query = "+query+", error = ", err, ", stack trace = ", string(debug.Stack()))
+			if isTimeout(err) || ctx.Err() != nil {
+				break
+			}
 		} else {
 			return nil
 		}
 	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("couldn't execute select method, %w", ctx.Err())
+	}
+	if isTimeout(err) {
+		return fmt.Errorf("couldn't execute select method, %s, %w", err.Error(), context.DeadlineExceeded)
+	}
 	logger.Error("couldn't execute method for all data sources", ", stack trace = ", string(debug.Stack()))
+	if err == nil || isConnectionError(err) {
+		return fmt.Errorf("couldn't execute select method, %w", ErrUnavailable)
+	}
 	return errors.New("couldn't execute select method, " + err.Error())
 }
 
+// maxExecutionTimeSeconds remaining time of ctx deadline rounded up, CLICKHOUSE_MAX_EXECUTION_TIME(default 60) when there is no deadline
+func maxExecutionTimeSeconds(ctx context.Context) int {
+	maxExecutionTime := utils.GetOrDefaultInt(viper.GetString("CLICKHOUSE_MAX_EXECUTION_TIME"), 60)
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := int(math.Ceil(time.Until(deadline).Seconds()))
+		if remaining < 1 {
+			remaining = 1
+		}
+		if remaining < maxExecutionTime {
+			return remaining
+		}
+	}
+	return maxExecutionTime
+}
+
+// isConnectionError errors of the connection itself, as opposed to errors returned by clickhouse for the query
+func isConnectionError(err error) bool {
+	var netError net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, io.EOF) || errors.As(err, &netError)
+}
+
+func isTimeout(err error) bool {
+	if exception, ok := err.(*clickhouse.Exception); ok {
+		return exception.Code == timeoutExceededCode
+	}
+	return false
+}
+
 func Reverse(inputSet *ds_utils.ConcurrentHashSet) []interface{} {
 	var reverseList []interface{}
 	if inputSet.Size() == 0 {
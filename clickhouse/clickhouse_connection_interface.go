@@ -1,6 +1,7 @@
 package clickhouse
 
 import (
+	"context"
 	"goapm/ds_utils"
 	"github.com/stretchr/testify/mock"
 )
@@ -9,6 +10,8 @@ type ClickhouseConnectionService interface {
 	GetConnectionMap() *ds_utils.ConcurrentHashMap
 	ExecuteInsertFunction(query string, statement stmt) error
 	ExecuteSelectFunction(isArray bool, dest interface{}, query string, args []interface{}) error
+	ExecuteInsertFunctionContext(ctx context.Context, query string, statement stmt) error
+	ExecuteSelectFunctionContext(ctx context.Context, isArray bool, dest interface{}, query string, args []interface{}) error
 }
 
 type MockClickhouseConnectionService struct {
@@ -21,11 +24,21 @@ func (service *MockClickhouseConnectionService) GetConnectionMap() *ds_utils.Con
 }
 
 func (service *MockClickhouseConnectionService) ExecuteInsertFunction(query string, statement stmt) error {
-	args := service.Called(mock.Anything, mock.Anything)
+	args := service.Called(query, statement)
 	return args.Error(0)
 }
 
 func (service *MockClickhouseConnectionService) ExecuteSelectFunction(isArray bool, dest interface{}, query string, args []interface{}) (error) {
-	argsMock := service.Called(mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	argsMock := service.Called(isArray, dest, query, args)
+	return argsMock.Error(0)
+}
+
+func (service *MockClickhouseConnectionService) ExecuteInsertFunctionContext(ctx context.Context, query string, statement stmt) error {
+	args := service.Called(ctx, query, statement)
+	return args.Error(0)
+}
+
+func (service *MockClickhouseConnectionService) ExecuteSelectFunctionContext(ctx context.Context, isArray bool, dest interface{}, query string, args []interface{}) error {
+	argsMock := service.Called(ctx, isArray, dest, query, args)
 	return argsMock.Error(0)
 }
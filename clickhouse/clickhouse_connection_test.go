@@ -0,0 +1,57 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"goapm/ds_utils"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func newSelectTest(t *testing.T) sqlmock.Sqlmock {
+	mockDB, sqlMock, err := sqlmock.New()
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = mockDB.Close() })
+	connectionsMap = ds_utils.NewSyncedMap()
+	dataSources = ds_utils.NewSyncedHashSet()
+	connectionsMap.Put("1", sqlx.NewDb(mockDB, "sqlmock"))
+	dataSources.Add("1")
+	return sqlMock
+}
+
+func TestMaxExecutionTimeSeconds(t *testing.T) {
+	assert.Equal(t, 60, maxExecutionTimeSeconds(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, 3, maxExecutionTimeSeconds(ctx))
+}
+
+func TestExecuteSelectFunctionContextAddsMaxExecutionTime(t *testing.T) {
+	sqlMock := newSelectTest(t)
+	sqlMock.ExpectQuery(regexp.QuoteMeta("SELECT name FROM signoz_index_aggregated WHERE serviceName = ? SETTINGS max_execution_time = 60")).
+		WithArgs("frontend").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("GET /"))
+
+	var names []string
+	err := NewClickhouseConnectionService().ExecuteSelectFunctionContext(context.Background(), true, &names,
+		"SELECT name FROM signoz_index_aggregated WHERE serviceName = ?", []interface{}{"frontend"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"GET /"}, names)
+	assert.Nil(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestExecuteSelectFunctionContextDeadlineExceeded(t *testing.T) {
+	sqlMock := newSelectTest(t)
+	sqlMock.ExpectQuery("SELECT name").WillDelayFor(time.Second).WillReturnRows(sqlmock.NewRows([]string{"name"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var names []string
+	err := NewClickhouseConnectionService().ExecuteSelectFunctionContext(ctx, true, &names, "SELECT name FROM signoz_index_aggregated", nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
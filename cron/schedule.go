@@ -0,0 +1,156 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule standard five field cron expression (minute hour day-of-month month day-of-week) evaluated in UTC.
+// Fields accept *, values, ranges a-b, steps */n and a-b/n and comma separated lists, months and weekdays also
+// accept three letter names. As in cron, when both day fields are restricted a day matching either of them matches
+type Schedule struct {
+	minutes    uint64
+	hours      uint64
+	days       uint64
+	months     uint64
+	weekdays   uint64
+	anyDay     bool
+	anyWeekday bool
+}
+
+type field struct {
+	name  string
+	min   int
+	max   int
+	names []string
+}
+
+var (
+	minuteField  = field{name: "minute", min: 0, max: 59}
+	hourField    = field{name: "hour", min: 0, max: 23}
+	dayField     = field{name: "day of month", min: 1, max: 31}
+	monthField   = field{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	weekdayField = field{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+var macros = map[string]string{
+	"@yearly":  "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 0",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+func Parse(expression string) (*Schedule, error) {
+	expression = strings.TrimSpace(expression)
+	if macro, ok := macros[strings.ToLower(expression)]; ok {
+		expression = macro
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression must have 5 fields, got %d", len(fields))
+	}
+
+	schedule := &Schedule{anyDay: strings.HasPrefix(fields[2], "*"), anyWeekday: strings.HasPrefix(fields[4], "*")}
+	var err error
+	if schedule.minutes, err = minuteField.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if schedule.hours, err = hourField.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if schedule.days, err = dayField.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if schedule.months, err = monthField.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if schedule.weekdays, err = weekdayField.parse(fields[4]); err != nil {
+		return nil, err
+	}
+	// 7 is sunday as well
+	if schedule.weekdays&(1<<7) != 0 {
+		schedule.weekdays |= 1
+	}
+	return schedule, nil
+}
+
+// Matches schedule fires in minute of t
+func (schedule *Schedule) Matches(t time.Time) bool {
+	t = t.UTC()
+	if schedule.minutes&(1<<uint(t.Minute())) == 0 || schedule.hours&(1<<uint(t.Hour())) == 0 ||
+		schedule.months&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	dayMatches := schedule.days&(1<<uint(t.Day())) != 0
+	weekdayMatches := schedule.weekdays&(1<<uint(t.Weekday())) != 0
+	if schedule.anyDay || schedule.anyWeekday {
+		return dayMatches && weekdayMatches
+	}
+	return dayMatches || weekdayMatches
+}
+
+// ActiveAt schedule fired within duration before t, i.e. t falls in a window of given duration started by schedule
+func (schedule *Schedule) ActiveAt(t time.Time, duration time.Duration) bool {
+	start := t.Add(-duration)
+	for minute := t.Truncate(time.Minute); minute.After(start); minute = minute.Add(-time.Minute) {
+		if schedule.Matches(minute) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f field) parse(expression string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expression, ",") {
+		rangePart, step := part, 1
+		if index := strings.Index(part, "/"); index >= 0 {
+			parsedStep, err := strconv.Atoi(part[index+1:])
+			if err != nil || parsedStep < 1 {
+				return 0, fmt.Errorf("invalid step in %s field %q", f.name, part)
+			}
+			rangePart, step = part[:index], parsedStep
+		}
+
+		low, high := f.min, f.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = f.value(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// a/n means from a to the end of the range
+				high = f.max
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range in %s field %q", f.name, part)
+			}
+		}
+		for value := low; value <= high; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(text string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(text, name) {
+			return i + f.min, nil
+		}
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil || value < f.min || value > f.max {
+		return 0, fmt.Errorf("%s field value %q must be between %d and %d", f.name, text, f.min, f.max)
+	}
+	return value, nil
+}
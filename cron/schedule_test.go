@@ -0,0 +1,52 @@
+package cron
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	for _, expression := range []string{"* * * * *", "*/15 2-4 1,15 jan-mar MON-fri", "30 22 * * 7", "@daily", "5/20 * * * *"} {
+		_, err := Parse(expression)
+		assert.Nil(t, err, expression)
+	}
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "*/0 * * * *",
+		"5-1 * * * *", "* * * * sunday", "a * * * *"} {
+		_, err := Parse(expression)
+		assert.NotNil(t, err, expression)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	// 2021-09-05 is sunday
+	sunday := time.Date(2021, 9, 5, 22, 30, 0, 0, time.UTC)
+	schedule, _ := Parse("30 22 * * 7")
+	assert.True(t, schedule.Matches(sunday))
+	assert.False(t, schedule.Matches(sunday.Add(time.Minute)))
+	assert.False(t, schedule.Matches(sunday.AddDate(0, 0, 1)))
+
+	schedule, _ = Parse("5/20 * * * *")
+	assert.True(t, schedule.Matches(time.Date(2021, 9, 5, 1, 45, 0, 0, time.UTC)))
+	assert.False(t, schedule.Matches(time.Date(2021, 9, 5, 1, 0, 0, 0, time.UTC)))
+
+	// both day fields restricted, either of them matches
+	schedule, _ = Parse("0 0 1 * mon")
+	assert.True(t, schedule.Matches(time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, schedule.Matches(time.Date(2021, 9, 6, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, schedule.Matches(time.Date(2021, 9, 7, 0, 0, 0, 0, time.UTC)))
+
+	// day field starting with * doesn't count as restricted, odd mondays only
+	schedule, _ = Parse("0 0 */2 * mon")
+	assert.True(t, schedule.Matches(time.Date(2021, 9, 13, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, schedule.Matches(time.Date(2021, 9, 6, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestActiveAt(t *testing.T) {
+	schedule, _ := Parse("0 2 * * sat")
+	start := time.Date(2021, 9, 4, 2, 0, 0, 0, time.UTC)
+	assert.True(t, schedule.ActiveAt(start, time.Hour))
+	assert.True(t, schedule.ActiveAt(start.Add(59*time.Minute+59*time.Second), time.Hour))
+	assert.False(t, schedule.ActiveAt(start.Add(time.Hour), time.Hour))
+	assert.False(t, schedule.ActiveAt(start.Add(-time.Second), time.Hour))
+}
@@ -0,0 +1,68 @@
+package dao
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+)
+
+type AlertDao interface {
+	GetRules(ctx context.Context) ([]model.AlertRule, error)
+	GetRule(ctx context.Context, id string) (*model.AlertRule, error)
+	SaveRule(ctx context.Context, rule *model.AlertRule) error
+	DeleteRule(ctx context.Context, rule *model.AlertRule) error
+	GetRuleStatuses(ctx context.Context) ([]model.AlertStatus, error)
+	InsertHistory(ctx context.Context, items []model.AlertHistoryItem) error
+	GetRuleHistory(ctx context.Context, ruleID string, limit int) ([]model.AlertHistoryItem, error)
+}
+
+type MockAlertDao struct {
+	mock.Mock
+}
+
+func (dao *MockAlertDao) GetRules(ctx context.Context) ([]model.AlertRule, error) {
+	args := dao.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.AlertRule), args.Error(1)
+}
+
+func (dao *MockAlertDao) GetRule(ctx context.Context, id string) (*model.AlertRule, error) {
+	args := dao.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.AlertRule), args.Error(1)
+}
+
+func (dao *MockAlertDao) SaveRule(ctx context.Context, rule *model.AlertRule) error {
+	args := dao.Called(ctx, rule)
+	return args.Error(0)
+}
+
+func (dao *MockAlertDao) DeleteRule(ctx context.Context, rule *model.AlertRule) error {
+	args := dao.Called(ctx, rule)
+	return args.Error(0)
+}
+
+func (dao *MockAlertDao) GetRuleStatuses(ctx context.Context) ([]model.AlertStatus, error) {
+	args := dao.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.AlertStatus), args.Error(1)
+}
+
+func (dao *MockAlertDao) InsertHistory(ctx context.Context, items []model.AlertHistoryItem) error {
+	args := dao.Called(ctx, items)
+	return args.Error(0)
+}
+
+func (dao *MockAlertDao) GetRuleHistory(ctx context.Context, ruleID string, limit int) ([]model.AlertHistoryItem, error) {
+	args := dao.Called(ctx, ruleID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.AlertHistoryItem), args.Error(1)
+}
@@ -0,0 +1,195 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"go.uber.org/zap"
+	"goapm/clickhouse"
+	model "goapm/domain"
+	"goapm/logger"
+	"goapm/query_builder"
+	"sync"
+	"time"
+)
+
+const insertAlertRuleQuery = "INSERT INTO alert_rules (id, name, metric, serviceName, operator, threshold, windowSeconds, forSeconds, labels, sloID, disabled, deleted, updatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+const insertAlertHistoryQuery = "INSERT INTO alert_history (ruleID, state, value, threshold, timestamp) VALUES (?, ?, ?, ?, ?)"
+
+var alertDaoOnce sync.Once
+var alertDao *AlertDaoImpl
+
+// AlertDaoImpl alert rules are versioned rows of ReplacingMergeTree, deleted rule is a newer version with deleted flag.
+// History keeps every state transition, current state of rule is its latest transition
+type AlertDaoImpl struct {
+	Logger                      *zap.SugaredLogger
+	ClickhouseConnectionService clickhouse.ClickhouseConnectionService
+}
+
+func NewAlertDao(ClickhouseConnectionService clickhouse.ClickhouseConnectionService) *AlertDaoImpl {
+	alertDaoOnce.Do(func() {
+		alertDao = &AlertDaoImpl{
+			Logger:                      logger.LOGGER,
+			ClickhouseConnectionService: ClickhouseConnectionService,
+		}
+	})
+	return alertDao
+}
+
+// alertRuleRow stored form of rule, labels are kept as json object
+type alertRuleRow struct {
+	ID            string  `db:"id"`
+	Name          string  `db:"name"`
+	Metric        string  `db:"metric"`
+	ServiceName   string  `db:"serviceName"`
+	Operator      string  `db:"operator"`
+	Threshold     float64 `db:"threshold"`
+	WindowSeconds uint32  `db:"windowSeconds"`
+	ForSeconds    uint32  `db:"forSeconds"`
+	Labels        string  `db:"labels"`
+	SLOID         string  `db:"sloID"`
+	Disabled      uint8   `db:"disabled"`
+	UpdatedAt     int64   `db:"updatedAtNano"`
+}
+
+func (row *alertRuleRow) rule() model.AlertRule {
+	rule := model.AlertRule{
+		ID:            row.ID,
+		Name:          row.Name,
+		Metric:        row.Metric,
+		ServiceName:   row.ServiceName,
+		Operator:      row.Operator,
+		Threshold:     row.Threshold,
+		WindowSeconds: int(row.WindowSeconds),
+		ForSeconds:    int(row.ForSeconds),
+		Labels:        map[string]string{},
+		SLOID:         row.SLOID,
+		Disabled:      row.Disabled == 1,
+		UpdatedAt:     row.UpdatedAt,
+	}
+	_ = json.Unmarshal([]byte(row.Labels), &rule.Labels)
+	return rule
+}
+
+func alertRulesQuery() *query_builder.SelectBuilder {
+	return query_builder.Select("id", "name", "metric", "serviceName", "operator", "threshold", "windowSeconds", "forSeconds", "labels",
+		"sloID", "disabled", "toUnixTimestamp64Nano(updatedAt) as updatedAtNano").
+		From("alert_rules").
+		Final().
+		Where("deleted = 0")
+}
+
+func (dao *AlertDaoImpl) GetRules(ctx context.Context) ([]model.AlertRule, error) {
+	var rows []alertRuleRow
+	err := dao.selectAll(ctx, &rows, alertRulesQuery().OrderBy("name", query_builder.Asc).OrderBy("id", query_builder.Asc))
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]model.AlertRule, len(rows))
+	for i := range rows {
+		rules[i] = rows[i].rule()
+	}
+	return rules, nil
+}
+
+// GetRule return nil when rule doesn't exist or was deleted
+func (dao *AlertDaoImpl) GetRule(ctx context.Context, id string) (*model.AlertRule, error) {
+	var rows []alertRuleRow
+	err := dao.selectAll(ctx, &rows, alertRulesQuery().Where("id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	rule := rows[0].rule()
+	return &rule, nil
+}
+
+func (dao *AlertDaoImpl) SaveRule(ctx context.Context, rule *model.AlertRule) error {
+	return dao.insertRule(ctx, rule, false)
+}
+
+func (dao *AlertDaoImpl) DeleteRule(ctx context.Context, rule *model.AlertRule) error {
+	return dao.insertRule(ctx, rule, true)
+}
+
+func (dao *AlertDaoImpl) insertRule(ctx context.Context, rule *model.AlertRule, deleted bool) error {
+	labels, err := json.Marshal(rule.Labels)
+	if err != nil {
+		return err
+	}
+	err = dao.ClickhouseConnectionService.ExecuteInsertFunctionContext(ctx, insertAlertRuleQuery, func(stmt *sql.Stmt) error {
+		_, err := stmt.Exec(rule.ID, rule.Name, rule.Metric, rule.ServiceName, rule.Operator, rule.Threshold, uint32(rule.WindowSeconds),
+			uint32(rule.ForSeconds), string(labels), rule.SLOID, boolToUInt8(rule.Disabled), boolToUInt8(deleted), time.Now())
+		return err
+	})
+	if err != nil {
+		dao.Logger.Debug("Error in saving alert rule: ", err)
+		return queryError(err)
+	}
+	return nil
+}
+
+// GetRuleStatuses latest transition of every rule which was evaluated at least once
+func (dao *AlertDaoImpl) GetRuleStatuses(ctx context.Context) ([]model.AlertStatus, error) {
+	statuses := []model.AlertStatus{}
+	query := query_builder.Select("ruleID", "argMax(state, timestamp) as state", "argMax(value, timestamp) as value",
+		"toUnixTimestamp64Nano(max(timestamp)) as since").
+		From("alert_history").
+		GroupBy("ruleID")
+	err := dao.selectAll(ctx, &statuses, query)
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+func (dao *AlertDaoImpl) InsertHistory(ctx context.Context, items []model.AlertHistoryItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	err := dao.ClickhouseConnectionService.ExecuteInsertFunctionContext(ctx, insertAlertHistoryQuery, func(stmt *sql.Stmt) error {
+		for _, item := range items {
+			_, err := stmt.Exec(item.RuleID, item.State, item.Value, item.Threshold, time.Unix(0, item.Timestamp))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		dao.Logger.Debug("Error in inserting alert history: ", err)
+		return queryError(err)
+	}
+	return nil
+}
+
+// GetRuleHistory latest transitions of rule, newest first
+func (dao *AlertDaoImpl) GetRuleHistory(ctx context.Context, ruleID string, limit int) ([]model.AlertHistoryItem, error) {
+	items := []model.AlertHistoryItem{}
+	query := query_builder.Select("ruleID", "state", "value", "threshold", "toUnixTimestamp64Nano(timestamp) as timestampNano").
+		From("alert_history").
+		Where("ruleID = ?", ruleID).
+		OrderBy("timestampNano", query_builder.Desc).
+		Limit(limit)
+	err := dao.selectAll(ctx, &items, query)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []model.AlertHistoryItem{}
+	}
+	return items, nil
+}
+
+func (dao *AlertDaoImpl) selectAll(ctx context.Context, dest interface{}, builder *query_builder.SelectBuilder) error {
+	return runSelect(ctx, dao.ClickhouseConnectionService, dao.Logger, true, dest, builder)
+}
+
+func boolToUInt8(value bool) uint8 {
+	if value {
+		return 1
+	}
+	return 0
+}
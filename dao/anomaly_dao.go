@@ -0,0 +1,29 @@
+package dao
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+)
+
+type AnomalyDao interface {
+	InsertAnomalies(ctx context.Context, anomalies []model.Anomaly) error
+	GetAnomalies(ctx context.Context, params *model.GetAnomaliesParams) ([]model.Anomaly, error)
+}
+
+type MockAnomalyDao struct {
+	mock.Mock
+}
+
+func (dao *MockAnomalyDao) InsertAnomalies(ctx context.Context, anomalies []model.Anomaly) error {
+	args := dao.Called(ctx, anomalies)
+	return args.Error(0)
+}
+
+func (dao *MockAnomalyDao) GetAnomalies(ctx context.Context, params *model.GetAnomaliesParams) ([]model.Anomaly, error) {
+	args := dao.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Anomaly), args.Error(1)
+}
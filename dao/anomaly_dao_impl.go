@@ -0,0 +1,83 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"go.uber.org/zap"
+	"goapm/clickhouse"
+	model "goapm/domain"
+	"goapm/logger"
+	"goapm/query_builder"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const insertAnomalyQuery = "INSERT INTO anomalies (serviceName, metric, timestamp, windowSeconds, value, baseline, deviation, score, direction) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+var anomalyDaoOnce sync.Once
+var anomalyDao *AnomalyDaoImpl
+
+type AnomalyDaoImpl struct {
+	Logger                      *zap.SugaredLogger
+	ClickhouseConnectionService clickhouse.ClickhouseConnectionService
+}
+
+func NewAnomalyDao(ClickhouseConnectionService clickhouse.ClickhouseConnectionService) *AnomalyDaoImpl {
+	anomalyDaoOnce.Do(func() {
+		anomalyDao = &AnomalyDaoImpl{
+			Logger:                      logger.LOGGER,
+			ClickhouseConnectionService: ClickhouseConnectionService,
+		}
+	})
+	return anomalyDao
+}
+
+func (dao *AnomalyDaoImpl) InsertAnomalies(ctx context.Context, anomalies []model.Anomaly) error {
+	if len(anomalies) == 0 {
+		return nil
+	}
+	err := dao.ClickhouseConnectionService.ExecuteInsertFunctionContext(ctx, insertAnomalyQuery, func(stmt *sql.Stmt) error {
+		for _, anomaly := range anomalies {
+			_, err := stmt.Exec(anomaly.ServiceName, anomaly.Metric, time.Unix(0, anomaly.Timestamp), uint32(anomaly.WindowSeconds),
+				anomaly.Value, anomaly.Baseline, anomaly.Deviation, anomaly.Score, anomaly.Direction)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		dao.Logger.Debug("Error in inserting anomalies: ", err)
+		return queryError(err)
+	}
+	return nil
+}
+
+// GetAnomalies anomalies of windows starting in time range, newest first
+func (dao *AnomalyDaoImpl) GetAnomalies(ctx context.Context, params *model.GetAnomaliesParams) ([]model.Anomaly, error) {
+	anomalies := []model.Anomaly{}
+	query := query_builder.Select("serviceName", "metric", "toUnixTimestamp64Nano(timestamp) as timestampNano", "windowSeconds",
+		"value", "baseline", "deviation", "score", "direction").
+		From("anomalies").
+		Where("timestamp >= ?", strconv.FormatInt(params.Start.UnixNano(), 10)).
+		Where("timestamp <= ?", strconv.FormatInt(params.End.UnixNano(), 10)).
+		WhereIf(len(params.ServiceName) > 0, "serviceName = ?", params.ServiceName).
+		WhereIf(len(params.Metric) > 0, "metric = ?", params.Metric).
+		OrderBy("timestampNano", query_builder.Desc).
+		OrderBy("serviceName", query_builder.Asc).
+		OrderBy("metric", query_builder.Asc).
+		Limit(params.Limit)
+	err := dao.selectAll(ctx, &anomalies, query)
+	if err != nil {
+		return nil, err
+	}
+	if anomalies == nil {
+		anomalies = []model.Anomaly{}
+	}
+	return anomalies, nil
+}
+
+func (dao *AnomalyDaoImpl) selectAll(ctx context.Context, dest interface{}, builder *query_builder.SelectBuilder) error {
+	return runSelect(ctx, dao.ClickhouseConnectionService, dao.Logger, true, dest, builder)
+}
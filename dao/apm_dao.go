@@ -9,16 +9,23 @@ type ApmDao interface {
 	GetServices(ctx context.Context, queryParams *model.GetServicesParams) (*[]model.ServiceItem, error)
 	GetServicesList(ctx context.Context) (*[]string, error)
 	GetServiceOverview(ctx context.Context, query *model.GetServiceOverviewParams) (*[]model.ServiceOverviewItem, error)
-	SearchSpans(ctx context.Context, query *model.SpanSearchParams) (*[]model.SearchSpansResult, error)
+	SearchSpans(ctx context.Context, query *model.SpanSearchParams) (*model.TraceResult, error)
 	GetServiceDBOverview(ctx context.Context, query *model.GetServiceOverviewParams) (*[]model.ServiceDBOverviewItem, error)
-	GetServiceExternalAvgDuration(ctx context.Context, query *model.GetServiceOverviewParams) (*[]model.ServiceExternalItem, error)
-	GetServiceExternalErrors(ctx context.Context, query *model.GetServiceOverviewParams) (*[]model.ServiceExternalItem, error)
-	GetServiceExternal(ctx context.Context, query *model.GetServiceOverviewParams) (*[]model.ServiceExternalItem, error)
+	GetServiceMetrics(ctx context.Context, queryParams *model.GetServiceMetricsParams) (*model.ServiceMetrics, error)
+	GetSLIEvents(ctx context.Context, queryParams *model.GetSLIEventsParams) ([]model.SLIEvents, error)
+	GetLatencyHistogram(ctx context.Context, queryParams *model.GetLatencyHistogramParams) (*model.LatencyHistogram, error)
+	GetLatencyHeatmap(ctx context.Context, queryParams *model.GetLatencyHistogramParams) (*model.LatencyHeatmap, error)
+	GetDBStatements(ctx context.Context, queryParams *model.GetDBStatementsParams) (*model.DBStatementsResult, error)
+	GetServiceExternal(ctx context.Context, queryParams *model.GetServiceExternalParams) (*[]model.ServiceExternalItem, error)
 	GetTopEndpoints(ctx context.Context, query *model.GetTopEndpointsParams) (*[]model.TopEndpointsItem, error)
 	GetUsage(ctx context.Context, query *model.GetUsageParams) (*[]model.UsageItem, error)
 	GetOperations(ctx context.Context, serviceName string) (*[]string, error)
 	GetTags(ctx context.Context, serviceName string) (*[]model.TagItem, error)
+	GetTagValues(ctx context.Context, queryParams *model.GetTagValuesParams) (*model.TagValuesResult, error)
 	SearchTraces(ctx context.Context, traceID string) (*[]model.SearchSpansResult, error)
-	GetServiceMapDependencies(ctx context.Context, query *model.GetServicesParams) (*[]model.ServiceMapDependencyResponseItem, error)
+	GetTraceSpans(ctx context.Context, traceID string) ([]model.SearchSpanReponseItem, error)
+	SearchTraceList(ctx context.Context, queryParams *model.SpanSearchParams) (*model.TraceResult, error)
+	GetServiceMapDependencies(ctx context.Context, query *model.GetServiceMapParams) (*[]model.ServiceMapDependencyResponseItem, error)
 	SearchSpansAggregate(ctx context.Context, queryParams *model.SpanSearchAggregatesParams) ([]model.SpanSearchAggregatesResponseItem, error)
+	SearchSpansAggregateGroups(ctx context.Context, queryParams *model.SpanSearchAggregatesParams) ([]model.SpanSearchAggregatesSeries, error)
 }
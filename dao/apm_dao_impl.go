@@ -3,11 +3,16 @@ package dao
 import (
 	"goapm/clickhouse"
 	"goapm/logger"
+	"goapm/query_builder"
 	"context"
+	"errors"
 	"fmt"
 	"go.uber.org/zap"
 	model "goapm/domain"
+	"math"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -32,27 +37,32 @@ func NewApmDao(ClickhouseConnectionService clickhouse.ClickhouseConnectionServic
 
 func (dao *ApmDaoImpl) GetServices(ctx context.Context, queryParams *model.GetServicesParams) (*[]model.ServiceItem, error) {
 	var serviceItems []model.ServiceItem
-	query := fmt.Sprintf("SELECT serviceName, quantileMerge(0.99)(quantile) as p99, avgMerge(avg) as avgDuration, sum(count) as numCalls FROM signoz_index_aggregated WHERE timestamp>='%s' AND timestamp<='%s' AND kind='2' GROUP BY serviceName ORDER BY p99 DESC", convertNanosToSeconds(queryParams.Start), convertNanosToSeconds(queryParams.End))
-
-	err := dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &serviceItems, query, nil)
-	dao.Logger.Info(query)
-
+	query := query_builder.Select("serviceName", "quantileMerge(0.99)(quantile) as p99", "avgMerge(avg) as avgDuration", "sum(count) as numCalls").
+		From("signoz_index_aggregated").
+		Where("timestamp >= ?", convertNanosToSeconds(queryParams.Start)).
+		Where("timestamp <= ?", convertNanosToSeconds(queryParams.End)).
+		Where("kind = '2'").
+		GroupBy("serviceName").
+		OrderBy("p99", query_builder.Desc)
+
+	err := dao.selectAll(ctx, &serviceItems, query)
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
 	}
 
 	var serviceErrorItems []model.ServiceItem
 
-	query = fmt.Sprintf("SELECT serviceName, sum(count) as numErrors FROM signoz_index_aggregated WHERE timestamp>='%s' AND timestamp<='%s' AND kind='2' AND (statusCode>=500 OR statusCode=2) GROUP BY serviceName", convertNanosToSeconds(queryParams.Start), convertNanosToSeconds(queryParams.End))
-
-	err = dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &serviceErrorItems, query, nil)
-
-	dao.Logger.Info(query)
+	query = query_builder.Select("serviceName", "sum(count) as numErrors").
+		From("signoz_index_aggregated").
+		Where("timestamp >= ?", convertNanosToSeconds(queryParams.Start)).
+		Where("timestamp <= ?", convertNanosToSeconds(queryParams.End)).
+		Where("kind = '2'").
+		Where("(statusCode >= 500 OR statusCode = 2)").
+		GroupBy("serviceName")
 
+	err = dao.selectAll(ctx, &serviceErrorItems, query)
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
 	}
 
 	m5xx := make(map[string]int)
@@ -66,21 +76,24 @@ func (dao *ApmDaoImpl) GetServices(ctx context.Context, queryParams *model.GetSe
 
 	var service4xxItems []model.ServiceItem
 
-	query = fmt.Sprintf("SELECT serviceName, sum(count) as num4xx FROM signoz_index_aggregated WHERE timestamp>='%s' AND timestamp<='%s' AND kind='2' AND statusCode>=400 AND statusCode<500 GROUP BY serviceName", convertNanosToSeconds(queryParams.Start), convertNanosToSeconds(queryParams.End))
-
-	err = dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &service4xxItems, query, nil)
-
-	dao.Logger.Info(query)
+	query = query_builder.Select("serviceName", "sum(count) as num4xx").
+		From("signoz_index_aggregated").
+		Where("timestamp >= ?", convertNanosToSeconds(queryParams.Start)).
+		Where("timestamp <= ?", convertNanosToSeconds(queryParams.End)).
+		Where("kind = '2'").
+		Where("statusCode >= 400").
+		Where("statusCode < 500").
+		GroupBy("serviceName")
 
+	err = dao.selectAll(ctx, &service4xxItems, query)
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
 	}
 
 	m4xx := make(map[string]int)
 
 	for j := range service4xxItems {
-		m5xx[service4xxItems[j].ServiceName] = service4xxItems[j].Num4XX
+		m4xx[service4xxItems[j].ServiceName] = service4xxItems[j].Num4XX
 	}
 
 	for i, _ := range serviceItems {
@@ -95,21 +108,37 @@ func (dao *ApmDaoImpl) GetServices(ctx context.Context, queryParams *model.GetSe
 		serviceItems[i].ErrorRate = float32(serviceItems[i].NumErrors) / float32(queryParams.Period)
 	}
 
+	rollup := apdexRollup().
+		Where("timestamp >= ?", convertNanosToSeconds(queryParams.Start)).
+		Where("timestamp <= ?", convertNanosToSeconds(queryParams.End))
+	spans := apdexSpans().
+		Where("timestamp >= ?", strconv.FormatInt(queryParams.Start.UnixNano(), 10)).
+		Where("timestamp <= ?", strconv.FormatInt(queryParams.End.UnixNano(), 10))
+	apdexRows, err := dao.apdexScores(ctx, rollup, spans)
+	if err != nil {
+		return nil, err
+	}
+	apdexByService := make(map[string]model.ApdexScore, len(apdexRows))
+	for _, row := range apdexRows {
+		apdexByService[row.ServiceName] = row.score()
+	}
+	for i := range serviceItems {
+		serviceItems[i].ApdexScore = apdexByService[serviceItems[i].ServiceName]
+	}
+
 	return &serviceItems, nil
 }
 
 func (dao *ApmDaoImpl) GetServicesList(ctx context.Context) (*[]string, error) {
 	var services []string
 
-	query := fmt.Sprintf(`SELECT DISTINCT serviceName FROM signoz_index_aggregated WHERE toDate(timestamp) > now() - INTERVAL 1 DAY`)
-
-	err := dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &services, query, nil)
-
-	dao.Logger.Info(query)
+	query := query_builder.Select("DISTINCT serviceName").
+		From("signoz_index_aggregated").
+		Where("toDate(timestamp) > now() - INTERVAL 1 DAY")
 
+	err := dao.selectAll(ctx, &services, query)
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
 	}
 
 	return &services, nil
@@ -118,28 +147,36 @@ func (dao *ApmDaoImpl) GetServicesList(ctx context.Context) (*[]string, error) {
 func (dao *ApmDaoImpl) GetServiceOverview(ctx context.Context, queryParams *model.GetServiceOverviewParams) (*[]model.ServiceOverviewItem, error) {
 	var serviceOverviewItems []model.ServiceOverviewItem
 
-	query := fmt.Sprintf("SELECT toStartOfInterval(timestamp, INTERVAL %s minute) as time, quantileMerge(0.99)(quantile) as p99, quantileMerge(0.95)(quantile) as p95,quantileMerge(0.50)(quantile) as p50, sum(count) as numCalls FROM signoz_index_aggregated WHERE timestamp>='%s' AND timestamp<='%s' AND kind='2' AND serviceName='%s' GROUP BY time ORDER BY time DESC", strconv.Itoa(queryParams.StepSeconds/60), convertNanosToSeconds(queryParams.Start), convertNanosToSeconds(queryParams.End), queryParams.ServiceName)
-
-	err := dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &serviceOverviewItems, query, nil)
-
-	dao.Logger.Info(query)
-
+	query := query_builder.Select(query_builder.StartOfInterval("timestamp", queryParams.StepSeconds/60)+" as time",
+		"quantileMerge(0.99)(quantile) as p99", "quantileMerge(0.95)(quantile) as p95", "quantileMerge(0.50)(quantile) as p50", "sum(count) as numCalls").
+		From("signoz_index_aggregated").
+		Where("timestamp >= ?", convertNanosToSeconds(queryParams.Start)).
+		Where("timestamp <= ?", convertNanosToSeconds(queryParams.End)).
+		Where("kind = '2'").
+		Where("serviceName = ?", queryParams.ServiceName).
+		GroupBy("time").
+		OrderBy("time", query_builder.Desc)
+
+	err := dao.selectAll(ctx, &serviceOverviewItems, query)
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
 	}
 
 	var serviceErrorItems []model.ServiceErrorItem
 
-	query = fmt.Sprintf("SELECT toStartOfInterval(timestamp, INTERVAL %s minute) as time, sum(count) as numErrors FROM signoz_index_aggregated WHERE timestamp>='%s' AND timestamp<='%s' AND kind='2' AND serviceName='%s' AND (statusCode>=500 OR statusCode=2) GROUP BY time ORDER BY time DESC", strconv.Itoa(queryParams.StepSeconds/60), convertNanosToSeconds(queryParams.Start), convertNanosToSeconds(queryParams.End), queryParams.ServiceName)
-
-	err = dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &serviceErrorItems, query, nil)
-
-	dao.Logger.Info(query)
-
+	query = query_builder.Select(query_builder.StartOfInterval("timestamp", queryParams.StepSeconds/60)+" as time", "sum(count) as numErrors").
+		From("signoz_index_aggregated").
+		Where("timestamp >= ?", convertNanosToSeconds(queryParams.Start)).
+		Where("timestamp <= ?", convertNanosToSeconds(queryParams.End)).
+		Where("kind = '2'").
+		Where("serviceName = ?", queryParams.ServiceName).
+		Where("(statusCode >= 500 OR statusCode = 2)").
+		GroupBy("time").
+		OrderBy("time", query_builder.Desc)
+
+	err = dao.selectAll(ctx, &serviceErrorItems, query)
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
 	}
 
 	m := make(map[int64]int)
@@ -161,108 +198,369 @@ func (dao *ApmDaoImpl) GetServiceOverview(ctx context.Context, queryParams *mode
 		serviceOverviewItems[i].CallRate = float32(serviceOverviewItems[i].NumCalls) / float32(queryParams.StepSeconds)
 	}
 
+	if !queryParams.Apdex {
+		return &serviceOverviewItems, nil
+	}
+
+	interval := query_builder.StartOfInterval("timestamp", queryParams.StepSeconds/60) + " as time"
+	rollup := apdexRollup(interval).
+		Where("serviceName = ?", queryParams.ServiceName).
+		Where("timestamp >= ?", convertNanosToSeconds(queryParams.Start)).
+		Where("timestamp <= ?", convertNanosToSeconds(queryParams.End))
+	spans := apdexSpans(interval).
+		Where("serviceName = ?", queryParams.ServiceName).
+		Where("timestamp >= ?", strconv.FormatInt(queryParams.Start.UnixNano(), 10)).
+		Where("timestamp <= ?", strconv.FormatInt(queryParams.End.UnixNano(), 10))
+	apdexRows, err := dao.apdexScores(ctx, rollup, spans, "time")
+	if err != nil {
+		return nil, err
+	}
+	apdexByTime := make(map[int64]model.ApdexScore, len(apdexRows))
+	for _, row := range apdexRows {
+		timeObj, _ := time.Parse(time.RFC3339Nano, row.Time)
+		apdexByTime[timeObj.UnixNano()] = row.score()
+	}
+	for i := range serviceOverviewItems {
+		score := apdexByTime[serviceOverviewItems[i].Timestamp]
+		serviceOverviewItems[i].ApdexScore = &score
+	}
+
 	return &serviceOverviewItems, nil
 }
 
-func (dao *ApmDaoImpl) SearchSpans(ctx context.Context, queryParams *model.SpanSearchParams) (*[]model.SearchSpansResult, error) {
-	query := fmt.Sprintf("SELECT timestamp, spanID, traceID, serviceName, name, kind, durationNano, tagsKeys, tagsValues FROM signoz_index_final WHERE timestamp >= ? AND timestamp <= ?")
+// SearchSpans return page of spans sorted by timestamp or duration with total count of matching spans,
+// next page can be requested with offset or with returned cursor which continues after the last span of the page
+func (dao *ApmDaoImpl) SearchSpans(ctx context.Context, queryParams *model.SpanSearchParams) (*model.TraceResult, error) {
+	query := query_builder.Select("timestamp", "spanID", "traceID", "serviceName", "name", "kind", "durationNano", "tagsKeys", "tagsValues").
+		From("signoz_index_final")
+	countQuery := query_builder.Select("count() as total").
+		From("signoz_index_final")
+	if err := applySpanSearchFilters(query, queryParams); err != nil {
+		return nil, err
+	}
+	_ = applySpanSearchFilters(countQuery, queryParams)
 
-	args := []interface{}{strconv.FormatInt(queryParams.Start.UnixNano(), 10), strconv.FormatInt(queryParams.End.UnixNano(), 10)}
+	orderColumn := "timestamp"
+	if queryParams.OrderParam == model.SpanOrderParamDuration {
+		orderColumn = "durationNano"
+	}
+	order := query_builder.Desc
+	comparison := "<"
+	if queryParams.Order == model.SpanOrderAscending {
+		order = query_builder.Asc
+		comparison = ">"
+	}
+	if queryParams.Cursor != nil {
+		cursorValue := "?"
+		if orderColumn == "timestamp" {
+			cursorValue = "fromUnixTimestamp64Nano(?)"
+		}
+		query.Where(fmt.Sprintf("(%s %s %s OR (%s = %s AND spanID %s ?))", orderColumn, comparison, cursorValue, orderColumn, cursorValue, comparison),
+			queryParams.Cursor.Value, queryParams.Cursor.Value, queryParams.Cursor.SpanID)
+	}
 
-	if len(queryParams.ServiceName) != 0 {
-		query = query + " AND serviceName = ?"
-		args = append(args, queryParams.ServiceName)
+	limit := int(queryParams.Limit)
+	if limit <= 0 {
+		limit = 100
+	}
+	query.OrderBy(orderColumn, order).OrderBy("spanID", order).Limit(limit)
+	if queryParams.Cursor == nil && queryParams.Offset > 0 {
+		query.Offset(int(queryParams.Offset))
 	}
 
-	if len(queryParams.OperationName) != 0 {
+	var searchScanResponses []model.SearchSpanReponseItem
 
-		query = query + " AND name = ?"
-		args = append(args, queryParams.OperationName)
+	err := dao.selectAll(ctx, &searchScanResponses, query)
+	if err != nil {
+		return nil, err
+	}
 
+	var total int
+	err = dao.selectOne(ctx, &total, countQuery)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(queryParams.Kind) != 0 {
-		query = query + " AND kind = ?"
-		args = append(args, queryParams.Kind)
+	searchSpansResult := model.SearchSpansResult{
+		Columns: []string{"__time", "SpanId", "TraceId", "ServiceName", "Name", "Kind", "DurationNano", "TagsKeys", "TagsValues"},
+		Events:  make([][]interface{}, len(searchScanResponses)),
+	}
 
+	for i, item := range searchScanResponses {
+		spanEvents := item.GetValues()
+		searchSpansResult.Events[i] = spanEvents
 	}
 
-	if len(queryParams.MinDuration) != 0 {
-		query = query + " AND durationNano >= ?"
-		args = append(args, queryParams.MinDuration)
+	result := &model.TraceResult{
+		Data:   []interface{}{searchSpansResult},
+		Total:  total,
+		Limit:  limit,
+		Offset: int(queryParams.Offset),
 	}
-	if len(queryParams.MaxDuration) != 0 {
-		query = query + " AND durationNano <= ?"
-		args = append(args, queryParams.MaxDuration)
+	if len(searchScanResponses) == limit {
+		last := searchScanResponses[len(searchScanResponses)-1]
+		cursor := model.SpanSearchCursor{OrderParam: queryParams.OrderParam, Order: queryParams.Order, Value: last.DurationNano, SpanID: last.SpanID}
+		if orderColumn == "timestamp" {
+			timeObj, _ := time.Parse(time.RFC3339Nano, last.Timestamp)
+			cursor.Value = timeObj.UnixNano()
+		}
+		if len(cursor.OrderParam) == 0 {
+			cursor.OrderParam = model.SpanOrderParamTimestamp
+		}
+		if len(cursor.Order) == 0 {
+			cursor.Order = model.SpanOrderDescending
+		}
+		result.NextCursor = cursor.Encode()
 	}
 
+	return result, nil
+}
+
+// applySpanSearchFilters add time range, service, operation, kind, duration and tag filters of span search
+func applySpanSearchFilters(query *query_builder.SelectBuilder, queryParams *model.SpanSearchParams) error {
+	query.Where("timestamp >= ?", strconv.FormatInt(queryParams.Start.UnixNano(), 10)).
+		Where("timestamp <= ?", strconv.FormatInt(queryParams.End.UnixNano(), 10)).
+		WhereIf(len(queryParams.ServiceName) != 0, "serviceName = ?", queryParams.ServiceName).
+		WhereIf(len(queryParams.OperationName) != 0, "name = ?", queryParams.OperationName).
+		WhereIf(len(queryParams.Kind) != 0, "kind = ?", queryParams.Kind).
+		WhereIf(len(queryParams.MinDuration) != 0, "durationNano >= ?", queryParams.MinDuration).
+		WhereIf(len(queryParams.MaxDuration) != 0, "durationNano <= ?", queryParams.MaxDuration)
+
 	for _, item := range queryParams.Tags {
 
 		if item.Key == "error" && item.Value == "true" {
-			query = query + " AND ( has(tags, 'error:true') OR statusCode>=500 OR statusCode=2)"
+			query.Where(errorSpanCondition)
 			continue
 		}
 
 		if item.Operator == "equals" {
-			query = query + " AND has(tags, ?)"
-			args = append(args, fmt.Sprintf("%s:%s", item.Key, item.Value))
+			query.Where("has(tags, ?)", fmt.Sprintf("%s:%s", item.Key, item.Value))
 		} else if item.Operator == "contains" {
-			query = query + " AND tagsValues[indexOf(tagsKeys, ?)] ILIKE ?"
-			args = append(args, item.Key)
-			args = append(args, fmt.Sprintf("%%%s%%", item.Value))
+			query.Where("tagsValues[indexOf(tagsKeys, ?)] ILIKE ?", item.Key, fmt.Sprintf("%%%s%%", item.Value))
 		} else if item.Operator == "regex" {
-			query = query + " AND match(tagsValues[indexOf(tagsKeys, ?)], ?)"
-			args = append(args, item.Key)
-			args = append(args, item.Value)
+			query.Where("match(tagsValues[indexOf(tagsKeys, ?)], ?)", item.Key, item.Value)
 		} else if item.Operator == "isnotnull" {
-			query = query + " AND has(tagsKeys, ?)"
-			args = append(args, item.Key)
+			query.Where("has(tagsKeys, ?)", item.Key)
 		} else {
-			return nil, fmt.Errorf("tag Operator %s not supported", item.Operator)
+			return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("tag Operator %s not supported", item.Operator)}
 		}
 
 	}
+	return nil
+}
 
-	query = query + " ORDER BY timestamp DESC LIMIT 100"
+// GetServiceMetrics totals of service server spans in time range, used by alert rules
+func (dao *ApmDaoImpl) GetServiceMetrics(ctx context.Context, queryParams *model.GetServiceMetricsParams) (*model.ServiceMetrics, error) {
+	var metrics []model.ServiceMetrics
+	query := query_builder.Select("sum(count) as numCalls", "sumIf(count, statusCode >= 500 OR statusCode = 2) as numErrors",
+		"quantileMerge(0.99)(quantile) as p99").
+		From("signoz_index_aggregated").
+		Where("timestamp >= ?", convertNanosToSeconds(queryParams.Start)).
+		Where("timestamp <= ?", convertNanosToSeconds(queryParams.End)).
+		Where("kind = '2'").
+		Where("serviceName = ?", queryParams.ServiceName)
+	err := dao.selectAll(ctx, &metrics, query)
+	if err != nil {
+		return nil, err
+	}
 
-	var searchScanResponses []model.SearchSpanReponseItem
+	result := &model.ServiceMetrics{}
+	if len(metrics) > 0 && metrics[0].NumCalls > 0 {
+		*result = metrics[0]
+	}
+	if !queryParams.Apdex {
+		return result, nil
+	}
+
+	rollup := apdexRollup().
+		Where("serviceName = ?", queryParams.ServiceName).
+		Where("timestamp >= ?", convertNanosToSeconds(queryParams.Start)).
+		Where("timestamp <= ?", convertNanosToSeconds(queryParams.End))
+	spans := apdexSpans().
+		Where("serviceName = ?", queryParams.ServiceName).
+		Where("timestamp >= ?", strconv.FormatInt(queryParams.Start.UnixNano(), 10)).
+		Where("timestamp <= ?", strconv.FormatInt(queryParams.End.UnixNano(), 10))
+	apdexRows, err := dao.apdexScores(ctx, rollup, spans)
+	if err != nil {
+		return nil, err
+	}
+	score := model.ApdexScore{}
+	if len(apdexRows) > 0 {
+		score = apdexRows[0].score()
+	}
+	result.Apdex = &score
+	return result, nil
+}
 
-	err := dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &searchScanResponses, query, args)
+type sliEventsRow struct {
+	Good  []uint64 `db:"good"`
+	Total []uint64 `db:"total"`
+}
 
-	dao.Logger.Info(query)
+// GetSLIEvents good and total server spans of slo for every start in one scan of the rollup table.
+// Latency events come from latencyCounts, its first element counts all spans and the rest count spans within
+// LatencyThresholdsMillis, rows written before latencyCounts existed count as neither good nor total
+func (dao *ApmDaoImpl) GetSLIEvents(ctx context.Context, queryParams *model.GetSLIEventsParams) ([]model.SLIEvents, error) {
+	if len(queryParams.Starts) == 0 {
+		return []model.SLIEvents{}, nil
+	}
+	thresholdIndex := -1
+	for i, threshold := range model.LatencyThresholdsMillis {
+		if threshold == queryParams.LatencyThresholdMs {
+			thresholdIndex = i
+		}
+	}
+	if queryParams.SLIType == model.SLITypeLatency && thresholdIndex < 0 {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("latency threshold %d ms is not supported", queryParams.LatencyThresholdMs)}
+	}
+
+	earliest := queryParams.Starts[0]
+	good := make([]string, len(queryParams.Starts))
+	total := make([]string, len(queryParams.Starts))
+	var goodArgs, totalArgs []interface{}
+	for i, start := range queryParams.Starts {
+		if start.Before(earliest) {
+			earliest = start
+		}
+		if queryParams.SLIType == model.SLITypeLatency {
+			good[i] = fmt.Sprintf("sumForEachMergeIf(latencyCounts, timestamp >= ?)[%d]", thresholdIndex+2)
+			total[i] = "sumForEachMergeIf(latencyCounts, timestamp >= ?)[1]"
+		} else {
+			good[i] = "sumIf(count, timestamp >= ? AND NOT (statusCode >= 500 OR statusCode = 2))"
+			total[i] = "sumIf(count, timestamp >= ?)"
+		}
+		goodArgs = append(goodArgs, convertNanosToSeconds(&start))
+		totalArgs = append(totalArgs, convertNanosToSeconds(&start))
+	}
 
+	var rows []sliEventsRow
+	query := query_builder.Select().
+		Column("["+strings.Join(good, ", ")+"] as good", goodArgs...).
+		Column("["+strings.Join(total, ", ")+"] as total", totalArgs...).
+		From("signoz_index_aggregated").
+		Where("timestamp >= ?", convertNanosToSeconds(&earliest)).
+		Where("timestamp <= ?", convertNanosToSeconds(&queryParams.End)).
+		Where("kind = '2'").
+		Where("serviceName = ?", queryParams.ServiceName).
+		WhereIf(len(queryParams.Operation) > 0, "name = ?", queryParams.Operation)
+	err := dao.selectAll(ctx, &rows, query)
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
 	}
 
-	searchSpansResult := []model.SearchSpansResult{
-		{
-			Columns: []string{"__time", "SpanId", "TraceId", "ServiceName", "Name", "Kind", "DurationNano", "TagsKeys", "TagsValues"},
-			Events:  make([][]interface{}, len(searchScanResponses)),
-		},
+	events := make([]model.SLIEvents, len(queryParams.Starts))
+	if len(rows) > 0 {
+		for i := range events {
+			if i < len(rows[0].Good) && i < len(rows[0].Total) {
+				events[i] = model.SLIEvents{Good: rows[0].Good[i], Total: rows[0].Total[i]}
+			}
+		}
 	}
+	return events, nil
+}
 
-	for i, item := range searchScanResponses {
-		spanEvents := item.GetValues()
-		searchSpansResult[0].Events[i] = spanEvents
+type latencyBucketRow struct {
+	Time   string `db:"time"`
+	Bucket uint64 `db:"bucket"`
+	Count  uint64 `db:"count"`
+}
+
+// GetLatencyHistogram count spans matching span search filters in duration buckets, bucketing is done by clickhouse
+func (dao *ApmDaoImpl) GetLatencyHistogram(ctx context.Context, queryParams *model.GetLatencyHistogramParams) (*model.LatencyHistogram, error) {
+	query := query_builder.Select(latencyBucketExpression(queryParams.Boundaries)+" as bucket", "count() as count").
+		From("signoz_index_final").
+		GroupBy("bucket")
+	if err := applySpanSearchFilters(query, queryParams.Filters); err != nil {
+		return nil, err
 	}
 
-	return &searchSpansResult, nil
+	var rows []latencyBucketRow
+	err := dao.selectAll(ctx, &rows, query)
+	if err != nil {
+		return nil, err
+	}
+
+	boundaries := queryParams.Boundaries
+	result := &model.LatencyHistogram{Buckets: make([]model.LatencyBucket, len(boundaries)+1)}
+	for i := range result.Buckets {
+		if i > 0 {
+			result.Buckets[i].LowerBound = boundaries[i-1]
+		}
+		if i < len(boundaries) {
+			result.Buckets[i].UpperBound = boundaries[i]
+		}
+	}
+	for _, row := range rows {
+		result.Buckets[latencyBucketIndex(row.Bucket, len(boundaries))].Count += row.Count
+		result.Total += row.Count
+	}
+	return result, nil
 }
 
-func (dao *ApmDaoImpl) GetServiceDBOverview(ctx context.Context, queryParams *model.GetServiceOverviewParams) (*[]model.ServiceDBOverviewItem, error) {
-	var serviceDBOverviewItems []model.ServiceDBOverviewItem
+// GetLatencyHeatmap latency histogram per time step, steps without spans are left out
+func (dao *ApmDaoImpl) GetLatencyHeatmap(ctx context.Context, queryParams *model.GetLatencyHistogramParams) (*model.LatencyHeatmap, error) {
+	query := query_builder.Select(query_builder.StartOfInterval("timestamp", queryParams.StepSeconds/60)+" as time",
+		latencyBucketExpression(queryParams.Boundaries)+" as bucket", "count() as count").
+		From("signoz_index_final").
+		GroupBy("time", "bucket").
+		OrderBy("time", query_builder.Asc)
+	if err := applySpanSearchFilters(query, queryParams.Filters); err != nil {
+		return nil, err
+	}
+
+	var rows []latencyBucketRow
+	err := dao.selectAll(ctx, &rows, query)
+	if err != nil {
+		return nil, err
+	}
 
-	query := fmt.Sprintf("SELECT toStartOfInterval(timestamp, INTERVAL %s minute) as time, avgMerge(avg) as avgDuration, sum(count) as numCalls, dbSystem FROM signoz_index_aggregated WHERE serviceName='%s' AND timestamp>='%s' AND timestamp<='%s' AND kind='3' AND dbName IS NOT NULL GROUP BY time, dbSystem ORDER BY time DESC", strconv.Itoa(queryParams.StepSeconds/60), queryParams.ServiceName, convertNanosToSeconds(queryParams.Start), convertNanosToSeconds(queryParams.End))
+	result := &model.LatencyHeatmap{Boundaries: queryParams.Boundaries, Series: []model.LatencyHeatmapItem{}}
+	for _, row := range rows {
+		timeObj, _ := time.Parse(time.RFC3339Nano, row.Time)
+		if len(result.Series) == 0 || result.Series[len(result.Series)-1].Timestamp != timeObj.UnixNano() {
+			result.Series = append(result.Series, model.LatencyHeatmapItem{
+				Timestamp: timeObj.UnixNano(),
+				Counts:    make([]uint64, len(queryParams.Boundaries)+1),
+			})
+		}
+		result.Series[len(result.Series)-1].Counts[latencyBucketIndex(row.Bucket, len(queryParams.Boundaries))] += row.Count
+	}
+	return result, nil
+}
 
-	err := dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &serviceDBOverviewItems, query, nil)
+// latencyBucketExpression 1 based index of the first boundary above span duration, 0 when duration is above all of them.
+// Boundaries are validated integers, so they are rendered into query like interval values
+func latencyBucketExpression(boundaries []int64) string {
+	values := make([]string, len(boundaries))
+	for i, boundary := range boundaries {
+		values[i] = strconv.FormatInt(boundary, 10)
+	}
+	return "arrayFirstIndex(boundary -> durationNano < boundary, [" + strings.Join(values, ", ") + "])"
+}
+
+func latencyBucketIndex(bucket uint64, boundariesCount int) int {
+	if bucket == 0 || bucket > uint64(boundariesCount) {
+		return boundariesCount
+	}
+	return int(bucket) - 1
+}
 
-	dao.Logger.Info(query)
+func (dao *ApmDaoImpl) GetServiceDBOverview(ctx context.Context, queryParams *model.GetServiceOverviewParams) (*[]model.ServiceDBOverviewItem, error) {
+	var serviceDBOverviewItems []model.ServiceDBOverviewItem
 
+	query := query_builder.Select(query_builder.StartOfInterval("timestamp", queryParams.StepSeconds/60)+" as time", "avgMerge(avg) as avgDuration", "sum(count) as numCalls", "dbSystem").
+		From("signoz_index_aggregated").
+		Where("serviceName = ?", queryParams.ServiceName).
+		Where("timestamp >= ?", convertNanosToSeconds(queryParams.Start)).
+		Where("timestamp <= ?", convertNanosToSeconds(queryParams.End)).
+		Where("kind = '3'").
+		Where("dbName IS NOT NULL").
+		GroupBy("time", "dbSystem").
+		OrderBy("time", query_builder.Desc)
+
+	err := dao.selectAll(ctx, &serviceDBOverviewItems, query)
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
 	}
 
 	for i := range serviceDBOverviewItems {
@@ -279,106 +577,135 @@ func (dao *ApmDaoImpl) GetServiceDBOverview(ctx context.Context, queryParams *mo
 	return &serviceDBOverviewItems, nil
 }
 
-func (dao *ApmDaoImpl) GetServiceExternalAvgDuration(ctx context.Context, queryParams *model.GetServiceOverviewParams) (*[]model.ServiceExternalItem, error) {
-	var serviceExternalItems []model.ServiceExternalItem
-
-	query := fmt.Sprintf("SELECT toStartOfInterval(timestamp, INTERVAL %s minute) as time, avgMerge(avg) as avgDuration FROM signoz_index_aggregated WHERE serviceName='%s' AND timestamp>='%s' AND timestamp<='%s' AND kind='3' AND externalHttpUrl IS NOT NULL GROUP BY time ORDER BY time DESC", strconv.Itoa(queryParams.StepSeconds/60), queryParams.ServiceName, convertNanosToSeconds(queryParams.Start), convertNanosToSeconds(queryParams.End))
-
-	err := dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &serviceExternalItems, query, nil)
-
-	dao.Logger.Info(query)
-
+const dbStatementExpression = "tagsValues[indexOf(tagsKeys, 'db.statement')]"
+const dbFingerprintExpression = "lower(hex(normalizedQueryHash(" + dbStatementExpression + ")))"
+const dbSlowSamplesLimit = 10
+
+// GetDBStatements db client calls of service grouped by system, database, operation and statement fingerprint.
+// Calls, latency and errors are read from signoz_db_statements_aggregated rollup of all spans, statements are
+// normalized by clickhouse normalizeQuery so calls differing only in literals share fingerprint. Slowest traces and
+// slow samples link to traces, so they are read from sampled signoz_index_final where the traces are kept
+func (dao *ApmDaoImpl) GetDBStatements(ctx context.Context, queryParams *model.GetDBStatementsParams) (*model.DBStatementsResult, error) {
+	result := &model.DBStatementsResult{Statements: []model.DBStatementItem{}, SlowSamples: []model.DBStatementSample{}}
+
+	statementsQuery := query_builder.Select("system", "database", "operation", "fingerprint", "any(statement) as statement",
+		"sum(count) as numCalls", "quantileMerge(0.50)(quantile) as p50", "quantileMerge(0.95)(quantile) as p95", "quantileMerge(0.99)(quantile) as p99",
+		"sum(errorCount) / sum(count) as errorRate").
+		From("signoz_db_statements_aggregated").
+		Where("serviceName = ?", queryParams.ServiceName).
+		Where("timestamp >= ?", convertNanosToSeconds(queryParams.Start)).
+		Where("timestamp <= ?", convertNanosToSeconds(queryParams.End)).
+		WhereIf(len(queryParams.DBSystem) != 0, "system = ?", queryParams.DBSystem).
+		WhereIf(len(queryParams.DBName) != 0, "database = ?", queryParams.DBName).
+		WhereIf(len(queryParams.DBOperation) != 0, "operation = ?", queryParams.DBOperation).
+		GroupBy("system", "database", "operation", "fingerprint").
+		OrderBy("numCalls", query_builder.Desc).
+		OrderBy("fingerprint", query_builder.Asc).
+		Limit(queryParams.Limit)
+	err := dao.selectAll(ctx, &result.Statements, statementsQuery)
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
 	}
 
-	for i := range serviceExternalItems {
-		timeObj, _ := time.Parse(time.RFC3339Nano, serviceExternalItems[i].Time)
-		serviceExternalItems[i].Timestamp = timeObj.UnixNano()
-		serviceExternalItems[i].Time = ""
-		serviceExternalItems[i].CallRate = float32(serviceExternalItems[i].NumCalls) / float32(queryParams.StepSeconds)
+	sampledSpans := func(query *query_builder.SelectBuilder) *query_builder.SelectBuilder {
+		return query.From("signoz_index_final").
+			Where("serviceName = ?", queryParams.ServiceName).
+			Where("timestamp >= ?", strconv.FormatInt(queryParams.Start.UnixNano(), 10)).
+			Where("timestamp <= ?", strconv.FormatInt(queryParams.End.UnixNano(), 10)).
+			Where("kind = 3").
+			Where("dbSystem IS NOT NULL").
+			WhereIf(len(queryParams.DBSystem) != 0, "dbSystem = ?", queryParams.DBSystem).
+			WhereIf(len(queryParams.DBName) != 0, "dbName = ?", queryParams.DBName).
+			WhereIf(len(queryParams.DBOperation) != 0, "dbOperation = ?", queryParams.DBOperation)
 	}
 
-	if serviceExternalItems == nil {
-		serviceExternalItems = []model.ServiceExternalItem{}
+	var slowestTraces []model.DBStatementItem
+	slowestQuery := sampledSpans(query_builder.Select("coalesce(dbSystem, '') as system", "coalesce(dbName, '') as database",
+		"coalesce(dbOperation, '') as operation", dbFingerprintExpression+" as fingerprint", "argMax(traceID, durationNano) as slowestTraceID")).
+		GroupBy("system", "database", "operation", "fingerprint")
+	err = dao.selectAll(ctx, &slowestTraces, slowestQuery)
+	if err != nil {
+		return nil, err
 	}
 
-	return &serviceExternalItems, nil
-}
-
-func (dao *ApmDaoImpl) GetServiceExternalErrors(ctx context.Context, queryParams *model.GetServiceOverviewParams) (*[]model.ServiceExternalItem, error) {
-	var serviceExternalErrorItems []model.ServiceExternalItem
-
-	query := fmt.Sprintf("SELECT toStartOfInterval(timestamp, INTERVAL %s minute) as time, avgMerge(avg) as avgDuration, sum(count) as numCalls, externalHttpUrl FROM signoz_index_aggregated WHERE serviceName='%s' AND timestamp>='%s' AND timestamp<='%s' AND kind='3' AND externalHttpUrl IS NOT NULL AND (statusCode >= 500 OR statusCode=2) GROUP BY time, externalHttpUrl ORDER BY time DESC", strconv.Itoa(queryParams.StepSeconds/60), queryParams.ServiceName, convertNanosToSeconds(queryParams.Start), convertNanosToSeconds(queryParams.End))
-
-	err := dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &serviceExternalErrorItems, query, nil)
-
-	dao.Logger.Info(query)
-
+	samplesQuery := sampledSpans(query_builder.Select("toUnixTimestamp64Nano(timestamp) as timestampNano", "traceID", "spanID",
+		"coalesce(dbSystem, '') as system", "coalesce(dbName, '') as database", dbFingerprintExpression+" as fingerprint",
+		dbStatementExpression+" as statement", "durationNano")).
+		OrderBy("durationNano", query_builder.Desc).
+		Limit(dbSlowSamplesLimit)
+	err = dao.selectAll(ctx, &result.SlowSamples, samplesQuery)
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
 	}
-	var serviceExternalTotalItems []model.ServiceExternalItem
 
-	queryTotal := fmt.Sprintf("SELECT toStartOfInterval(timestamp, INTERVAL %s minute) as time, avg(durationNano) as avgDuration, count(1) as numCalls, externalHttpUrl FROM signoz_index_final WHERE serviceName='%s' AND timestamp>='%s' AND timestamp<='%s' AND kind='3' AND externalHttpUrl IS NOT NULL GROUP BY time, externalHttpUrl ORDER BY time DESC", strconv.Itoa(queryParams.StepSeconds/60), queryParams.ServiceName, strconv.FormatInt(queryParams.Start.UnixNano(), 10), strconv.FormatInt(queryParams.End.UnixNano(), 10))
-
-	errTotal := dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &serviceExternalTotalItems, queryTotal, nil)
-
-	if errTotal != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+	slowestTraceIDs := make(map[string]string, len(slowestTraces))
+	for _, item := range slowestTraces {
+		slowestTraceIDs[dbStatementKey(item)] = item.SlowestTraceID
 	}
-
-	m := make(map[string]int)
-
-	for j, _ := range serviceExternalErrorItems {
-		timeObj, _ := time.Parse(time.RFC3339Nano, serviceExternalErrorItems[j].Time)
-		m[strconv.FormatInt(timeObj.UnixNano(), 10)+"-"+serviceExternalErrorItems[j].ExternalHttpUrl] = serviceExternalErrorItems[j].NumCalls
+	period := queryParams.Period
+	if period < 1 {
+		period = 1
 	}
-
-	for i, _ := range serviceExternalTotalItems {
-		timeObj, _ := time.Parse(time.RFC3339Nano, serviceExternalTotalItems[i].Time)
-		serviceExternalTotalItems[i].Timestamp = int64(timeObj.UnixNano())
-		serviceExternalTotalItems[i].Time = ""
-		// serviceExternalTotalItems[i].CallRate = float32(serviceExternalTotalItems[i].NumCalls) / float32(queryParams.StepSeconds)
-
-		if val, ok := m[strconv.FormatInt(serviceExternalTotalItems[i].Timestamp, 10)+"-"+serviceExternalTotalItems[i].ExternalHttpUrl]; ok {
-			serviceExternalTotalItems[i].NumErrors = val
-			serviceExternalTotalItems[i].ErrorRate = float32(serviceExternalTotalItems[i].NumErrors) * 100 / float32(serviceExternalTotalItems[i].NumCalls)
-		}
-		serviceExternalTotalItems[i].CallRate = 0
-		serviceExternalTotalItems[i].NumCalls = 0
-
+	for i := range result.Statements {
+		result.Statements[i].CallRate = float64(result.Statements[i].NumCalls) / float64(period)
+		result.Statements[i].SlowestTraceID = slowestTraceIDs[dbStatementKey(result.Statements[i])]
 	}
-
-	if serviceExternalTotalItems == nil {
-		serviceExternalTotalItems = []model.ServiceExternalItem{}
+	if result.Statements == nil {
+		result.Statements = []model.DBStatementItem{}
 	}
-
-	return &serviceExternalTotalItems, nil
+	if result.SlowSamples == nil {
+		result.SlowSamples = []model.DBStatementSample{}
+	}
+	return result, nil
 }
 
-func (dao *ApmDaoImpl) GetServiceExternal(ctx context.Context, queryParams *model.GetServiceOverviewParams) (*[]model.ServiceExternalItem, error) {
-	var serviceExternalItems []model.ServiceExternalItem
+func dbStatementKey(item model.DBStatementItem) string {
+	return strings.Join([]string{item.DBSystem, item.DBName, item.DBOperation, item.Fingerprint}, "\x00")
+}
 
-	query := fmt.Sprintf("SELECT toStartOfInterval(timestamp, INTERVAL %s minute) as time, avgMerge(avg) as avgDuration, sum(count) as numCalls, externalHttpUrl FROM signoz_index_aggregated WHERE serviceName='%s' AND timestamp>='%s' AND timestamp<='%s' AND kind='3' AND externalHttpUrl IS NOT NULL GROUP BY time, externalHttpUrl ORDER BY time DESC", strconv.Itoa(queryParams.StepSeconds/60), queryParams.ServiceName, convertNanosToSeconds(queryParams.Start), convertNanosToSeconds(queryParams.End))
+// externalHostExpression host of external call, domain of http url for spans rolled up before externalHost was filled
+const externalHostExpression = "coalesce(externalHost, domain(coalesce(externalHttpUrl, '')))"
 
-	err := dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &serviceExternalItems, query, nil)
+// GetServiceExternal calls, errors and latency of client and producer spans to external hosts or urls per time bucket.
+// Everything is read from signoz_index_aggregated rollup of all spans so totals and errors always come from the same spans,
+// db client calls are excluded as they are covered by db statements
+func (dao *ApmDaoImpl) GetServiceExternal(ctx context.Context, queryParams *model.GetServiceExternalParams) (*[]model.ServiceExternalItem, error) {
+	var serviceExternalItems []model.ServiceExternalItem
 
-	dao.Logger.Info(query)
+	query := query_builder.Select(query_builder.StartOfInterval("timestamp", queryParams.StepSeconds/60)+" as time", externalHostExpression+" as host",
+		"sum(count) as numCalls", "sumIf(count, statusCode >= 500 OR statusCode = 2) as numErrors", "avgMerge(avg) as avgDuration",
+		"quantileMerge(0.50)(quantile) as p50", "quantileMerge(0.95)(quantile) as p95", "quantileMerge(0.99)(quantile) as p99")
+	if queryParams.GroupBy == model.ExternalGroupURL {
+		query.Columns("coalesce(externalHttpUrl, '') as externalHttpUrl")
+	}
+	query.From("signoz_index_aggregated").
+		Where("serviceName = ?", queryParams.ServiceName).
+		Where("timestamp >= ?", convertNanosToSeconds(queryParams.Start)).
+		Where("timestamp <= ?", convertNanosToSeconds(queryParams.End)).
+		Where("kind IN ('3', '4')").
+		Where("dbSystem IS NULL").
+		Where(externalHostExpression + " != ''")
+	if queryParams.GroupBy == model.ExternalGroupURL {
+		query.GroupBy("time", "host", "externalHttpUrl")
+	} else {
+		query.GroupBy("time", "host")
+	}
+	query.OrderBy("time", query_builder.Desc).
+		OrderBy("numCalls", query_builder.Desc)
 
+	err := dao.selectAll(ctx, &serviceExternalItems, query)
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
 	}
 
-	for i, _ := range serviceExternalItems {
+	for i := range serviceExternalItems {
 		timeObj, _ := time.Parse(time.RFC3339Nano, serviceExternalItems[i].Time)
-		serviceExternalItems[i].Timestamp = int64(timeObj.UnixNano())
+		serviceExternalItems[i].Timestamp = timeObj.UnixNano()
 		serviceExternalItems[i].Time = ""
 		serviceExternalItems[i].CallRate = float32(serviceExternalItems[i].NumCalls) / float32(queryParams.StepSeconds)
+		if serviceExternalItems[i].NumCalls > 0 {
+			serviceExternalItems[i].ErrorRate = float32(serviceExternalItems[i].NumErrors) * 100 / float32(serviceExternalItems[i].NumCalls)
+		}
 	}
 
 	if serviceExternalItems == nil {
@@ -391,15 +718,46 @@ func (dao *ApmDaoImpl) GetServiceExternal(ctx context.Context, queryParams *mode
 func (dao *ApmDaoImpl) GetTopEndpoints(ctx context.Context, queryParams *model.GetTopEndpointsParams) (*[]model.TopEndpointsItem, error) {
 	var topEndpointsItems []model.TopEndpointsItem
 
-	query := fmt.Sprintf("SELECT quantileMerge(0.5)(quantile) as p50, quantileMerge(0.95)(quantile) as p95, quantileMerge(0.99)(quantile) as p99, sum(count) as numCalls, name  FROM signoz_index_aggregated WHERE  timestamp >= '%s' AND timestamp <= '%s' AND  kind='2' and serviceName='%s' GROUP BY name", convertNanosToSeconds(queryParams.Start), convertNanosToSeconds(queryParams.End), queryParams.ServiceName)
-
-	err := dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &topEndpointsItems, query, nil)
+	query := query_builder.Select("quantileMerge(0.5)(quantile) as p50", "quantileMerge(0.95)(quantile) as p95", "quantileMerge(0.99)(quantile) as p99", "sum(count) as numCalls",
+		"sumIf(count, statusCode >= 500 OR statusCode = 2) as numErrors", "name").
+		From("signoz_index_aggregated").
+		Where("timestamp >= ?", convertNanosToSeconds(queryParams.Start)).
+		Where("timestamp <= ?", convertNanosToSeconds(queryParams.End)).
+		Where("kind = '2'").
+		Where("serviceName = ?", queryParams.ServiceName).
+		GroupBy("name")
 
-	dao.Logger.Info(query)
+	err := dao.selectAll(ctx, &topEndpointsItems, query)
+	if err != nil {
+		return nil, err
+	}
 
+	rollup := apdexRollup("name").
+		Where("serviceName = ?", queryParams.ServiceName).
+		Where("timestamp >= ?", convertNanosToSeconds(queryParams.Start)).
+		Where("timestamp <= ?", convertNanosToSeconds(queryParams.End))
+	spans := apdexSpans("name").
+		Where("serviceName = ?", queryParams.ServiceName).
+		Where("timestamp >= ?", strconv.FormatInt(queryParams.Start.UnixNano(), 10)).
+		Where("timestamp <= ?", strconv.FormatInt(queryParams.End.UnixNano(), 10))
+	apdexRows, err := dao.apdexScores(ctx, rollup, spans, "name")
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
+	}
+	apdexByName := make(map[string]model.ApdexScore, len(apdexRows))
+	for _, row := range apdexRows {
+		apdexByName[row.Name] = row.score()
+	}
+	period := queryParams.End.Unix() - queryParams.Start.Unix()
+	if period < 1 {
+		period = 1
+	}
+	for i := range topEndpointsItems {
+		topEndpointsItems[i].ApdexScore = apdexByName[topEndpointsItems[i].Name]
+		topEndpointsItems[i].CallRate = float32(topEndpointsItems[i].NumCalls) / float32(period)
+		if topEndpointsItems[i].NumCalls > 0 {
+			topEndpointsItems[i].ErrorRate = float32(topEndpointsItems[i].NumErrors) * 100 / float32(topEndpointsItems[i].NumCalls)
+		}
 	}
 
 	if topEndpointsItems == nil {
@@ -409,23 +767,140 @@ func (dao *ApmDaoImpl) GetTopEndpoints(ctx context.Context, queryParams *model.G
 	return &topEndpointsItems, nil
 }
 
-func (dao *ApmDaoImpl) GetUsage(ctx context.Context, queryParams *model.GetUsageParams) (*[]model.UsageItem, error) {
-	var usageItems []model.UsageItem
+const errorSpanCondition = "(has(tags, 'error:true') OR statusCode >= 500 OR statusCode = 2)"
+
+// apdexRow apdex counts of one group, group columns not selected by the query stay empty. Tolerable spans are
+// satisfied or tolerating
+type apdexRow struct {
+	ServiceName string  `db:"serviceName"`
+	Name        string  `db:"name"`
+	Time        string  `db:"time"`
+	Threshold   float64 `db:"apdexThreshold"`
+	Satisfied   uint64  `db:"satisfied"`
+	Tolerable   uint64  `db:"tolerable"`
+	Total       uint64  `db:"total"`
+}
 
-	var query string
-	if len(queryParams.ServiceName) != 0 {
-		query = fmt.Sprintf("SELECT toStartOfInterval(timestamp, INTERVAL %d HOUR) as time, count(1) as count FROM signoz_index_final WHERE serviceName='%s' AND timestamp>='%s' AND timestamp<='%s' GROUP BY time ORDER BY time ASC", queryParams.StepHour, queryParams.ServiceName, strconv.FormatInt(queryParams.Start.UnixNano(), 10), strconv.FormatInt(queryParams.End.UnixNano(), 10))
-	} else {
-		query = fmt.Sprintf("SELECT toStartOfInterval(timestamp, INTERVAL %d HOUR) as time, count(1) as count FROM signoz_index_final WHERE timestamp>='%s' AND timestamp<='%s' GROUP BY time ORDER BY time ASC", queryParams.StepHour, strconv.FormatInt(queryParams.Start.UnixNano(), 10), strconv.FormatInt(queryParams.End.UnixNano(), 10))
+// score of row
+func (row apdexRow) score() model.ApdexScore {
+	var tolerating uint64
+	if row.Tolerable > row.Satisfied {
+		tolerating = row.Tolerable - row.Satisfied
+	}
+	return model.NewApdexScore(row.Threshold, row.Satisfied, tolerating, row.Total)
+}
+
+// apdexRollup server span rows of rollup table for apdexScores, caller adds group columns and filters
+func apdexRollup(columns ...string) *query_builder.SelectBuilder {
+	return query_builder.Select(columns...).
+		Columns("serviceName", "statusCode", "finalizeAggregation(latencyCounts) as latencies").
+		From("signoz_index_aggregated").
+		Where("kind = '2'")
+}
+
+// apdexSpans server spans of raw table for apdexScores, caller adds the same group columns and filters as to apdexRollup
+func apdexSpans(columns ...string) *query_builder.SelectBuilder {
+	return query_builder.Select(columns...).
+		Columns("serviceName", "statusCode", "durationNano").
+		From("signoz_index_final").
+		Where("kind = 2")
+}
+
+// rollupApdexThresholds apdex thresholds in seconds which latencyCounts of the rollup table counts both threshold and
+// 4 times threshold for
+func rollupApdexThresholds() []float64 {
+	var thresholds []float64
+	for _, threshold := range model.LatencyThresholdsMillis {
+		if latencyCountPosition(4*threshold) > 0 {
+			thresholds = append(thresholds, float64(threshold)/1000)
+		}
 	}
+	return thresholds
+}
+
+// apdexScores apdex per service and group columns, threshold of service is read from apdex_settings joined in clickhouse,
+// services without setting use DefaultApdexThreshold. Services with threshold in rollupApdexThresholds are counted from
+// latencyCounts, its first element counts all spans and the rest count spans within LatencyThresholdsMillis, rows
+// written before the counts of threshold existed are skipped. Services with any other threshold are counted from raw spans
+func (dao *ApmDaoImpl) apdexScores(ctx context.Context, rollup *query_builder.SelectBuilder, spans *query_builder.SelectBuilder,
+	groupBy ...string) ([]apdexRow, error) {
+	thresholds := rollupApdexThresholds()
+	values := make([]string, len(thresholds))
+	satisfied := make([]string, len(thresholds))
+	tolerable := make([]string, len(thresholds))
+	for i, threshold := range thresholds {
+		millis := int64(math.Round(threshold * 1000))
+		values[i] = strconv.FormatFloat(threshold, 'g', -1, 64)
+		satisfied[i] = strconv.Itoa(latencyCountPosition(millis))
+		tolerable[i] = strconv.Itoa(latencyCountPosition(4 * millis))
+	}
+	rollupThresholds := "(" + strings.Join(values, ", ") + ")"
+	thresholdIndex := "indexOf([" + strings.Join(values, ", ") + "], apdexThreshold)"
+	satisfiedPosition := "[" + strings.Join(satisfied, ", ") + "][" + thresholdIndex + "]"
+	tolerablePosition := "[" + strings.Join(tolerable, ", ") + "][" + thresholdIndex + "]"
+
+	group := append([]string{"serviceName"}, groupBy...)
+	rollupQuery := query_builder.Select(group...).
+		Columns(fmt.Sprintf("if(threshold > 0, threshold, %g) as apdexThreshold", model.DefaultApdexThreshold),
+			"sumIf(latencies["+satisfiedPosition+"], NOT (statusCode >= 500 OR statusCode = 2)) as satisfied",
+			"sumIf(latencies["+tolerablePosition+"], NOT (statusCode >= 500 OR statusCode = 2)) as tolerable",
+			"sum(latencies[1]) as total").
+		FromSubquery(rollup, "rollup").
+		LeftJoin(query_builder.Select("serviceName", "threshold").From("apdex_settings").Final(), "settings", "serviceName").
+		Where("apdexThreshold IN " + rollupThresholds).
+		Where("length(latencies) >= greatest(" + satisfiedPosition + ", " + tolerablePosition + ")").
+		GroupBy(append(group, "apdexThreshold")...)
+
+	var rows []apdexRow
+	err := dao.selectAll(ctx, &rows, rollupQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	// IN lets clickhouse skip spans of other services by primary key
+	otherThresholds := query_builder.Select("serviceName", "threshold").From("apdex_settings").Final().
+		Where("threshold NOT IN " + rollupThresholds)
+	spansQuery := query_builder.Select(group...).
+		Columns("any(threshold) as apdexThreshold",
+			"countIf(NOT (statusCode >= 500 OR statusCode = 2) AND durationNano <= threshold * 1000000000) as satisfied",
+			"countIf(NOT (statusCode >= 500 OR statusCode = 2) AND durationNano <= 4 * threshold * 1000000000) as tolerable",
+			"count() as total").
+		FromSubquery(spans.Where("serviceName IN (SELECT serviceName FROM apdex_settings FINAL WHERE threshold NOT IN "+rollupThresholds+")"), "spans").
+		InnerJoin(otherThresholds, "settings", "serviceName").
+		GroupBy(group...)
+
+	var spanRows []apdexRow
+	err = dao.selectAll(ctx, &spanRows, spansQuery)
+	if err != nil {
+		return nil, err
+	}
+	return append(rows, spanRows...), nil
+}
 
-	err := dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &usageItems, query, nil)
+// latencyCountPosition 1-based position in latencyCounts of spans within millis, 0 when the latency is not counted
+func latencyCountPosition(millis int64) int {
+	for i, threshold := range model.LatencyThresholdsMillis {
+		if threshold == millis {
+			return i + 2
+		}
+	}
+	return 0
+}
 
-	dao.Logger.Info(query)
+func (dao *ApmDaoImpl) GetUsage(ctx context.Context, queryParams *model.GetUsageParams) (*[]model.UsageItem, error) {
+	var usageItems []model.UsageItem
 
+	query := query_builder.Select(query_builder.StartOfInterval("timestamp", queryParams.StepHour*60)+" as time", "count(1) as count").
+		From("signoz_index_final").
+		WhereIf(len(queryParams.ServiceName) != 0, "serviceName = ?", queryParams.ServiceName).
+		Where("timestamp >= ?", strconv.FormatInt(queryParams.Start.UnixNano(), 10)).
+		Where("timestamp <= ?", strconv.FormatInt(queryParams.End.UnixNano(), 10)).
+		GroupBy("time").
+		OrderBy("time", query_builder.Asc)
+
+	err := dao.selectAll(ctx, &usageItems, query)
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
 	}
 
 	for i := range usageItems {
@@ -444,48 +919,83 @@ func (dao *ApmDaoImpl) GetUsage(ctx context.Context, queryParams *model.GetUsage
 func (dao *ApmDaoImpl) GetOperations(ctx context.Context, serviceName string) (*[]string, error) {
 	var operations []string
 
-	query := fmt.Sprintf(`SELECT DISTINCT(name) FROM signoz_index_aggregated WHERE serviceName='%s'  AND toDate(timestamp) > now() - INTERVAL 1 DAY`, serviceName)
-
-	err := dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &operations, query, nil)
-
-	dao.Logger.Info(query)
+	query := query_builder.Select("DISTINCT(name)").
+		From("signoz_index_aggregated").
+		Where("serviceName = ?", serviceName).
+		Where("toDate(timestamp) > now() - INTERVAL 1 DAY")
 
+	err := dao.selectAll(ctx, &operations, query)
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
 	}
 	return &operations, nil
 }
 
+// GetTags tag keys of service spans of the last day with number of spans having the key. Counted from spans as tagsKeys
+// of a rollup row are keys of any of its spans
 func (dao *ApmDaoImpl) GetTags(ctx context.Context, serviceName string) (*[]model.TagItem, error) {
 	var tagItems []model.TagItem
 
-	query := fmt.Sprintf(`SELECT DISTINCT arrayJoin(tagsKeys) as tagKeys FROM signoz_index_aggregated WHERE serviceName='%s'  AND toDate(timestamp) > now() - INTERVAL 1 DAY`, serviceName)
-
-	err := dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &tagItems, query, nil)
-
-	dao.Logger.Info(query)
+	query := query_builder.Select("arrayJoin(tagsKeys) as tagKeys", "toInt64(count()) as tagCount").
+		From("signoz_index_final").
+		Where("serviceName = ?", serviceName).
+		Where("timestamp > now() - INTERVAL 1 DAY").
+		GroupBy("tagKeys").
+		OrderBy("tagCount", query_builder.Desc)
 
+	err := dao.selectAll(ctx, &tagItems, query)
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
 	}
 
 	return &tagItems, nil
 }
 
-func (dao *ApmDaoImpl) SearchTraces(ctx context.Context, traceID string) (*[]model.SearchSpansResult, error) {
-	var searchScanResponses []model.SearchSpanReponseItem
+// GetTagValues top values of tag key by number of spans, prefix match is case insensitive
+func (dao *ApmDaoImpl) GetTagValues(ctx context.Context, queryParams *model.GetTagValuesParams) (*model.TagValuesResult, error) {
+	result := &model.TagValuesResult{Key: queryParams.Key, Values: []model.TagValueItem{}}
+
+	applyFilters := func(query *query_builder.SelectBuilder) *query_builder.SelectBuilder {
+		return query.From("signoz_index_final").
+			Where("timestamp >= ?", strconv.FormatInt(queryParams.Start.UnixNano(), 10)).
+			Where("timestamp <= ?", strconv.FormatInt(queryParams.End.UnixNano(), 10)).
+			Where("has(tagsKeys, ?)", queryParams.Key).
+			WhereIf(len(queryParams.ServiceName) != 0, "serviceName = ?", queryParams.ServiceName).
+			WhereIf(len(queryParams.OperationName) != 0, "name = ?", queryParams.OperationName)
+	}
 
-	query := fmt.Sprintf("SELECT timestamp, spanID, traceID, serviceName, name, kind, durationNano, tagsKeys, tagsValues, references FROM signoz_index_final WHERE traceID='%s'", traceID)
+	valuesQuery := applyFilters(query_builder.Select("count() as count").
+		Column("tagsValues[indexOf(tagsKeys, ?)] as tagValue", queryParams.Key)).
+		WhereIf(len(queryParams.Prefix) != 0, "startsWith(lowerUTF8(tagsValues[indexOf(tagsKeys, ?)]), lowerUTF8(?))", queryParams.Key, queryParams.Prefix).
+		GroupBy("tagValue").
+		OrderBy("count", query_builder.Desc).
+		OrderBy("tagValue", query_builder.Asc).
+		Limit(queryParams.Limit)
+	err := dao.selectAll(ctx, &result.Values, valuesQuery)
+	if err != nil {
+		return nil, err
+	}
 
-	err := dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &searchScanResponses, query, nil)
+	var cardinality struct {
+		Cardinality uint64 `db:"cardinality"`
+		SpanCount   uint64 `db:"spanCount"`
+	}
+	cardinalityQuery := applyFilters(query_builder.Select("count() as spanCount").
+		Column("uniq(tagsValues[indexOf(tagsKeys, ?)]) as cardinality", queryParams.Key))
+	err = dao.selectOne(ctx, &cardinality, cardinalityQuery)
+	if err != nil {
+		return nil, err
+	}
+	result.Cardinality = cardinality.Cardinality
+	result.SpanCount = cardinality.SpanCount
 
-	dao.Logger.Info(query)
+	return result, nil
+}
 
+func (dao *ApmDaoImpl) SearchTraces(ctx context.Context, traceID string) (*[]model.SearchSpansResult, error) {
+	searchScanResponses, err := dao.GetTraceSpans(ctx, traceID)
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
 	}
 
 	searchSpansResult := []model.SearchSpansResult{
@@ -503,116 +1013,412 @@ func (dao *ApmDaoImpl) SearchTraces(ctx context.Context, traceID string) (*[]mod
 	return &searchSpansResult, nil
 }
 
-func (dao *ApmDaoImpl) GetServiceMapDependencies(ctx context.Context, queryParams *model.GetServicesParams) (*[]model.ServiceMapDependencyResponseItem, error) {
-	var serviceMapDependencyItems []model.ServiceMapDependencyItem
+type traceListRow struct {
+	TraceID         string `db:"traceID"`
+	StartTime       string `db:"startTime"`
+	MaxDurationNano int64  `db:"maxDurationNano"`
+}
 
-	query := fmt.Sprintf(`SELECT spanID, parentSpanID, serviceName FROM signoz_index_final WHERE timestamp>='%s' AND timestamp<='%s'`, strconv.FormatInt(queryParams.Start.UnixNano(), 10), strconv.FormatInt(queryParams.End.UnixNano(), 10))
+// GetTraceSpans return all spans of trace with their references
+func (dao *ApmDaoImpl) GetTraceSpans(ctx context.Context, traceID string) ([]model.SearchSpanReponseItem, error) {
+	var searchScanResponses []model.SearchSpanReponseItem
 
-	err := dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &serviceMapDependencyItems, query, nil)
+	query := query_builder.Select("timestamp", "spanID", "traceID", "serviceName", "name", "kind", "durationNano", "tagsKeys", "tagsValues", "references").
+		From("signoz_index_final").
+		Where("traceID = ?", traceID)
 
-	zap.S().Info(query)
+	err := dao.selectAll(ctx, &searchScanResponses, query)
+	if err != nil {
+		return nil, err
+	}
+	return searchScanResponses, nil
+}
+
+// SearchTraceList return page of traces having at least one span matching the filters, every trace is
+// summarised from all of its spans, not only the matching ones
+func (dao *ApmDaoImpl) SearchTraceList(ctx context.Context, queryParams *model.SpanSearchParams) (*model.TraceResult, error) {
+	query := query_builder.Select("traceID", "min(timestamp) as startTime", "max(durationNano) as maxDurationNano").
+		From("signoz_index_final")
+	countQuery := query_builder.Select("uniqExact(traceID) as total").
+		From("signoz_index_final")
+	if err := applySpanSearchFilters(query, queryParams); err != nil {
+		return nil, err
+	}
+	_ = applySpanSearchFilters(countQuery, queryParams)
 
+	orderColumn := "startTime"
+	if queryParams.OrderParam == model.SpanOrderParamDuration {
+		orderColumn = "maxDurationNano"
+	}
+	order := query_builder.Desc
+	if queryParams.Order == model.SpanOrderAscending {
+		order = query_builder.Asc
+	}
+	limit := int(queryParams.Limit)
+	if limit <= 0 {
+		limit = 100
+	}
+	query.GroupBy("traceID").OrderBy(orderColumn, order).OrderBy("traceID", order).Limit(limit)
+	if queryParams.Offset > 0 {
+		query.Offset(int(queryParams.Offset))
+	}
+
+	var traceIDRows []traceListRow
+	err := dao.selectAll(ctx, &traceIDRows, query)
 	if err != nil {
-		zap.S().Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		return nil, err
 	}
 
-	serviceMap := make(map[string]*model.ServiceMapDependencyResponseItem)
+	var total int
+	err = dao.selectOne(ctx, &total, countQuery)
+	if err != nil {
+		return nil, err
+	}
 
-	spanId2ServiceNameMap := make(map[string]string)
-	for i, _ := range serviceMapDependencyItems {
-		spanId2ServiceNameMap[serviceMapDependencyItems[i].SpanId] = serviceMapDependencyItems[i].ServiceName
+	result := &model.TraceResult{
+		Data:   []interface{}{},
+		Total:  total,
+		Limit:  limit,
+		Offset: int(queryParams.Offset),
 	}
-	for i, _ := range serviceMapDependencyItems {
-		parent2childServiceName := spanId2ServiceNameMap[serviceMapDependencyItems[i].ParentSpanId] + "-" + spanId2ServiceNameMap[serviceMapDependencyItems[i].SpanId]
-		if _, ok := serviceMap[parent2childServiceName]; !ok {
-			serviceMap[parent2childServiceName] = &model.ServiceMapDependencyResponseItem{
-				Parent:    spanId2ServiceNameMap[serviceMapDependencyItems[i].ParentSpanId],
-				Child:     spanId2ServiceNameMap[serviceMapDependencyItems[i].SpanId],
-				CallCount: 1,
-			}
-		} else {
-			serviceMap[parent2childServiceName].CallCount++
-		}
+	if len(traceIDRows) == 0 {
+		return result, nil
 	}
 
-	retMe := make([]model.ServiceMapDependencyResponseItem, 0, len(serviceMap))
-	for _, dependency := range serviceMap {
-		if dependency.Parent == "" {
-			continue
+	traceIDs := make([]string, len(traceIDRows))
+	for i, row := range traceIDRows {
+		traceIDs[i] = row.TraceID
+	}
+	var spans []model.TraceResultSpan
+	spansQuery := query_builder.Select("timestamp", "spanID", "traceID", "parentSpanID", "serviceName", "name", "kind", "durationNano", "statusCode", "tagsKeys", "tagsValues").
+		From("signoz_index_final").
+		Where("traceID IN (?)", traceIDs)
+	err = dao.selectAll(ctx, &spans, spansQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	spansByTrace := make(map[string][]model.TraceResultSpan, len(traceIDs))
+	for _, span := range spans {
+		spansByTrace[span.TraceID] = append(spansByTrace[span.TraceID], span)
+	}
+	for _, traceID := range traceIDs {
+		result.Data = append(result.Data, summariseTrace(traceID, spansByTrace[traceID]))
+	}
+	return result, nil
+}
+
+// summariseTrace build trace list row, root is the span without parent or the earliest span
+// when root span is missing (not yet received or dropped)
+func summariseTrace(traceID string, spans []model.TraceResultSpan) model.TraceResultItem {
+	item := model.TraceResultItem{TraceID: traceID, SpanCount: len(spans), ServiceNames: []string{}}
+	var root *model.TraceResultSpan
+	var rootStart, end int64
+	services := map[string]bool{}
+	for i := range spans {
+		span := &spans[i]
+		timeObj, _ := time.Parse(time.RFC3339Nano, span.Timestamp)
+		start := timeObj.UnixNano()
+		if i == 0 || start < item.StartTime {
+			item.StartTime = start
+		}
+		if start+span.DurationNano > end {
+			end = start + span.DurationNano
+		}
+		isRoot := len(span.ParentSpanID) == 0
+		currentIsRoot := root != nil && len(root.ParentSpanID) == 0
+		if root == nil || (isRoot && !currentIsRoot) || (isRoot == currentIsRoot && start < rootStart) {
+			root = span
+			rootStart = start
+		}
+		if span.IsError() {
+			item.ErrorCount++
 		}
-		retMe = append(retMe, *dependency)
+		if !services[span.ServiceName] {
+			services[span.ServiceName] = true
+			item.ServiceNames = append(item.ServiceNames, span.ServiceName)
+		}
+	}
+	if root != nil {
+		item.RootServiceName = root.ServiceName
+		item.RootOperationName = root.Name
+		item.DurationNano = end - item.StartTime
+	}
+	sort.Strings(item.ServiceNames)
+	return item
+}
+
+// GetServiceMapDependencies edges between services from service_dependencies rollup of all spans, read with FINAL as
+// a batch aggregated twice leaves duplicate rows until merged. Spans calling the same service are kept as self edges
+func (dao *ApmDaoImpl) GetServiceMapDependencies(ctx context.Context, queryParams *model.GetServiceMapParams) (*[]model.ServiceMapDependencyResponseItem, error) {
+	serviceMapDependencyItems := []model.ServiceMapDependencyResponseItem{}
+
+	period := queryParams.Period
+	if period < 1 {
+		period = 1
+	}
+	query := query_builder.Select("parentServiceName as parent", "serviceName as child", "sum(count) as callCount",
+		"quantileMerge(0.50)(quantile) as p50", "quantileMerge(0.99)(quantile) as p99",
+		"sum(errorCount) / sum(count) as errorRate",
+		fmt.Sprintf("sum(count) / %d as callRate", period)).
+		From("service_dependencies").
+		Final().
+		Where("timestamp >= ?", convertNanosToSeconds(queryParams.Start)).
+		Where("timestamp <= ?", convertNanosToSeconds(queryParams.End)).
+		WhereIf(len(queryParams.Environment) != 0, "environment = ?", queryParams.Environment).
+		GroupBy("parent", "child").
+		OrderBy("callCount", query_builder.Desc)
+
+	err := dao.selectAll(ctx, &serviceMapDependencyItems, query)
+	if err != nil {
+		return nil, err
 	}
 
-	return &retMe, nil
+	return &serviceMapDependencyItems, nil
 }
 
+// spanAggregation aggregate expression over signoz_index_aggregated rollup and over raw spans,
+// aggregations without rollup expression are always computed from raw spans
+type spanAggregation struct {
+	rollup string
+	raw    string
+}
+
+var spanAggregations = map[string]spanAggregation{
+	"count":          {rollup: "sum(count)", raw: "count()"},
+	"rate_per_sec":   {rollup: "sum(count)", raw: "count()"},
+	"count_distinct": {raw: "uniq(traceID)"},
+	"avg":            {rollup: "avgMerge(avg)", raw: "avg(durationNano)"},
+	"min":            {raw: "min(durationNano)"},
+	"max":            {raw: "max(durationNano)"},
+	"p50":            {rollup: "quantileMerge(0.50)(quantile)", raw: "quantile(0.50)(durationNano)"},
+	"p90":            {rollup: "quantileMerge(0.90)(quantile)", raw: "quantile(0.90)(durationNano)"},
+	"p95":            {rollup: "quantileMerge(0.95)(quantile)", raw: "quantile(0.95)(durationNano)"},
+	"p99":            {rollup: "quantileMerge(0.99)(quantile)", raw: "quantile(0.99)(durationNano)"},
+}
+
+// SearchSpansAggregate aggregates are read from signoz_index_aggregated rollup, rollup doesn't keep tags and
+// durations of single spans so tag and duration filtered aggregates are computed from signoz_index_final
 func (dao *ApmDaoImpl) SearchSpansAggregate(ctx context.Context, queryParams *model.SpanSearchAggregatesParams) ([]model.SpanSearchAggregatesResponseItem, error) {
 	var spanSearchAggregatesResponseItems []model.SpanSearchAggregatesResponseItem
 
-	aggregationQuery := ""
-	if queryParams.Dimension == "duration" {
-		switch queryParams.AggregationOption {
-		case "p50":
-			aggregationQuery = " quantileMerge(0.50)(quantile) as value "
-			break
+	query, _, err := spanAggregatesQuery(queryParams)
+	if err != nil {
+		return nil, err
+	}
+	query.GroupBy("time").OrderBy("time", query_builder.Asc)
 
-		case "p95":
-			aggregationQuery = " quantileMerge(0.95)(quantile) as value "
-			break
+	err = dao.selectAll(ctx, &spanSearchAggregatesResponseItems, query)
+	if err != nil {
+		return nil, err
+	}
 
-		case "p99":
-			aggregationQuery = " quantileMerge(0.99)(quantile) as value "
-			break
-		}
-	} else if queryParams.Dimension == "calls" {
-		aggregationQuery = " sum(count) as value "
+	for i := range spanSearchAggregatesResponseItems {
+		setAggregateTimestamp(&spanSearchAggregatesResponseItems[i], queryParams)
 	}
 
-	query := fmt.Sprintf("SELECT toStartOfInterval(timestamp, INTERVAL %d minute) as time, %s FROM signoz_index_aggregated WHERE timestamp >= ? AND timestamp <= ?", queryParams.StepSeconds/60, aggregationQuery)
+	return spanSearchAggregatesResponseItems, nil
+}
+
+type spanAggregatesTopGroup struct {
+	Value float32 `db:"value"`
+	Group string  `db:"groupKey"`
+}
 
-	args := []interface{}{convertNanosToSeconds(queryParams.Start), convertNanosToSeconds(queryParams.End)}
+type spanAggregatesGroupRow struct {
+	Time    string  `db:"time"`
+	Value   float32 `db:"value"`
+	Group   string  `db:"groupKey"`
+	IsOther uint8   `db:"isOther"`
+}
 
-	if len(queryParams.ServiceName) != 0 {
-		query = query + " AND serviceName = ?"
-		args = append(args, queryParams.ServiceName)
+// SearchSpansAggregateGroups series per group, top GroupLimit groups are picked by aggregated value over the whole
+// time range and all other groups are merged into one other series
+func (dao *ApmDaoImpl) SearchSpansAggregateGroups(ctx context.Context, queryParams *model.SpanSearchAggregatesParams) ([]model.SpanSearchAggregatesSeries, error) {
+	topQuery, raw, err := spanAggregatesTotalQuery(queryParams)
+	if err != nil {
+		return nil, err
+	}
+	groupExpression, groupArgs, err := spanAggregatesGroupExpression(queryParams.GroupBy, raw)
+	if err != nil {
+		return nil, err
+	}
+	topQuery.Column(groupExpression+" as groupKey", groupArgs...).
+		GroupBy("groupKey").
+		OrderBy("value", query_builder.Desc).
+		OrderBy("groupKey", query_builder.Asc).
+		Limit(queryParams.GroupLimit)
+
+	var topGroups []spanAggregatesTopGroup
+	err = dao.selectAll(ctx, &topGroups, topQuery)
+	if err != nil {
+		return nil, err
+	}
+	if len(topGroups) == 0 {
+		return []model.SpanSearchAggregatesSeries{}, nil
 	}
 
-	if len(queryParams.OperationName) != 0 {
+	groupValues := make([]string, len(topGroups))
+	for i, group := range topGroups {
+		groupValues[i] = group.Group
+	}
+	query, _, err := spanAggregatesQuery(queryParams)
+	if err != nil {
+		return nil, err
+	}
+	query.Column(fmt.Sprintf("if(%s IN (?), %s, '') as groupKey", groupExpression, groupExpression), append(append(append([]interface{}{}, groupArgs...), groupValues), groupArgs...)...).
+		Column(fmt.Sprintf("%s NOT IN (?) as isOther", groupExpression), append(append([]interface{}{}, groupArgs...), groupValues)...).
+		GroupBy("time", "groupKey", "isOther").
+		OrderBy("time", query_builder.Asc)
 
-		query = query + " AND name = ?"
-		args = append(args, queryParams.OperationName)
+	var rows []spanAggregatesGroupRow
+	err = dao.selectAll(ctx, &rows, query)
+	if err != nil {
+		return nil, err
+	}
 
+	series := make([]model.SpanSearchAggregatesSeries, len(groupValues))
+	seriesIndex := make(map[string]int, len(groupValues))
+	for i, group := range groupValues {
+		series[i] = model.SpanSearchAggregatesSeries{Group: group, Items: []model.SpanSearchAggregatesResponseItem{}}
+		seriesIndex[group] = i
+	}
+	other := model.SpanSearchAggregatesSeries{Group: "other", Other: true, Items: []model.SpanSearchAggregatesResponseItem{}}
+	for _, row := range rows {
+		item := model.SpanSearchAggregatesResponseItem{Time: row.Time, Value: row.Value}
+		setAggregateTimestamp(&item, queryParams)
+		if row.IsOther == 1 {
+			other.Items = append(other.Items, item)
+		} else if i, ok := seriesIndex[row.Group]; ok {
+			series[i].Items = append(series[i].Items, item)
+		}
 	}
+	if len(other.Items) > 0 {
+		series = append(series, other)
+	}
+	return series, nil
+}
+
+// spanAggregatesQuery time series query with time and value columns, returned flag is true when raw spans are queried
+func spanAggregatesQuery(queryParams *model.SpanSearchAggregatesParams) (*query_builder.SelectBuilder, bool, error) {
+	return spanAggregatesBaseQuery(queryParams, query_builder.StartOfInterval("timestamp", queryParams.StepSeconds/60)+" as time")
+}
 
-	if len(queryParams.Kind) != 0 {
-		query = query + " AND kind = ?"
-		args = append(args, queryParams.Kind)
+// spanAggregatesTotalQuery value aggregated over whole time range
+func spanAggregatesTotalQuery(queryParams *model.SpanSearchAggregatesParams) (*query_builder.SelectBuilder, bool, error) {
+	return spanAggregatesBaseQuery(queryParams)
+}
 
+func spanAggregatesBaseQuery(queryParams *model.SpanSearchAggregatesParams, columns ...string) (*query_builder.SelectBuilder, bool, error) {
+	aggregation, ok := spanAggregations[queryParams.AggregationOption]
+	if !ok {
+		return nil, false, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("aggregation option %s not supported", queryParams.AggregationOption)}
+	}
+	raw := len(aggregation.rollup) == 0 || len(queryParams.Tags) != 0 || len(queryParams.MinDuration) != 0 || len(queryParams.MaxDuration) != 0 ||
+		strings.HasPrefix(queryParams.GroupBy, model.AggregateGroupTagPrefix)
+
+	if !raw {
+		query := query_builder.Select(append(columns, aggregation.rollup+" as value")...).
+			From("signoz_index_aggregated").
+			Where("timestamp >= ?", convertNanosToSeconds(queryParams.Start)).
+			Where("timestamp <= ?", convertNanosToSeconds(queryParams.End)).
+			WhereIf(len(queryParams.ServiceName) != 0, "serviceName = ?", queryParams.ServiceName).
+			WhereIf(len(queryParams.OperationName) != 0, "name = ?", queryParams.OperationName).
+			WhereIf(len(queryParams.Kind) != 0, "kind = ?", queryParams.Kind)
+		return query, false, nil
 	}
 
-	query = query + " GROUP BY time ORDER BY time"
+	query := query_builder.Select(append(columns, aggregation.raw+" as value")...).
+		From("signoz_index_final")
+	err := applySpanSearchFilters(query, &model.SpanSearchParams{
+		ServiceName:   queryParams.ServiceName,
+		OperationName: queryParams.OperationName,
+		Kind:          queryParams.Kind,
+		MinDuration:   queryParams.MinDuration,
+		MaxDuration:   queryParams.MaxDuration,
+		Tags:          queryParams.Tags,
+		Start:         queryParams.Start,
+		End:           queryParams.End,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if strings.HasPrefix(queryParams.GroupBy, model.AggregateGroupTagPrefix) {
+		query.Where("has(tagsKeys, ?)", strings.TrimPrefix(queryParams.GroupBy, model.AggregateGroupTagPrefix))
+	}
+	return query, true, nil
+}
 
-	err := dao.ClickhouseConnectionService.ExecuteSelectFunction(true, &spanSearchAggregatesResponseItems, query, args)
+// spanAggregatesGroupExpression group column expression with its args, tag groups are only available on raw spans
+func spanAggregatesGroupExpression(groupBy string, raw bool) (string, []interface{}, error) {
+	switch {
+	case groupBy == model.AggregateGroupService:
+		return "serviceName", nil, nil
+	case groupBy == model.AggregateGroupOperation:
+		return "name", nil, nil
+	case groupBy == model.AggregateGroupStatusCode:
+		return "toString(statusCode)", nil, nil
+	case strings.HasPrefix(groupBy, model.AggregateGroupTagPrefix) && raw:
+		return "tagsValues[indexOf(tagsKeys, ?)]", []interface{}{strings.TrimPrefix(groupBy, model.AggregateGroupTagPrefix)}, nil
+	}
+	return "", nil, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("groupBy %s not supported", groupBy)}
+}
 
-	dao.Logger.Info(query)
+func setAggregateTimestamp(item *model.SpanSearchAggregatesResponseItem, queryParams *model.SpanSearchAggregatesParams) {
+	timeObj, _ := time.Parse(time.RFC3339Nano, item.Time)
+	item.Timestamp = int64(timeObj.UnixNano())
+	item.Time = ""
+	if queryParams.AggregationOption == "rate_per_sec" {
+		item.Value = float32(item.Value) / float32(queryParams.StepSeconds)
+	}
+}
 
+// selectAll build query and select all rows into dest, query is cancelled with ctx
+func (dao *ApmDaoImpl) selectAll(ctx context.Context, dest interface{}, builder *query_builder.SelectBuilder) error {
+	return dao.selectQuery(ctx, true, dest, builder)
+}
+
+// selectOne build query and select single row into dest
+func (dao *ApmDaoImpl) selectOne(ctx context.Context, dest interface{}, builder *query_builder.SelectBuilder) error {
+	return dao.selectQuery(ctx, false, dest, builder)
+}
+
+func (dao *ApmDaoImpl) selectQuery(ctx context.Context, isArray bool, dest interface{}, builder *query_builder.SelectBuilder) error {
+	return runSelect(ctx, dao.ClickhouseConnectionService, dao.Logger, isArray, dest, builder)
+}
+
+// runSelect build and run select query, errors are logged and mapped to api errors
+func runSelect(ctx context.Context, connectionService clickhouse.ClickhouseConnectionService, logger *zap.SugaredLogger,
+	isArray bool, dest interface{}, builder *query_builder.SelectBuilder) error {
+	query, args, err := builder.Build()
 	if err != nil {
-		dao.Logger.Debug("Error in processing sql query: ", err)
-		return nil, fmt.Errorf("error in processing sql query")
+		logger.Debug("Error in building sql query: ", err)
+		return &model.ApiError{Typ: model.ErrorInternal, Err: fmt.Errorf("error in building sql query")}
 	}
 
-	for i := range spanSearchAggregatesResponseItems {
+	err = connectionService.ExecuteSelectFunctionContext(ctx, isArray, dest, query, args)
 
-		timeObj, _ := time.Parse(time.RFC3339Nano, spanSearchAggregatesResponseItems[i].Time)
-		spanSearchAggregatesResponseItems[i].Timestamp = int64(timeObj.UnixNano())
-		spanSearchAggregatesResponseItems[i].Time = ""
-		if queryParams.AggregationOption == "rate_per_sec" {
-			spanSearchAggregatesResponseItems[i].Value = float32(spanSearchAggregatesResponseItems[i].Value) / float32(queryParams.StepSeconds)
-		}
+	logger.Info(query)
+
+	if err != nil {
+		logger.Debug("Error in processing sql query: ", err)
+		return queryError(err)
 	}
+	return nil
+}
 
-	return spanSearchAggregatesResponseItems, nil
+// queryError map clickhouse errors to api errors, query details are only logged and hidden behind generic message
+func queryError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &model.ApiError{Typ: model.ErrorTimeout, Err: fmt.Errorf("query timed out")}
+	}
+	if errors.Is(err, context.Canceled) {
+		return &model.ApiError{Typ: model.ErrorCanceled, Err: fmt.Errorf("query was canceled")}
+	}
+	if errors.Is(err, clickhouse.ErrUnavailable) {
+		return &model.ApiError{Typ: model.ErrorUnavailable, Err: fmt.Errorf("storage is unavailable")}
+	}
+	return &model.ApiError{Typ: model.ErrorExec, Err: fmt.Errorf("error in processing sql query")}
 }
 
 func convertNanosToSeconds(timeToParse *time.Time) string {
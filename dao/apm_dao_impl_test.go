@@ -0,0 +1,596 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"goapm/clickhouse"
+	model "goapm/domain"
+	"goapm/logger"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var hostileValues = []string{
+	"frontend' OR '1'='1",
+	"x'; DROP TABLE signoz_index_final; --",
+	`\' UNION SELECT * FROM system.users --`,
+	"?",
+}
+
+type capturedQuery struct {
+	Query string
+	Args  []interface{}
+}
+
+func newApmDaoTest() (*ApmDaoImpl, *[]capturedQuery) {
+	var queries []capturedQuery
+	clickhouseConnectionMock := new(clickhouse.MockClickhouseConnectionService)
+	clickhouseConnectionMock.On("ExecuteSelectFunctionContext", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			queries = append(queries, capturedQuery{Query: args.String(3), Args: args.Get(4).([]interface{})})
+		})
+	return &ApmDaoImpl{
+		Logger:                      logger.LOGGER,
+		ClickhouseConnectionService: clickhouseConnectionMock,
+	}, &queries
+}
+
+// assertSameShape run call with a benign and with hostile values, generated sql must be identical
+// and hostile value must only be passed as bound arg
+func assertSameShape(t *testing.T, call func(dao *ApmDaoImpl, value string)) {
+	benignDao, benignQueries := newApmDaoTest()
+	call(benignDao, "frontend")
+	assert.NotEmpty(t, *benignQueries)
+
+	for _, hostileValue := range hostileValues {
+		hostileDao, hostileQueries := newApmDaoTest()
+		call(hostileDao, hostileValue)
+		assert.Equal(t, len(*benignQueries), len(*hostileQueries))
+		for i := range *hostileQueries {
+			hostileQuery := (*hostileQueries)[i]
+			assert.Equal(t, (*benignQueries)[i].Query, hostileQuery.Query)
+			assert.False(t, strings.Contains(hostileQuery.Query, hostileValue) && hostileValue != "?")
+			assert.Contains(t, hostileQuery.Args, hostileValue)
+		}
+	}
+}
+
+func TestApmDaoQueriesKeepShapeWithHostileInput(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	end := start.Add(time.Hour)
+	ctx := context.Background()
+	overviewParams := func(serviceName string) *model.GetServiceOverviewParams {
+		return &model.GetServiceOverviewParams{Start: &start, End: &end, ServiceName: serviceName, StepSeconds: 60}
+	}
+
+	assertSameShape(t, func(dao *ApmDaoImpl, value string) {
+		_, _ = dao.GetServiceOverview(ctx, overviewParams(value))
+	})
+	assertSameShape(t, func(dao *ApmDaoImpl, value string) {
+		_, _ = dao.GetServiceDBOverview(ctx, overviewParams(value))
+	})
+	assertSameShape(t, func(dao *ApmDaoImpl, value string) {
+		_, _ = dao.GetServiceExternal(ctx, &model.GetServiceExternalParams{Start: &start, End: &end, ServiceName: value, StepSeconds: 60,
+			GroupBy: model.ExternalGroupURL})
+	})
+	assertSameShape(t, func(dao *ApmDaoImpl, value string) {
+		_, _ = dao.GetTopEndpoints(ctx, &model.GetTopEndpointsParams{Start: &start, End: &end, ServiceName: value})
+	})
+	assertSameShape(t, func(dao *ApmDaoImpl, value string) {
+		_, _ = dao.GetUsage(ctx, &model.GetUsageParams{Start: &start, End: &end, ServiceName: value, StepHour: 1})
+	})
+	assertSameShape(t, func(dao *ApmDaoImpl, value string) {
+		_, _ = dao.GetOperations(ctx, value)
+	})
+	assertSameShape(t, func(dao *ApmDaoImpl, value string) {
+		_, _ = dao.GetTags(ctx, value)
+	})
+	assertSameShape(t, func(dao *ApmDaoImpl, value string) {
+		_, _ = dao.SearchTraces(ctx, value)
+	})
+	assertSameShape(t, func(dao *ApmDaoImpl, value string) {
+		_, _ = dao.SearchSpans(ctx, &model.SpanSearchParams{Start: &start, End: &end, ServiceName: value, OperationName: value,
+			Tags: []model.TagQuery{{Key: value, Value: "1", Operator: "regex"}}})
+	})
+	assertSameShape(t, func(dao *ApmDaoImpl, value string) {
+		_, _ = dao.GetTagValues(ctx, &model.GetTagValuesParams{Start: &start, End: &end, Key: value, Prefix: value, ServiceName: value, Limit: 10})
+	})
+	assertSameShape(t, func(dao *ApmDaoImpl, value string) {
+		_, _ = dao.SearchTraceList(ctx, &model.SpanSearchParams{Start: &start, End: &end, ServiceName: value,
+			Tags: []model.TagQuery{{Key: value, Value: value, Operator: "contains"}}})
+	})
+	assertSameShape(t, func(dao *ApmDaoImpl, value string) {
+		_, _ = dao.SearchSpansAggregate(ctx, &model.SpanSearchAggregatesParams{Start: &start, End: &end, ServiceName: value,
+			Dimension: "calls", AggregationOption: "count", StepSeconds: 60})
+	})
+}
+
+func TestSearchSpansRejectsUnknownTagOperator(t *testing.T) {
+	classUnderTest, queries := newApmDaoTest()
+	start := time.Unix(1600000000, 0)
+	_, err := classUnderTest.SearchSpans(context.Background(), &model.SpanSearchParams{Start: &start, End: &start,
+		Tags: []model.TagQuery{{Key: "http.method", Value: "GET", Operator: "'; DROP TABLE x"}}})
+	assert.NotNil(t, err)
+	assert.Empty(t, *queries)
+}
+
+func TestApmDaoTimeoutIsReportedAsApiError(t *testing.T) {
+	clickhouseConnectionMock := new(clickhouse.MockClickhouseConnectionService)
+	clickhouseConnectionMock.On("ExecuteSelectFunctionContext", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(fmt.Errorf("couldn't execute select method, %w", context.DeadlineExceeded))
+	classUnderTest := &ApmDaoImpl{Logger: logger.LOGGER, ClickhouseConnectionService: clickhouseConnectionMock}
+
+	_, err := classUnderTest.GetOperations(context.Background(), "frontend")
+	var apiError *model.ApiError
+	assert.True(t, errors.As(err, &apiError))
+	assert.Equal(t, model.ErrorTimeout, apiError.Typ)
+}
+
+func newSearchSpansTest(rows []model.SearchSpanReponseItem, total int) (*ApmDaoImpl, *[]capturedQuery) {
+	var queries []capturedQuery
+	clickhouseConnectionMock := new(clickhouse.MockClickhouseConnectionService)
+	clickhouseConnectionMock.On("ExecuteSelectFunctionContext", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			queries = append(queries, capturedQuery{Query: args.String(3), Args: args.Get(4).([]interface{})})
+			switch dest := args.Get(2).(type) {
+			case *[]model.SearchSpanReponseItem:
+				*dest = rows
+			case *int:
+				*dest = total
+			}
+		})
+	return &ApmDaoImpl{Logger: logger.LOGGER, ClickhouseConnectionService: clickhouseConnectionMock}, &queries
+}
+
+func TestSearchSpansPagination(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	rows := []model.SearchSpanReponseItem{
+		{Timestamp: "2020-09-13T12:26:40.5Z", SpanID: "b", DurationNano: 300},
+		{Timestamp: "2020-09-13T12:26:40.1Z", SpanID: "a", DurationNano: 200},
+	}
+	classUnderTest, queries := newSearchSpansTest(rows, 7)
+
+	result, err := classUnderTest.SearchSpans(context.Background(), &model.SpanSearchParams{Start: &start, End: &start, Limit: 2, Offset: 4,
+		Order: model.SpanOrderAscending, OrderParam: model.SpanOrderParamDuration})
+	assert.Nil(t, err)
+	assert.Equal(t, 7, result.Total)
+	assert.Equal(t, 2, result.Limit)
+	assert.Equal(t, 4, result.Offset)
+	assert.True(t, strings.HasSuffix((*queries)[0].Query, "ORDER BY durationNano ASC, spanID ASC LIMIT 2 OFFSET 4"))
+	assert.True(t, strings.HasPrefix((*queries)[1].Query, "SELECT count() as total FROM signoz_index_final WHERE"))
+
+	cursor, err := model.DecodeSpanSearchCursor(result.NextCursor)
+	assert.Nil(t, err)
+	assert.Equal(t, model.SpanSearchCursor{OrderParam: model.SpanOrderParamDuration, Order: model.SpanOrderAscending, Value: 200, SpanID: "a"}, *cursor)
+}
+
+func TestSearchSpansCursor(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	classUnderTest, queries := newSearchSpansTest([]model.SearchSpanReponseItem{{Timestamp: "2020-09-13T12:26:40Z", SpanID: "c"}}, 3)
+	cursor := &model.SpanSearchCursor{OrderParam: model.SpanOrderParamTimestamp, Order: model.SpanOrderDescending, Value: 1600000000000000000, SpanID: "d"}
+
+	result, err := classUnderTest.SearchSpans(context.Background(), &model.SpanSearchParams{Start: &start, End: &start, Limit: 10,
+		Order: model.SpanOrderDescending, OrderParam: model.SpanOrderParamTimestamp, Cursor: cursor})
+	assert.Nil(t, err)
+	assert.Empty(t, result.NextCursor)
+	assert.Contains(t, (*queries)[0].Query, "(timestamp < fromUnixTimestamp64Nano(?) OR (timestamp = fromUnixTimestamp64Nano(?) AND spanID < ?)) ORDER BY timestamp DESC, spanID DESC LIMIT 10")
+	assert.Equal(t, []interface{}{int64(1600000000000000000), int64(1600000000000000000), "d"}, (*queries)[0].Args[2:])
+	assert.NotContains(t, (*queries)[1].Query, "spanID <")
+}
+
+func TestSearchTraceList(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	var queries []capturedQuery
+	clickhouseConnectionMock := new(clickhouse.MockClickhouseConnectionService)
+	clickhouseConnectionMock.On("ExecuteSelectFunctionContext", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			queries = append(queries, capturedQuery{Query: args.String(3), Args: args.Get(4).([]interface{})})
+			switch dest := args.Get(2).(type) {
+			case *[]traceListRow:
+				*dest = []traceListRow{{TraceID: "t2"}, {TraceID: "t1"}}
+			case *int:
+				*dest = 2
+			case *[]model.TraceResultSpan:
+				*dest = []model.TraceResultSpan{
+					{TraceID: "t1", SpanID: "c", ParentSpanID: "missing", ServiceName: "redis", Name: "GET", Timestamp: "2020-09-13T12:26:40.1Z", DurationNano: 50},
+					{TraceID: "t2", SpanID: "b", ParentSpanID: "a", ServiceName: "redis", Name: "GET", Timestamp: "2020-09-13T12:26:40.2Z", DurationNano: 900000000, StatusCode: 2},
+					{TraceID: "t2", SpanID: "a", ServiceName: "frontend", Name: "HTTP GET /", Timestamp: "2020-09-13T12:26:40.1Z", DurationNano: 500000000},
+					{TraceID: "t1", SpanID: "d", ParentSpanID: "c", ServiceName: "api", Name: "query", Timestamp: "2020-09-13T12:26:40Z", DurationNano: 10,
+						TagsKeys: []string{"error"}, TagsValues: []string{"true"}},
+				}
+			}
+		})
+	classUnderTest := &ApmDaoImpl{Logger: logger.LOGGER, ClickhouseConnectionService: clickhouseConnectionMock}
+
+	result, err := classUnderTest.SearchTraceList(context.Background(), &model.SpanSearchParams{Start: &start, End: &start, Limit: 2,
+		OrderParam: model.SpanOrderParamDuration})
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(queries[0].Query, "GROUP BY traceID ORDER BY maxDurationNano DESC, traceID DESC LIMIT 2"))
+	assert.Equal(t, []string{"t2", "t1"}, queries[2].Args[0])
+	assert.Equal(t, 2, result.Total)
+
+	spanStart := time.Date(2020, 9, 13, 12, 26, 40, 100000000, time.UTC).UnixNano()
+	assert.Equal(t, []interface{}{
+		model.TraceResultItem{TraceID: "t2", RootServiceName: "frontend", RootOperationName: "HTTP GET /", StartTime: spanStart,
+			DurationNano: 1000000000, SpanCount: 2, ErrorCount: 1, ServiceNames: []string{"frontend", "redis"}},
+		model.TraceResultItem{TraceID: "t1", RootServiceName: "api", RootOperationName: "query", StartTime: spanStart - 100000000,
+			DurationNano: 100000050, SpanCount: 2, ErrorCount: 1, ServiceNames: []string{"api", "redis"}},
+	}, result.Data)
+}
+
+func TestSearchSpansAggregateTable(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	end := start.Add(time.Hour)
+	ctx := context.Background()
+
+	classUnderTest, queries := newApmDaoTest()
+	_, err := classUnderTest.SearchSpansAggregate(ctx, &model.SpanSearchAggregatesParams{Start: &start, End: &end, ServiceName: "frontend",
+		Dimension: "duration", AggregationOption: "p95", StepSeconds: 60})
+	assert.Nil(t, err)
+	assert.Contains(t, (*queries)[0].Query, "quantileMerge(0.95)(quantile) as value FROM signoz_index_aggregated WHERE")
+
+	classUnderTest, queries = newApmDaoTest()
+	_, err = classUnderTest.SearchSpansAggregate(ctx, &model.SpanSearchAggregatesParams{Start: &start, End: &end, ServiceName: "frontend",
+		Dimension: "duration", AggregationOption: "p95", StepSeconds: 60, MinDuration: "1000",
+		Tags: []model.TagQuery{{Key: "http.status_code", Value: "500", Operator: "equals"}, {Key: "error", Value: "true", Operator: "equals"}}})
+	assert.Nil(t, err)
+	query := (*queries)[0]
+	assert.Contains(t, query.Query, "quantile(0.95)(durationNano) as value FROM signoz_index_final WHERE")
+	assert.Contains(t, query.Query, "durationNano >= ?")
+	assert.Contains(t, query.Query, "has(tags, ?)")
+	assert.Contains(t, query.Query, "(has(tags, 'error:true') OR statusCode >= 500 OR statusCode = 2)")
+	assert.Contains(t, query.Args, "http.status_code:500")
+	assert.Contains(t, query.Args, strconv.FormatInt(start.UnixNano(), 10))
+
+	_, err = classUnderTest.SearchSpansAggregate(ctx, &model.SpanSearchAggregatesParams{Start: &start, End: &end, Dimension: "calls",
+		AggregationOption: "count", StepSeconds: 60, Tags: []model.TagQuery{{Key: "a", Value: "b", Operator: "startswith"}}})
+	var apiError *model.ApiError
+	assert.True(t, errors.As(err, &apiError))
+	assert.Equal(t, model.ErrorBadData, apiError.Typ)
+}
+
+func TestSearchSpansAggregateOptions(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	ctx := context.Background()
+	expected := map[string]string{
+		"avg":            "avgMerge(avg) as value FROM signoz_index_aggregated",
+		"p90":            "quantileMerge(0.90)(quantile) as value FROM signoz_index_aggregated",
+		"min":            "min(durationNano) as value FROM signoz_index_final",
+		"max":            "max(durationNano) as value FROM signoz_index_final",
+		"count_distinct": "uniq(traceID) as value FROM signoz_index_final",
+	}
+	for option, expectedQuery := range expected {
+		classUnderTest, queries := newApmDaoTest()
+		_, err := classUnderTest.SearchSpansAggregate(ctx, &model.SpanSearchAggregatesParams{Start: &start, End: &start, AggregationOption: option, StepSeconds: 60})
+		assert.Nil(t, err)
+		assert.Contains(t, (*queries)[0].Query, expectedQuery, option)
+	}
+}
+
+func TestSearchSpansAggregateGroups(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	var queries []capturedQuery
+	clickhouseConnectionMock := new(clickhouse.MockClickhouseConnectionService)
+	clickhouseConnectionMock.On("ExecuteSelectFunctionContext", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			queries = append(queries, capturedQuery{Query: args.String(3), Args: args.Get(4).([]interface{})})
+			switch dest := args.Get(2).(type) {
+			case *[]spanAggregatesTopGroup:
+				*dest = []spanAggregatesTopGroup{{Group: "GET", Value: 10}, {Group: "POST", Value: 5}}
+			case *[]spanAggregatesGroupRow:
+				*dest = []spanAggregatesGroupRow{
+					{Time: "2020-09-13T12:26:00Z", Group: "POST", Value: 1},
+					{Time: "2020-09-13T12:26:00Z", Group: "", IsOther: 1, Value: 3},
+					{Time: "2020-09-13T12:27:00Z", Group: "GET", Value: 2},
+				}
+			}
+		})
+	classUnderTest := &ApmDaoImpl{Logger: logger.LOGGER, ClickhouseConnectionService: clickhouseConnectionMock}
+
+	series, err := classUnderTest.SearchSpansAggregateGroups(context.Background(), &model.SpanSearchAggregatesParams{Start: &start, End: &start,
+		AggregationOption: "rate_per_sec", StepSeconds: 60, GroupBy: "tag:http.method", GroupLimit: 2, ServiceName: "frontend"})
+	assert.Nil(t, err)
+
+	assert.True(t, strings.HasPrefix(queries[0].Query, "SELECT count() as value, tagsValues[indexOf(tagsKeys, ?)] as groupKey FROM signoz_index_final WHERE"))
+	assert.True(t, strings.HasSuffix(queries[0].Query, "AND has(tagsKeys, ?) GROUP BY groupKey ORDER BY value DESC, groupKey ASC LIMIT 2"))
+	assert.Equal(t, []interface{}{"http.method", []string{"GET", "POST"}, "http.method", "http.method", []string{"GET", "POST"}}, queries[1].Args[:5])
+	assert.Equal(t, "http.method", queries[1].Args[len(queries[1].Args)-1])
+
+	minute := time.Date(2020, 9, 13, 12, 26, 0, 0, time.UTC).UnixNano()
+	assert.Equal(t, []model.SpanSearchAggregatesSeries{
+		{Group: "GET", Items: []model.SpanSearchAggregatesResponseItem{{Timestamp: minute + int64(time.Minute), Value: float32(2) / 60}}},
+		{Group: "POST", Items: []model.SpanSearchAggregatesResponseItem{{Timestamp: minute, Value: float32(1) / 60}}},
+		{Group: "other", Other: true, Items: []model.SpanSearchAggregatesResponseItem{{Timestamp: minute, Value: float32(3) / 60}}},
+	}, series)
+}
+
+func TestGetTagValues(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	classUnderTest, queries := newApmDaoTest()
+
+	result, err := classUnderTest.GetTagValues(context.Background(), &model.GetTagValuesParams{Start: &start, End: &start, Key: "http.method",
+		Prefix: "po", OperationName: "HTTP POST", Limit: 5})
+	assert.Nil(t, err)
+	assert.Equal(t, "http.method", result.Key)
+	assert.Equal(t, []model.TagValueItem{}, result.Values)
+
+	valuesQuery := (*queries)[0]
+	assert.True(t, strings.HasPrefix(valuesQuery.Query, "SELECT count() as count, tagsValues[indexOf(tagsKeys, ?)] as tagValue FROM signoz_index_final WHERE"))
+	assert.True(t, strings.HasSuffix(valuesQuery.Query, "GROUP BY tagValue ORDER BY count DESC, tagValue ASC LIMIT 5"))
+	assert.Equal(t, []interface{}{"http.method", strconv.FormatInt(start.UnixNano(), 10), strconv.FormatInt(start.UnixNano(), 10),
+		"http.method", "HTTP POST", "http.method", "po"}, valuesQuery.Args)
+	assert.Contains(t, (*queries)[1].Query, "uniq(tagsValues[indexOf(tagsKeys, ?)]) as cardinality")
+	assert.NotContains(t, (*queries)[1].Query, "startsWith")
+}
+
+func TestGetServiceMapDependencies(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	end := start.Add(time.Hour)
+	classUnderTest, queries := newApmDaoTest()
+
+	result, err := classUnderTest.GetServiceMapDependencies(context.Background(), &model.GetServiceMapParams{Start: &start, End: &end, Period: 3600, Environment: "prod"})
+	assert.Nil(t, err)
+	assert.Equal(t, []model.ServiceMapDependencyResponseItem{}, *result)
+	query := (*queries)[0]
+	assert.Contains(t, query.Query, "sum(count) / 3600 as callRate FROM service_dependencies FINAL WHERE")
+	assert.True(t, strings.HasSuffix(query.Query, "GROUP BY parent, child ORDER BY callCount DESC"))
+	assert.Equal(t, []interface{}{"1600000000", "1600003600", "prod"}, query.Args)
+}
+
+func TestServiceDependenciesQuery(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	query, args, err := serviceDependenciesQuery(start, start.Add(time.Minute)).Build()
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(query, "SELECT toStartOfMinute(timestamp) as minute, parentServiceName, serviceName, environment, quantileState(durationNano)"))
+	assert.Contains(t, query, "FROM signoz_index_tmp WHERE timestamp >= ? AND timestamp < ? AND parentSpanID != '') AS childSpans")
+	assert.Contains(t, query, ") AS parentSpans USING (traceID, parentSpanID) GROUP BY minute, parentServiceName, serviceName, environment")
+	assert.Equal(t, []interface{}{model.EnvironmentTag, model.EnvironmentTag, "1600000000000000000", "1600000060000000000",
+		"1599996400000000000", "1600000060000000000"}, args)
+}
+
+func TestGetTagsCountsSpans(t *testing.T) {
+	classUnderTest, queries := newApmDaoTest()
+
+	_, err := classUnderTest.GetTags(context.Background(), "orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT arrayJoin(tagsKeys) as tagKeys, toInt64(count()) as tagCount FROM signoz_index_final "+
+		"WHERE serviceName = ? AND timestamp > now() - INTERVAL 1 DAY GROUP BY tagKeys ORDER BY tagCount DESC", (*queries)[0].Query)
+}
+
+func TestGetDBStatements(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	end := start.Add(time.Hour)
+	classUnderTest, queries := newApmDaoTest()
+
+	result, err := classUnderTest.GetDBStatements(context.Background(), &model.GetDBStatementsParams{Start: &start, End: &end, Period: 3600,
+		ServiceName: "orders", DBSystem: "postgresql", Limit: 20})
+	assert.Nil(t, err)
+	assert.Equal(t, []model.DBStatementItem{}, result.Statements)
+	assert.Equal(t, []model.DBStatementSample{}, result.SlowSamples)
+
+	statementsQuery := (*queries)[0]
+	assert.Contains(t, statementsQuery.Query, "any(statement) as statement, sum(count) as numCalls, quantileMerge(0.50)(quantile) as p50")
+	assert.True(t, strings.HasSuffix(statementsQuery.Query, "FROM signoz_db_statements_aggregated WHERE serviceName = ? AND timestamp >= ? AND timestamp <= ? AND "+
+		"system = ? GROUP BY system, database, operation, fingerprint ORDER BY numCalls DESC, fingerprint ASC LIMIT 20"))
+	assert.Equal(t, []interface{}{"orders", convertNanosToSeconds(&start), convertNanosToSeconds(&end), "postgresql"}, statementsQuery.Args)
+
+	slowestQuery := (*queries)[1]
+	assert.Contains(t, slowestQuery.Query, "lower(hex(normalizedQueryHash(tagsValues[indexOf(tagsKeys, 'db.statement')]))) as fingerprint")
+	assert.True(t, strings.HasSuffix(slowestQuery.Query, "FROM signoz_index_final WHERE serviceName = ? AND timestamp >= ? AND timestamp <= ? AND kind = 3 AND "+
+		"dbSystem IS NOT NULL AND dbSystem = ? GROUP BY system, database, operation, fingerprint"))
+	assert.Equal(t, []interface{}{"orders", strconv.FormatInt(start.UnixNano(), 10), strconv.FormatInt(end.UnixNano(), 10), "postgresql"}, slowestQuery.Args)
+	assert.True(t, strings.HasSuffix((*queries)[2].Query, "ORDER BY durationNano DESC LIMIT 10"))
+}
+
+func TestDBStatementKey(t *testing.T) {
+	item := model.DBStatementItem{DBSystem: "postgresql", DBName: "orders", DBOperation: "SELECT", Fingerprint: "ab12"}
+	assert.Equal(t, dbStatementKey(item), dbStatementKey(model.DBStatementItem{DBSystem: "postgresql", DBName: "orders", DBOperation: "SELECT", Fingerprint: "ab12", NumCalls: 3}))
+	assert.NotEqual(t, dbStatementKey(item), dbStatementKey(model.DBStatementItem{DBSystem: "postgresql", DBName: "orders", Fingerprint: "ab12"}))
+}
+
+func TestGetServiceExternal(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	end := start.Add(time.Hour)
+	classUnderTest, queries := newApmDaoTest()
+
+	result, err := classUnderTest.GetServiceExternal(context.Background(), &model.GetServiceExternalParams{Start: &start, End: &end,
+		ServiceName: "orders", StepSeconds: 300, GroupBy: model.ExternalGroupHost})
+	assert.Nil(t, err)
+	assert.Equal(t, []model.ServiceExternalItem{}, *result)
+	query := (*queries)[0]
+	assert.True(t, strings.HasPrefix(query.Query, "SELECT toStartOfInterval(timestamp, INTERVAL 5 minute) as time, coalesce(externalHost, domain(coalesce(externalHttpUrl, ''))) as host, "+
+		"sum(count) as numCalls, sumIf(count, statusCode >= 500 OR statusCode = 2) as numErrors"))
+	assert.Contains(t, query.Query, "FROM signoz_index_aggregated WHERE serviceName = ? AND timestamp >= ? AND timestamp <= ? AND kind IN ('3', '4') AND "+
+		"dbSystem IS NULL AND coalesce(externalHost, domain(coalesce(externalHttpUrl, ''))) != ''")
+	assert.Equal(t, []interface{}{"orders", convertNanosToSeconds(&start), convertNanosToSeconds(&end)}, query.Args)
+	assert.NotContains(t, query.Query, "externalHttpUrl as")
+	assert.True(t, strings.HasSuffix(query.Query, "GROUP BY time, host ORDER BY time DESC, numCalls DESC"))
+
+	_, err = classUnderTest.GetServiceExternal(context.Background(), &model.GetServiceExternalParams{Start: &start, End: &end,
+		ServiceName: "orders", StepSeconds: 300, GroupBy: model.ExternalGroupURL})
+	assert.Nil(t, err)
+	assert.Contains(t, (*queries)[1].Query, "coalesce(externalHttpUrl, '') as externalHttpUrl")
+	assert.Contains(t, (*queries)[1].Query, "GROUP BY time, host, externalHttpUrl")
+}
+
+func TestGetTopEndpointsApdex(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	classUnderTest, queries := newApmDaoTest()
+
+	result, err := classUnderTest.GetTopEndpoints(context.Background(), &model.GetTopEndpointsParams{Start: &start, End: &start, ServiceName: "orders"})
+	assert.Nil(t, err)
+	assert.Equal(t, []model.TopEndpointsItem{}, *result)
+	thresholds := "[0.025, 0.05, 0.1, 0.2, 0.25, 0.3, 0.5, 0.75, 1, 2.5]"
+	satisfied := "[4, 5, 6, 7, 8, 9, 11, 12, 14, 18][indexOf(" + thresholds + ", apdexThreshold)]"
+	tolerable := "[6, 7, 10, 13, 14, 15, 17, 19, 20, 22][indexOf(" + thresholds + ", apdexThreshold)]"
+	apdexQuery := (*queries)[1]
+	assert.True(t, strings.HasPrefix(apdexQuery.Query, "SELECT serviceName, name, if(threshold > 0, threshold, 0.5) as apdexThreshold, "+
+		"sumIf(latencies["+satisfied+"], NOT (statusCode >= 500 OR statusCode = 2)) as satisfied, "+
+		"sumIf(latencies["+tolerable+"], NOT (statusCode >= 500 OR statusCode = 2)) as tolerable, sum(latencies[1]) as total "+
+		"FROM (SELECT name, serviceName, statusCode, finalizeAggregation(latencyCounts) as latencies FROM signoz_index_aggregated "+
+		"WHERE kind = '2' AND serviceName = ? AND timestamp >= ? AND timestamp <= ?) AS rollup "+
+		"LEFT JOIN (SELECT serviceName, threshold FROM apdex_settings FINAL) AS settings USING (serviceName)"))
+	// rows written before latencyCounts had the counts of threshold count neither as good nor in total
+	assert.True(t, strings.HasSuffix(apdexQuery.Query, "WHERE apdexThreshold IN (0.025, 0.05, 0.1, 0.2, 0.25, 0.3, 0.5, 0.75, 1, 2.5) AND "+
+		"length(latencies) >= greatest("+satisfied+", "+tolerable+") GROUP BY serviceName, name, apdexThreshold"))
+	assert.Equal(t, []interface{}{"orders", convertNanosToSeconds(&start), convertNanosToSeconds(&start)}, apdexQuery.Args)
+
+	// services with threshold not counted by the rollup table are counted from raw spans
+	spansQuery := (*queries)[2]
+	assert.True(t, strings.HasPrefix(spansQuery.Query, "SELECT serviceName, name, any(threshold) as apdexThreshold, "+
+		"countIf(NOT (statusCode >= 500 OR statusCode = 2) AND durationNano <= threshold * 1000000000) as satisfied, "+
+		"countIf(NOT (statusCode >= 500 OR statusCode = 2) AND durationNano <= 4 * threshold * 1000000000) as tolerable, count() as total "+
+		"FROM (SELECT name, serviceName, statusCode, durationNano FROM signoz_index_final WHERE kind = 2 AND serviceName = ? AND "+
+		"timestamp >= ? AND timestamp <= ? AND serviceName IN (SELECT serviceName FROM apdex_settings FINAL WHERE threshold NOT IN "))
+	assert.True(t, strings.HasSuffix(spansQuery.Query, "INNER JOIN (SELECT serviceName, threshold FROM apdex_settings FINAL "+
+		"WHERE threshold NOT IN (0.025, 0.05, 0.1, 0.2, 0.25, 0.3, 0.5, 0.75, 1, 2.5)) AS settings USING (serviceName) GROUP BY serviceName, name"))
+	assert.Equal(t, []interface{}{"orders", strconv.FormatInt(start.UnixNano(), 10), strconv.FormatInt(start.UnixNano(), 10)}, spansQuery.Args)
+}
+
+func TestGetServiceOverviewApdexOnDemand(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	classUnderTest, queries := newApmDaoTest()
+
+	_, err := classUnderTest.GetServiceOverview(context.Background(), &model.GetServiceOverviewParams{Start: &start, End: &start, ServiceName: "orders", StepSeconds: 60})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(*queries))
+
+	_, err = classUnderTest.GetServiceOverview(context.Background(), &model.GetServiceOverviewParams{Start: &start, End: &start, ServiceName: "orders", StepSeconds: 60, Apdex: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(*queries))
+	assert.Contains(t, (*queries)[4].Query, "GROUP BY serviceName, time, apdexThreshold")
+	assert.Contains(t, (*queries)[5].Query, "GROUP BY serviceName, time")
+}
+
+func TestRollupApdexThresholds(t *testing.T) {
+	assert.Equal(t, []float64{0.025, 0.05, 0.1, 0.2, 0.25, 0.3, 0.5, 0.75, 1, 2.5}, rollupApdexThresholds())
+	assert.Contains(t, rollupApdexThresholds(), model.DefaultApdexThreshold)
+	assert.Equal(t, 2, latencyCountPosition(5))
+	assert.Zero(t, latencyCountPosition(1))
+}
+
+func TestApdexScore(t *testing.T) {
+	score := apdexRow{Threshold: 0.3, Satisfied: 60, Tolerable: 90, Total: 100}.score()
+	assert.InDelta(t, 0.75, *score.Apdex, 1e-9)
+	assert.Equal(t, model.ApdexScore{Apdex: score.Apdex, ApdexThreshold: 0.3, ApdexSatisfied: 60, ApdexTolerating: 30, ApdexFrustrated: 10}, score)
+
+	// rows counted before 4 times threshold was
+	score = apdexRow{Threshold: 0.1, Satisfied: 60, Tolerable: 40, Total: 100}.score()
+	assert.Equal(t, uint64(0), score.ApdexTolerating)
+	assert.Equal(t, uint64(40), score.ApdexFrustrated)
+
+	assert.Nil(t, apdexRow{Threshold: 0.5}.score().Apdex)
+}
+
+func TestGetServicesCountsErrorsAnd4xxSeparately(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	end := start.Add(100 * time.Second)
+	clickhouseConnectionMock := new(clickhouse.MockClickhouseConnectionService)
+	clickhouseConnectionMock.On("ExecuteSelectFunctionContext", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			items, ok := args.Get(2).(*[]model.ServiceItem)
+			if !ok {
+				return
+			}
+			query := args.String(3)
+			switch {
+			case strings.Contains(query, "as numErrors"):
+				*items = []model.ServiceItem{{ServiceName: "cart", NumErrors: 5}}
+			case strings.Contains(query, "as num4xx"):
+				*items = []model.ServiceItem{{ServiceName: "cart", Num4XX: 20}}
+			default:
+				*items = []model.ServiceItem{{ServiceName: "cart", NumCalls: 200}}
+			}
+		})
+	classUnderTest := &ApmDaoImpl{Logger: logger.LOGGER, ClickhouseConnectionService: clickhouseConnectionMock}
+
+	services, err := classUnderTest.GetServices(context.Background(), &model.GetServicesParams{Start: &start, End: &end, Period: 100})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, (*services)[0].NumErrors)
+	assert.Equal(t, 20, (*services)[0].Num4XX)
+	assert.Equal(t, float32(0.05), (*services)[0].ErrorRate)
+	assert.Equal(t, float32(0.2), (*services)[0].FourXXRate)
+}
+
+func TestGetLatencyHistogram(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	var queries []capturedQuery
+	clickhouseConnectionMock := new(clickhouse.MockClickhouseConnectionService)
+	clickhouseConnectionMock.On("ExecuteSelectFunctionContext", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			queries = append(queries, capturedQuery{Query: args.String(3), Args: args.Get(4).([]interface{})})
+			*args.Get(2).(*[]latencyBucketRow) = []latencyBucketRow{
+				{Time: "2020-09-13T12:26:00Z", Bucket: 1, Count: 5},
+				{Time: "2020-09-13T12:26:00Z", Bucket: 0, Count: 1},
+				{Time: "2020-09-13T12:27:00Z", Bucket: 2, Count: 3},
+			}
+		})
+	classUnderTest := &ApmDaoImpl{Logger: logger.LOGGER, ClickhouseConnectionService: clickhouseConnectionMock}
+	params := &model.GetLatencyHistogramParams{Boundaries: []int64{1000000, 5000000}, StepSeconds: 60,
+		Filters: &model.SpanSearchParams{Start: &start, End: &start, ServiceName: "frontend", Tags: []model.TagQuery{{Key: "http.method", Value: "GET", Operator: "equals"}}}}
+
+	histogram, err := classUnderTest.GetLatencyHistogram(context.Background(), params)
+	assert.Nil(t, err)
+	assert.Equal(t, &model.LatencyHistogram{Total: 9, Buckets: []model.LatencyBucket{
+		{LowerBound: 0, UpperBound: 1000000, Count: 5},
+		{LowerBound: 1000000, UpperBound: 5000000, Count: 3},
+		{LowerBound: 5000000, Count: 1},
+	}}, histogram)
+	assert.True(t, strings.HasPrefix(queries[0].Query, "SELECT arrayFirstIndex(boundary -> durationNano < boundary, [1000000, 5000000]) as bucket, count() as count FROM signoz_index_final WHERE"))
+	assert.Contains(t, queries[0].Args, "http.method:GET")
+
+	heatmap, err := classUnderTest.GetLatencyHeatmap(context.Background(), params)
+	assert.Nil(t, err)
+	assert.Equal(t, []model.LatencyHeatmapItem{
+		{Timestamp: time.Date(2020, 9, 13, 12, 26, 0, 0, time.UTC).UnixNano(), Counts: []uint64{5, 0, 1}},
+		{Timestamp: time.Date(2020, 9, 13, 12, 27, 0, 0, time.UTC).UnixNano(), Counts: []uint64{0, 3, 0}},
+	}, heatmap.Series)
+	assert.True(t, strings.HasSuffix(queries[1].Query, "GROUP BY time, bucket ORDER BY time ASC"))
+}
+
+func TestGetSLIEvents(t *testing.T) {
+	end := time.Unix(1600003600, 0)
+	var queries []capturedQuery
+	clickhouseConnectionMock := new(clickhouse.MockClickhouseConnectionService)
+	clickhouseConnectionMock.On("ExecuteSelectFunctionContext", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			queries = append(queries, capturedQuery{Query: args.String(3), Args: args.Get(4).([]interface{})})
+			*args.Get(2).(*[]sliEventsRow) = []sliEventsRow{{Good: []uint64{990, 95}, Total: []uint64{1000, 100}}}
+		})
+	classUnderTest := &ApmDaoImpl{Logger: logger.LOGGER, ClickhouseConnectionService: clickhouseConnectionMock}
+	params := &model.GetSLIEventsParams{ServiceName: "frontend", Operation: "GET /cart", SLIType: model.SLITypeLatency, LatencyThresholdMs: 250,
+		Starts: []time.Time{end.Add(-time.Hour), end.Add(-5 * time.Minute)}, End: end}
+
+	events, err := classUnderTest.GetSLIEvents(context.Background(), params)
+	assert.Nil(t, err)
+	assert.Equal(t, []model.SLIEvents{{Good: 990, Total: 1000}, {Good: 95, Total: 100}}, events)
+	// 250ms is the 7th threshold, preceded by total
+	assert.True(t, strings.HasPrefix(queries[0].Query, "SELECT [sumForEachMergeIf(latencyCounts, timestamp >= ?)[8], sumForEachMergeIf(latencyCounts, timestamp >= ?)[8]] as good, "))
+	assert.Equal(t, []interface{}{"1600000000", "1600003300", "1600000000", "1600003300", "1600000000", "1600003600", "frontend", "GET /cart"}, queries[0].Args)
+
+	params.SLIType = model.SLITypeAvailability
+	_, err = classUnderTest.GetSLIEvents(context.Background(), params)
+	assert.Nil(t, err)
+	assert.Contains(t, queries[1].Query, "sumIf(count, timestamp >= ? AND NOT (statusCode >= 500 OR statusCode = 2))")
+
+	params.SLIType, params.LatencyThresholdMs = model.SLITypeLatency, 260
+	_, err = classUnderTest.GetSLIEvents(context.Background(), params)
+	assert.NotNil(t, err)
+
+	assertSameShape(t, func(dao *ApmDaoImpl, value string) {
+		_, _ = dao.GetSLIEvents(context.Background(), &model.GetSLIEventsParams{ServiceName: value, Operation: value,
+			SLIType: model.SLITypeAvailability, Starts: []time.Time{end}, End: end})
+	})
+}
@@ -0,0 +1,44 @@
+package dao
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+)
+
+type ChannelDao interface {
+	GetChannels(ctx context.Context) ([]model.NotificationChannel, error)
+	GetChannel(ctx context.Context, id string) (*model.NotificationChannel, error)
+	SaveChannel(ctx context.Context, channel *model.NotificationChannel) error
+	DeleteChannel(ctx context.Context, channel *model.NotificationChannel) error
+}
+
+type MockChannelDao struct {
+	mock.Mock
+}
+
+func (dao *MockChannelDao) GetChannels(ctx context.Context) ([]model.NotificationChannel, error) {
+	args := dao.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.NotificationChannel), args.Error(1)
+}
+
+func (dao *MockChannelDao) GetChannel(ctx context.Context, id string) (*model.NotificationChannel, error) {
+	args := dao.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.NotificationChannel), args.Error(1)
+}
+
+func (dao *MockChannelDao) SaveChannel(ctx context.Context, channel *model.NotificationChannel) error {
+	args := dao.Called(ctx, channel)
+	return args.Error(0)
+}
+
+func (dao *MockChannelDao) DeleteChannel(ctx context.Context, channel *model.NotificationChannel) error {
+	args := dao.Called(ctx, channel)
+	return args.Error(0)
+}
@@ -0,0 +1,152 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"go.uber.org/zap"
+	"goapm/clickhouse"
+	model "goapm/domain"
+	"goapm/logger"
+	"goapm/query_builder"
+	"sync"
+	"time"
+)
+
+const insertChannelQuery = "INSERT INTO notification_channels (id, name, type, url, headers, template, recipients, matchers, groupBy, repeatIntervalSeconds, disabled, deleted, updatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+var channelDaoOnce sync.Once
+var channelDao *ChannelDaoImpl
+
+// ChannelDaoImpl channels are versioned the same way as alert rules, deleted channel is a newer version with deleted flag
+type ChannelDaoImpl struct {
+	Logger                      *zap.SugaredLogger
+	ClickhouseConnectionService clickhouse.ClickhouseConnectionService
+}
+
+func NewChannelDao(ClickhouseConnectionService clickhouse.ClickhouseConnectionService) *ChannelDaoImpl {
+	channelDaoOnce.Do(func() {
+		channelDao = &ChannelDaoImpl{
+			Logger:                      logger.LOGGER,
+			ClickhouseConnectionService: ClickhouseConnectionService,
+		}
+	})
+	return channelDao
+}
+
+// channelRow stored form of channel, headers and matchers are kept as json objects
+type channelRow struct {
+	ID                    string   `db:"id"`
+	Name                  string   `db:"name"`
+	Type                  string   `db:"type"`
+	URL                   string   `db:"url"`
+	Headers               string   `db:"headers"`
+	Template              string   `db:"template"`
+	Recipients            []string `db:"recipients"`
+	Matchers              string   `db:"matchers"`
+	GroupBy               []string `db:"groupBy"`
+	RepeatIntervalSeconds uint32   `db:"repeatIntervalSeconds"`
+	Disabled              uint8    `db:"disabled"`
+	UpdatedAt             int64    `db:"updatedAtNano"`
+}
+
+func (row *channelRow) channel() model.NotificationChannel {
+	channel := model.NotificationChannel{
+		ID:                    row.ID,
+		Name:                  row.Name,
+		Type:                  row.Type,
+		URL:                   row.URL,
+		Headers:               map[string]string{},
+		Template:              row.Template,
+		Recipients:            row.Recipients,
+		Matchers:              map[string]string{},
+		GroupBy:               row.GroupBy,
+		RepeatIntervalSeconds: int(row.RepeatIntervalSeconds),
+		Disabled:              row.Disabled == 1,
+		UpdatedAt:             row.UpdatedAt,
+	}
+	_ = json.Unmarshal([]byte(row.Headers), &channel.Headers)
+	_ = json.Unmarshal([]byte(row.Matchers), &channel.Matchers)
+	if channel.Recipients == nil {
+		channel.Recipients = []string{}
+	}
+	if channel.GroupBy == nil {
+		channel.GroupBy = []string{}
+	}
+	return channel
+}
+
+func channelsQuery() *query_builder.SelectBuilder {
+	return query_builder.Select("id", "name", "type", "url", "headers", "template", "recipients", "matchers", "groupBy",
+		"repeatIntervalSeconds", "disabled", "toUnixTimestamp64Nano(updatedAt) as updatedAtNano").
+		From("notification_channels").
+		Final().
+		Where("deleted = 0")
+}
+
+func (dao *ChannelDaoImpl) GetChannels(ctx context.Context) ([]model.NotificationChannel, error) {
+	var rows []channelRow
+	err := dao.selectAll(ctx, &rows, channelsQuery().OrderBy("name", query_builder.Asc).OrderBy("id", query_builder.Asc))
+	if err != nil {
+		return nil, err
+	}
+	channels := make([]model.NotificationChannel, len(rows))
+	for i := range rows {
+		channels[i] = rows[i].channel()
+	}
+	return channels, nil
+}
+
+// GetChannel return nil when channel doesn't exist or was deleted
+func (dao *ChannelDaoImpl) GetChannel(ctx context.Context, id string) (*model.NotificationChannel, error) {
+	var rows []channelRow
+	err := dao.selectAll(ctx, &rows, channelsQuery().Where("id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	channel := rows[0].channel()
+	return &channel, nil
+}
+
+func (dao *ChannelDaoImpl) SaveChannel(ctx context.Context, channel *model.NotificationChannel) error {
+	return dao.insertChannel(ctx, channel, false)
+}
+
+func (dao *ChannelDaoImpl) DeleteChannel(ctx context.Context, channel *model.NotificationChannel) error {
+	return dao.insertChannel(ctx, channel, true)
+}
+
+func (dao *ChannelDaoImpl) insertChannel(ctx context.Context, channel *model.NotificationChannel, deleted bool) error {
+	headers, err := json.Marshal(channel.Headers)
+	if err != nil {
+		return err
+	}
+	matchers, err := json.Marshal(channel.Matchers)
+	if err != nil {
+		return err
+	}
+	recipients, groupBy := channel.Recipients, channel.GroupBy
+	if recipients == nil {
+		recipients = []string{}
+	}
+	if groupBy == nil {
+		groupBy = []string{}
+	}
+	err = dao.ClickhouseConnectionService.ExecuteInsertFunctionContext(ctx, insertChannelQuery, func(stmt *sql.Stmt) error {
+		_, err := stmt.Exec(channel.ID, channel.Name, channel.Type, channel.URL, string(headers), channel.Template, recipients,
+			string(matchers), groupBy, uint32(channel.RepeatIntervalSeconds), boolToUInt8(channel.Disabled), boolToUInt8(deleted), time.Now())
+		return err
+	})
+	if err != nil {
+		dao.Logger.Debug("Error in saving notification channel: ", err)
+		return queryError(err)
+	}
+	return nil
+}
+
+func (dao *ChannelDaoImpl) selectAll(ctx context.Context, dest interface{}, builder *query_builder.SelectBuilder) error {
+	return runSelect(ctx, dao.ClickhouseConnectionService, dao.Logger, true, dest, builder)
+}
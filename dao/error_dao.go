@@ -0,0 +1,32 @@
+package dao
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+)
+
+type ErrorDao interface {
+	GetErrorGroups(ctx context.Context, queryParams *model.GetErrorsParams) (*model.ErrorGroupsResult, error)
+	GetErrorGroup(ctx context.Context, queryParams *model.GetErrorParams) (*model.ErrorDetail, error)
+}
+
+type MockErrorDao struct {
+	mock.Mock
+}
+
+func (dao *MockErrorDao) GetErrorGroups(ctx context.Context, queryParams *model.GetErrorsParams) (*model.ErrorGroupsResult, error) {
+	args := dao.Called(ctx, queryParams)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ErrorGroupsResult), args.Error(1)
+}
+
+func (dao *MockErrorDao) GetErrorGroup(ctx context.Context, queryParams *model.GetErrorParams) (*model.ErrorDetail, error) {
+	args := dao.Called(ctx, queryParams)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ErrorDetail), args.Error(1)
+}
@@ -0,0 +1,133 @@
+package dao
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"goapm/clickhouse"
+	model "goapm/domain"
+	"goapm/logger"
+	"goapm/query_builder"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const errorSamplesLimit = 10
+
+var errorDaoOnce sync.Once
+var errorDao *ErrorDaoImpl
+
+// ErrorDaoImpl read exceptions from signoz_error_index, the table is filled by materialized view from spans
+// having exception.fingerprint tag
+type ErrorDaoImpl struct {
+	Logger                      *zap.SugaredLogger
+	ClickhouseConnectionService clickhouse.ClickhouseConnectionService
+}
+
+func NewErrorDao(ClickhouseConnectionService clickhouse.ClickhouseConnectionService) *ErrorDaoImpl {
+	errorDaoOnce.Do(func() {
+		errorDao = &ErrorDaoImpl{
+			Logger:                      logger.LOGGER,
+			ClickhouseConnectionService: ClickhouseConnectionService,
+		}
+	})
+	return errorDao
+}
+
+// GetErrorGroups page of fingerprints ordered by number of occurrences
+func (dao *ErrorDaoImpl) GetErrorGroups(ctx context.Context, queryParams *model.GetErrorsParams) (*model.ErrorGroupsResult, error) {
+	result := &model.ErrorGroupsResult{Items: []model.ErrorGroupItem{}, Limit: queryParams.Limit, Offset: queryParams.Offset}
+
+	query := errorGroupQuery().
+		Where("timestamp >= ?", strconv.FormatInt(queryParams.Start.UnixNano(), 10)).
+		Where("timestamp <= ?", strconv.FormatInt(queryParams.End.UnixNano(), 10)).
+		WhereIf(len(queryParams.ServiceName) != 0, "serviceName = ?", queryParams.ServiceName).
+		GroupBy("fingerprint").
+		OrderBy("count", query_builder.Desc).
+		OrderBy("fingerprint", query_builder.Asc).
+		Limit(queryParams.Limit).
+		Offset(queryParams.Offset)
+	err := dao.selectAll(ctx, &result.Items, query)
+	if err != nil {
+		return nil, err
+	}
+	if result.Items == nil {
+		result.Items = []model.ErrorGroupItem{}
+	}
+
+	countQuery := query_builder.Select("uniqExact(fingerprint) as total").
+		From("signoz_error_index").
+		Where("timestamp >= ?", strconv.FormatInt(queryParams.Start.UnixNano(), 10)).
+		Where("timestamp <= ?", strconv.FormatInt(queryParams.End.UnixNano(), 10)).
+		WhereIf(len(queryParams.ServiceName) != 0, "serviceName = ?", queryParams.ServiceName)
+	err = dao.selectOne(ctx, &result.Total, countQuery)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetErrorGroup summary, latest samples and occurrences timeline of one fingerprint,
+// detail with zero count is returned when fingerprint has no occurrences in time range
+func (dao *ErrorDaoImpl) GetErrorGroup(ctx context.Context, queryParams *model.GetErrorParams) (*model.ErrorDetail, error) {
+	detail := &model.ErrorDetail{
+		ErrorGroupItem: model.ErrorGroupItem{Fingerprint: queryParams.Fingerprint, ServiceNames: []string{}},
+		Samples:        []model.ErrorSample{},
+		Timeline:       []model.ErrorTimelineItem{},
+	}
+	applyFilters := func(query *query_builder.SelectBuilder) *query_builder.SelectBuilder {
+		return query.From("signoz_error_index").
+			Where("fingerprint = ?", queryParams.Fingerprint).
+			Where("timestamp >= ?", strconv.FormatInt(queryParams.Start.UnixNano(), 10)).
+			Where("timestamp <= ?", strconv.FormatInt(queryParams.End.UnixNano(), 10)).
+			WhereIf(len(queryParams.ServiceName) != 0, "serviceName = ?", queryParams.ServiceName)
+	}
+
+	var groups []model.ErrorGroupItem
+	err := dao.selectAll(ctx, &groups, applyFilters(errorGroupQuery()).GroupBy("fingerprint"))
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return detail, nil
+	}
+	detail.ErrorGroupItem = groups[0]
+
+	samplesQuery := applyFilters(query_builder.Select("toUnixTimestamp64Nano(timestamp) as timestampNano", "traceID", "spanID", "serviceName", "name",
+		"exceptionMessage", "exceptionStacktrace")).
+		OrderBy("timestamp", query_builder.Desc).
+		Limit(errorSamplesLimit)
+	err = dao.selectAll(ctx, &detail.Samples, samplesQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	timelineQuery := applyFilters(query_builder.Select(query_builder.StartOfInterval("timestamp", queryParams.StepSeconds/60)+" as time", "count() as count")).
+		GroupBy("time").
+		OrderBy("time", query_builder.Asc)
+	err = dao.selectAll(ctx, &detail.Timeline, timelineQuery)
+	if err != nil {
+		return nil, err
+	}
+	for i := range detail.Timeline {
+		timeObj, _ := time.Parse(time.RFC3339Nano, detail.Timeline[i].Time)
+		detail.Timeline[i].Timestamp = timeObj.UnixNano()
+		detail.Timeline[i].Time = ""
+	}
+	return detail, nil
+}
+
+func errorGroupQuery() *query_builder.SelectBuilder {
+	return query_builder.Select("fingerprint", "any(exceptionType) as exceptionType", "any(exceptionMessage) as exceptionMessage", "count() as count",
+		"toUnixTimestamp64Nano(min(timestamp)) as firstSeen", "toUnixTimestamp64Nano(max(timestamp)) as lastSeen",
+		"groupUniqArray(serviceName) as serviceNames").
+		From("signoz_error_index")
+}
+
+func (dao *ErrorDaoImpl) selectAll(ctx context.Context, dest interface{}, builder *query_builder.SelectBuilder) error {
+	return runSelect(ctx, dao.ClickhouseConnectionService, dao.Logger, true, dest, builder)
+}
+
+func (dao *ErrorDaoImpl) selectOne(ctx context.Context, dest interface{}, builder *query_builder.SelectBuilder) error {
+	return runSelect(ctx, dao.ClickhouseConnectionService, dao.Logger, false, dest, builder)
+}
@@ -0,0 +1,63 @@
+package dao
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"goapm/clickhouse"
+	model "goapm/domain"
+	"goapm/logger"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newErrorDaoTest(groups []model.ErrorGroupItem) (*ErrorDaoImpl, *[]capturedQuery) {
+	var queries []capturedQuery
+	clickhouseConnectionMock := new(clickhouse.MockClickhouseConnectionService)
+	clickhouseConnectionMock.On("ExecuteSelectFunctionContext", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			queries = append(queries, capturedQuery{Query: args.String(3), Args: args.Get(4).([]interface{})})
+			switch dest := args.Get(2).(type) {
+			case *[]model.ErrorGroupItem:
+				*dest = groups
+			case *[]model.ErrorTimelineItem:
+				*dest = []model.ErrorTimelineItem{{Time: "2020-09-13T12:26:00Z", Count: 3}}
+			}
+		})
+	return &ErrorDaoImpl{Logger: logger.LOGGER, ClickhouseConnectionService: clickhouseConnectionMock}, &queries
+}
+
+func TestGetErrorGroups(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	classUnderTest, queries := newErrorDaoTest(nil)
+
+	result, err := classUnderTest.GetErrorGroups(context.Background(), &model.GetErrorsParams{Start: &start, End: &start, ServiceName: "frontend", Limit: 20, Offset: 40})
+	assert.Nil(t, err)
+	assert.Equal(t, []model.ErrorGroupItem{}, result.Items)
+	assert.True(t, strings.HasPrefix((*queries)[0].Query, "SELECT fingerprint, any(exceptionType) as exceptionType"))
+	assert.True(t, strings.HasSuffix((*queries)[0].Query, "AND serviceName = ? GROUP BY fingerprint ORDER BY count DESC, fingerprint ASC LIMIT 20 OFFSET 40"))
+	assert.True(t, strings.HasPrefix((*queries)[1].Query, "SELECT uniqExact(fingerprint) as total FROM signoz_error_index WHERE"))
+}
+
+func TestGetErrorGroup(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	ctx := context.Background()
+
+	classUnderTest, queries := newErrorDaoTest(nil)
+	detail, err := classUnderTest.GetErrorGroup(ctx, &model.GetErrorParams{Start: &start, End: &start, Fingerprint: "abc", StepSeconds: 300})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), detail.Count)
+	assert.Equal(t, 1, len(*queries))
+	assert.Equal(t, "abc", (*queries)[0].Args[0])
+
+	classUnderTest, queries = newErrorDaoTest([]model.ErrorGroupItem{{Fingerprint: "abc", Count: 3, ServiceNames: []string{"orders"}}})
+	detail, err = classUnderTest.GetErrorGroup(ctx, &model.GetErrorParams{Start: &start, End: &start, Fingerprint: "abc", StepSeconds: 300})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), detail.Count)
+	assert.Equal(t, 3, len(*queries))
+	assert.True(t, strings.HasSuffix((*queries)[1].Query, "ORDER BY timestamp DESC LIMIT 10"))
+	assert.Contains(t, (*queries)[2].Query, "toStartOfInterval(timestamp, INTERVAL 5 minute) as time")
+	assert.Equal(t, []model.ErrorTimelineItem{{Timestamp: time.Date(2020, 9, 13, 12, 26, 0, 0, time.UTC).UnixNano(), Count: 3}}, detail.Timeline)
+}
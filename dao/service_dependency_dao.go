@@ -0,0 +1,35 @@
+package dao
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type ServiceDependencyDao interface {
+	GetAggregatedUntil(ctx context.Context) (*time.Time, error)
+	AggregateDependencies(ctx context.Context, start time.Time, end time.Time) error
+	SetAggregatedUntil(ctx context.Context, end time.Time) error
+}
+
+type MockServiceDependencyDao struct {
+	mock.Mock
+}
+
+func (dao *MockServiceDependencyDao) GetAggregatedUntil(ctx context.Context) (*time.Time, error) {
+	args := dao.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*time.Time), args.Error(1)
+}
+
+func (dao *MockServiceDependencyDao) AggregateDependencies(ctx context.Context, start time.Time, end time.Time) error {
+	args := dao.Called(ctx, start, end)
+	return args.Error(0)
+}
+
+func (dao *MockServiceDependencyDao) SetAggregatedUntil(ctx context.Context, end time.Time) error {
+	args := dao.Called(ctx, end)
+	return args.Error(0)
+}
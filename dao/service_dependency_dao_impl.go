@@ -0,0 +1,116 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"go.uber.org/zap"
+	"goapm/clickhouse"
+	model "goapm/domain"
+	"goapm/logger"
+	"goapm/query_builder"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const serviceDependenciesKey = "SERVICE_DEPENDENCIES"
+const lastSuccessLayout = "2006-01-02 15:04:05"
+
+// parentSpanLookback parents started before their children, long running parents up to an hour earlier are found
+const parentSpanLookback = time.Hour
+
+const insertLastSuccessQuery = "INSERT INTO last_success (last_success_key, last_success_date, event_time) VALUES (?, ?, ?)"
+
+var serviceDependencyDaoOnce sync.Once
+var serviceDependencyDao *ServiceDependencyDaoImpl
+
+// ServiceDependencyDaoImpl edges between services are aggregated from all spans of signoz_index_tmp into
+// service_dependencies per minute. A materialized view sees only spans of one insert while parent and child spans
+// usually come in different inserts, so finished minutes are aggregated by a job and its progress is kept in last_success.
+// Rows of a minute are written by one batch and service_dependencies is a ReplacingMergeTree keyed by minute and edge,
+// so a batch aggregated again after its progress was lost replaces its rows instead of doubling them
+type ServiceDependencyDaoImpl struct {
+	Logger                      *zap.SugaredLogger
+	ClickhouseConnectionService clickhouse.ClickhouseConnectionService
+}
+
+func NewServiceDependencyDao(ClickhouseConnectionService clickhouse.ClickhouseConnectionService) *ServiceDependencyDaoImpl {
+	serviceDependencyDaoOnce.Do(func() {
+		serviceDependencyDao = &ServiceDependencyDaoImpl{
+			Logger:                      logger.LOGGER,
+			ClickhouseConnectionService: ClickhouseConnectionService,
+		}
+	})
+	return serviceDependencyDao
+}
+
+// GetAggregatedUntil end of the last aggregated minute, nil when nothing was aggregated yet
+func (dao *ServiceDependencyDaoImpl) GetAggregatedUntil(ctx context.Context) (*time.Time, error) {
+	var dates []string
+	query := query_builder.Select("last_success_date").
+		From("last_success").
+		Final().
+		Where("last_success_key = ?", serviceDependenciesKey)
+	err := runSelect(ctx, dao.ClickhouseConnectionService, dao.Logger, true, &dates, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(dates) == 0 {
+		return nil, nil
+	}
+	until, err := time.ParseInLocation(lastSuccessLayout, dates[0], time.UTC)
+	if err != nil {
+		return nil, err
+	}
+	return &until, nil
+}
+
+// AggregateDependencies aggregate calls of child spans started in [start, end) by parent and child service
+func (dao *ServiceDependencyDaoImpl) AggregateDependencies(ctx context.Context, start time.Time, end time.Time) error {
+	query, args, err := serviceDependenciesQuery(start, end).Build()
+	if err != nil {
+		return err
+	}
+	insert := "INSERT INTO service_dependencies (timestamp, parentServiceName, serviceName, environment, quantile, count, errorCount) " + query
+	err = dao.ClickhouseConnectionService.ExecuteInsertFunctionContext(ctx, insert, func(stmt *sql.Stmt) error {
+		_, err := stmt.Exec(args...)
+		return err
+	})
+	if err != nil {
+		dao.Logger.Debug("Error in aggregating service dependencies: ", err)
+		return queryError(err)
+	}
+	return nil
+}
+
+func (dao *ServiceDependencyDaoImpl) SetAggregatedUntil(ctx context.Context, end time.Time) error {
+	err := dao.ClickhouseConnectionService.ExecuteInsertFunctionContext(ctx, insertLastSuccessQuery, func(stmt *sql.Stmt) error {
+		_, err := stmt.Exec(serviceDependenciesKey, end.UTC().Format(lastSuccessLayout), time.Now())
+		return err
+	})
+	if err != nil {
+		dao.Logger.Debug("Error in saving service dependencies progress: ", err)
+		return queryError(err)
+	}
+	return nil
+}
+
+// serviceDependenciesQuery child spans joined with their parent spans, environment of edge is the one of child span
+func serviceDependenciesQuery(start time.Time, end time.Time) *query_builder.SelectBuilder {
+	childSpans := query_builder.Select("timestamp", "traceID", "parentSpanID", "serviceName", "durationNano", "statusCode").
+		Column("if(has(tagsKeys, ?), tagsValues[indexOf(tagsKeys, ?)], '') as environment", model.EnvironmentTag, model.EnvironmentTag).
+		From("signoz_index_tmp").
+		Where("timestamp >= ?", strconv.FormatInt(start.UnixNano(), 10)).
+		Where("timestamp < ?", strconv.FormatInt(end.UnixNano(), 10)).
+		Where("parentSpanID != ''")
+	parentSpans := query_builder.Select("traceID", "spanID as parentSpanID", "serviceName as parentServiceName").
+		From("signoz_index_tmp").
+		Where("timestamp >= ?", strconv.FormatInt(start.Add(-parentSpanLookback).UnixNano(), 10)).
+		Where("timestamp < ?", strconv.FormatInt(end.UnixNano(), 10))
+
+	return query_builder.Select("toStartOfMinute(timestamp) as minute", "parentServiceName", "serviceName", "environment",
+		"quantileState(durationNano)", "toUInt64(count())", "toUInt64(countIf(statusCode >= 500 OR statusCode = 2))").
+		FromSubquery(childSpans, "childSpans").
+		InnerJoin(parentSpans, "parentSpans", "traceID", "parentSpanID").
+		GroupBy("minute", "parentServiceName", "serviceName", "environment")
+}
@@ -0,0 +1,29 @@
+package dao
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+)
+
+type SettingsDao interface {
+	GetApdexSettings(ctx context.Context) ([]model.ApdexSetting, error)
+	SetApdexSetting(ctx context.Context, setting *model.ApdexSetting) error
+}
+
+type MockSettingsDao struct {
+	mock.Mock
+}
+
+func (dao *MockSettingsDao) GetApdexSettings(ctx context.Context) ([]model.ApdexSetting, error) {
+	args := dao.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.ApdexSetting), args.Error(1)
+}
+
+func (dao *MockSettingsDao) SetApdexSetting(ctx context.Context, setting *model.ApdexSetting) error {
+	args := dao.Called(ctx, setting)
+	return args.Error(0)
+}
@@ -0,0 +1,62 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"go.uber.org/zap"
+	"goapm/clickhouse"
+	model "goapm/domain"
+	"goapm/logger"
+	"goapm/query_builder"
+	"sync"
+	"time"
+)
+
+const insertApdexSettingQuery = "INSERT INTO apdex_settings (serviceName, threshold, updatedAt) VALUES (?, ?, ?)"
+
+var settingsDaoOnce sync.Once
+var settingsDao *SettingsDaoImpl
+
+// SettingsDaoImpl user editable settings kept in ReplacingMergeTree tables, latest version of a row wins
+type SettingsDaoImpl struct {
+	Logger                      *zap.SugaredLogger
+	ClickhouseConnectionService clickhouse.ClickhouseConnectionService
+}
+
+func NewSettingsDao(ClickhouseConnectionService clickhouse.ClickhouseConnectionService) *SettingsDaoImpl {
+	settingsDaoOnce.Do(func() {
+		settingsDao = &SettingsDaoImpl{
+			Logger:                      logger.LOGGER,
+			ClickhouseConnectionService: ClickhouseConnectionService,
+		}
+	})
+	return settingsDao
+}
+
+func (dao *SettingsDaoImpl) GetApdexSettings(ctx context.Context) ([]model.ApdexSetting, error) {
+	settings := []model.ApdexSetting{}
+	query := query_builder.Select("serviceName", "threshold").
+		From("apdex_settings").
+		Final().
+		OrderBy("serviceName", query_builder.Asc)
+	err := runSelect(ctx, dao.ClickhouseConnectionService, dao.Logger, true, &settings, query)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		settings = []model.ApdexSetting{}
+	}
+	return settings, nil
+}
+
+func (dao *SettingsDaoImpl) SetApdexSetting(ctx context.Context, setting *model.ApdexSetting) error {
+	err := dao.ClickhouseConnectionService.ExecuteInsertFunctionContext(ctx, insertApdexSettingQuery, func(stmt *sql.Stmt) error {
+		_, err := stmt.Exec(setting.ServiceName, setting.Threshold, time.Now())
+		return err
+	})
+	if err != nil {
+		dao.Logger.Debug("Error in saving apdex setting: ", err)
+		return queryError(err)
+	}
+	return nil
+}
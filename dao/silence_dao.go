@@ -0,0 +1,74 @@
+package dao
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+)
+
+type SilenceDao interface {
+	GetSilences(ctx context.Context) ([]model.Silence, error)
+	GetSilence(ctx context.Context, id string) (*model.Silence, error)
+	SaveSilence(ctx context.Context, silence *model.Silence) error
+	DeleteSilence(ctx context.Context, silence *model.Silence) error
+	GetMaintenanceWindows(ctx context.Context) ([]model.MaintenanceWindow, error)
+	GetMaintenanceWindow(ctx context.Context, id string) (*model.MaintenanceWindow, error)
+	SaveMaintenanceWindow(ctx context.Context, window *model.MaintenanceWindow) error
+	DeleteMaintenanceWindow(ctx context.Context, window *model.MaintenanceWindow) error
+}
+
+type MockSilenceDao struct {
+	mock.Mock
+}
+
+func (dao *MockSilenceDao) GetSilences(ctx context.Context) ([]model.Silence, error) {
+	args := dao.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Silence), args.Error(1)
+}
+
+func (dao *MockSilenceDao) GetSilence(ctx context.Context, id string) (*model.Silence, error) {
+	args := dao.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Silence), args.Error(1)
+}
+
+func (dao *MockSilenceDao) SaveSilence(ctx context.Context, silence *model.Silence) error {
+	args := dao.Called(ctx, silence)
+	return args.Error(0)
+}
+
+func (dao *MockSilenceDao) DeleteSilence(ctx context.Context, silence *model.Silence) error {
+	args := dao.Called(ctx, silence)
+	return args.Error(0)
+}
+
+func (dao *MockSilenceDao) GetMaintenanceWindows(ctx context.Context) ([]model.MaintenanceWindow, error) {
+	args := dao.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.MaintenanceWindow), args.Error(1)
+}
+
+func (dao *MockSilenceDao) GetMaintenanceWindow(ctx context.Context, id string) (*model.MaintenanceWindow, error) {
+	args := dao.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.MaintenanceWindow), args.Error(1)
+}
+
+func (dao *MockSilenceDao) SaveMaintenanceWindow(ctx context.Context, window *model.MaintenanceWindow) error {
+	args := dao.Called(ctx, window)
+	return args.Error(0)
+}
+
+func (dao *MockSilenceDao) DeleteMaintenanceWindow(ctx context.Context, window *model.MaintenanceWindow) error {
+	args := dao.Called(ctx, window)
+	return args.Error(0)
+}
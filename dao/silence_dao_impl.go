@@ -0,0 +1,209 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"go.uber.org/zap"
+	"goapm/clickhouse"
+	model "goapm/domain"
+	"goapm/logger"
+	"goapm/query_builder"
+	"sync"
+	"time"
+)
+
+const insertSilenceQuery = "INSERT INTO alert_silences (id, matchers, startsAt, endsAt, createdBy, comment, deleted, updatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+const insertMaintenanceWindowQuery = "INSERT INTO maintenance_windows (id, name, matchers, schedule, durationSeconds, createdBy, comment, disabled, deleted, updatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+var silenceDaoOnce sync.Once
+var silenceDao *SilenceDaoImpl
+
+// SilenceDaoImpl silences and maintenance windows are versioned the same way as alert rules
+type SilenceDaoImpl struct {
+	Logger                      *zap.SugaredLogger
+	ClickhouseConnectionService clickhouse.ClickhouseConnectionService
+}
+
+func NewSilenceDao(ClickhouseConnectionService clickhouse.ClickhouseConnectionService) *SilenceDaoImpl {
+	silenceDaoOnce.Do(func() {
+		silenceDao = &SilenceDaoImpl{
+			Logger:                      logger.LOGGER,
+			ClickhouseConnectionService: ClickhouseConnectionService,
+		}
+	})
+	return silenceDao
+}
+
+type silenceRow struct {
+	ID        string `db:"id"`
+	Matchers  string `db:"matchers"`
+	StartsAt  int64  `db:"startsAtNano"`
+	EndsAt    int64  `db:"endsAtNano"`
+	CreatedBy string `db:"createdBy"`
+	Comment   string `db:"comment"`
+	UpdatedAt int64  `db:"updatedAtNano"`
+}
+
+func (row *silenceRow) silence() model.Silence {
+	silence := model.Silence{
+		ID:        row.ID,
+		Matchers:  map[string]string{},
+		StartsAt:  row.StartsAt,
+		EndsAt:    row.EndsAt,
+		CreatedBy: row.CreatedBy,
+		Comment:   row.Comment,
+		UpdatedAt: row.UpdatedAt,
+	}
+	_ = json.Unmarshal([]byte(row.Matchers), &silence.Matchers)
+	return silence
+}
+
+func silencesQuery() *query_builder.SelectBuilder {
+	return query_builder.Select("id", "matchers", "toUnixTimestamp64Nano(startsAt) as startsAtNano", "toUnixTimestamp64Nano(endsAt) as endsAtNano",
+		"createdBy", "comment", "toUnixTimestamp64Nano(updatedAt) as updatedAtNano").
+		From("alert_silences").
+		Final().
+		Where("deleted = 0")
+}
+
+// GetSilences newest first, expired silences are kept until table ttl removes them
+func (dao *SilenceDaoImpl) GetSilences(ctx context.Context) ([]model.Silence, error) {
+	var rows []silenceRow
+	err := dao.selectAll(ctx, &rows, silencesQuery().OrderBy("startsAtNano", query_builder.Desc).OrderBy("id", query_builder.Asc))
+	if err != nil {
+		return nil, err
+	}
+	silences := make([]model.Silence, len(rows))
+	for i := range rows {
+		silences[i] = rows[i].silence()
+	}
+	return silences, nil
+}
+
+// GetSilence return nil when silence doesn't exist or was deleted
+func (dao *SilenceDaoImpl) GetSilence(ctx context.Context, id string) (*model.Silence, error) {
+	var rows []silenceRow
+	err := dao.selectAll(ctx, &rows, silencesQuery().Where("id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	silence := rows[0].silence()
+	return &silence, nil
+}
+
+func (dao *SilenceDaoImpl) SaveSilence(ctx context.Context, silence *model.Silence) error {
+	return dao.insertSilence(ctx, silence, false)
+}
+
+func (dao *SilenceDaoImpl) DeleteSilence(ctx context.Context, silence *model.Silence) error {
+	return dao.insertSilence(ctx, silence, true)
+}
+
+func (dao *SilenceDaoImpl) insertSilence(ctx context.Context, silence *model.Silence, deleted bool) error {
+	matchers, err := json.Marshal(silence.Matchers)
+	if err != nil {
+		return err
+	}
+	return dao.insert(ctx, insertSilenceQuery, silence.ID, string(matchers), time.Unix(0, silence.StartsAt), time.Unix(0, silence.EndsAt),
+		silence.CreatedBy, silence.Comment, boolToUInt8(deleted), time.Now())
+}
+
+type maintenanceWindowRow struct {
+	ID              string `db:"id"`
+	Name            string `db:"name"`
+	Matchers        string `db:"matchers"`
+	Schedule        string `db:"schedule"`
+	DurationSeconds uint32 `db:"durationSeconds"`
+	CreatedBy       string `db:"createdBy"`
+	Comment         string `db:"comment"`
+	Disabled        uint8  `db:"disabled"`
+	UpdatedAt       int64  `db:"updatedAtNano"`
+}
+
+func (row *maintenanceWindowRow) window() model.MaintenanceWindow {
+	window := model.MaintenanceWindow{
+		ID:              row.ID,
+		Name:            row.Name,
+		Matchers:        map[string]string{},
+		Schedule:        row.Schedule,
+		DurationSeconds: int(row.DurationSeconds),
+		CreatedBy:       row.CreatedBy,
+		Comment:         row.Comment,
+		Disabled:        row.Disabled == 1,
+		UpdatedAt:       row.UpdatedAt,
+	}
+	_ = json.Unmarshal([]byte(row.Matchers), &window.Matchers)
+	return window
+}
+
+func maintenanceWindowsQuery() *query_builder.SelectBuilder {
+	return query_builder.Select("id", "name", "matchers", "schedule", "durationSeconds", "createdBy", "comment", "disabled",
+		"toUnixTimestamp64Nano(updatedAt) as updatedAtNano").
+		From("maintenance_windows").
+		Final().
+		Where("deleted = 0")
+}
+
+func (dao *SilenceDaoImpl) GetMaintenanceWindows(ctx context.Context) ([]model.MaintenanceWindow, error) {
+	var rows []maintenanceWindowRow
+	err := dao.selectAll(ctx, &rows, maintenanceWindowsQuery().OrderBy("name", query_builder.Asc).OrderBy("id", query_builder.Asc))
+	if err != nil {
+		return nil, err
+	}
+	windows := make([]model.MaintenanceWindow, len(rows))
+	for i := range rows {
+		windows[i] = rows[i].window()
+	}
+	return windows, nil
+}
+
+// GetMaintenanceWindow return nil when window doesn't exist or was deleted
+func (dao *SilenceDaoImpl) GetMaintenanceWindow(ctx context.Context, id string) (*model.MaintenanceWindow, error) {
+	var rows []maintenanceWindowRow
+	err := dao.selectAll(ctx, &rows, maintenanceWindowsQuery().Where("id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	window := rows[0].window()
+	return &window, nil
+}
+
+func (dao *SilenceDaoImpl) SaveMaintenanceWindow(ctx context.Context, window *model.MaintenanceWindow) error {
+	return dao.insertMaintenanceWindow(ctx, window, false)
+}
+
+func (dao *SilenceDaoImpl) DeleteMaintenanceWindow(ctx context.Context, window *model.MaintenanceWindow) error {
+	return dao.insertMaintenanceWindow(ctx, window, true)
+}
+
+func (dao *SilenceDaoImpl) insertMaintenanceWindow(ctx context.Context, window *model.MaintenanceWindow, deleted bool) error {
+	matchers, err := json.Marshal(window.Matchers)
+	if err != nil {
+		return err
+	}
+	return dao.insert(ctx, insertMaintenanceWindowQuery, window.ID, window.Name, string(matchers), window.Schedule,
+		uint32(window.DurationSeconds), window.CreatedBy, window.Comment, boolToUInt8(window.Disabled), boolToUInt8(deleted), time.Now())
+}
+
+func (dao *SilenceDaoImpl) insert(ctx context.Context, query string, args ...interface{}) error {
+	err := dao.ClickhouseConnectionService.ExecuteInsertFunctionContext(ctx, query, func(stmt *sql.Stmt) error {
+		_, err := stmt.Exec(args...)
+		return err
+	})
+	if err != nil {
+		dao.Logger.Debug("Error in saving silence: ", err)
+		return queryError(err)
+	}
+	return nil
+}
+
+func (dao *SilenceDaoImpl) selectAll(ctx context.Context, dest interface{}, builder *query_builder.SelectBuilder) error {
+	return runSelect(ctx, dao.ClickhouseConnectionService, dao.Logger, true, dest, builder)
+}
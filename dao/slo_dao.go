@@ -0,0 +1,44 @@
+package dao
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+)
+
+type SLODao interface {
+	GetSLOs(ctx context.Context) ([]model.SLO, error)
+	GetSLO(ctx context.Context, id string) (*model.SLO, error)
+	SaveSLO(ctx context.Context, slo *model.SLO) error
+	DeleteSLO(ctx context.Context, slo *model.SLO) error
+}
+
+type MockSLODao struct {
+	mock.Mock
+}
+
+func (dao *MockSLODao) GetSLOs(ctx context.Context) ([]model.SLO, error) {
+	args := dao.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.SLO), args.Error(1)
+}
+
+func (dao *MockSLODao) GetSLO(ctx context.Context, id string) (*model.SLO, error) {
+	args := dao.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.SLO), args.Error(1)
+}
+
+func (dao *MockSLODao) SaveSLO(ctx context.Context, slo *model.SLO) error {
+	args := dao.Called(ctx, slo)
+	return args.Error(0)
+}
+
+func (dao *MockSLODao) DeleteSLO(ctx context.Context, slo *model.SLO) error {
+	args := dao.Called(ctx, slo)
+	return args.Error(0)
+}
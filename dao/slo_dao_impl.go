@@ -0,0 +1,129 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"go.uber.org/zap"
+	"goapm/clickhouse"
+	model "goapm/domain"
+	"goapm/logger"
+	"goapm/query_builder"
+	"sync"
+	"time"
+)
+
+const insertSLOQuery = "INSERT INTO slos (id, name, serviceName, operation, sliType, latencyThresholdMs, target, windowDays, labels, deleted, updatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+var sloDaoOnce sync.Once
+var sloDao *SLODaoImpl
+
+// SLODaoImpl slos are versioned the same way as alert rules
+type SLODaoImpl struct {
+	Logger                      *zap.SugaredLogger
+	ClickhouseConnectionService clickhouse.ClickhouseConnectionService
+}
+
+func NewSLODao(ClickhouseConnectionService clickhouse.ClickhouseConnectionService) *SLODaoImpl {
+	sloDaoOnce.Do(func() {
+		sloDao = &SLODaoImpl{
+			Logger:                      logger.LOGGER,
+			ClickhouseConnectionService: ClickhouseConnectionService,
+		}
+	})
+	return sloDao
+}
+
+type sloRow struct {
+	ID                 string  `db:"id"`
+	Name               string  `db:"name"`
+	ServiceName        string  `db:"serviceName"`
+	Operation          string  `db:"operation"`
+	SLIType            string  `db:"sliType"`
+	LatencyThresholdMs uint32  `db:"latencyThresholdMs"`
+	Target             float64 `db:"target"`
+	WindowDays         uint16  `db:"windowDays"`
+	Labels             string  `db:"labels"`
+	UpdatedAt          int64   `db:"updatedAtNano"`
+}
+
+func (row *sloRow) slo() model.SLO {
+	slo := model.SLO{
+		ID:                 row.ID,
+		Name:               row.Name,
+		ServiceName:        row.ServiceName,
+		Operation:          row.Operation,
+		SLIType:            row.SLIType,
+		LatencyThresholdMs: int64(row.LatencyThresholdMs),
+		Target:             row.Target,
+		WindowDays:         int(row.WindowDays),
+		Labels:             map[string]string{},
+		UpdatedAt:          row.UpdatedAt,
+	}
+	_ = json.Unmarshal([]byte(row.Labels), &slo.Labels)
+	return slo
+}
+
+func slosQuery() *query_builder.SelectBuilder {
+	return query_builder.Select("id", "name", "serviceName", "operation", "sliType", "latencyThresholdMs", "target", "windowDays", "labels",
+		"toUnixTimestamp64Nano(updatedAt) as updatedAtNano").
+		From("slos").
+		Final().
+		Where("deleted = 0")
+}
+
+func (dao *SLODaoImpl) GetSLOs(ctx context.Context) ([]model.SLO, error) {
+	var rows []sloRow
+	err := dao.selectAll(ctx, &rows, slosQuery().OrderBy("name", query_builder.Asc).OrderBy("id", query_builder.Asc))
+	if err != nil {
+		return nil, err
+	}
+	slos := make([]model.SLO, len(rows))
+	for i := range rows {
+		slos[i] = rows[i].slo()
+	}
+	return slos, nil
+}
+
+// GetSLO return nil when slo doesn't exist or was deleted
+func (dao *SLODaoImpl) GetSLO(ctx context.Context, id string) (*model.SLO, error) {
+	var rows []sloRow
+	err := dao.selectAll(ctx, &rows, slosQuery().Where("id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	slo := rows[0].slo()
+	return &slo, nil
+}
+
+func (dao *SLODaoImpl) SaveSLO(ctx context.Context, slo *model.SLO) error {
+	return dao.insertSLO(ctx, slo, false)
+}
+
+func (dao *SLODaoImpl) DeleteSLO(ctx context.Context, slo *model.SLO) error {
+	return dao.insertSLO(ctx, slo, true)
+}
+
+func (dao *SLODaoImpl) insertSLO(ctx context.Context, slo *model.SLO, deleted bool) error {
+	labels, err := json.Marshal(slo.Labels)
+	if err != nil {
+		return err
+	}
+	err = dao.ClickhouseConnectionService.ExecuteInsertFunctionContext(ctx, insertSLOQuery, func(stmt *sql.Stmt) error {
+		_, err := stmt.Exec(slo.ID, slo.Name, slo.ServiceName, slo.Operation, slo.SLIType, uint32(slo.LatencyThresholdMs), slo.Target,
+			uint16(slo.WindowDays), string(labels), boolToUInt8(deleted), time.Now())
+		return err
+	})
+	if err != nil {
+		dao.Logger.Debug("Error in saving slo: ", err)
+		return queryError(err)
+	}
+	return nil
+}
+
+func (dao *SLODaoImpl) selectAll(ctx context.Context, dest interface{}, builder *query_builder.SelectBuilder) error {
+	return runSelect(ctx, dao.ClickhouseConnectionService, dao.Logger, true, dest, builder)
+}
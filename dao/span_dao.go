@@ -0,0 +1,20 @@
+package dao
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+)
+
+type SpanDao interface {
+	InsertSpans(ctx context.Context, spans []model.Span) error
+}
+
+type MockSpanDao struct {
+	mock.Mock
+}
+
+func (dao *MockSpanDao) InsertSpans(ctx context.Context, spans []model.Span) error {
+	args := dao.Called(ctx, spans)
+	return args.Error(0)
+}
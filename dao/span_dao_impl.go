@@ -0,0 +1,62 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"goapm/clickhouse"
+	model "goapm/domain"
+	"goapm/logger"
+	"goapm/utils"
+	"sync"
+)
+
+const insertSpanQuery = "INSERT INTO signoz_index_tmp (timestamp, traceID, spanID, parentSpanID, serviceName, name, kind, durationNano, tags, tagsKeys, tagsValues, statusCode, references, externalHttpMethod, externalHttpUrl, component, dbSystem, dbName, dbOperation, peerService, externalHost) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+var spanDaoOnce sync.Once
+var spanDao *SpanDaoImpl
+
+type SpanDaoImpl struct {
+	Logger                      *zap.SugaredLogger
+	ClickhouseConnectionService clickhouse.ClickhouseConnectionService
+}
+
+func NewSpanDao(ClickhouseConnectionService clickhouse.ClickhouseConnectionService) *SpanDaoImpl {
+	spanDaoOnce.Do(func() {
+		spanDao = &SpanDaoImpl{
+			Logger:                      logger.LOGGER,
+			ClickhouseConnectionService: ClickhouseConnectionService,
+		}
+	})
+	return spanDao
+}
+
+// InsertSpans insert spans into signoz_index_tmp, every SPAN_INSERT_BATCH_SIZE spans are sent in a single transaction
+func (dao *SpanDaoImpl) InsertSpans(ctx context.Context, spans []model.Span) error {
+	batchSize := utils.GetOrDefaultInt(viper.GetString("SPAN_INSERT_BATCH_SIZE"), 10000)
+	for start := 0; start < len(spans); start += batchSize {
+		end := start + batchSize
+		if end > len(spans) {
+			end = len(spans)
+		}
+		batch := spans[start:end]
+		err := dao.ClickhouseConnectionService.ExecuteInsertFunctionContext(ctx, insertSpanQuery, func(stmt *sql.Stmt) error {
+			for i := range batch {
+				span := &batch[i]
+				_, err := stmt.Exec(span.Timestamp, span.TraceID, span.SpanID, span.ParentSpanID, span.ServiceName, span.Name,
+					span.Kind, span.DurationNano, span.Tags, span.TagsKeys, span.TagsValues, span.StatusCode, span.References,
+					span.ExternalHttpMethod, span.ExternalHttpUrl, span.Component, span.DBSystem, span.DBName, span.DBOperation, span.PeerService, span.ExternalHost)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			dao.Logger.Debug("Error in inserting spans: ", err)
+			return queryError(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package model
+
+import "time"
+
+// metrics of alert rules, latency thresholds are in milliseconds, rates in percent
+const (
+	AlertMetricErrorRate    = "error_rate"
+	AlertMetricP99Latency   = "p99_latency"
+	AlertMetricCallRateDrop = "call_rate_drop"
+	AlertMetricApdex        = "apdex"
+	AlertMetricSLOBurnRate  = "slo_burn_rate"
+)
+
+const (
+	AlertOperatorAbove = "above"
+	AlertOperatorBelow = "below"
+)
+
+// states of alert rule, rule is pending while its condition holds for less than its for duration
+const (
+	AlertStateInactive = "inactive"
+	AlertStatePending  = "pending"
+	AlertStateFiring   = "firing"
+	AlertStateResolved = "resolved"
+)
+
+// AlertRule condition on metric of service evaluated over last WindowSeconds, State and StateSince are filled
+// from rule history when rules are listed
+type AlertRule struct {
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	Metric        string            `json:"metric"`
+	ServiceName   string            `json:"serviceName"`
+	Operator      string            `json:"operator"`
+	Threshold     float64           `json:"threshold"`
+	WindowSeconds int               `json:"windowSeconds"`
+	ForSeconds    int               `json:"forSeconds"`
+	Labels        map[string]string `json:"labels"`
+	SLOID         string            `json:"sloID,omitempty"`
+	Disabled      bool              `json:"disabled"`
+	UpdatedAt     int64             `json:"updatedAt"`
+	State         string            `json:"state,omitempty"`
+	StateSince    int64             `json:"stateSince,omitempty"`
+}
+
+// ConditionMet compare metric value with threshold by operator of rule
+func (rule *AlertRule) ConditionMet(value float64) bool {
+	if rule.Operator == AlertOperatorBelow {
+		return value < rule.Threshold
+	}
+	return value > rule.Threshold
+}
+
+// AlertStatus state of rule after its last transition and metric value which caused it
+type AlertStatus struct {
+	RuleID string  `db:"ruleID"`
+	State  string  `db:"state"`
+	Value  float64 `db:"value"`
+	Since  int64   `db:"since"`
+}
+
+// AlertHistoryItem state transition of rule with metric value which caused it
+type AlertHistoryItem struct {
+	RuleID    string  `json:"ruleID" db:"ruleID"`
+	State     string  `json:"state" db:"state"`
+	Value     float64 `json:"value" db:"value"`
+	Threshold float64 `json:"threshold" db:"threshold"`
+	Timestamp int64   `json:"timestamp" db:"timestampNano"`
+}
+
+// NextAlertState state of rule after evaluation at now, since is the time rule entered current state.
+// Condition has to hold for forDuration before pending rule fires, firing rule resolves as soon as condition doesn't hold
+func NextAlertState(state string, since time.Time, conditionMet bool, now time.Time, forDuration time.Duration) string {
+	switch {
+	case conditionMet && state == AlertStateFiring:
+		return AlertStateFiring
+	case conditionMet && state == AlertStatePending:
+		if now.Sub(since) >= forDuration {
+			return AlertStateFiring
+		}
+		return AlertStatePending
+	case conditionMet:
+		if forDuration <= 0 {
+			return AlertStateFiring
+		}
+		return AlertStatePending
+	case state == AlertStateFiring:
+		return AlertStateResolved
+	case state == AlertStatePending:
+		return AlertStateInactive
+	case state == "":
+		return AlertStateInactive
+	}
+	return state
+}
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// RED metrics checked for anomalies, call rate is in calls per second, error rate in percent, p99 in nanoseconds
+const (
+	AnomalyMetricCallRate  = "call_rate"
+	AnomalyMetricErrorRate = "error_rate"
+	AnomalyMetricP99       = "p99"
+)
+
+var AnomalyMetrics = []string{AnomalyMetricCallRate, AnomalyMetricErrorRate, AnomalyMetricP99}
+
+const (
+	AnomalyDirectionAbove = "above"
+	AnomalyDirectionBelow = "below"
+)
+
+// Anomaly metric of service in window starting at Timestamp which deviates from the same window of previous weeks.
+// Baseline is median of previous weeks, Deviation is their median absolute deviation scaled to standard deviation
+// and Score is robust z-score of Value
+type Anomaly struct {
+	ServiceName   string  `json:"serviceName" db:"serviceName"`
+	Metric        string  `json:"metric" db:"metric"`
+	Timestamp     int64   `json:"timestamp" db:"timestampNano"`
+	WindowSeconds int     `json:"windowSeconds" db:"windowSeconds"`
+	Value         float64 `json:"value" db:"value"`
+	Baseline      float64 `json:"baseline" db:"baseline"`
+	Deviation     float64 `json:"deviation" db:"deviation"`
+	Score         float64 `json:"score" db:"score"`
+	Direction     string  `json:"direction" db:"direction"`
+}
+
+type GetAnomaliesParams struct {
+	ServiceName string
+	Metric      string
+	Start       *time.Time
+	End         *time.Time
+	Limit       int
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// span search sort columns and directions
+const (
+	SpanOrderParamTimestamp = "timestamp"
+	SpanOrderParamDuration  = "duration"
+	SpanOrderAscending      = "ascending"
+	SpanOrderDescending     = "descending"
+)
+
+// SpanSearchCursor keyset position of the last returned span, Value is timestamp nanos or duration nanos by OrderParam,
+// SpanID breaks ties between spans with same value
+type SpanSearchCursor struct {
+	OrderParam string `json:"p"`
+	Order      string `json:"o"`
+	Value      int64  `json:"v"`
+	SpanID     string `json:"s"`
+}
+
+// Encode cursor as opaque url safe string
+func (cursor *SpanSearchCursor) Encode() string {
+	value, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+// DecodeSpanSearchCursor parse cursor returned by Encode
+func DecodeSpanSearchCursor(encoded string) (*SpanSearchCursor, error) {
+	value, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("cursor param is not in correct format")
+	}
+	cursor := &SpanSearchCursor{}
+	if err = json.Unmarshal(value, cursor); err != nil || len(cursor.SpanID) == 0 {
+		return nil, errors.New("cursor param is not in correct format")
+	}
+	return cursor, nil
+}
@@ -0,0 +1,80 @@
+package model
+
+import "sort"
+
+// types of notification channels
+const (
+	ChannelTypeWebhook = "webhook"
+	ChannelTypeSlack   = "slack"
+	ChannelTypeEmail   = "email"
+)
+
+const DefaultRepeatIntervalSeconds = 4 * 60 * 60
+
+// NotificationChannel destination of alert notifications. Alerts whose labels contain all Matchers are sent to channel,
+// grouped by values of GroupBy labels, group is sent again after RepeatIntervalSeconds while its alerts keep firing.
+// Template is go text/template rendering webhook json body from NotificationGroup
+type NotificationChannel struct {
+	ID                    string            `json:"id"`
+	Name                  string            `json:"name"`
+	Type                  string            `json:"type"`
+	URL                   string            `json:"url,omitempty"`
+	Headers               map[string]string `json:"headers,omitempty"`
+	Template              string            `json:"template,omitempty"`
+	Recipients            []string          `json:"recipients,omitempty"`
+	Matchers              map[string]string `json:"matchers"`
+	GroupBy               []string          `json:"groupBy"`
+	RepeatIntervalSeconds int               `json:"repeatIntervalSeconds"`
+	Disabled              bool              `json:"disabled"`
+	UpdatedAt             int64             `json:"updatedAt"`
+}
+
+// Matches labels contain every matcher of channel, channel without matchers receives all alerts
+func (channel *NotificationChannel) Matches(labels map[string]string) bool {
+	return MatchLabels(channel.Matchers, labels)
+}
+
+// Notification alert of rule in notification group, Since is the time rule entered its state
+type Notification struct {
+	RuleID      string            `json:"ruleID"`
+	RuleName    string            `json:"ruleName"`
+	ServiceName string            `json:"serviceName"`
+	Metric      string            `json:"metric"`
+	State       string            `json:"state"`
+	Value       float64           `json:"value"`
+	Threshold   float64           `json:"threshold"`
+	Labels      map[string]string `json:"labels"`
+	Since       int64             `json:"since"`
+}
+
+// NotificationGroup alerts sent to channel in one message
+type NotificationGroup struct {
+	ChannelID   string            `json:"channelID"`
+	ChannelName string            `json:"channelName"`
+	GroupLabels map[string]string `json:"groupLabels"`
+	Firing      []Notification    `json:"firing"`
+	Resolved    []Notification    `json:"resolved"`
+}
+
+// NotificationLabels labels of rule used by channel matchers, grouping and silences, rule labels can't override built in ones
+func (rule *AlertRule) NotificationLabels() map[string]string {
+	labels := make(map[string]string, len(rule.Labels)+4)
+	for key, value := range rule.Labels {
+		labels[key] = value
+	}
+	labels["ruleID"] = rule.ID
+	labels["alertname"] = rule.Name
+	labels["service"] = rule.ServiceName
+	labels["metric"] = rule.Metric
+	return labels
+}
+
+// SortNotifications order notifications by rule name and id so messages and their fingerprints are stable
+func SortNotifications(notifications []Notification) {
+	sort.Slice(notifications, func(i, j int) bool {
+		if notifications[i].RuleName != notifications[j].RuleName {
+			return notifications[i].RuleName < notifications[j].RuleName
+		}
+		return notifications[i].RuleID < notifications[j].RuleID
+	})
+}
@@ -25,6 +25,33 @@ type GetTopEndpointsParams struct {
 	ServiceName string
 	Start       *time.Time
 	End         *time.Time
+	CompareTo   time.Duration
+}
+
+type GetTagValuesParams struct {
+	Key           string
+	Prefix        string
+	ServiceName   string
+	OperationName string
+	Limit         int
+	Start         *time.Time
+	End           *time.Time
+}
+
+type GetErrorsParams struct {
+	ServiceName string
+	Limit       int
+	Offset      int
+	Start       *time.Time
+	End         *time.Time
+}
+
+type GetErrorParams struct {
+	Fingerprint string
+	ServiceName string
+	StepSeconds int
+	Start       *time.Time
+	End         *time.Time
 }
 
 type GetUsageParams struct {
@@ -43,6 +70,76 @@ type GetServicesParams struct {
 	Period    int
 	Start     *time.Time
 	End       *time.Time
+	CompareTo time.Duration
+}
+
+// GetServiceMapParams service map of environment, when FocusService is set only services up to Hops calls away are kept
+type GetServiceMapParams struct {
+	Period       int
+	Environment  string
+	FocusService string
+	Hops         int
+	Start        *time.Time
+	End          *time.Time
+}
+
+// GetServiceMetricsParams totals of service server spans in time range, apdex needs a join with settings and is computed only on demand
+type GetServiceMetricsParams struct {
+	ServiceName string
+	Apdex       bool
+	Start       *time.Time
+	End         *time.Time
+}
+
+// GetLatencyHistogramParams spans matching span search filters counted in duration buckets, Boundaries are ascending
+// nanoseconds, StepSeconds is used only by heatmap
+type GetLatencyHistogramParams struct {
+	Filters     *SpanSearchParams
+	Boundaries  []int64
+	StepSeconds int
+}
+
+// DefaultLatencyBoundaries log scale boundaries from 1ms to ~65s, each bucket is twice as wide as the previous one
+func DefaultLatencyBoundaries() []int64 {
+	boundaries := make([]int64, 17)
+	for i := range boundaries {
+		boundaries[i] = int64(time.Millisecond) << i
+	}
+	return boundaries
+}
+
+// DefaultApdexThreshold apdex threshold in seconds of services without setting
+const DefaultApdexThreshold = 0.5
+
+// LatencyThresholdsMillis latency thresholds counted by latencyCounts of signoz_index_aggregated, the list has to stay
+// in sync with signoz_index_aggregated_mv and a threshold can only be appended. Rows written before a threshold was
+// appended don't count towards it
+var LatencyThresholdsMillis = []int64{5, 10, 25, 50, 100, 200, 250, 300, 400, 500, 750, 800, 1000, 1200, 1500, 2000, 2500, 3000, 4000,
+	5000, 10000, 30000, 60000}
+
+type GetServiceExternalParams struct {
+	ServiceName string
+	GroupBy     string
+	StepSeconds int
+	Start       *time.Time
+	End         *time.Time
+}
+
+// groupBy values of service external calls, url groups also carry their host
+const (
+	ExternalGroupHost = "host"
+	ExternalGroupURL  = "url"
+)
+
+type GetDBStatementsParams struct {
+	ServiceName string
+	DBSystem    string
+	DBName      string
+	DBOperation string
+	Limit       int
+	Period      int
+	Start       *time.Time
+	End         *time.Time
 }
 
 type GetServiceOverviewParams struct {
@@ -53,6 +150,8 @@ type GetServiceOverviewParams struct {
 	ServiceName string
 	Period      string
 	StepSeconds int
+	CompareTo   time.Duration
+	Apdex       bool
 }
 
 type ApplicationPercentileParams struct {
@@ -88,8 +187,18 @@ type SpanSearchAggregatesParams struct {
 	StepSeconds       int
 	Dimension         string
 	AggregationOption string
+	GroupBy           string
+	GroupLimit        int
 }
 
+// groupBy values of span aggregates, any tag can be used as group with tag: prefix, e.g. tag:http.method
+const (
+	AggregateGroupService    = "service"
+	AggregateGroupOperation  = "operation"
+	AggregateGroupStatusCode = "status_code"
+	AggregateGroupTagPrefix  = "tag:"
+)
+
 type SpanSearchParams struct {
 	ServiceName   string
 	OperationName string
@@ -101,7 +210,9 @@ type SpanSearchParams struct {
 	MaxDuration   string
 	Limit         int64
 	Order         string
+	OrderParam    string
 	Offset        int64
+	Cursor        *SpanSearchCursor
 	BatchSize     int64
 	Tags          []TagQuery
 }
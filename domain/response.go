@@ -11,18 +11,31 @@ type ApiError struct {
 	Typ ErrorType
 	Err error
 }
+
+func (apiError *ApiError) Error() string {
+	if apiError.Err == nil {
+		return string(apiError.Typ)
+	}
+	return apiError.Err.Error()
+}
+
+func (apiError *ApiError) Unwrap() error {
+	return apiError.Err
+}
+
 type ErrorType string
 
 const (
-	ErrorNone           ErrorType = ""
-	ErrorTimeout        ErrorType = "timeout"
-	ErrorCanceled       ErrorType = "canceled"
-	ErrorExec           ErrorType = "execution"
-	ErrorBadData        ErrorType = "bad_data"
-	ErrorInternal       ErrorType = "internal"
-	ErrorUnavailable    ErrorType = "unavailable"
-	ErrorNotFound       ErrorType = "not_found"
-	ErrorNotImplemented ErrorType = "not_implemented"
+	ErrorNone            ErrorType = ""
+	ErrorTimeout         ErrorType = "timeout"
+	ErrorCanceled        ErrorType = "canceled"
+	ErrorExec            ErrorType = "execution"
+	ErrorBadData         ErrorType = "bad_data"
+	ErrorInternal        ErrorType = "internal"
+	ErrorUnavailable     ErrorType = "unavailable"
+	ErrorNotFound        ErrorType = "not_found"
+	ErrorNotImplemented  ErrorType = "not_implemented"
+	ErrorTooManyRequests ErrorType = "too_many_requests"
 )
 
 type ServiceItem struct {
@@ -35,6 +48,8 @@ type ServiceItem struct {
 	ErrorRate    float32 `json:"errorRate" db:"errorRate"`
 	Num4XX       int     `json:"num4XX" db:"num4xx"`
 	FourXXRate   float32 `json:"fourXXRate" db:"fourXXRate"`
+	ApdexScore
+	Comparison *MetricsComparison `json:"comparison,omitempty" db:"-"`
 }
 
 type ServiceListErrorItem struct {
@@ -59,6 +74,8 @@ type ServiceOverviewItem struct {
 	CallRate     float32 `json:"callRate" db:"callRate"`
 	NumErrors    int     `json:"numErrors" db:"numErrors"`
 	ErrorRate    float32 `json:"errorRate" db:"errorRate"`
+	*ApdexScore
+	Comparison *MetricsComparison `json:"comparison,omitempty" db:"-"`
 }
 
 type SearchSpansResult struct {
@@ -67,25 +84,48 @@ type SearchSpansResult struct {
 }
 
 type TraceResult struct {
-	Data   []interface{} `json:"data" db:"data"`
-	Total  int           `json:"total" db:"total"`
-	Limit  int           `json:"limit" db:"limit"`
-	Offset int           `json:"offset" db:"offset"`
+	Data       []interface{} `json:"data" db:"data"`
+	Total      int           `json:"total" db:"total"`
+	Limit      int           `json:"limit" db:"limit"`
+	Offset     int           `json:"offset" db:"offset"`
+	NextCursor string        `json:"nextCursor,omitempty" db:"nextCursor"`
 }
 type TraceResultItem struct {
-	TraceID string
-	Spans   []TraceResultSpan
+	TraceID           string            `json:"traceID"`
+	RootServiceName   string            `json:"rootServiceName"`
+	RootOperationName string            `json:"rootOperationName"`
+	StartTime         int64             `json:"startTime"`
+	DurationNano      int64             `json:"durationNano"`
+	SpanCount         int               `json:"spanCount"`
+	ErrorCount        int               `json:"errorCount"`
+	ServiceNames      []string          `json:"serviceNames"`
+	Spans             []TraceResultSpan `json:"spans,omitempty"`
 }
 type TraceResultSpan struct {
-	Timestamp    string   `db:"timestamp"`
-	SpanID       string   `db:"spanID"`
-	TraceID      string   `db:"traceID"`
-	ServiceName  string   `db:"serviceName"`
-	Name         string   `db:"name"`
-	Kind         int32    `db:"kind"`
-	DurationNano int64    `db:"durationNano"`
-	TagsKeys     []string `db:"tagsKeys"`
-	TagsValues   []string `db:"tagsValues"`
+	Timestamp    string   `json:"timestamp" db:"timestamp"`
+	SpanID       string   `json:"spanID" db:"spanID"`
+	TraceID      string   `json:"traceID" db:"traceID"`
+	ParentSpanID string   `json:"parentSpanID" db:"parentSpanID"`
+	ServiceName  string   `json:"serviceName" db:"serviceName"`
+	Name         string   `json:"name" db:"name"`
+	Kind         int32    `json:"kind" db:"kind"`
+	DurationNano int64    `json:"durationNano" db:"durationNano"`
+	StatusCode   int64    `json:"statusCode" db:"statusCode"`
+	TagsKeys     []string `json:"tagsKeys" db:"tagsKeys"`
+	TagsValues   []string `json:"tagsValues" db:"tagsValues"`
+}
+
+// IsError span is an error when it has error tag or http 5xx/otel error status code
+func (span *TraceResultSpan) IsError() bool {
+	if span.StatusCode >= 500 || span.StatusCode == SpanStatusError {
+		return true
+	}
+	for i, key := range span.TagsKeys {
+		if key == "error" && i < len(span.TagsValues) && span.TagsValues[i] == "true" {
+			return true
+		}
+	}
+	return false
 }
 
 type SearchSpanReponseItem struct {
@@ -101,6 +141,36 @@ type SearchSpanReponseItem struct {
 	TagsValues   []string `db:"tagsValues"`
 }
 
+// TraceTree spans of a trace linked to their parents, spans whose parent isn't in the trace are extra roots
+// marked as orphans
+type TraceTree struct {
+	TraceID      string           `json:"traceID"`
+	StartTime    int64            `json:"startTime"`
+	DurationNano int64            `json:"durationNano"`
+	SpanCount    int              `json:"spanCount"`
+	OrphanCount  int              `json:"orphanCount"`
+	CriticalPath []string         `json:"criticalPath"`
+	Roots        []*TraceTreeSpan `json:"roots"`
+}
+
+type TraceTreeSpan struct {
+	SpanID         string           `json:"spanID"`
+	ParentSpanID   string           `json:"parentSpanID,omitempty"`
+	ServiceName    string           `json:"serviceName"`
+	Name           string           `json:"name"`
+	Kind           int32            `json:"kind"`
+	StartTime      int64            `json:"startTime"`
+	DurationNano   int64            `json:"durationNano"`
+	SelfTimeNano   int64            `json:"selfTimeNano"`
+	Depth          int              `json:"depth"`
+	Orphan         bool             `json:"orphan"`
+	OnCriticalPath bool             `json:"onCriticalPath"`
+	TagsKeys       []string         `json:"tagsKeys"`
+	TagsValues     []string         `json:"tagsValues"`
+	References     []OtelSpanRef    `json:"references"`
+	Children       []*TraceTreeSpan `json:"children"`
+}
+
 type OtelSpanRef struct {
 	TraceId string `json:"traceId,omitempty"`
 	SpanId  string `json:"spanId,omitempty"`
@@ -114,11 +184,36 @@ func (ref *OtelSpanRef) toString() string {
 	return retString
 }
 
+// GetReferences parse references column, invalid json is treated as span without references
+func (item *SearchSpanReponseItem) GetReferences() []OtelSpanRef {
+	references := []OtelSpanRef{}
+	if err := json.Unmarshal([]byte(item.References), &references); err != nil {
+		return []OtelSpanRef{}
+	}
+	return references
+}
+
+// GetParentSpanID parent is CHILD_OF reference, FOLLOWS_FROM reference in the same trace is used when there is no CHILD_OF
+func (item *SearchSpanReponseItem) GetParentSpanID() string {
+	followsFrom := ""
+	for _, ref := range item.GetReferences() {
+		if len(ref.SpanId) == 0 || ref.SpanId == item.SpanID {
+			continue
+		}
+		if ref.RefType == "CHILD_OF" {
+			return ref.SpanId
+		}
+		if ref.RefType == "FOLLOWS_FROM" && ref.TraceId == item.TraceID && len(followsFrom) == 0 {
+			followsFrom = ref.SpanId
+		}
+	}
+	return followsFrom
+}
+
 func (item *SearchSpanReponseItem) GetValues() []interface{} {
 
 	timeObj, _ := time.Parse(time.RFC3339Nano, item.Timestamp)
-	references := []OtelSpanRef{}
-	json.Unmarshal([]byte(item.References), &references)
+	references := item.GetReferences()
 
 	referencesStringArray := []string{}
 	for _, item := range references {
@@ -130,15 +225,53 @@ func (item *SearchSpanReponseItem) GetValues() []interface{} {
 	return returnArray
 }
 
+// ServiceExternalItem external calls of service to one host or url in time bucket, error rate is in percent
 type ServiceExternalItem struct {
 	Time            string  `json:"time,omitempty" db:"time,omitempty"`
 	Timestamp       int64   `json:"timestamp,omitempty" db:"timestamp,omitempty"`
+	Host            string  `json:"host" db:"host"`
 	ExternalHttpUrl string  `json:"externalHttpUrl,omitempty" db:"externalHttpUrl,omitempty"`
-	AvgDuration     float32 `json:"avgDuration,omitempty" db:"avgDuration,omitempty"`
-	NumCalls        int     `json:"numCalls,omitempty" db:"numCalls,omitempty"`
-	CallRate        float32 `json:"callRate,omitempty" db:"callRate,omitempty"`
+	NumCalls        int     `json:"numCalls" db:"numCalls"`
+	CallRate        float32 `json:"callRate" db:"callRate"`
 	NumErrors       int     `json:"numErrors" db:"numErrors"`
 	ErrorRate       float32 `json:"errorRate" db:"errorRate"`
+	AvgDuration     float32 `json:"avgDuration" db:"avgDuration"`
+	P50             float32 `json:"p50" db:"p50"`
+	P95             float32 `json:"p95" db:"p95"`
+	P99             float32 `json:"p99" db:"p99"`
+}
+
+// DBStatementItem db client calls of one statement fingerprint, statement is normalized with literals replaced by ?
+type DBStatementItem struct {
+	DBSystem       string  `json:"dbSystem" db:"system"`
+	DBName         string  `json:"dbName" db:"database"`
+	DBOperation    string  `json:"dbOperation" db:"operation"`
+	Fingerprint    string  `json:"fingerprint" db:"fingerprint"`
+	Statement      string  `json:"statement" db:"statement"`
+	NumCalls       uint64  `json:"numCalls" db:"numCalls"`
+	P50            float64 `json:"p50" db:"p50"`
+	P95            float64 `json:"p95" db:"p95"`
+	P99            float64 `json:"p99" db:"p99"`
+	ErrorRate      float64 `json:"errorRate" db:"errorRate"`
+	CallRate       float64 `json:"callRate" db:"callRate"`
+	SlowestTraceID string  `json:"slowestTraceID" db:"slowestTraceID"`
+}
+
+// DBStatementSample single slow db call, statement is as sent by the client
+type DBStatementSample struct {
+	Timestamp    int64  `json:"timestamp" db:"timestampNano"`
+	TraceID      string `json:"traceID" db:"traceID"`
+	SpanID       string `json:"spanID" db:"spanID"`
+	DBSystem     string `json:"dbSystem" db:"system"`
+	DBName       string `json:"dbName" db:"database"`
+	Fingerprint  string `json:"fingerprint" db:"fingerprint"`
+	Statement    string `json:"statement" db:"statement"`
+	DurationNano uint64 `json:"durationNano" db:"durationNano"`
+}
+
+type DBStatementsResult struct {
+	Statements  []DBStatementItem   `json:"statements"`
+	SlowSamples []DBStatementSample `json:"slowSamples"`
 }
 
 type ServiceDBOverviewItem struct {
@@ -150,12 +283,6 @@ type ServiceDBOverviewItem struct {
 	CallRate    float32 `json:"callRate,omitempty" db:"callRate,omitempty"`
 }
 
-type ServiceMapDependencyItem struct {
-	SpanId       string `json:"spanId,omitempty" db:"spanID,omitempty"`
-	ParentSpanId string `json:"parentSpanId,omitempty" db:"parentSpanID,omitempty"`
-	ServiceName  string `json:"serviceName,omitempty" db:"serviceName,omitempty"`
-}
-
 type UsageItem struct {
 	Time      string `json:"time,omitempty" db:"time,omitempty"`
 	Timestamp int64  `json:"timestamp" db:"timestamp"`
@@ -167,7 +294,88 @@ type TopEndpointsItem struct {
 	Percentile95 float32 `json:"p95" db:"p95"`
 	Percentile99 float32 `json:"p99" db:"p99"`
 	NumCalls     int     `json:"numCalls" db:"numCalls"`
+	CallRate     float32 `json:"callRate" db:"callRate"`
+	NumErrors    int     `json:"numErrors" db:"numErrors"`
+	ErrorRate    float32 `json:"errorRate" db:"errorRate"`
 	Name         string  `json:"name" db:"name"`
+	ApdexScore
+	Comparison *MetricsComparison `json:"comparison,omitempty" db:"-"`
+}
+
+// ServiceMetrics totals of service server spans, P99 is in nanoseconds
+type ServiceMetrics struct {
+	NumCalls  uint64      `json:"numCalls" db:"numCalls"`
+	NumErrors uint64      `json:"numErrors" db:"numErrors"`
+	P99       float64     `json:"p99" db:"p99"`
+	Apdex     *ApdexScore `json:"apdex,omitempty" db:"-"`
+}
+
+// LatencyBucket spans with lowerBound <= durationNano < upperBound, last bucket is unbounded and has upperBound 0
+type LatencyBucket struct {
+	LowerBound int64  `json:"lowerBound"`
+	UpperBound int64  `json:"upperBound"`
+	Count      uint64 `json:"count"`
+}
+
+type LatencyHistogram struct {
+	Total   uint64          `json:"total"`
+	Buckets []LatencyBucket `json:"buckets"`
+}
+
+// LatencyHeatmap histogram per time step, counts of item are in order of buckets given by boundaries,
+// i.e. counts[0] are spans below boundaries[0] and the last count are spans above the last boundary
+type LatencyHeatmap struct {
+	Boundaries []int64              `json:"boundaries"`
+	Series     []LatencyHeatmapItem `json:"series"`
+}
+
+type LatencyHeatmapItem struct {
+	Timestamp int64    `json:"timestamp"`
+	Counts    []uint64 `json:"counts"`
+}
+
+// MetricsComparison values of the same item compareTo earlier and changes against them, delta is current - baseline,
+// percentage delta is relative to baseline and null when baseline is zero
+type MetricsComparison struct {
+	BaselineTimestamp     int64    `json:"baselineTimestamp,omitempty"`
+	BaselineP99           float32  `json:"baselineP99"`
+	BaselineErrorRate     float32  `json:"baselineErrorRate"`
+	BaselineCallRate      float32  `json:"baselineCallRate"`
+	P99Delta              float32  `json:"p99Delta"`
+	P99DeltaPercent       *float32 `json:"p99DeltaPercent"`
+	ErrorRateDelta        float32  `json:"errorRateDelta"`
+	ErrorRateDeltaPercent *float32 `json:"errorRateDeltaPercent"`
+	CallRateDelta         float32  `json:"callRateDelta"`
+	CallRateDeltaPercent  *float32 `json:"callRateDeltaPercent"`
+}
+
+// ApdexScore user satisfaction of server spans, (satisfied + tolerating / 2) / total. Spans not slower than threshold
+// are satisfied, up to 4 times threshold tolerating, slower or failed spans frustrated. Threshold is in seconds,
+// score is null without spans
+type ApdexScore struct {
+	Apdex           *float64 `json:"apdex" db:"-"`
+	ApdexThreshold  float64  `json:"apdexThreshold" db:"-"`
+	ApdexSatisfied  uint64   `json:"apdexSatisfied" db:"-"`
+	ApdexTolerating uint64   `json:"apdexTolerating" db:"-"`
+	ApdexFrustrated uint64   `json:"apdexFrustrated" db:"-"`
+}
+
+// NewApdexScore compute score from bucket counts
+func NewApdexScore(threshold float64, satisfied uint64, tolerating uint64, total uint64) ApdexScore {
+	score := ApdexScore{ApdexThreshold: threshold, ApdexSatisfied: satisfied, ApdexTolerating: tolerating}
+	if total > satisfied+tolerating {
+		score.ApdexFrustrated = total - satisfied - tolerating
+	}
+	if total > 0 {
+		apdex := (float64(satisfied) + float64(tolerating)/2) / float64(total)
+		score.Apdex = &apdex
+	}
+	return score
+}
+
+type ApdexSetting struct {
+	ServiceName string  `json:"serviceName" db:"serviceName"`
+	Threshold   float64 `json:"threshold" db:"threshold"`
 }
 
 type TagItem struct {
@@ -175,10 +383,68 @@ type TagItem struct {
 	TagCount int    `json:"tagCount" db:"tagCount"`
 }
 
+// TagValuesResult most frequent values of tag key, cardinality is an estimate of distinct values of the key
+type TagValuesResult struct {
+	Key         string         `json:"key"`
+	Cardinality uint64         `json:"cardinality"`
+	SpanCount   uint64         `json:"spanCount"`
+	Values      []TagValueItem `json:"values"`
+}
+
+type TagValueItem struct {
+	Value string `json:"value" db:"tagValue"`
+	Count uint64 `json:"count" db:"count"`
+}
+
+// ErrorGroupItem exceptions with the same fingerprint, first and last seen are unix nanos within queried time range
+type ErrorGroupItem struct {
+	Fingerprint      string   `json:"fingerprint" db:"fingerprint"`
+	ExceptionType    string   `json:"exceptionType" db:"exceptionType"`
+	ExceptionMessage string   `json:"exceptionMessage" db:"exceptionMessage"`
+	Count            uint64   `json:"count" db:"count"`
+	FirstSeen        int64    `json:"firstSeen" db:"firstSeen"`
+	LastSeen         int64    `json:"lastSeen" db:"lastSeen"`
+	ServiceNames     []string `json:"serviceNames" db:"serviceNames"`
+}
+
+type ErrorGroupsResult struct {
+	Items  []ErrorGroupItem `json:"items"`
+	Total  int              `json:"total"`
+	Limit  int              `json:"limit"`
+	Offset int              `json:"offset"`
+}
+
+type ErrorDetail struct {
+	ErrorGroupItem
+	Samples  []ErrorSample       `json:"samples"`
+	Timeline []ErrorTimelineItem `json:"timeline"`
+}
+
+type ErrorSample struct {
+	Timestamp           int64  `json:"timestamp" db:"timestampNano"`
+	TraceID             string `json:"traceID" db:"traceID"`
+	SpanID              string `json:"spanID" db:"spanID"`
+	ServiceName         string `json:"serviceName" db:"serviceName"`
+	Name                string `json:"name" db:"name"`
+	ExceptionMessage    string `json:"exceptionMessage" db:"exceptionMessage"`
+	ExceptionStacktrace string `json:"exceptionStacktrace" db:"exceptionStacktrace"`
+}
+
+type ErrorTimelineItem struct {
+	Timestamp int64  `json:"timestamp" db:"timestamp"`
+	Time      string `json:"time,omitempty" db:"time"`
+	Count     uint64 `json:"count" db:"count"`
+}
+
+// ServiceMapDependencyResponseItem calls from parent to child service, latency and errors are of child spans
 type ServiceMapDependencyResponseItem struct {
-	Parent    string `json:"parent,omitempty" db:"parent,omitempty"`
-	Child     string `json:"child,omitempty" db:"child,omitempty"`
-	CallCount int    `json:"callCount,omitempty" db:"callCount,omitempty"`
+	Parent    string  `json:"parent,omitempty" db:"parent,omitempty"`
+	Child     string  `json:"child,omitempty" db:"child,omitempty"`
+	CallCount int     `json:"callCount,omitempty" db:"callCount,omitempty"`
+	P50       float64 `json:"p50" db:"p50"`
+	P99       float64 `json:"p99" db:"p99"`
+	ErrorRate float64 `json:"errorRate" db:"errorRate"`
+	CallRate  float64 `json:"callRate" db:"callRate"`
 }
 
 type SpanSearchAggregatesResponseItem struct {
@@ -186,3 +452,10 @@ type SpanSearchAggregatesResponseItem struct {
 	Time      string  `json:"time,omitempty" db:"time"`
 	Value     float32 `json:"value,omitempty" db:"value"`
 }
+
+// SpanSearchAggregatesSeries series of one group, spans outside of top groups are summed up in series with Other set
+type SpanSearchAggregatesSeries struct {
+	Group string                             `json:"group"`
+	Other bool                               `json:"other"`
+	Items []SpanSearchAggregatesResponseItem `json:"items"`
+}
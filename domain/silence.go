@@ -0,0 +1,59 @@
+package model
+
+import (
+	"goapm/cron"
+	"time"
+)
+
+// Silence suppresses notifications of alerts whose labels contain all Matchers between StartsAt and EndsAt (unix nanos)
+type Silence struct {
+	ID        string            `json:"id"`
+	Matchers  map[string]string `json:"matchers"`
+	StartsAt  int64             `json:"startsAt"`
+	EndsAt    int64             `json:"endsAt"`
+	CreatedBy string            `json:"createdBy"`
+	Comment   string            `json:"comment"`
+	UpdatedAt int64             `json:"updatedAt"`
+	Active    bool              `json:"active"`
+}
+
+func (silence *Silence) ActiveAt(now time.Time) bool {
+	return silence.StartsAt <= now.UnixNano() && now.UnixNano() < silence.EndsAt
+}
+
+// MaintenanceWindow recurring silence, window starts whenever cron Schedule fires (UTC) and lasts DurationSeconds.
+// Window without matchers suppresses all notifications
+type MaintenanceWindow struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	Matchers        map[string]string `json:"matchers"`
+	Schedule        string            `json:"schedule"`
+	DurationSeconds int               `json:"durationSeconds"`
+	CreatedBy       string            `json:"createdBy"`
+	Comment         string            `json:"comment"`
+	Disabled        bool              `json:"disabled"`
+	UpdatedAt       int64             `json:"updatedAt"`
+	Active          bool              `json:"active"`
+}
+
+// ActiveAt window is enabled and started by its schedule less than its duration before now, invalid schedule is never active
+func (window *MaintenanceWindow) ActiveAt(now time.Time) bool {
+	if window.Disabled {
+		return false
+	}
+	schedule, err := cron.Parse(window.Schedule)
+	if err != nil {
+		return false
+	}
+	return schedule.ActiveAt(now, time.Duration(window.DurationSeconds)*time.Second)
+}
+
+// MatchLabels labels contain every matcher, empty matchers match any labels
+func MatchLabels(matchers map[string]string, labels map[string]string) bool {
+	for key, value := range matchers {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package model
+
+import "time"
+
+// types of service level indicators, availability counts server spans without error as good, latency counts
+// server spans finishing within LatencyThresholdMs as good
+const (
+	SLITypeAvailability = "availability"
+	SLITypeLatency      = "latency"
+)
+
+const MaxSLOWindowDays = 30
+
+// SLO objective of service, or of one of its operations, for Target percent of events being good over rolling WindowDays
+type SLO struct {
+	ID                 string            `json:"id"`
+	Name               string            `json:"name"`
+	ServiceName        string            `json:"serviceName"`
+	Operation          string            `json:"operation,omitempty"`
+	SLIType            string            `json:"sliType"`
+	LatencyThresholdMs int64             `json:"latencyThresholdMs,omitempty"`
+	Target             float64           `json:"target"`
+	WindowDays         int               `json:"windowDays"`
+	Labels             map[string]string `json:"labels"`
+	UpdatedAt          int64             `json:"updatedAt"`
+}
+
+// ErrorBudget fraction of events allowed to be bad
+func (slo *SLO) ErrorBudget() float64 {
+	return 1 - slo.Target/100
+}
+
+// BurnRateConditions DefaultBurnRateConditions with factors scaled to window of slo, so each condition is met by
+// spending the same fraction of budget as over 30 days
+func (slo *SLO) BurnRateConditions() []BurnRateCondition {
+	conditions := make([]BurnRateCondition, len(DefaultBurnRateConditions))
+	for i, condition := range DefaultBurnRateConditions {
+		condition.Factor = condition.Factor * float64(slo.WindowDays) / MaxSLOWindowDays
+		conditions[i] = condition
+	}
+	return conditions
+}
+
+// BurnRateWindow window over which burn rate is reported
+type BurnRateWindow struct {
+	Name     string
+	Duration time.Duration
+}
+
+var BurnRateWindows = []BurnRateWindow{
+	{Name: "5m", Duration: 5 * time.Minute},
+	{Name: "30m", Duration: 30 * time.Minute},
+	{Name: "1h", Duration: time.Hour},
+	{Name: "6h", Duration: 6 * time.Hour},
+	{Name: "3d", Duration: 3 * 24 * time.Hour},
+}
+
+// BurnRateCondition met when burn rate over both windows is above Factor. Long window makes the alert significant,
+// short window makes it resolve soon after burning stops
+type BurnRateCondition struct {
+	Severity    string  `json:"severity"`
+	LongWindow  string  `json:"longWindow"`
+	ShortWindow string  `json:"shortWindow"`
+	Factor      float64 `json:"factor"`
+}
+
+// DefaultBurnRateConditions multi-window, multi-burn-rate conditions from the google sre workbook for 30 day window,
+// 14.4 burns 2% of budget in an hour, 6 burns 5% in 6 hours and 1 burns 10% in 3 days
+var DefaultBurnRateConditions = []BurnRateCondition{
+	{Severity: "page", LongWindow: "1h", ShortWindow: "5m", Factor: 14.4},
+	{Severity: "page", LongWindow: "6h", ShortWindow: "30m", Factor: 6},
+	{Severity: "ticket", LongWindow: "3d", ShortWindow: "6h", Factor: 1},
+}
+
+// BurnRate rate at which error budget is spent over window, 1 spends exactly the budget over the slo window
+type BurnRate struct {
+	Window        string  `json:"window"`
+	WindowSeconds int     `json:"windowSeconds"`
+	GoodEvents    uint64  `json:"goodEvents"`
+	TotalEvents   uint64  `json:"totalEvents"`
+	ErrorRate     float64 `json:"errorRate"`
+	BurnRate      float64 `json:"burnRate"`
+}
+
+// SLOStatus slo with its indicator over rolling window, remaining error budget in percent (negative when exhausted),
+// burn rates and burn rate conditions currently met
+type SLOStatus struct {
+	SLO                  *SLO                `json:"slo"`
+	SLI                  float64             `json:"sli"`
+	GoodEvents           uint64              `json:"goodEvents"`
+	TotalEvents          uint64              `json:"totalEvents"`
+	ErrorBudgetRemaining float64             `json:"errorBudgetRemaining"`
+	BurnRates            []BurnRate          `json:"burnRates"`
+	Alerts               []BurnRateCondition `json:"alerts"`
+}
+
+// GetSLIEventsParams good and total events of slo from each of Starts until End
+type GetSLIEventsParams struct {
+	ServiceName        string
+	Operation          string
+	SLIType            string
+	LatencyThresholdMs int64
+	Starts             []time.Time
+	End                time.Time
+}
+
+type SLIEvents struct {
+	Good  uint64
+	Total uint64
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// span kinds as stored in the kind column, values follow the OpenTelemetry SpanKind enum
+const (
+	SpanKindUnspecified int32 = 0
+	SpanKindInternal    int32 = 1
+	SpanKindServer      int32 = 2
+	SpanKindClient      int32 = 3
+	SpanKindProducer    int32 = 4
+	SpanKindConsumer    int32 = 5
+)
+
+// status codes stored in statusCode column when span has no http.status_code, follows OpenTelemetry StatusCode enum
+const (
+	SpanStatusUnset int64 = 0
+	SpanStatusOk    int64 = 1
+	SpanStatusError int64 = 2
+)
+
+// exception tags of OpenTelemetry semantic conventions, exception.fingerprint groups exceptions of the same kind
+// and is computed on ingestion
+const (
+	ExceptionTypeTag        = "exception.type"
+	ExceptionMessageTag     = "exception.message"
+	ExceptionStacktraceTag  = "exception.stacktrace"
+	ExceptionFingerprintTag = "exception.fingerprint"
+)
+
+// EnvironmentTag OpenTelemetry resource attribute of deployment environment, copied into span tags by receivers
+const EnvironmentTag = "deployment.environment"
+
+// Span internal span model, fields are mapped one to one on signoz_index columns
+type Span struct {
+	Timestamp          time.Time `json:"timestamp" db:"timestamp"`
+	TraceID            string    `json:"traceID" db:"traceID"`
+	SpanID             string    `json:"spanID" db:"spanID"`
+	ParentSpanID       string    `json:"parentSpanID" db:"parentSpanID"`
+	ServiceName        string    `json:"serviceName" db:"serviceName"`
+	Name               string    `json:"name" db:"name"`
+	Kind               int32     `json:"kind" db:"kind"`
+	DurationNano       uint64    `json:"durationNano" db:"durationNano"`
+	Tags               []string  `json:"tags" db:"tags"`
+	TagsKeys           []string  `json:"tagsKeys" db:"tagsKeys"`
+	TagsValues         []string  `json:"tagsValues" db:"tagsValues"`
+	StatusCode         int64     `json:"statusCode" db:"statusCode"`
+	References         string    `json:"references" db:"references"`
+	ExternalHttpMethod *string   `json:"externalHttpMethod,omitempty" db:"externalHttpMethod"`
+	ExternalHttpUrl    *string   `json:"externalHttpUrl,omitempty" db:"externalHttpUrl"`
+	Component          *string   `json:"component,omitempty" db:"component"`
+	DBSystem           *string   `json:"dbSystem,omitempty" db:"dbSystem"`
+	DBName             *string   `json:"dbName,omitempty" db:"dbName"`
+	DBOperation        *string   `json:"dbOperation,omitempty" db:"dbOperation"`
+	PeerService        *string   `json:"peerService,omitempty" db:"peerService"`
+	ExternalHost       *string   `json:"externalHost,omitempty" db:"externalHost"`
+}
+
+// AddTag append key/value to tags arrays, tags keeps the "key:value" format used by has(tags, ...) filters
+func (span *Span) AddTag(key string, value string) {
+	span.Tags = append(span.Tags, key+":"+value)
+	span.TagsKeys = append(span.TagsKeys, key)
+	span.TagsValues = append(span.TagsValues, value)
+}
+
+// GetTag get tag value by key, second value is false if key doesn't exist
+func (span *Span) GetTag(key string) (string, bool) {
+	for i, tagKey := range span.TagsKeys {
+		if tagKey == key {
+			return span.TagsValues[i], true
+		}
+	}
+	return "", false
+}
+
+// SetReferences serialize references the same way OtelSpanRef is parsed when reading spans
+func (span *Span) SetReferences(references []OtelSpanRef) {
+	if len(references) == 0 {
+		span.References = "[]"
+		return
+	}
+	referencesJson, _ := json.Marshal(references)
+	span.References = string(referencesJson)
+}
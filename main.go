@@ -2,6 +2,7 @@ package main
 
 import (
 	controllers "goapm/app"
+	"os"
 
 	_ "fmt"
 	_ "net/http"
@@ -20,6 +21,10 @@ var (
 
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(controllers.RunMigrateCommand(appName, os.Args[2:]))
+	}
+
 	controllers.InitControllers(appName, Version, Build, Commit, BuildTime)
 
 }
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+)
+
+// MigrationStatus state of single migration on single clickhouse host
+type MigrationStatus struct {
+	Host    string
+	Version uint64
+	Name    string
+	Applied bool
+}
+
+type MigrationService interface {
+	Up(ctx context.Context) error
+	Down(ctx context.Context, steps int) error
+	Status(ctx context.Context) ([]MigrationStatus, error)
+}
+
+type MockMigrationService struct {
+	mock.Mock
+}
+
+func (service *MockMigrationService) Up(ctx context.Context) error {
+	args := service.Called(ctx)
+	return args.Error(0)
+}
+
+func (service *MockMigrationService) Down(ctx context.Context, steps int) error {
+	args := service.Called(ctx, steps)
+	return args.Error(0)
+}
+
+func (service *MockMigrationService) Status(ctx context.Context) ([]MigrationStatus, error) {
+	args := service.Called(ctx)
+	return args.Get(0).([]MigrationStatus), args.Error(1)
+}
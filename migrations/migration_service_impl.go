@@ -0,0 +1,246 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"goapm/clickhouse"
+	"goapm/logger"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+const createSchemaMigrationsQuery = `CREATE TABLE IF NOT EXISTS schema_migrations {{.OnCluster}} (
+    version UInt64,
+    name String,
+    applied UInt8,
+    event_time DateTime64(3)
+) ENGINE = ReplacingMergeTree(event_time)
+ORDER BY version`
+
+const appliedVersionsQuery = "SELECT version FROM schema_migrations FINAL WHERE applied = 1 ORDER BY version"
+const insertSchemaMigrationQuery = "INSERT INTO schema_migrations (version, name, applied, event_time) VALUES (?, ?, ?, ?)"
+
+var migrationServiceOnce sync.Once
+var migrationService *MigrationServiceImpl
+
+type dataSource struct {
+	Host string
+	DB   *sqlx.DB
+}
+
+// MigrationServiceImpl apply embedded migrations, applied versions are tracked in schema_migrations table.
+// When CLICKHOUSE_CLUSTER is set, ddl runs once with ON CLUSTER on the first data source and versions are tracked there,
+// otherwise every data source from CLICKHOUSE_DATA_SOURCES is migrated and tracked separately
+type MigrationServiceImpl struct {
+	Logger                      *zap.SugaredLogger
+	ClickhouseConnectionService clickhouse.ClickhouseConnectionService
+	Migrations                  []Migration
+	Cluster                     string
+}
+
+func NewMigrationService(ClickhouseConnectionService clickhouse.ClickhouseConnectionService) *MigrationServiceImpl {
+	migrationServiceOnce.Do(func() {
+		migrations, err := LoadMigrations()
+		if err != nil {
+			// embedded files are checked by tests, so this can only be a broken build
+			panic(err)
+		}
+		migrationService = &MigrationServiceImpl{
+			Logger:                      logger.LOGGER,
+			ClickhouseConnectionService: ClickhouseConnectionService,
+			Migrations:                  migrations,
+			Cluster:                     viper.GetString("CLICKHOUSE_CLUSTER"),
+		}
+	})
+	return migrationService
+}
+
+// Up apply all migrations which aren't applied yet, in version order
+func (service *MigrationServiceImpl) Up(ctx context.Context) error {
+	all, targets, err := service.dataSources()
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		applied, err := service.appliedVersions(ctx, target)
+		if err != nil {
+			return err
+		}
+		for _, migration := range service.Migrations {
+			if applied[migration.Version] {
+				continue
+			}
+			service.Logger.Info(fmt.Sprintf("applying migration %d_%s on %s", migration.Version, migration.Name, target.Host))
+			if err = service.execute(ctx, all, target, migration.Up); err != nil {
+				return fmt.Errorf("migration %d_%s failed on %s, %s", migration.Version, migration.Name, target.Host, err.Error())
+			}
+			if err = service.record(ctx, target, migration, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Down revert last steps applied migrations, in reverse version order
+func (service *MigrationServiceImpl) Down(ctx context.Context, steps int) error {
+	all, targets, err := service.dataSources()
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		applied, err := service.appliedVersions(ctx, target)
+		if err != nil {
+			return err
+		}
+		reverted := 0
+		for i := len(service.Migrations) - 1; i >= 0 && reverted < steps; i-- {
+			migration := service.Migrations[i]
+			if !applied[migration.Version] {
+				continue
+			}
+			service.Logger.Info(fmt.Sprintf("reverting migration %d_%s on %s", migration.Version, migration.Name, target.Host))
+			if err = service.execute(ctx, all, target, migration.Down); err != nil {
+				return fmt.Errorf("migration %d_%s revert failed on %s, %s", migration.Version, migration.Name, target.Host, err.Error())
+			}
+			if err = service.record(ctx, target, migration, false); err != nil {
+				return err
+			}
+			reverted++
+		}
+	}
+	return nil
+}
+
+// Status list every known migration with its state on every migrated host
+func (service *MigrationServiceImpl) Status(ctx context.Context) ([]MigrationStatus, error) {
+	_, targets, err := service.dataSources()
+	if err != nil {
+		return nil, err
+	}
+	var statuses []MigrationStatus
+	for _, target := range targets {
+		applied, err := service.appliedVersions(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		for _, migration := range service.Migrations {
+			statuses = append(statuses, MigrationStatus{
+				Host:    target.Host,
+				Version: migration.Version,
+				Name:    migration.Name,
+				Applied: applied[migration.Version],
+			})
+		}
+	}
+	return statuses, nil
+}
+
+// dataSources return all connections sorted by url and the ones which should be migrated
+func (service *MigrationServiceImpl) dataSources() ([]dataSource, []dataSource, error) {
+	connections := service.ClickhouseConnectionService.GetConnectionMap()
+	var urls []string
+	for _, key := range connections.Keys() {
+		urls = append(urls, key.(string))
+	}
+	if len(urls) == 0 {
+		return nil, nil, fmt.Errorf("no clickhouse data sources available")
+	}
+	sort.Strings(urls)
+
+	all := make([]dataSource, 0, len(urls))
+	for _, dataSourceUrl := range urls {
+		connectionVal, _ := connections.Get(dataSourceUrl)
+		all = append(all, dataSource{Host: displayHost(dataSourceUrl), DB: connectionVal.(*sqlx.DB)})
+	}
+	if len(service.Cluster) > 0 {
+		return all, all[:1], nil
+	}
+	return all, all, nil
+}
+
+func (service *MigrationServiceImpl) appliedVersions(ctx context.Context, target dataSource) (map[uint64]bool, error) {
+	statements, err := RenderStatements(createSchemaMigrationsQuery, service.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	for _, statement := range statements {
+		if _, err = target.DB.ExecContext(ctx, statement); err != nil {
+			service.Logger.Debug("Error in processing sql query: ", err)
+			return nil, fmt.Errorf("unable to create schema_migrations on %s", target.Host)
+		}
+	}
+
+	var versions []uint64
+	if err = target.DB.SelectContext(ctx, &versions, appliedVersionsQuery); err != nil {
+		service.Logger.Debug("Error in processing sql query: ", err)
+		return nil, fmt.Errorf("unable to read schema_migrations on %s", target.Host)
+	}
+	applied := make(map[uint64]bool, len(versions))
+	for _, version := range versions {
+		applied[version] = true
+	}
+	return applied, nil
+}
+
+// execute run migration statements on target, in cluster mode non ddl statements run on every data source
+// since ON CLUSTER only distributes ddl
+func (service *MigrationServiceImpl) execute(ctx context.Context, all []dataSource, target dataSource, sqlTemplate string) error {
+	statements, err := RenderStatements(sqlTemplate, service.Cluster)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		runOn := []dataSource{target}
+		if len(service.Cluster) > 0 && !isDDL(statement) {
+			runOn = all
+		}
+		for _, source := range runOn {
+			if _, err = source.DB.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (service *MigrationServiceImpl) record(ctx context.Context, target dataSource, migration Migration, applied bool) error {
+	var appliedFlag uint8
+	if applied {
+		appliedFlag = 1
+	}
+	tx, err := target.DB.BeginTx(ctx, nil)
+	if err != nil {
+		service.Logger.Debug("Error in processing sql query: ", err)
+		return fmt.Errorf("unable to record migration %d on %s", migration.Version, target.Host)
+	}
+	stmt, err := tx.PrepareContext(ctx, insertSchemaMigrationQuery)
+	if err == nil {
+		_, err = stmt.ExecContext(ctx, migration.Version, migration.Name, appliedFlag, time.Now().UTC())
+		_ = stmt.Close()
+	}
+	if err != nil {
+		_ = tx.Rollback()
+	} else {
+		err = tx.Commit()
+	}
+	if err != nil {
+		service.Logger.Debug("Error in processing sql query: ", err)
+		return fmt.Errorf("unable to record migration %d on %s", migration.Version, target.Host)
+	}
+	return nil
+}
+
+// displayHost strip credentials from data source url
+func displayHost(dataSourceUrl string) string {
+	parsedUrl, err := url.Parse(dataSourceUrl)
+	if err != nil || len(parsedUrl.Host) == 0 {
+		return "unknown"
+	}
+	return parsedUrl.Host
+}
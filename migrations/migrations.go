@@ -0,0 +1,117 @@
+package migrations
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+//go:embed sql/*.sql
+var migrationFiles embed.FS
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration single schema change, Up and Down are sql templates which may contain several statements separated by ';'
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type templateParams struct {
+	OnCluster string
+}
+
+// LoadMigrations read embedded sql/NNNNNN_name.(up|down).sql files, result is sorted by version,
+// every version must have both up and down file
+func LoadMigrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrationsByVersion := make(map[uint64]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+		content, err := migrationFiles.ReadFile(path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := migrationsByVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			migrationsByVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(migrationsByVersion))
+	for _, migration := range migrationsByVersion {
+		if len(strings.TrimSpace(migration.Up)) == 0 || len(strings.TrimSpace(migration.Down)) == 0 {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// RenderStatements execute migration template and split it into statements,
+// {{.OnCluster}} is replaced by "ON CLUSTER <cluster>" when cluster isn't empty
+func RenderStatements(sqlTemplate string, cluster string) ([]string, error) {
+	parsedTemplate, err := template.New("migration").Parse(sqlTemplate)
+	if err != nil {
+		return nil, err
+	}
+	params := templateParams{}
+	if len(cluster) > 0 {
+		params.OnCluster = "ON CLUSTER " + cluster
+	}
+	var rendered bytes.Buffer
+	if err = parsedTemplate.Execute(&rendered, params); err != nil {
+		return nil, err
+	}
+
+	var statements []string
+	for _, statement := range strings.Split(rendered.String(), ";") {
+		statement = strings.TrimSpace(statement)
+		if len(statement) > 0 {
+			statements = append(statements, statement)
+		}
+	}
+	return statements, nil
+}
+
+// isDDL statements which are distributed by ON CLUSTER, other statements(like seed inserts) run on every data source
+func isDDL(statement string) bool {
+	upperStatement := strings.ToUpper(statement)
+	for _, prefix := range []string{"CREATE ", "DROP ", "ALTER ", "RENAME ", "TRUNCATE "} {
+		if strings.HasPrefix(upperStatement, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package migrations
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"goapm/clickhouse"
+	model "goapm/domain"
+	"goapm/ds_utils"
+	"goapm/logger"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations()
+	assert.Nil(t, err)
+	assert.Equal(t, 15, len(migrations))
+	for i, migration := range migrations {
+		assert.Equal(t, uint64(i+1), migration.Version)
+		assert.NotEmpty(t, migration.Up)
+		assert.NotEmpty(t, migration.Down)
+	}
+	assert.Equal(t, "create_signoz_index_tmp", migrations[0].Name)
+	assert.Equal(t, "create_last_success", migrations[3].Name)
+	assert.Equal(t, "create_signoz_error_index", migrations[4].Name)
+	assert.Equal(t, "create_service_dependencies", migrations[5].Name)
+	// a batch of service dependency job aggregated again replaces its rows
+	assert.Contains(t, migrations[5].Up, "ENGINE = ReplacingMergeTree()")
+	assert.Equal(t, "create_db_statements_aggregated", migrations[6].Name)
+	assert.Equal(t, "add_external_host", migrations[7].Name)
+	assert.Equal(t, "create_apdex_settings", migrations[8].Name)
+	assert.Equal(t, "add_latency_counts", migrations[9].Name)
+	assert.Equal(t, "create_alerting", migrations[10].Name)
+	assert.Equal(t, "create_notification_channels", migrations[11].Name)
+	assert.Equal(t, "create_silences", migrations[12].Name)
+	assert.Equal(t, "create_slos", migrations[13].Name)
+	assert.Equal(t, "create_anomalies", migrations[14].Name)
+}
+
+// viewQuery select of rollup view created or altered in sql, empty if there is none
+func viewQuery(sql string) string {
+	start := strings.Index(sql, "signoz_index_aggregated_mv {{.OnCluster}}")
+	if start < 0 || !strings.Contains(sql[start:], "SELECT") {
+		return ""
+	}
+	query := sql[start+strings.Index(sql[start:], "SELECT"):]
+	return strings.TrimSpace(strings.SplitN(query, ";", 2)[0])
+}
+
+// view is altered in place, dropping it would lose spans inserted until it is created again, and down migration
+// restores the query of the previous migration creating or altering the view
+func TestRollupViewAlteredInPlace(t *testing.T) {
+	migrations, err := LoadMigrations()
+	assert.Nil(t, err)
+	previous := viewQuery(migrations[2].Up)
+	assert.NotEmpty(t, previous)
+	for _, migration := range migrations[3:] {
+		assert.NotContains(t, migration.Up, "DROP TABLE IF EXISTS signoz_index_aggregated_mv")
+		assert.NotContains(t, migration.Down, "DROP TABLE IF EXISTS signoz_index_aggregated_mv")
+		if query := viewQuery(migration.Up); len(query) != 0 {
+			assert.Equal(t, previous, viewQuery(migration.Down), migration.Name)
+			previous = query
+		}
+	}
+}
+
+// latency counts of rollup view must match thresholds apdex and slos can use
+func TestLatencyThresholds(t *testing.T) {
+	migrations, err := LoadMigrations()
+	assert.Nil(t, err)
+	thresholds := make([]string, len(model.LatencyThresholdsMillis))
+	for i, threshold := range model.LatencyThresholdsMillis {
+		thresholds[i] = strconv.FormatInt(threshold*1000000, 10)
+	}
+	assert.Contains(t, migrations[9].Up, "["+strings.Join(thresholds, ", ")+"]")
+}
+
+func TestRenderStatements(t *testing.T) {
+	statements, err := RenderStatements("CREATE TABLE a {{.OnCluster}} (x UInt8) ENGINE = Memory;\n\nDROP TABLE b {{.OnCluster}};\n", "apm")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"CREATE TABLE a ON CLUSTER apm (x UInt8) ENGINE = Memory", "DROP TABLE b ON CLUSTER apm"}, statements)
+
+	statements, err = RenderStatements("DROP TABLE b {{.OnCluster}}", "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"DROP TABLE b"}, statements)
+}
+
+func TestIsDDL(t *testing.T) {
+	assert.True(t, isDDL("create table a"))
+	assert.True(t, isDDL("DROP TABLE a"))
+	assert.False(t, isDDL("INSERT INTO last_success SELECT 1"))
+}
+
+func TestDisplayHost(t *testing.T) {
+	assert.Equal(t, "clickhouse-1:9000", displayHost("tcp://clickhouse-1:9000?username=apm&password=secret&database=apm"))
+}
+
+func newMigrationServiceTest(t *testing.T, cluster string) (*MigrationServiceImpl, sqlmock.Sqlmock) {
+	mockDB, sqlMock, err := sqlmock.New()
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = mockDB.Close() })
+	connections := ds_utils.NewSyncedMap()
+	connections.Put("tcp://clickhouse-1:9000?password=secret", sqlx.NewDb(mockDB, "sqlmock"))
+	clickhouseConnectionMock := new(clickhouse.MockClickhouseConnectionService)
+	clickhouseConnectionMock.On("GetConnectionMap").Return(connections)
+
+	return &MigrationServiceImpl{
+		Logger:                      logger.LOGGER,
+		ClickhouseConnectionService: clickhouseConnectionMock,
+		Migrations: []Migration{
+			{Version: 1, Name: "create_a", Up: "CREATE TABLE a {{.OnCluster}} (x UInt8) ENGINE = Memory", Down: "DROP TABLE a {{.OnCluster}}"},
+			{Version: 2, Name: "create_b", Up: "CREATE TABLE b {{.OnCluster}} (x UInt8) ENGINE = Memory;INSERT INTO b SELECT 1", Down: "DROP TABLE b {{.OnCluster}}"},
+		},
+		Cluster: cluster,
+	}, sqlMock
+}
+
+func TestMigrationServiceUp(t *testing.T) {
+	classUnderTest, sqlMock := newMigrationServiceTest(t, "apm")
+	sqlMock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations ON CLUSTER apm")).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(appliedVersionsQuery)).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(uint64(1)))
+	sqlMock.ExpectExec(regexp.QuoteMeta("CREATE TABLE b ON CLUSTER apm")).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec(regexp.QuoteMeta("INSERT INTO b SELECT 1")).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectPrepare(regexp.QuoteMeta(insertSchemaMigrationQuery)).
+		ExpectExec().WithArgs(uint64(2), "create_b", uint8(1), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	err := classUnderTest.Up(context.Background())
+	assert.Nil(t, err)
+	assert.Nil(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestMigrationServiceDown(t *testing.T) {
+	classUnderTest, sqlMock := newMigrationServiceTest(t, "")
+	sqlMock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations (")).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(appliedVersionsQuery)).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(uint64(1)).AddRow(uint64(2)))
+	sqlMock.ExpectExec(regexp.QuoteMeta("DROP TABLE b")).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectPrepare(regexp.QuoteMeta(insertSchemaMigrationQuery)).
+		ExpectExec().WithArgs(uint64(2), "create_b", uint8(0), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	err := classUnderTest.Down(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Nil(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestMigrationServiceStatus(t *testing.T) {
+	classUnderTest, sqlMock := newMigrationServiceTest(t, "")
+	sqlMock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations (")).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(appliedVersionsQuery)).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(uint64(1)))
+
+	statuses, err := classUnderTest.Status(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []MigrationStatus{
+		{Host: "clickhouse-1:9000", Version: 1, Name: "create_a", Applied: true},
+		{Host: "clickhouse-1:9000", Version: 2, Name: "create_b", Applied: false},
+	}, statuses)
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS signoz_index_final {{.OnCluster}}
@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS signoz_index_aggregated {{.OnCluster}} (
+    timestamp DateTime CODEC(Delta, ZSTD(1)),
+    serviceName LowCardinality(String) CODEC(ZSTD(1)),
+    name LowCardinality(String) CODEC(ZSTD(1)),
+    kind Int32 CODEC(ZSTD(1)),
+    statusCode Int64 CODEC(ZSTD(1)),
+    externalHttpUrl Nullable(String) CODEC(ZSTD(1)),
+    dbSystem Nullable(String) CODEC(ZSTD(1)),
+    dbName Nullable(String) CODEC(ZSTD(1)),
+    tagsKeys SimpleAggregateFunction(groupUniqArrayArray, Array(String)),
+    quantile AggregateFunction(quantile, UInt64),
+    avg AggregateFunction(avg, UInt64),
+    count SimpleAggregateFunction(sum, UInt64)
+) ENGINE = AggregatingMergeTree()
+PARTITION BY toDate(timestamp)
+ORDER BY (serviceName, timestamp, name, kind, statusCode, externalHttpUrl, dbSystem, dbName)
+TTL timestamp + INTERVAL 30 DAY
+SETTINGS allow_nullable_key = 1;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS signoz_index_aggregated_mv {{.OnCluster}} TO signoz_index_aggregated AS
+SELECT
+    toStartOfMinute(timestamp) AS timestamp,
+    serviceName,
+    name,
+    kind,
+    statusCode,
+    externalHttpUrl,
+    dbSystem,
+    dbName,
+    groupUniqArrayArray(tagsKeys) AS tagsKeys,
+    quantileState(durationNano) AS quantile,
+    avgState(durationNano) AS avg,
+    toUInt64(count()) AS count
+FROM signoz_index_tmp
+GROUP BY timestamp, serviceName, name, kind, statusCode, externalHttpUrl, dbSystem, dbName
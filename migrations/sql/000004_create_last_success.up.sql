@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS last_success {{.OnCluster}} (
+    last_success_key String,
+    last_success_date String,
+    event_time DateTime
+) ENGINE = ReplacingMergeTree(event_time)
+ORDER BY last_success_key;
+
+INSERT INTO last_success SELECT 'TRACE_FILTER', formatDateTime(now() - INTERVAL 1 HOUR, '%Y-%m-%d %H:%M:%S'), now()
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS signoz_error_index_mv {{.OnCluster}};
+
+DROP TABLE IF EXISTS signoz_error_index {{.OnCluster}}
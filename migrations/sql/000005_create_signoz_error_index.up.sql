@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS signoz_error_index {{.OnCluster}} (
+    timestamp DateTime64(9) CODEC(Delta, ZSTD(1)),
+    fingerprint String CODEC(ZSTD(1)),
+    exceptionType LowCardinality(String) CODEC(ZSTD(1)),
+    exceptionMessage String CODEC(ZSTD(1)),
+    exceptionStacktrace String CODEC(ZSTD(1)),
+    serviceName LowCardinality(String) CODEC(ZSTD(1)),
+    name LowCardinality(String) CODEC(ZSTD(1)),
+    traceID String CODEC(ZSTD(1)),
+    spanID String CODEC(ZSTD(1))
+) ENGINE = MergeTree()
+PARTITION BY toDate(timestamp)
+ORDER BY (fingerprint, timestamp)
+TTL toDateTime(timestamp) + INTERVAL 15 DAY;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS signoz_error_index_mv {{.OnCluster}} TO signoz_error_index AS
+SELECT
+    timestamp,
+    tagsValues[indexOf(tagsKeys, 'exception.fingerprint')] AS fingerprint,
+    tagsValues[indexOf(tagsKeys, 'exception.type')] AS exceptionType,
+    tagsValues[indexOf(tagsKeys, 'exception.message')] AS exceptionMessage,
+    tagsValues[indexOf(tagsKeys, 'exception.stacktrace')] AS exceptionStacktrace,
+    serviceName,
+    name,
+    traceID,
+    spanID
+FROM signoz_index_tmp
+WHERE has(tagsKeys, 'exception.fingerprint')
@@ -0,0 +1,3 @@
+ALTER TABLE last_success {{.OnCluster}} DELETE WHERE last_success_key = 'SERVICE_DEPENDENCIES';
+
+DROP TABLE IF EXISTS service_dependencies {{.OnCluster}}
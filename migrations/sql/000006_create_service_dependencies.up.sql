@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS service_dependencies {{.OnCluster}} (
+    timestamp DateTime CODEC(Delta, ZSTD(1)),
+    parentServiceName LowCardinality(String) CODEC(ZSTD(1)),
+    serviceName LowCardinality(String) CODEC(ZSTD(1)),
+    environment LowCardinality(String) CODEC(ZSTD(1)),
+    quantile AggregateFunction(quantile, UInt64),
+    count UInt64 CODEC(ZSTD(1)),
+    errorCount UInt64 CODEC(ZSTD(1))
+) ENGINE = ReplacingMergeTree()
+PARTITION BY toDate(timestamp)
+ORDER BY (timestamp, parentServiceName, serviceName, environment)
+TTL timestamp + INTERVAL 30 DAY;
+
+INSERT INTO last_success SELECT 'SERVICE_DEPENDENCIES', formatDateTime(toStartOfMinute(now()) - INTERVAL 1 HOUR, '%Y-%m-%d %H:%M:%S', 'UTC'), now()
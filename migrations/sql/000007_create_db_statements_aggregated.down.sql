@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS signoz_db_statements_aggregated_mv {{.OnCluster}};
+
+DROP TABLE IF EXISTS signoz_db_statements_aggregated {{.OnCluster}}
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS signoz_db_statements_aggregated {{.OnCluster}} (
+    timestamp DateTime CODEC(Delta, ZSTD(1)),
+    serviceName LowCardinality(String) CODEC(ZSTD(1)),
+    system LowCardinality(String) CODEC(ZSTD(1)),
+    database LowCardinality(String) CODEC(ZSTD(1)),
+    operation LowCardinality(String) CODEC(ZSTD(1)),
+    fingerprint String CODEC(ZSTD(1)),
+    statement SimpleAggregateFunction(any, String),
+    quantile AggregateFunction(quantile, UInt64),
+    count SimpleAggregateFunction(sum, UInt64),
+    errorCount SimpleAggregateFunction(sum, UInt64)
+) ENGINE = AggregatingMergeTree()
+PARTITION BY toDate(timestamp)
+ORDER BY (serviceName, timestamp, system, database, operation, fingerprint)
+TTL timestamp + INTERVAL 30 DAY;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS signoz_db_statements_aggregated_mv {{.OnCluster}} TO signoz_db_statements_aggregated AS
+SELECT
+    toStartOfMinute(timestamp) AS timestamp,
+    serviceName,
+    coalesce(dbSystem, '') AS system,
+    coalesce(dbName, '') AS database,
+    coalesce(dbOperation, '') AS operation,
+    lower(hex(normalizedQueryHash(tagsValues[indexOf(tagsKeys, 'db.statement')]))) AS fingerprint,
+    any(normalizeQuery(tagsValues[indexOf(tagsKeys, 'db.statement')])) AS statement,
+    quantileState(durationNano) AS quantile,
+    toUInt64(count()) AS count,
+    toUInt64(countIf(has(tags, 'error:true') OR statusCode >= 500 OR statusCode = 2)) AS errorCount
+FROM signoz_index_tmp
+WHERE kind = 3 AND dbSystem IS NOT NULL
+GROUP BY timestamp, serviceName, system, database, operation, fingerprint
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS apdex_settings {{.OnCluster}}
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS apdex_settings {{.OnCluster}} (
+    serviceName String,
+    threshold Float64,
+    updatedAt DateTime64(3)
+) ENGINE = ReplacingMergeTree(updatedAt)
+ORDER BY serviceName
@@ -0,0 +1,19 @@
+ALTER TABLE signoz_index_aggregated_mv {{.OnCluster}} MODIFY QUERY
+SELECT
+    toStartOfMinute(timestamp) AS timestamp,
+    serviceName,
+    name,
+    kind,
+    statusCode,
+    externalHttpUrl,
+    dbSystem,
+    dbName,
+    externalHost,
+    groupUniqArrayArray(tagsKeys) AS tagsKeys,
+    quantileState(durationNano) AS quantile,
+    avgState(durationNano) AS avg,
+    toUInt64(count()) AS count
+FROM signoz_index_tmp
+GROUP BY timestamp, serviceName, name, kind, statusCode, externalHttpUrl, dbSystem, dbName, externalHost;
+
+ALTER TABLE signoz_index_aggregated {{.OnCluster}} DROP COLUMN IF EXISTS latencyCounts
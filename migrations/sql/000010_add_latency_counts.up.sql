@@ -0,0 +1,20 @@
+ALTER TABLE signoz_index_aggregated {{.OnCluster}} ADD COLUMN IF NOT EXISTS latencyCounts AggregateFunction(sumForEach, Array(UInt64));
+
+ALTER TABLE signoz_index_aggregated_mv {{.OnCluster}} MODIFY QUERY
+SELECT
+    toStartOfMinute(timestamp) AS timestamp,
+    serviceName,
+    name,
+    kind,
+    statusCode,
+    externalHttpUrl,
+    dbSystem,
+    dbName,
+    externalHost,
+    groupUniqArrayArray(tagsKeys) AS tagsKeys,
+    quantileState(durationNano) AS quantile,
+    avgState(durationNano) AS avg,
+    toUInt64(count()) AS count,
+    sumForEachState(arrayConcat([toUInt64(1)], arrayMap(threshold -> toUInt64(durationNano <= threshold), [5000000, 10000000, 25000000, 50000000, 100000000, 200000000, 250000000, 300000000, 400000000, 500000000, 750000000, 800000000, 1000000000, 1200000000, 1500000000, 2000000000, 2500000000, 3000000000, 4000000000, 5000000000, 10000000000, 30000000000, 60000000000]))) AS latencyCounts
+FROM signoz_index_tmp
+GROUP BY timestamp, serviceName, name, kind, statusCode, externalHttpUrl, dbSystem, dbName, externalHost
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS alert_history {{.OnCluster}};
+
+DROP TABLE IF EXISTS alert_rules {{.OnCluster}}
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS alert_rules {{.OnCluster}} (
+    id String,
+    name String,
+    metric LowCardinality(String),
+    serviceName String,
+    operator LowCardinality(String),
+    threshold Float64,
+    windowSeconds UInt32,
+    forSeconds UInt32,
+    labels String,
+    disabled UInt8,
+    deleted UInt8,
+    updatedAt DateTime64(3)
+) ENGINE = ReplacingMergeTree(updatedAt)
+ORDER BY id;
+
+CREATE TABLE IF NOT EXISTS alert_history {{.OnCluster}} (
+    ruleID String,
+    state LowCardinality(String),
+    value Float64,
+    threshold Float64,
+    timestamp DateTime64(9) CODEC(Delta, ZSTD(1))
+) ENGINE = MergeTree()
+PARTITION BY toDate(timestamp)
+ORDER BY (ruleID, timestamp)
+TTL toDateTime(timestamp) + INTERVAL 90 DAY
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS notification_channels {{.OnCluster}} (
+    id String,
+    name String,
+    type LowCardinality(String),
+    url String,
+    headers String,
+    template String,
+    recipients Array(String),
+    matchers String,
+    groupBy Array(String),
+    repeatIntervalSeconds UInt32,
+    disabled UInt8,
+    deleted UInt8,
+    updatedAt DateTime64(3)
+) ENGINE = ReplacingMergeTree(updatedAt)
+ORDER BY id
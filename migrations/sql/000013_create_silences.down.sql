@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS maintenance_windows {{.OnCluster}};
+
+DROP TABLE IF EXISTS alert_silences {{.OnCluster}}
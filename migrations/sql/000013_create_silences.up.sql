@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS alert_silences {{.OnCluster}} (
+    id String,
+    matchers String,
+    startsAt DateTime64(9),
+    endsAt DateTime64(9),
+    createdBy String,
+    comment String,
+    deleted UInt8,
+    updatedAt DateTime64(3)
+) ENGINE = ReplacingMergeTree(updatedAt)
+ORDER BY id
+TTL toDateTime(endsAt) + INTERVAL 30 DAY;
+
+CREATE TABLE IF NOT EXISTS maintenance_windows {{.OnCluster}} (
+    id String,
+    name String,
+    matchers String,
+    schedule String,
+    durationSeconds UInt32,
+    createdBy String,
+    comment String,
+    disabled UInt8,
+    deleted UInt8,
+    updatedAt DateTime64(3)
+) ENGINE = ReplacingMergeTree(updatedAt)
+ORDER BY id
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS slos {{.OnCluster}};
+
+ALTER TABLE alert_rules {{.OnCluster}} DROP COLUMN IF EXISTS sloID
@@ -0,0 +1,16 @@
+ALTER TABLE alert_rules {{.OnCluster}} ADD COLUMN IF NOT EXISTS sloID String;
+
+CREATE TABLE IF NOT EXISTS slos {{.OnCluster}} (
+    id String,
+    name String,
+    serviceName String,
+    operation String,
+    sliType LowCardinality(String),
+    latencyThresholdMs UInt32,
+    target Float64,
+    windowDays UInt16,
+    labels String,
+    deleted UInt8,
+    updatedAt DateTime64(3)
+) ENGINE = ReplacingMergeTree(updatedAt)
+ORDER BY id
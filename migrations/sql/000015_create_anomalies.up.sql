@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS anomalies {{.OnCluster}} (
+    serviceName LowCardinality(String),
+    metric LowCardinality(String),
+    timestamp DateTime64(9) CODEC(Delta, ZSTD(1)),
+    windowSeconds UInt32,
+    value Float64,
+    baseline Float64,
+    deviation Float64,
+    score Float64,
+    direction LowCardinality(String)
+) ENGINE = MergeTree()
+PARTITION BY toDate(timestamp)
+ORDER BY (serviceName, metric, timestamp)
+TTL toDateTime(timestamp) + INTERVAL 90 DAY
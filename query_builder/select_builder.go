@@ -0,0 +1,240 @@
+package query_builder
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Order string
+
+const (
+	Asc  Order = "ASC"
+	Desc Order = "DESC"
+)
+
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SelectBuilder build clickhouse select queries with bound args.
+// Columns, table and conditions are sql fragments and must be constants, every user value goes through '?' args,
+// group by/order by accept only plain identifiers, limit/offset/interval values are rendered as integers
+type SelectBuilder struct {
+	columns    []string
+	columnArgs []interface{}
+	table      string
+	fromArgs   []interface{}
+	final      bool
+	conditions []string
+	args       []interface{}
+	groupBy    []string
+	orderBy    []string
+	limit      int
+	offset     int
+	err        error
+}
+
+func Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{columns: columns, limit: -1, offset: -1}
+}
+
+func (builder *SelectBuilder) Columns(columns ...string) *SelectBuilder {
+	builder.columns = append(builder.columns, columns...)
+	return builder
+}
+
+// Column add column expression with bound args, args of columns precede args of conditions in built query
+func (builder *SelectBuilder) Column(column string, args ...interface{}) *SelectBuilder {
+	if placeholders := countPlaceholders(column); placeholders != len(args) {
+		builder.setError(fmt.Errorf("column %q has %d placeholders but got %d args", column, placeholders, len(args)))
+	}
+	builder.columns = append(builder.columns, column)
+	builder.columnArgs = append(builder.columnArgs, args...)
+	return builder
+}
+
+func (builder *SelectBuilder) From(table string) *SelectBuilder {
+	if !identifierRegex.MatchString(table) {
+		builder.setError(fmt.Errorf("invalid table name %q", table))
+	}
+	builder.table = table
+	return builder
+}
+
+// FromSubquery select from subquery, its args are bound before args of joins and conditions
+func (builder *SelectBuilder) FromSubquery(subquery *SelectBuilder, alias string) *SelectBuilder {
+	builder.table = builder.subquery(subquery, alias)
+	return builder
+}
+
+// InnerJoin join subquery on columns with the same name in both sides
+func (builder *SelectBuilder) InnerJoin(subquery *SelectBuilder, alias string, using ...string) *SelectBuilder {
+	return builder.join("INNER JOIN", subquery, alias, using)
+}
+
+// LeftJoin same as InnerJoin but keeps rows without match, columns of subquery get their type default for such rows
+func (builder *SelectBuilder) LeftJoin(subquery *SelectBuilder, alias string, using ...string) *SelectBuilder {
+	return builder.join("LEFT JOIN", subquery, alias, using)
+}
+
+func (builder *SelectBuilder) join(kind string, subquery *SelectBuilder, alias string, using []string) *SelectBuilder {
+	for _, column := range using {
+		if !identifierRegex.MatchString(column) {
+			builder.setError(fmt.Errorf("invalid join column %q", column))
+		}
+	}
+	if len(using) == 0 {
+		builder.setError(fmt.Errorf("join without columns"))
+	}
+	builder.table += " " + kind + " " + builder.subquery(subquery, alias) + " USING (" + strings.Join(using, ", ") + ")"
+	return builder
+}
+
+func (builder *SelectBuilder) subquery(subquery *SelectBuilder, alias string) string {
+	if !identifierRegex.MatchString(alias) {
+		builder.setError(fmt.Errorf("invalid alias %q", alias))
+	}
+	query, args, err := subquery.Build()
+	if err != nil {
+		builder.setError(err)
+	}
+	builder.fromArgs = append(builder.fromArgs, args...)
+	return "(" + query + ") AS " + alias
+}
+
+// Final add FINAL modifier, used for ReplacingMergeTree tables
+func (builder *SelectBuilder) Final() *SelectBuilder {
+	builder.final = true
+	return builder
+}
+
+// Where add condition joined with AND, number of '?' placeholders must match number of args
+func (builder *SelectBuilder) Where(condition string, args ...interface{}) *SelectBuilder {
+	if placeholders := countPlaceholders(condition); placeholders != len(args) {
+		builder.setError(fmt.Errorf("condition %q has %d placeholders but got %d args", condition, placeholders, len(args)))
+	}
+	builder.conditions = append(builder.conditions, condition)
+	builder.args = append(builder.args, args...)
+	return builder
+}
+
+// WhereIf add condition only when apply is true, saves the if blocks around optional filters
+func (builder *SelectBuilder) WhereIf(apply bool, condition string, args ...interface{}) *SelectBuilder {
+	if apply {
+		return builder.Where(condition, args...)
+	}
+	return builder
+}
+
+func (builder *SelectBuilder) GroupBy(columns ...string) *SelectBuilder {
+	for _, column := range columns {
+		if !identifierRegex.MatchString(column) {
+			builder.setError(fmt.Errorf("invalid group by column %q", column))
+		}
+	}
+	builder.groupBy = append(builder.groupBy, columns...)
+	return builder
+}
+
+func (builder *SelectBuilder) OrderBy(column string, order Order) *SelectBuilder {
+	if !identifierRegex.MatchString(column) {
+		builder.setError(fmt.Errorf("invalid order by column %q", column))
+	}
+	if order != Asc && order != Desc {
+		builder.setError(fmt.Errorf("invalid order %q", order))
+	}
+	builder.orderBy = append(builder.orderBy, column+" "+string(order))
+	return builder
+}
+
+func (builder *SelectBuilder) Limit(limit int) *SelectBuilder {
+	if limit < 0 {
+		builder.setError(fmt.Errorf("invalid limit %d", limit))
+	}
+	builder.limit = limit
+	return builder
+}
+
+func (builder *SelectBuilder) Offset(offset int) *SelectBuilder {
+	if offset < 0 {
+		builder.setError(fmt.Errorf("invalid offset %d", offset))
+	}
+	builder.offset = offset
+	return builder
+}
+
+// Build return query and its args, error is returned if any of the builder calls got invalid input
+func (builder *SelectBuilder) Build() (string, []interface{}, error) {
+	if builder.err != nil {
+		return "", nil, builder.err
+	}
+	if len(builder.columns) == 0 || len(builder.table) == 0 {
+		return "", nil, fmt.Errorf("select query must have columns and table")
+	}
+
+	var query strings.Builder
+	query.WriteString("SELECT ")
+	query.WriteString(strings.Join(builder.columns, ", "))
+	query.WriteString(" FROM ")
+	query.WriteString(builder.table)
+	if builder.final {
+		query.WriteString(" FINAL")
+	}
+	if len(builder.conditions) > 0 {
+		query.WriteString(" WHERE ")
+		query.WriteString(strings.Join(builder.conditions, " AND "))
+	}
+	if len(builder.groupBy) > 0 {
+		query.WriteString(" GROUP BY ")
+		query.WriteString(strings.Join(builder.groupBy, ", "))
+	}
+	if len(builder.orderBy) > 0 {
+		query.WriteString(" ORDER BY ")
+		query.WriteString(strings.Join(builder.orderBy, ", "))
+	}
+	if builder.limit >= 0 {
+		query.WriteString(" LIMIT ")
+		query.WriteString(strconv.Itoa(builder.limit))
+	}
+	if builder.offset >= 0 {
+		query.WriteString(" OFFSET ")
+		query.WriteString(strconv.Itoa(builder.offset))
+	}
+	args := builder.args
+	if len(builder.columnArgs) > 0 || len(builder.fromArgs) > 0 {
+		args = append(append(append([]interface{}{}, builder.columnArgs...), builder.fromArgs...), builder.args...)
+	}
+	return query.String(), args, nil
+}
+
+func (builder *SelectBuilder) setError(err error) {
+	if builder.err == nil {
+		builder.err = err
+	}
+}
+
+// StartOfInterval toStartOfInterval expression with interval rendered as integer,
+// clickhouse driver doesn't bind args after INTERVAL keyword
+func StartOfInterval(column string, minutes int) string {
+	return fmt.Sprintf("toStartOfInterval(%s, INTERVAL %d minute)", column, minutes)
+}
+
+// countPlaceholders count '?' outside of quoted literals
+func countPlaceholders(condition string) int {
+	count := 0
+	inQuote := false
+	escaped := false
+	for _, char := range condition {
+		switch {
+		case escaped:
+			escaped = false
+		case char == '\\':
+			escaped = true
+		case char == '\'':
+			inQuote = !inQuote
+		case char == '?' && !inQuote:
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,104 @@
+package query_builder
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestSelectBuilderBuild(t *testing.T) {
+	query, args, err := Select("serviceName", "sum(count) as numCalls").
+		From("signoz_index_aggregated").
+		Where("timestamp >= ?", "1600000000").
+		Where("kind = '2'").
+		WhereIf(false, "name = ?", "skipped").
+		WhereIf(true, "serviceName = ?", "frontend").
+		GroupBy("serviceName").
+		OrderBy("numCalls", Desc).
+		Limit(10).
+		Offset(20).
+		Build()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT serviceName, sum(count) as numCalls FROM signoz_index_aggregated WHERE timestamp >= ? AND kind = '2' AND serviceName = ? GROUP BY serviceName ORDER BY numCalls DESC LIMIT 10 OFFSET 20", query)
+	assert.Equal(t, []interface{}{"1600000000", "frontend"}, args)
+}
+
+func TestSelectBuilderFinal(t *testing.T) {
+	query, _, err := Select("last_success_date").From("last_success").Final().Where("last_success_key = ?", "TRACE_FILTER").Build()
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT last_success_date FROM last_success FINAL WHERE last_success_key = ?", query)
+}
+
+func TestSelectBuilderColumnArgs(t *testing.T) {
+	query, args, err := Select("count() as value").
+		Column("tagsValues[indexOf(tagsKeys, ?)] as groupKey", "http.method").
+		From("signoz_index_final").
+		Where("serviceName = ?", "frontend").
+		GroupBy("groupKey").
+		Build()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT count() as value, tagsValues[indexOf(tagsKeys, ?)] as groupKey FROM signoz_index_final WHERE serviceName = ? GROUP BY groupKey", query)
+	assert.Equal(t, []interface{}{"http.method", "frontend"}, args)
+
+	_, _, err = Select("1").Column("tagsValues[indexOf(tagsKeys, ?)]").From("signoz_index_final").Build()
+	assert.NotNil(t, err)
+}
+
+func TestSelectBuilderJoin(t *testing.T) {
+	child := Select("traceID", "parentSpanID", "serviceName").From("signoz_index_final").Where("timestamp >= ?", "1")
+	parent := Select("traceID", "spanID as parentSpanID").From("signoz_index_final").Where("timestamp >= ?", "2")
+	query, args, err := Select("count() as callCount").
+		FromSubquery(child, "child").
+		InnerJoin(parent, "parent", "traceID", "parentSpanID").
+		Where("serviceName = ?", "frontend").
+		Build()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT count() as callCount FROM (SELECT traceID, parentSpanID, serviceName FROM signoz_index_final WHERE timestamp >= ?) AS child "+
+		"INNER JOIN (SELECT traceID, spanID as parentSpanID FROM signoz_index_final WHERE timestamp >= ?) AS parent USING (traceID, parentSpanID) "+
+		"WHERE serviceName = ?", query)
+	assert.Equal(t, []interface{}{"1", "2", "frontend"}, args)
+
+	query, _, err = Select("1").FromSubquery(child, "child").LeftJoin(Select("serviceName").From("apdex_settings").Final(), "settings", "serviceName").Build()
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(query, "LEFT JOIN (SELECT serviceName FROM apdex_settings FINAL) AS settings USING (serviceName)"))
+
+	_, _, err = Select("1").FromSubquery(child, "child").InnerJoin(parent, "parent", "traceID) OR (1").Build()
+	assert.NotNil(t, err)
+	_, _, err = Select("1").FromSubquery(Select("1").From("x y"), "child").Build()
+	assert.NotNil(t, err)
+}
+
+func TestSelectBuilderPlaceholderMismatch(t *testing.T) {
+	_, _, err := Select("1").From("signoz_index_final").Where("traceID = ?").Build()
+	assert.NotNil(t, err)
+
+	_, _, err = Select("1").From("signoz_index_final").Where("has(tags, 'what?')", "extra").Build()
+	assert.NotNil(t, err)
+
+	_, _, err = Select("1").From("signoz_index_final").Where("has(tags, 'what?') AND traceID = ?", "1").Build()
+	assert.Nil(t, err)
+}
+
+func TestSelectBuilderRejectsHostileIdentifiers(t *testing.T) {
+	_, _, err := Select("1").From("signoz_index_final; DROP TABLE signoz_index_final").Build()
+	assert.NotNil(t, err)
+
+	_, _, err = Select("1").From("signoz_index_final").OrderBy("timestamp DESC; DROP TABLE x", Asc).Build()
+	assert.NotNil(t, err)
+
+	_, _, err = Select("1").From("signoz_index_final").OrderBy("timestamp", Order("DESC, sleep(10)")).Build()
+	assert.NotNil(t, err)
+
+	_, _, err = Select("1").From("signoz_index_final").GroupBy("time) --").Build()
+	assert.NotNil(t, err)
+
+	_, _, err = Select("1").From("signoz_index_final").Limit(-1).Build()
+	assert.NotNil(t, err)
+}
+
+func TestStartOfInterval(t *testing.T) {
+	assert.Equal(t, "toStartOfInterval(timestamp, INTERVAL 5 minute)", StartOfInterval("timestamp", 5))
+}
@@ -0,0 +1,132 @@
+package receivers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	model "goapm/domain"
+	"regexp"
+	"strings"
+)
+
+const exceptionEventName = "exception"
+
+var (
+	hexNumberRegex  = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+	lineNumberRegex = regexp.MustCompile(`(:\d+)+`)
+	pyLineRegex     = regexp.MustCompile(`line \d+`)
+	uuidRegex       = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	quotedRegex     = regexp.MustCompile(`'[^']*'|"[^"]*"`)
+	numberRegex     = regexp.MustCompile(`\d+`)
+	spacesRegex     = regexp.MustCompile(`\s+`)
+)
+
+// addExceptionTags copy exception.* attributes of exception event into span tags, attributes already set on the span
+// and attributes of an earlier exception event win
+func addExceptionTags(span *model.Span, eventName string, attributes map[string]string) {
+	if eventName != exceptionEventName {
+		return
+	}
+	for _, key := range []string{model.ExceptionTypeTag, model.ExceptionMessageTag, model.ExceptionStacktraceTag} {
+		value, ok := attributes[key]
+		if !ok {
+			continue
+		}
+		if _, exists := span.GetTag(key); !exists {
+			span.AddTag(key, value)
+		}
+	}
+}
+
+// setExceptionFingerprint add exception.fingerprint tag to spans carrying an exception
+func setExceptionFingerprint(span *model.Span) {
+	exceptionType, _ := span.GetTag(model.ExceptionTypeTag)
+	message, _ := span.GetTag(model.ExceptionMessageTag)
+	if len(exceptionType) == 0 && len(message) == 0 {
+		return
+	}
+	if _, exists := span.GetTag(model.ExceptionFingerprintTag); exists {
+		return
+	}
+	stacktrace, _ := span.GetTag(model.ExceptionStacktraceTag)
+	span.AddTag(model.ExceptionFingerprintTag, exceptionFingerprint(exceptionType, message, stacktrace))
+}
+
+// exceptionFingerprint hash of exception type and normalized top frame, exceptions without stacktrace
+// are grouped by normalized message instead
+func exceptionFingerprint(exceptionType string, message string, stacktrace string) string {
+	key := normalizeFrame(topFrame(stacktrace))
+	if len(key) == 0 {
+		key = normalizeMessage(message)
+	}
+	hash := sha1.Sum([]byte(exceptionType + "\n" + key))
+	return hex.EncodeToString(hash[:8])
+}
+
+// goInternalFramePrefixes functions of go stacks which are where the stack was captured rather than where error happened,
+// otel sdk records stack of RecordError and debug.Stack includes itself
+var goInternalFramePrefixes = []string{"runtime.", "runtime/", "go.opentelemetry.io/otel/"}
+
+// topFrame the frame where exception was raised. First line of a stacktrace is the exception header(java, js, go),
+// python tracebacks list most recent call last and go stacks start with frames capturing the stack
+func topFrame(stacktrace string) string {
+	var lines []string
+	for _, line := range strings.Split(stacktrace, "\n") {
+		if line = strings.TrimSpace(line); len(line) != 0 {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	if strings.HasPrefix(lines[0], "Traceback") {
+		for i := len(lines) - 1; i > 0; i-- {
+			if strings.HasPrefix(lines[i], "File ") {
+				return lines[i]
+			}
+		}
+	}
+	if strings.HasPrefix(lines[0], "goroutine ") {
+		if frame := goApplicationFrame(lines[1:]); len(frame) != 0 {
+			return frame
+		}
+	}
+	if len(lines) == 1 {
+		return lines[0]
+	}
+	return lines[1]
+}
+
+// goApplicationFrame first function of go stack outside of runtime and otel, every frame is a function line followed
+// by its file line
+func goApplicationFrame(lines []string) string {
+	for i := 0; i < len(lines); i += 2 {
+		internal := false
+		for _, prefix := range goInternalFramePrefixes {
+			if strings.HasPrefix(lines[i], prefix) {
+				internal = true
+			}
+		}
+		if !internal {
+			return lines[i]
+		}
+	}
+	return ""
+}
+
+// normalizeFrame drop line numbers and addresses which change between builds and runs
+func normalizeFrame(frame string) string {
+	frame = strings.TrimPrefix(frame, "at ")
+	frame = hexNumberRegex.ReplaceAllString(frame, "")
+	frame = pyLineRegex.ReplaceAllString(frame, "line")
+	frame = lineNumberRegex.ReplaceAllString(frame, "")
+	return strings.TrimSpace(spacesRegex.ReplaceAllString(frame, " "))
+}
+
+// normalizeMessage replace ids, quoted values and numbers so messages differing only in values are grouped together
+func normalizeMessage(message string) string {
+	message = uuidRegex.ReplaceAllString(message, "<uuid>")
+	message = hexNumberRegex.ReplaceAllString(message, "<hex>")
+	message = quotedRegex.ReplaceAllString(message, "<str>")
+	message = numberRegex.ReplaceAllString(message, "<n>")
+	return strings.TrimSpace(spacesRegex.ReplaceAllString(message, " "))
+}
@@ -0,0 +1,85 @@
+package receivers
+
+import (
+	"github.com/stretchr/testify/assert"
+	model "goapm/domain"
+	"strings"
+	"testing"
+)
+
+func TestExceptionFingerprintIgnoresLineNumbersAndValues(t *testing.T) {
+	first := exceptionFingerprint("java.lang.IllegalStateException", "order 42 not found",
+		"java.lang.IllegalStateException: order 42 not found\n\tat com.shop.orders.OrderService.get(OrderService.java:87)\n\tat com.shop.Main.run(Main.java:3)")
+	second := exceptionFingerprint("java.lang.IllegalStateException", "order 7 not found",
+		"java.lang.IllegalStateException: order 7 not found\n\tat com.shop.orders.OrderService.get(OrderService.java:91)\n\tat com.shop.Other.run(Other.java:5)")
+	otherFrame := exceptionFingerprint("java.lang.IllegalStateException", "order 42 not found",
+		"java.lang.IllegalStateException: order 42 not found\n\tat com.shop.orders.OrderService.list(OrderService.java:87)")
+	otherType := exceptionFingerprint("java.lang.NullPointerException", "order 42 not found",
+		"java.lang.NullPointerException: order 42 not found\n\tat com.shop.orders.OrderService.get(OrderService.java:87)")
+
+	assert.Equal(t, 16, len(first))
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, first, otherFrame)
+	assert.NotEqual(t, first, otherType)
+}
+
+func TestExceptionFingerprintWithoutStacktrace(t *testing.T) {
+	assert.Equal(t, exceptionFingerprint("TimeoutError", "request 5d2c1f9e-8a6b-4c3d-9e0f-1a2b3c4d5e6f timed out after 30s", ""),
+		exceptionFingerprint("TimeoutError", "request 0a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d timed out after 45s", ""))
+	assert.NotEqual(t, exceptionFingerprint("TimeoutError", "connect timed out", ""),
+		exceptionFingerprint("TimeoutError", "read timed out", ""))
+}
+
+func TestTopFrame(t *testing.T) {
+	python := "Traceback (most recent call last):\n  File \"/app/main.py\", line 10, in <module>\n    run()\n  File \"/app/orders.py\", line 52, in get\n    raise KeyError(key)\nKeyError: 'a'"
+	assert.Equal(t, `File "/app/orders.py", line 52, in get`, topFrame(python))
+	assert.Equal(t, `File "/app/orders.py", line, in get`, normalizeFrame(topFrame(python)))
+	assert.Equal(t, "main.handler(0xc000010000)", topFrame("goroutine 1 [running]:\nmain.handler(0xc000010000)\n\t/app/main.go:12 +0x1d"))
+	assert.Equal(t, "main.handler()", normalizeFrame("main.handler(0xc000010000)"))
+	assert.Equal(t, "", topFrame(""))
+}
+
+// stack recorded by RecordError of otel go sdk
+const goSDKStack = `goroutine 42 [running]:
+go.opentelemetry.io/otel/sdk/trace.recordStackTrace()
+	/go/pkg/mod/go.opentelemetry.io/otel/sdk@v1.21.0/trace/span.go:511 +0x5d
+go.opentelemetry.io/otel/sdk/trace.(*recordingSpan).RecordError(0xc0001a2000, {0x7a3e40, 0xc000012345}, {0xc00009e1c0, 0x1, 0x1})
+	/go/pkg/mod/go.opentelemetry.io/otel/sdk@v1.21.0/trace/span.go:480 +0x3b1
+main.(*orderHandler).get(0xc00011c000, {0x7b1e28, 0xc0000a2000}, {0xc000014090, 0x2})
+	/app/handler.go:42 +0x1c5
+net/http.HandlerFunc.ServeHTTP(0xc000020000, {0x7b1e28, 0xc0000a2000}, 0xc0000b6000)
+	/usr/local/go/src/net/http/server.go:2136 +0x29
+created by net/http.(*Server).Serve in goroutine 1
+	/usr/local/go/src/net/http/server.go:3086 +0x5cb`
+
+func TestTopFrameOfGoStack(t *testing.T) {
+	assert.Equal(t, "main.(*orderHandler).get(0xc00011c000, {0x7b1e28, 0xc0000a2000}, {0xc000014090, 0x2})", topFrame(goSDKStack))
+	debugStack := "goroutine 7 [running]:\nruntime/debug.Stack()\n\t/usr/local/go/src/runtime/debug/stack.go:24 +0x5e\n" +
+		"main.(*cartHandler).add(0xc000120000)\n\t/app/cart.go:17 +0x2a"
+	assert.Equal(t, "main.(*cartHandler).add(0xc000120000)", topFrame(debugStack))
+
+	// errors of the same type raised in different functions get different fingerprints
+	otherFunction := strings.Replace(goSDKStack, "main.(*orderHandler).get", "main.(*orderHandler).list", 1)
+	assert.NotEqual(t, exceptionFingerprint("*errors.errorString", "not found", goSDKStack),
+		exceptionFingerprint("*errors.errorString", "not found", otherFunction))
+	otherLine := strings.Replace(goSDKStack, "/app/handler.go:42 +0x1c5", "/app/handler.go:45 +0x1d0", 1)
+	assert.Equal(t, exceptionFingerprint("*errors.errorString", "not found", goSDKStack),
+		exceptionFingerprint("*errors.errorString", "not found", otherLine))
+}
+
+func TestSetExceptionFingerprint(t *testing.T) {
+	span := model.Span{}
+	addExceptionTags(&span, "log", map[string]string{model.ExceptionTypeTag: "ignored"})
+	setExceptionFingerprint(&span)
+	_, ok := span.GetTag(model.ExceptionFingerprintTag)
+	assert.False(t, ok)
+
+	addExceptionTags(&span, "exception", map[string]string{model.ExceptionTypeTag: "KeyError", model.ExceptionMessageTag: "'a'"})
+	addExceptionTags(&span, "exception", map[string]string{model.ExceptionTypeTag: "ValueError"})
+	setExceptionFingerprint(&span)
+	exceptionType, _ := span.GetTag(model.ExceptionTypeTag)
+	fingerprint, ok := span.GetTag(model.ExceptionFingerprintTag)
+	assert.Equal(t, "KeyError", exceptionType)
+	assert.True(t, ok)
+	assert.Equal(t, exceptionFingerprint("KeyError", "'a'", ""), fingerprint)
+}
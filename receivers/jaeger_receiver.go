@@ -0,0 +1,297 @@
+package receivers
+
+import (
+	"encoding/base64"
+	"fmt"
+	model "goapm/domain"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jaeger model, see https://github.com/jaegertracing/jaeger-idl/blob/master/thrift/jaeger.thrift
+type jaegerTag struct {
+	Key     string
+	VType   int32
+	VStr    string
+	VDouble float64
+	VBool   bool
+	VLong   int64
+	VBinary []byte
+}
+
+type jaegerSpanRef struct {
+	RefType     int32
+	TraceIdLow  int64
+	TraceIdHigh int64
+	SpanId      int64
+}
+
+type jaegerSpan struct {
+	TraceIdLow    int64
+	TraceIdHigh   int64
+	SpanId        int64
+	ParentSpanId  int64
+	OperationName string
+	References    []jaegerSpanRef
+	StartTime     int64
+	Duration      int64
+	Tags          []jaegerTag
+}
+
+type jaegerProcess struct {
+	ServiceName string
+	Tags        []jaegerTag
+}
+
+type jaegerBatch struct {
+	Process jaegerProcess
+	Spans   []jaegerSpan
+}
+
+// jaeger TagType enum
+const (
+	jaegerTagString int32 = 0
+	jaegerTagDouble int32 = 1
+	jaegerTagBool   int32 = 2
+	jaegerTagLong   int32 = 3
+	jaegerTagBinary int32 = 4
+)
+
+const jaegerRefFollowsFrom int32 = 1
+
+var jaegerSpanKinds = map[string]int32{
+	"client":   model.SpanKindClient,
+	"server":   model.SpanKindServer,
+	"producer": model.SpanKindProducer,
+	"consumer": model.SpanKindConsumer,
+	"internal": model.SpanKindInternal,
+}
+
+// ParseJaegerThrift parse jaeger Batch encoded with thrift binary protocol into internal spans
+func ParseJaegerThrift(body []byte) ([]model.Span, error) {
+	reader := &thriftReader{buf: body}
+	batch, err := readJaegerBatch(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error in parsing jaeger thrift payload, %s", err.Error())
+	}
+
+	spans := make([]model.Span, 0, len(batch.Spans))
+	for i := range batch.Spans {
+		spans = append(spans, batch.Spans[i].toSpan(&batch.Process))
+	}
+	return spans, nil
+}
+
+func (jaegerSpan *jaegerSpan) toSpan(process *jaegerProcess) model.Span {
+	traceId := formatJaegerTraceId(jaegerSpan.TraceIdHigh, jaegerSpan.TraceIdLow)
+	span := model.Span{
+		Timestamp:   time.Unix(0, jaegerSpan.StartTime*int64(time.Microsecond)).UTC(),
+		TraceID:     traceId,
+		SpanID:      formatJaegerSpanId(jaegerSpan.SpanId),
+		ServiceName: process.ServiceName,
+		Name:        jaegerSpan.OperationName,
+		Kind:        model.SpanKindInternal,
+	}
+	if jaegerSpan.Duration > 0 {
+		span.DurationNano = uint64(jaegerSpan.Duration * int64(time.Microsecond))
+	}
+
+	spanStatus := model.SpanStatusUnset
+	for i := range jaegerSpan.Tags {
+		tag := &jaegerSpan.Tags[i]
+		value := tag.asString()
+		switch tag.Key {
+		case "span.kind":
+			if kind, ok := jaegerSpanKinds[strings.ToLower(value)]; ok {
+				span.Kind = kind
+			}
+		case "error":
+			if value == "true" {
+				spanStatus = model.SpanStatusError
+			}
+		case "otel.status_code":
+			if strings.EqualFold(value, "ERROR") {
+				spanStatus = model.SpanStatusError
+			} else if strings.EqualFold(value, "OK") && spanStatus == model.SpanStatusUnset {
+				spanStatus = model.SpanStatusOk
+			}
+		}
+		span.AddTag(tag.Key, value)
+	}
+	for i := range process.Tags {
+		if _, ok := span.GetTag(process.Tags[i].Key); !ok {
+			span.AddTag(process.Tags[i].Key, process.Tags[i].asString())
+		}
+	}
+	if spanStatus == model.SpanStatusError {
+		if _, ok := span.GetTag("error"); !ok {
+			span.AddTag("error", "true")
+		}
+	}
+
+	var references []model.OtelSpanRef
+	for _, ref := range jaegerSpan.References {
+		refType := "CHILD_OF"
+		if ref.RefType == jaegerRefFollowsFrom {
+			refType = "FOLLOWS_FROM"
+		}
+		reference := model.OtelSpanRef{TraceId: formatJaegerTraceId(ref.TraceIdHigh, ref.TraceIdLow), SpanId: formatJaegerSpanId(ref.SpanId), RefType: refType}
+		if len(span.ParentSpanID) == 0 && refType == "CHILD_OF" && reference.TraceId == traceId {
+			span.ParentSpanID = reference.SpanId
+		}
+		references = append(references, reference)
+	}
+	// old clients only send parentSpanId without references
+	if jaegerSpan.ParentSpanId != 0 && len(span.ParentSpanID) == 0 {
+		span.ParentSpanID = formatJaegerSpanId(jaegerSpan.ParentSpanId)
+		references = append([]model.OtelSpanRef{{TraceId: traceId, SpanId: span.ParentSpanID, RefType: "CHILD_OF"}}, references...)
+	}
+	span.SetReferences(references)
+
+	populateSpanColumns(&span, spanStatus)
+	return span
+}
+
+func (tag *jaegerTag) asString() string {
+	switch tag.VType {
+	case jaegerTagDouble:
+		return strconv.FormatFloat(tag.VDouble, 'f', -1, 64)
+	case jaegerTagBool:
+		return strconv.FormatBool(tag.VBool)
+	case jaegerTagLong:
+		return strconv.FormatInt(tag.VLong, 10)
+	case jaegerTagBinary:
+		return base64.StdEncoding.EncodeToString(tag.VBinary)
+	}
+	return tag.VStr
+}
+
+func formatJaegerTraceId(high int64, low int64) string {
+	return fmt.Sprintf("%016x%016x", uint64(high), uint64(low))
+}
+
+func formatJaegerSpanId(spanId int64) string {
+	return fmt.Sprintf("%016x", uint64(spanId))
+}
+
+func readJaegerBatch(reader *thriftReader) (*jaegerBatch, error) {
+	batch := &jaegerBatch{}
+	err := reader.readStruct(func(fieldId int16, fieldType byte) error {
+		switch {
+		case fieldId == 1 && fieldType == thriftStruct:
+			return reader.readStruct(func(fieldId int16, fieldType byte) error {
+				switch {
+				case fieldId == 1 && fieldType == thriftString:
+					serviceName, err := reader.readString()
+					batch.Process.ServiceName = serviceName
+					return err
+				case fieldId == 2 && fieldType == thriftList:
+					tags, err := readJaegerTags(reader)
+					batch.Process.Tags = tags
+					return err
+				}
+				return reader.skip(fieldType)
+			})
+		case fieldId == 2 && fieldType == thriftList:
+			return reader.readList(func(elementType byte) error {
+				span, err := readJaegerSpan(reader)
+				if err != nil {
+					return err
+				}
+				batch.Spans = append(batch.Spans, span)
+				return nil
+			})
+		}
+		return reader.skip(fieldType)
+	})
+	return batch, err
+}
+
+func readJaegerSpan(reader *thriftReader) (jaegerSpan, error) {
+	var span jaegerSpan
+	err := reader.readStruct(func(fieldId int16, fieldType byte) error {
+		var err error
+		switch {
+		case fieldId == 1 && fieldType == thriftI64:
+			span.TraceIdLow, err = reader.readI64()
+		case fieldId == 2 && fieldType == thriftI64:
+			span.TraceIdHigh, err = reader.readI64()
+		case fieldId == 3 && fieldType == thriftI64:
+			span.SpanId, err = reader.readI64()
+		case fieldId == 4 && fieldType == thriftI64:
+			span.ParentSpanId, err = reader.readI64()
+		case fieldId == 5 && fieldType == thriftString:
+			span.OperationName, err = reader.readString()
+		case fieldId == 6 && fieldType == thriftList:
+			err = reader.readList(func(elementType byte) error {
+				ref, err := readJaegerSpanRef(reader)
+				span.References = append(span.References, ref)
+				return err
+			})
+		case fieldId == 8 && fieldType == thriftI64:
+			span.StartTime, err = reader.readI64()
+		case fieldId == 9 && fieldType == thriftI64:
+			span.Duration, err = reader.readI64()
+		case fieldId == 10 && fieldType == thriftList:
+			span.Tags, err = readJaegerTags(reader)
+		default:
+			err = reader.skip(fieldType)
+		}
+		return err
+	})
+	return span, err
+}
+
+func readJaegerSpanRef(reader *thriftReader) (jaegerSpanRef, error) {
+	var ref jaegerSpanRef
+	err := reader.readStruct(func(fieldId int16, fieldType byte) error {
+		var err error
+		switch {
+		case fieldId == 1 && fieldType == thriftI32:
+			ref.RefType, err = reader.readI32()
+		case fieldId == 2 && fieldType == thriftI64:
+			ref.TraceIdLow, err = reader.readI64()
+		case fieldId == 3 && fieldType == thriftI64:
+			ref.TraceIdHigh, err = reader.readI64()
+		case fieldId == 4 && fieldType == thriftI64:
+			ref.SpanId, err = reader.readI64()
+		default:
+			err = reader.skip(fieldType)
+		}
+		return err
+	})
+	return ref, err
+}
+
+func readJaegerTags(reader *thriftReader) ([]jaegerTag, error) {
+	var tags []jaegerTag
+	err := reader.readList(func(elementType byte) error {
+		var tag jaegerTag
+		err := reader.readStruct(func(fieldId int16, fieldType byte) error {
+			var err error
+			switch {
+			case fieldId == 1 && fieldType == thriftString:
+				tag.Key, err = reader.readString()
+			case fieldId == 2 && fieldType == thriftI32:
+				tag.VType, err = reader.readI32()
+			case fieldId == 3 && fieldType == thriftString:
+				tag.VStr, err = reader.readString()
+			case fieldId == 4 && fieldType == thriftDouble:
+				tag.VDouble, err = reader.readDouble()
+			case fieldId == 5 && fieldType == thriftBool:
+				tag.VBool, err = reader.readBool()
+			case fieldId == 6 && fieldType == thriftI64:
+				tag.VLong, err = reader.readI64()
+			case fieldId == 7 && fieldType == thriftString:
+				tag.VBinary, err = reader.readBinary()
+			default:
+				err = reader.skip(fieldType)
+			}
+			return err
+		})
+		tags = append(tags, tag)
+		return err
+	})
+	return tags, err
+}
@@ -0,0 +1,127 @@
+package receivers
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	model "goapm/domain"
+	"testing"
+)
+
+type thriftTestWriter struct {
+	buf []byte
+}
+
+func (writer *thriftTestWriter) field(fieldType byte, fieldId int16) {
+	writer.buf = append(writer.buf, fieldType)
+	value := make([]byte, 2)
+	binary.BigEndian.PutUint16(value, uint16(fieldId))
+	writer.buf = append(writer.buf, value...)
+}
+
+func (writer *thriftTestWriter) i32(value int32) {
+	encoded := make([]byte, 4)
+	binary.BigEndian.PutUint32(encoded, uint32(value))
+	writer.buf = append(writer.buf, encoded...)
+}
+
+func (writer *thriftTestWriter) i64(value int64) {
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, uint64(value))
+	writer.buf = append(writer.buf, encoded...)
+}
+
+func (writer *thriftTestWriter) str(value string) {
+	writer.i32(int32(len(value)))
+	writer.buf = append(writer.buf, value...)
+}
+
+func (writer *thriftTestWriter) list(elementType byte, size int32) {
+	writer.buf = append(writer.buf, elementType)
+	writer.i32(size)
+}
+
+func (writer *thriftTestWriter) stop() {
+	writer.buf = append(writer.buf, thriftStop)
+}
+
+func (writer *thriftTestWriter) stringTag(key string, value string) {
+	writer.field(thriftString, 1)
+	writer.str(key)
+	writer.field(thriftI32, 2)
+	writer.i32(jaegerTagString)
+	writer.field(thriftString, 3)
+	writer.str(value)
+	writer.stop()
+}
+
+func TestParseJaegerThrift(t *testing.T) {
+	writer := &thriftTestWriter{}
+	// process
+	writer.field(thriftStruct, 1)
+	writer.field(thriftString, 1)
+	writer.str("customer")
+	writer.field(thriftList, 2)
+	writer.list(thriftStruct, 1)
+	writer.stringTag("hostname", "host-1")
+	writer.stop()
+	// spans
+	writer.field(thriftList, 2)
+	writer.list(thriftStruct, 1)
+	writer.field(thriftI64, 1)
+	writer.i64(2)
+	writer.field(thriftI64, 2)
+	writer.i64(1)
+	writer.field(thriftI64, 3)
+	writer.i64(3)
+	writer.field(thriftI64, 4)
+	writer.i64(0)
+	writer.field(thriftString, 5)
+	writer.str("SQL SELECT")
+	writer.field(thriftList, 6)
+	writer.list(thriftStruct, 1)
+	writer.field(thriftI32, 1)
+	writer.i32(0)
+	writer.field(thriftI64, 2)
+	writer.i64(2)
+	writer.field(thriftI64, 3)
+	writer.i64(1)
+	writer.field(thriftI64, 4)
+	writer.i64(10)
+	writer.stop()
+	writer.field(thriftI32, 7)
+	writer.i32(1)
+	writer.field(thriftI64, 8)
+	writer.i64(1556604172355737)
+	writer.field(thriftI64, 9)
+	writer.i64(250)
+	writer.field(thriftList, 10)
+	writer.list(thriftStruct, 3)
+	writer.stringTag("span.kind", "client")
+	writer.stringTag("db.system", "mysql")
+	writer.field(thriftString, 1)
+	writer.str("error")
+	writer.field(thriftI32, 2)
+	writer.i32(jaegerTagBool)
+	writer.field(thriftBool, 5)
+	writer.buf = append(writer.buf, 1)
+	writer.stop()
+	writer.stop()
+	writer.stop()
+
+	spans, err := ParseJaegerThrift(writer.buf)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "00000000000000010000000000000002", spans[0].TraceID)
+	assert.Equal(t, "0000000000000003", spans[0].SpanID)
+	assert.Equal(t, "000000000000000a", spans[0].ParentSpanID)
+	assert.Equal(t, "customer", spans[0].ServiceName)
+	assert.Equal(t, model.SpanKindClient, spans[0].Kind)
+	assert.Equal(t, uint64(250000), spans[0].DurationNano)
+	assert.Equal(t, model.SpanStatusError, spans[0].StatusCode)
+	assert.Equal(t, "mysql", *spans[0].DBSystem)
+	assert.Contains(t, spans[0].Tags, "hostname:host-1")
+	assert.Equal(t, `[{"traceId":"00000000000000010000000000000002","spanId":"000000000000000a","refType":"CHILD_OF"}]`, spans[0].References)
+
+	_, err = ParseJaegerThrift(writer.buf[:20])
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,282 @@
+package receivers
+
+import (
+	"encoding/hex"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+)
+
+// protobuf decoding of opentelemetry/proto/collector/trace/v1 ExportTraceServiceRequest,
+// only fields used by goapm are read, unknown fields are skipped
+
+type protoField struct {
+	number protowire.Number
+	typ    protowire.Type
+	bytes  []byte
+	scalar uint64
+}
+
+// walkProtoMessage iterate over all fields of encoded message and call handle for each one
+func walkProtoMessage(message []byte, handle func(field protoField) error) error {
+	for len(message) > 0 {
+		number, typ, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		message = message[n:]
+		field := protoField{number: number, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			field.scalar, n = protowire.ConsumeVarint(message)
+		case protowire.Fixed64Type:
+			field.scalar, n = protowire.ConsumeFixed64(message)
+		case protowire.Fixed32Type:
+			var value uint32
+			value, n = protowire.ConsumeFixed32(message)
+			field.scalar = uint64(value)
+		case protowire.BytesType:
+			field.bytes, n = protowire.ConsumeBytes(message)
+		default:
+			n = protowire.ConsumeFieldValue(number, typ, message)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		message = message[n:]
+		if err := handle(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeOtlpExportRequest(message []byte) (*otlpExportRequest, error) {
+	request := &otlpExportRequest{}
+	err := walkProtoMessage(message, func(field protoField) error {
+		if field.number == 1 && field.typ == protowire.BytesType {
+			resourceSpans, err := decodeOtlpResourceSpans(field.bytes)
+			if err != nil {
+				return err
+			}
+			request.ResourceSpans = append(request.ResourceSpans, resourceSpans)
+		}
+		return nil
+	})
+	return request, err
+}
+
+func decodeOtlpResourceSpans(message []byte) (otlpResourceSpans, error) {
+	var resourceSpans otlpResourceSpans
+	err := walkProtoMessage(message, func(field protoField) error {
+		if field.typ != protowire.BytesType {
+			return nil
+		}
+		switch field.number {
+		case 1:
+			return walkProtoMessage(field.bytes, func(resourceField protoField) error {
+				if resourceField.number == 1 && resourceField.typ == protowire.BytesType {
+					keyValue, err := decodeOtlpKeyValue(resourceField.bytes)
+					if err != nil {
+						return err
+					}
+					resourceSpans.Resource.Attributes = append(resourceSpans.Resource.Attributes, keyValue)
+				}
+				return nil
+			})
+		case 2, 1000:
+			scopeSpans, err := decodeOtlpScopeSpans(field.bytes)
+			if err != nil {
+				return err
+			}
+			resourceSpans.ScopeSpans = append(resourceSpans.ScopeSpans, scopeSpans)
+		}
+		return nil
+	})
+	return resourceSpans, err
+}
+
+func decodeOtlpScopeSpans(message []byte) (otlpScopeSpans, error) {
+	var scopeSpans otlpScopeSpans
+	err := walkProtoMessage(message, func(field protoField) error {
+		if field.typ != protowire.BytesType {
+			return nil
+		}
+		switch field.number {
+		case 1:
+			return walkProtoMessage(field.bytes, func(scopeField protoField) error {
+				if scopeField.number == 1 {
+					scopeSpans.Scope.Name = string(scopeField.bytes)
+				} else if scopeField.number == 2 {
+					scopeSpans.Scope.Version = string(scopeField.bytes)
+				}
+				return nil
+			})
+		case 2:
+			span, err := decodeOtlpSpan(field.bytes)
+			if err != nil {
+				return err
+			}
+			scopeSpans.Spans = append(scopeSpans.Spans, span)
+		}
+		return nil
+	})
+	return scopeSpans, err
+}
+
+func decodeOtlpSpan(message []byte) (otlpSpan, error) {
+	var span otlpSpan
+	err := walkProtoMessage(message, func(field protoField) error {
+		switch field.number {
+		case 1:
+			span.TraceId = hex.EncodeToString(field.bytes)
+		case 2:
+			span.SpanId = hex.EncodeToString(field.bytes)
+		case 4:
+			span.ParentSpanId = hex.EncodeToString(field.bytes)
+		case 5:
+			span.Name = string(field.bytes)
+		case 6:
+			span.Kind = int32(field.scalar)
+		case 7:
+			span.StartTimeUnixNano = otlpUint64(field.scalar)
+		case 8:
+			span.EndTimeUnixNano = otlpUint64(field.scalar)
+		case 9:
+			keyValue, err := decodeOtlpKeyValue(field.bytes)
+			if err != nil {
+				return err
+			}
+			span.Attributes = append(span.Attributes, keyValue)
+		case 11:
+			event, err := decodeOtlpEvent(field.bytes)
+			if err != nil {
+				return err
+			}
+			span.Events = append(span.Events, event)
+		case 13:
+			link, err := decodeOtlpLink(field.bytes)
+			if err != nil {
+				return err
+			}
+			span.Links = append(span.Links, link)
+		case 15:
+			return walkProtoMessage(field.bytes, func(statusField protoField) error {
+				if statusField.number == 2 {
+					span.Status.Message = string(statusField.bytes)
+				} else if statusField.number == 3 {
+					span.Status.Code = int64(statusField.scalar)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return span, err
+}
+
+func decodeOtlpEvent(message []byte) (otlpEvent, error) {
+	var event otlpEvent
+	err := walkProtoMessage(message, func(field protoField) error {
+		switch field.number {
+		case 1:
+			event.TimeUnixNano = otlpUint64(field.scalar)
+		case 2:
+			event.Name = string(field.bytes)
+		case 3:
+			keyValue, err := decodeOtlpKeyValue(field.bytes)
+			if err != nil {
+				return err
+			}
+			event.Attributes = append(event.Attributes, keyValue)
+		}
+		return nil
+	})
+	return event, err
+}
+
+func decodeOtlpLink(message []byte) (otlpLink, error) {
+	var link otlpLink
+	err := walkProtoMessage(message, func(field protoField) error {
+		switch field.number {
+		case 1:
+			link.TraceId = hex.EncodeToString(field.bytes)
+		case 2:
+			link.SpanId = hex.EncodeToString(field.bytes)
+		case 4:
+			keyValue, err := decodeOtlpKeyValue(field.bytes)
+			if err != nil {
+				return err
+			}
+			link.Attributes = append(link.Attributes, keyValue)
+		}
+		return nil
+	})
+	return link, err
+}
+
+func decodeOtlpKeyValue(message []byte) (otlpKeyValue, error) {
+	var keyValue otlpKeyValue
+	err := walkProtoMessage(message, func(field protoField) error {
+		if field.number == 1 {
+			keyValue.Key = string(field.bytes)
+		} else if field.number == 2 {
+			value, err := decodeOtlpAnyValue(field.bytes)
+			if err != nil {
+				return err
+			}
+			keyValue.Value = value
+		}
+		return nil
+	})
+	return keyValue, err
+}
+
+func decodeOtlpAnyValue(message []byte) (otlpAnyValue, error) {
+	var value otlpAnyValue
+	err := walkProtoMessage(message, func(field protoField) error {
+		switch field.number {
+		case 1:
+			stringValue := string(field.bytes)
+			value.StringValue = &stringValue
+		case 2:
+			boolValue := field.scalar != 0
+			value.BoolValue = &boolValue
+		case 3:
+			intValue := otlpInt64(int64(field.scalar))
+			value.IntValue = &intValue
+		case 4:
+			doubleValue := math.Float64frombits(field.scalar)
+			value.DoubleValue = &doubleValue
+		case 5:
+			value.ArrayValue = &otlpArrayValue{}
+			return walkProtoMessage(field.bytes, func(arrayField protoField) error {
+				if arrayField.number != 1 {
+					return nil
+				}
+				arrayValue, err := decodeOtlpAnyValue(arrayField.bytes)
+				if err != nil {
+					return err
+				}
+				value.ArrayValue.Values = append(value.ArrayValue.Values, arrayValue)
+				return nil
+			})
+		case 6:
+			value.KvlistValue = &otlpKvList{}
+			return walkProtoMessage(field.bytes, func(listField protoField) error {
+				if listField.number != 1 {
+					return nil
+				}
+				keyValue, err := decodeOtlpKeyValue(listField.bytes)
+				if err != nil {
+					return err
+				}
+				value.KvlistValue.Values = append(value.KvlistValue.Values, keyValue)
+				return nil
+			})
+		case 7:
+			value.BytesValue = field.bytes
+		}
+		return nil
+	})
+	return value, err
+}
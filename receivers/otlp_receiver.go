@@ -0,0 +1,256 @@
+package receivers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	model "goapm/domain"
+	"strconv"
+	"time"
+)
+
+// otlp model, json tags follow the OTLP/JSON encoding, protobuf payloads are decoded into the same structs
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	// InstrumentationLibrarySpans deprecated name of scopeSpans, still sent by older sdks
+	InstrumentationLibrarySpans []otlpScopeSpans `json:"instrumentationLibrarySpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope                  otlpScope  `json:"scope"`
+	InstrumentationLibrary otlpScope  `json:"instrumentationLibrary"`
+	Spans                  []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpSpan struct {
+	TraceId           string         `json:"traceId"`
+	SpanId            string         `json:"spanId"`
+	ParentSpanId      string         `json:"parentSpanId"`
+	Name              string         `json:"name"`
+	Kind              int32          `json:"kind"`
+	StartTimeUnixNano otlpUint64     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   otlpUint64     `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes"`
+	Events            []otlpEvent    `json:"events"`
+	Links             []otlpLink     `json:"links"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano otlpUint64     `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes"`
+}
+
+type otlpLink struct {
+	TraceId    string         `json:"traceId"`
+	SpanId     string         `json:"spanId"`
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpStatus struct {
+	Code    int64  `json:"code"`
+	Message string `json:"message"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *otlpInt64      `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+	KvlistValue *otlpKvList     `json:"kvlistValue,omitempty"`
+	BytesValue  []byte          `json:"bytesValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+type otlpKvList struct {
+	Values []otlpKeyValue `json:"values"`
+}
+
+// otlpUint64 OTLP/JSON encodes 64 bit integers as strings, but numbers are accepted as well
+type otlpUint64 uint64
+
+func (value *otlpUint64) UnmarshalJSON(data []byte) error {
+	parsed, err := strconv.ParseUint(string(bytes.Trim(data, `"`)), 10, 64)
+	if err != nil {
+		return err
+	}
+	*value = otlpUint64(parsed)
+	return nil
+}
+
+type otlpInt64 int64
+
+func (value *otlpInt64) UnmarshalJSON(data []byte) error {
+	parsed, err := strconv.ParseInt(string(bytes.Trim(data, `"`)), 10, 64)
+	if err != nil {
+		return err
+	}
+	*value = otlpInt64(parsed)
+	return nil
+}
+
+// ParseOtlpJson parse OTLP/JSON ExportTraceServiceRequest into internal spans
+func ParseOtlpJson(body []byte) ([]model.Span, error) {
+	var request otlpExportRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("error in parsing otlp json payload, %s", err.Error())
+	}
+	return request.toSpans(), nil
+}
+
+// ParseOtlpProtobuf parse OTLP/protobuf ExportTraceServiceRequest into internal spans
+func ParseOtlpProtobuf(body []byte) ([]model.Span, error) {
+	request, err := decodeOtlpExportRequest(body)
+	if err != nil {
+		return nil, fmt.Errorf("error in parsing otlp protobuf payload, %s", err.Error())
+	}
+	return request.toSpans(), nil
+}
+
+func (request *otlpExportRequest) toSpans() []model.Span {
+	var spans []model.Span
+	for _, resourceSpans := range request.ResourceSpans {
+		serviceName := ""
+		for _, attribute := range resourceSpans.Resource.Attributes {
+			if attribute.Key == "service.name" {
+				serviceName = attribute.Value.asString()
+			}
+		}
+		scopeSpansList := append(resourceSpans.ScopeSpans, resourceSpans.InstrumentationLibrarySpans...)
+		for _, scopeSpans := range scopeSpansList {
+			scope := scopeSpans.Scope
+			if len(scope.Name) == 0 {
+				scope = scopeSpans.InstrumentationLibrary
+			}
+			for _, otlpSpan := range scopeSpans.Spans {
+				spans = append(spans, otlpSpan.toSpan(serviceName, resourceSpans.Resource.Attributes, scope))
+			}
+		}
+	}
+	return spans
+}
+
+func (otlpSpan *otlpSpan) toSpan(serviceName string, resourceAttributes []otlpKeyValue, scope otlpScope) model.Span {
+	span := model.Span{
+		Timestamp:    time.Unix(0, int64(otlpSpan.StartTimeUnixNano)).UTC(),
+		TraceID:      otlpSpan.TraceId,
+		SpanID:       otlpSpan.SpanId,
+		ParentSpanID: otlpSpan.ParentSpanId,
+		ServiceName:  serviceName,
+		Name:         otlpSpan.Name,
+		Kind:         otlpSpan.Kind,
+	}
+	if otlpSpan.EndTimeUnixNano > otlpSpan.StartTimeUnixNano {
+		span.DurationNano = uint64(otlpSpan.EndTimeUnixNano - otlpSpan.StartTimeUnixNano)
+	}
+
+	// span attributes win over resource attributes with the same key
+	for _, attribute := range otlpSpan.Attributes {
+		span.AddTag(attribute.Key, attribute.Value.asString())
+	}
+	for _, attribute := range resourceAttributes {
+		if _, ok := span.GetTag(attribute.Key); !ok {
+			span.AddTag(attribute.Key, attribute.Value.asString())
+		}
+	}
+	for _, event := range otlpSpan.Events {
+		attributes := make(map[string]string, len(event.Attributes))
+		for _, attribute := range event.Attributes {
+			attributes[attribute.Key] = attribute.Value.asString()
+		}
+		addExceptionTags(&span, event.Name, attributes)
+	}
+	if len(scope.Name) != 0 {
+		span.AddTag("otel.library.name", scope.Name)
+	}
+	if len(scope.Version) != 0 {
+		span.AddTag("otel.library.version", scope.Version)
+	}
+	if otlpSpan.Status.Code == model.SpanStatusError {
+		span.AddTag("error", "true")
+	}
+	if len(otlpSpan.Status.Message) != 0 {
+		span.AddTag("otel.status_description", otlpSpan.Status.Message)
+	}
+
+	var references []model.OtelSpanRef
+	if len(otlpSpan.ParentSpanId) != 0 {
+		references = append(references, model.OtelSpanRef{TraceId: otlpSpan.TraceId, SpanId: otlpSpan.ParentSpanId, RefType: "CHILD_OF"})
+	}
+	for _, link := range otlpSpan.Links {
+		references = append(references, model.OtelSpanRef{TraceId: link.TraceId, SpanId: link.SpanId, RefType: "FOLLOWS_FROM"})
+	}
+	span.SetReferences(references)
+
+	populateSpanColumns(&span, otlpSpan.Status.Code)
+	return span
+}
+
+// asString flatten any value into the string stored in tagsValues, arrays and maps are stored as json
+func (value *otlpAnyValue) asString() string {
+	switch {
+	case value.StringValue != nil:
+		return *value.StringValue
+	case value.BoolValue != nil:
+		return strconv.FormatBool(*value.BoolValue)
+	case value.IntValue != nil:
+		return strconv.FormatInt(int64(*value.IntValue), 10)
+	case value.DoubleValue != nil:
+		return strconv.FormatFloat(*value.DoubleValue, 'f', -1, 64)
+	case value.BytesValue != nil:
+		return base64.StdEncoding.EncodeToString(value.BytesValue)
+	case value.ArrayValue != nil, value.KvlistValue != nil:
+		valueJson, _ := json.Marshal(value.asInterface())
+		return string(valueJson)
+	}
+	return ""
+}
+
+func (value *otlpAnyValue) asInterface() interface{} {
+	switch {
+	case value.ArrayValue != nil:
+		values := make([]interface{}, 0, len(value.ArrayValue.Values))
+		for i := range value.ArrayValue.Values {
+			values = append(values, value.ArrayValue.Values[i].asInterface())
+		}
+		return values
+	case value.KvlistValue != nil:
+		values := make(map[string]interface{}, len(value.KvlistValue.Values))
+		for i := range value.KvlistValue.Values {
+			values[value.KvlistValue.Values[i].Key] = value.KvlistValue.Values[i].Value.asInterface()
+		}
+		return values
+	case value.BoolValue != nil:
+		return *value.BoolValue
+	case value.IntValue != nil:
+		return int64(*value.IntValue)
+	case value.DoubleValue != nil:
+		return *value.DoubleValue
+	}
+	return value.asString()
+}
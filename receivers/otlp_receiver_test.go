@@ -0,0 +1,128 @@
+package receivers
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	model "goapm/domain"
+	"google.golang.org/protobuf/encoding/protowire"
+	"testing"
+)
+
+func appendStringKeyValue(b []byte, number protowire.Number, key string, value string) []byte {
+	var anyValue []byte
+	anyValue = protowire.AppendTag(anyValue, 1, protowire.BytesType)
+	anyValue = protowire.AppendString(anyValue, value)
+
+	var keyValue []byte
+	keyValue = protowire.AppendTag(keyValue, 1, protowire.BytesType)
+	keyValue = protowire.AppendString(keyValue, key)
+	keyValue = protowire.AppendTag(keyValue, 2, protowire.BytesType)
+	keyValue = protowire.AppendBytes(keyValue, anyValue)
+
+	b = protowire.AppendTag(b, number, protowire.BytesType)
+	return protowire.AppendBytes(b, keyValue)
+}
+
+func TestParseOtlpProtobuf(t *testing.T) {
+	traceId, _ := hex.DecodeString("5b8efff798038103d269b633813fc60c")
+	spanId, _ := hex.DecodeString("eee19b7ec3c1b174")
+	parentSpanId, _ := hex.DecodeString("eee19b7ec3c1b173")
+
+	var status []byte
+	status = protowire.AppendTag(status, 3, protowire.VarintType)
+	status = protowire.AppendVarint(status, 2)
+
+	var span []byte
+	span = protowire.AppendTag(span, 1, protowire.BytesType)
+	span = protowire.AppendBytes(span, traceId)
+	span = protowire.AppendTag(span, 2, protowire.BytesType)
+	span = protowire.AppendBytes(span, spanId)
+	span = protowire.AppendTag(span, 4, protowire.BytesType)
+	span = protowire.AppendBytes(span, parentSpanId)
+	span = protowire.AppendTag(span, 5, protowire.BytesType)
+	span = protowire.AppendString(span, "GET /users")
+	span = protowire.AppendTag(span, 6, protowire.VarintType)
+	span = protowire.AppendVarint(span, 3)
+	span = protowire.AppendTag(span, 7, protowire.Fixed64Type)
+	span = protowire.AppendFixed64(span, 1000)
+	span = protowire.AppendTag(span, 8, protowire.Fixed64Type)
+	span = protowire.AppendFixed64(span, 3000)
+	span = appendStringKeyValue(span, 9, "http.url", "http://users/api")
+	span = appendStringKeyValue(span, 9, "http.status_code", "503")
+	span = protowire.AppendTag(span, 15, protowire.BytesType)
+	span = protowire.AppendBytes(span, status)
+
+	var scope []byte
+	scope = protowire.AppendTag(scope, 1, protowire.BytesType)
+	scope = protowire.AppendString(scope, "io.opentelemetry.http")
+
+	var scopeSpans []byte
+	scopeSpans = protowire.AppendTag(scopeSpans, 1, protowire.BytesType)
+	scopeSpans = protowire.AppendBytes(scopeSpans, scope)
+	scopeSpans = protowire.AppendTag(scopeSpans, 2, protowire.BytesType)
+	scopeSpans = protowire.AppendBytes(scopeSpans, span)
+
+	var resource []byte
+	resource = appendStringKeyValue(resource, 1, "service.name", "frontend")
+
+	var resourceSpans []byte
+	resourceSpans = protowire.AppendTag(resourceSpans, 1, protowire.BytesType)
+	resourceSpans = protowire.AppendBytes(resourceSpans, resource)
+	resourceSpans = protowire.AppendTag(resourceSpans, 2, protowire.BytesType)
+	resourceSpans = protowire.AppendBytes(resourceSpans, scopeSpans)
+
+	var request []byte
+	request = protowire.AppendTag(request, 1, protowire.BytesType)
+	request = protowire.AppendBytes(request, resourceSpans)
+
+	spans, err := ParseOtlpProtobuf(request)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "5b8efff798038103d269b633813fc60c", spans[0].TraceID)
+	assert.Equal(t, "eee19b7ec3c1b174", spans[0].SpanID)
+	assert.Equal(t, "eee19b7ec3c1b173", spans[0].ParentSpanID)
+	assert.Equal(t, "frontend", spans[0].ServiceName)
+	assert.Equal(t, model.SpanKindClient, spans[0].Kind)
+	assert.Equal(t, uint64(2000), spans[0].DurationNano)
+	assert.Equal(t, int64(503), spans[0].StatusCode)
+	assert.Equal(t, "http://users/api", *spans[0].ExternalHttpUrl)
+	assert.Equal(t, "users", *spans[0].ExternalHost)
+	assert.Contains(t, spans[0].Tags, "error:true")
+	assert.Contains(t, spans[0].Tags, "otel.library.name:io.opentelemetry.http")
+	assert.Equal(t, `[{"traceId":"5b8efff798038103d269b633813fc60c","spanId":"eee19b7ec3c1b173","refType":"CHILD_OF"}]`, spans[0].References)
+
+	_, err = ParseOtlpProtobuf([]byte{0x0a, 0xff})
+	assert.NotNil(t, err)
+}
+
+func TestParseOtlpJson(t *testing.T) {
+	body := `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"orders"}}]},
+		"scopeSpans":[{"scope":{"name":"manual"},"spans":[{"traceId":"5b8efff798038103d269b633813fc60c","spanId":"eee19b7ec3c1b174",
+		"name":"SELECT orders","kind":3,"startTimeUnixNano":"1544712660000000000","endTimeUnixNano":1544712661000000000,
+		"attributes":[{"key":"db.system","value":{"stringValue":"mysql"}},{"key":"db.rows","value":{"intValue":"12"}},
+		{"key":"db.args","value":{"arrayValue":{"values":[{"stringValue":"a"},{"boolValue":true}]}}}],
+		"events":[{"name":"exception","attributes":[{"key":"exception.type","value":{"stringValue":"SQLException"}},
+		{"key":"exception.message","value":{"stringValue":"deadlock"}}]}],
+		"status":{"code":1}}]}]}]}`
+
+	spans, err := ParseOtlpJson([]byte(body))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "orders", spans[0].ServiceName)
+	assert.Equal(t, uint64(1000000000), spans[0].DurationNano)
+	assert.Equal(t, int64(1544712660), spans[0].Timestamp.Unix())
+	assert.Equal(t, "mysql", *spans[0].DBSystem)
+	assert.Equal(t, int64(1), spans[0].StatusCode)
+	assert.Equal(t, "[]", spans[0].References)
+	value, _ := spans[0].GetTag("db.rows")
+	assert.Equal(t, "12", value)
+	value, _ = spans[0].GetTag("db.args")
+	assert.Equal(t, `["a",true]`, value)
+	value, _ = spans[0].GetTag(model.ExceptionTypeTag)
+	assert.Equal(t, "SQLException", value)
+	_, ok := spans[0].GetTag(model.ExceptionFingerprintTag)
+	assert.True(t, ok)
+
+	_, err = ParseOtlpJson([]byte("{"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,68 @@
+package receivers
+
+import (
+	model "goapm/domain"
+	"net/url"
+	"strconv"
+)
+
+const unknownServiceName = "unknown_service"
+
+// populateSpanColumns fill the dedicated signoz_index columns(statusCode, externalHttpUrl, externalHost, dbSystem...) from span tags,
+// statusCode is taken from http.status_code if exists, otherwise from given span status
+func populateSpanColumns(span *model.Span, spanStatus int64) {
+	span.StatusCode = spanStatus
+	if httpStatusCode, ok := span.GetTag("http.status_code"); ok {
+		if statusCode, err := strconv.ParseInt(httpStatusCode, 10, 64); err == nil {
+			span.StatusCode = statusCode
+		}
+	}
+
+	if len(span.ServiceName) == 0 {
+		span.ServiceName = unknownServiceName
+	}
+
+	span.Component = getTagPointer(span, "component")
+	span.DBSystem = getTagPointer(span, "db.system")
+	span.DBName = getTagPointer(span, "db.name")
+	span.DBOperation = getTagPointer(span, "db.operation")
+	span.PeerService = getTagPointer(span, "peer.service")
+
+	if span.Kind == model.SpanKindClient {
+		span.ExternalHttpMethod = getTagPointer(span, "http.method")
+		span.ExternalHttpUrl = getTagPointer(span, "http.url")
+	}
+	if span.Kind == model.SpanKindClient || span.Kind == model.SpanKindProducer {
+		span.ExternalHost = externalHost(span)
+	}
+
+	setExceptionFingerprint(span)
+
+	if len(span.References) == 0 {
+		span.SetReferences(nil)
+	}
+}
+
+// externalHost host called by client or producer span, net.peer.name if reported, otherwise peer.service
+// and at last host of http.url, so grpc and messaging calls without http.url get a host too
+func externalHost(span *model.Span) *string {
+	for _, key := range []string{"net.peer.name", "peer.service"} {
+		if value, ok := span.GetTag(key); ok && len(value) != 0 {
+			return &value
+		}
+	}
+	if httpUrl, ok := span.GetTag("http.url"); ok {
+		if parsed, err := url.Parse(httpUrl); err == nil && len(parsed.Hostname()) != 0 {
+			host := parsed.Hostname()
+			return &host
+		}
+	}
+	return nil
+}
+
+func getTagPointer(span *model.Span, key string) *string {
+	if value, ok := span.GetTag(key); ok {
+		return &value
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package receivers
+
+import (
+	"github.com/stretchr/testify/assert"
+	model "goapm/domain"
+	"testing"
+)
+
+func newColumnsSpan(kind int32, tags ...string) *model.Span {
+	span := &model.Span{ServiceName: "frontend", Kind: kind}
+	for i := 0; i+1 < len(tags); i += 2 {
+		span.AddTag(tags[i], tags[i+1])
+	}
+	populateSpanColumns(span, 0)
+	return span
+}
+
+func TestPopulateExternalHost(t *testing.T) {
+	span := newColumnsSpan(model.SpanKindClient, "rpc.system", "grpc", "net.peer.name", "users", "peer.service", "users-svc")
+	assert.Equal(t, "users", *span.ExternalHost)
+	assert.Nil(t, span.ExternalHttpUrl)
+
+	span = newColumnsSpan(model.SpanKindProducer, "messaging.system", "kafka", "peer.service", "kafka")
+	assert.Equal(t, "kafka", *span.ExternalHost)
+
+	span = newColumnsSpan(model.SpanKindClient, "http.url", "https://api.stripe.com:443/v1/charges")
+	assert.Equal(t, "api.stripe.com", *span.ExternalHost)
+
+	span = newColumnsSpan(model.SpanKindClient, "net.peer.name", "", "http.url", "http://users/api")
+	assert.Equal(t, "users", *span.ExternalHost)
+
+	assert.Nil(t, newColumnsSpan(model.SpanKindClient).ExternalHost)
+	assert.Nil(t, newColumnsSpan(model.SpanKindServer, "net.peer.name", "frontend").ExternalHost)
+}
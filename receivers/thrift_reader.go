@@ -0,0 +1,185 @@
+package receivers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// thrift binary protocol type ids
+const (
+	thriftStop   byte = 0
+	thriftBool   byte = 2
+	thriftByte   byte = 3
+	thriftDouble byte = 4
+	thriftI16    byte = 6
+	thriftI32    byte = 8
+	thriftI64    byte = 10
+	thriftString byte = 11
+	thriftStruct byte = 12
+	thriftMap    byte = 13
+	thriftSet    byte = 14
+	thriftList   byte = 15
+)
+
+const thriftMaxContainerSize = 1 << 24
+
+var errThriftEndOfBuffer = errors.New("unexpected end of thrift payload")
+
+// thriftReader minimal reader of thrift binary protocol(TBinaryProtocol), enough to decode jaeger batches
+type thriftReader struct {
+	buf []byte
+	pos int
+}
+
+func (reader *thriftReader) next(size int) ([]byte, error) {
+	if size < 0 || reader.pos+size > len(reader.buf) {
+		return nil, errThriftEndOfBuffer
+	}
+	value := reader.buf[reader.pos : reader.pos+size]
+	reader.pos += size
+	return value, nil
+}
+
+func (reader *thriftReader) readByte() (byte, error) {
+	value, err := reader.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return value[0], nil
+}
+
+func (reader *thriftReader) readBool() (bool, error) {
+	value, err := reader.readByte()
+	return value != 0, err
+}
+
+func (reader *thriftReader) readI16() (int16, error) {
+	value, err := reader.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(binary.BigEndian.Uint16(value)), nil
+}
+
+func (reader *thriftReader) readI32() (int32, error) {
+	value, err := reader.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(value)), nil
+}
+
+func (reader *thriftReader) readI64() (int64, error) {
+	value, err := reader.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(value)), nil
+}
+
+func (reader *thriftReader) readDouble() (float64, error) {
+	value, err := reader.readI64()
+	return math.Float64frombits(uint64(value)), err
+}
+
+func (reader *thriftReader) readBinary() ([]byte, error) {
+	size, err := reader.readI32()
+	if err != nil {
+		return nil, err
+	}
+	return reader.next(int(size))
+}
+
+func (reader *thriftReader) readString() (string, error) {
+	value, err := reader.readBinary()
+	return string(value), err
+}
+
+// readStruct read struct fields until stop field, handle must read(or skip) the value of every field it gets
+func (reader *thriftReader) readStruct(handle func(fieldId int16, fieldType byte) error) error {
+	for {
+		fieldType, err := reader.readByte()
+		if err != nil {
+			return err
+		}
+		if fieldType == thriftStop {
+			return nil
+		}
+		fieldId, err := reader.readI16()
+		if err != nil {
+			return err
+		}
+		if err = handle(fieldId, fieldType); err != nil {
+			return err
+		}
+	}
+}
+
+// readList read list header and call handle for each element
+func (reader *thriftReader) readList(handle func(elementType byte) error) error {
+	elementType, err := reader.readByte()
+	if err != nil {
+		return err
+	}
+	size, err := reader.readI32()
+	if err != nil {
+		return err
+	}
+	if size < 0 || size > thriftMaxContainerSize {
+		return fmt.Errorf("invalid thrift list size %d", size)
+	}
+	for i := int32(0); i < size; i++ {
+		if err = handle(elementType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skip read and drop value of given type
+func (reader *thriftReader) skip(fieldType byte) error {
+	var err error
+	switch fieldType {
+	case thriftBool, thriftByte:
+		_, err = reader.next(1)
+	case thriftI16:
+		_, err = reader.next(2)
+	case thriftI32:
+		_, err = reader.next(4)
+	case thriftDouble, thriftI64:
+		_, err = reader.next(8)
+	case thriftString:
+		_, err = reader.readBinary()
+	case thriftStruct:
+		err = reader.readStruct(func(fieldId int16, fieldType byte) error {
+			return reader.skip(fieldType)
+		})
+	case thriftMap:
+		var keyType, valueType byte
+		var size int32
+		if keyType, err = reader.readByte(); err != nil {
+			return err
+		}
+		if valueType, err = reader.readByte(); err != nil {
+			return err
+		}
+		if size, err = reader.readI32(); err != nil {
+			return err
+		}
+		if size < 0 || size > thriftMaxContainerSize {
+			return fmt.Errorf("invalid thrift map size %d", size)
+		}
+		for i := int32(0); i < size && err == nil; i++ {
+			if err = reader.skip(keyType); err == nil {
+				err = reader.skip(valueType)
+			}
+		}
+	case thriftSet, thriftList:
+		err = reader.readList(reader.skip)
+	default:
+		err = fmt.Errorf("unknown thrift type %d", fieldType)
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package receivers
+
+import (
+	"encoding/json"
+	"fmt"
+	model "goapm/domain"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	Ipv4        string `json:"ipv4"`
+	Ipv6        string `json:"ipv6"`
+	Port        int    `json:"port"`
+}
+
+type zipkinSpan struct {
+	TraceId        string            `json:"traceId"`
+	Id             string            `json:"id"`
+	ParentId       string            `json:"parentId"`
+	Name           string            `json:"name"`
+	Kind           string            `json:"kind"`
+	Timestamp      int64             `json:"timestamp"`
+	Duration       int64             `json:"duration"`
+	LocalEndpoint  *zipkinEndpoint   `json:"localEndpoint"`
+	RemoteEndpoint *zipkinEndpoint   `json:"remoteEndpoint"`
+	Tags           map[string]string `json:"tags"`
+}
+
+var zipkinSpanKinds = map[string]int32{
+	"CLIENT":   model.SpanKindClient,
+	"SERVER":   model.SpanKindServer,
+	"PRODUCER": model.SpanKindProducer,
+	"CONSUMER": model.SpanKindConsumer,
+}
+
+// ParseZipkinJson parse zipkin v2 json span list into internal spans
+func ParseZipkinJson(body []byte) ([]model.Span, error) {
+	var zipkinSpans []zipkinSpan
+	if err := json.Unmarshal(body, &zipkinSpans); err != nil {
+		return nil, fmt.Errorf("error in parsing zipkin json payload, %s", err.Error())
+	}
+
+	spans := make([]model.Span, 0, len(zipkinSpans))
+	for i := range zipkinSpans {
+		spans = append(spans, zipkinSpans[i].toSpan())
+	}
+	return spans, nil
+}
+
+func (zipkinSpan *zipkinSpan) toSpan() model.Span {
+	traceId := padTraceId(strings.ToLower(zipkinSpan.TraceId))
+	span := model.Span{
+		Timestamp:    time.Unix(0, zipkinSpan.Timestamp*int64(time.Microsecond)).UTC(),
+		TraceID:      traceId,
+		SpanID:       strings.ToLower(zipkinSpan.Id),
+		ParentSpanID: strings.ToLower(zipkinSpan.ParentId),
+		Name:         zipkinSpan.Name,
+		Kind:         model.SpanKindInternal,
+	}
+	if zipkinSpan.Duration > 0 {
+		span.DurationNano = uint64(zipkinSpan.Duration * int64(time.Microsecond))
+	}
+	if kind, ok := zipkinSpanKinds[strings.ToUpper(zipkinSpan.Kind)]; ok {
+		span.Kind = kind
+	}
+	if zipkinSpan.LocalEndpoint != nil {
+		span.ServiceName = zipkinSpan.LocalEndpoint.ServiceName
+	}
+
+	spanStatus := model.SpanStatusUnset
+	tagKeys := make([]string, 0, len(zipkinSpan.Tags))
+	for key := range zipkinSpan.Tags {
+		tagKeys = append(tagKeys, key)
+	}
+	sort.Strings(tagKeys)
+	for _, key := range tagKeys {
+		value := zipkinSpan.Tags[key]
+		// zipkin error tag holds the error message, any value means the span failed
+		if key == "error" {
+			spanStatus = model.SpanStatusError
+			span.AddTag("error", "true")
+			if len(value) != 0 && value != "true" {
+				span.AddTag("otel.status_description", value)
+			}
+			continue
+		}
+		span.AddTag(key, value)
+	}
+	if remoteEndpoint := zipkinSpan.RemoteEndpoint; remoteEndpoint != nil {
+		if len(remoteEndpoint.ServiceName) != 0 {
+			if _, ok := span.GetTag("peer.service"); !ok {
+				span.AddTag("peer.service", remoteEndpoint.ServiceName)
+			}
+		}
+		if len(remoteEndpoint.Ipv4) != 0 {
+			span.AddTag("net.peer.ip", remoteEndpoint.Ipv4)
+		} else if len(remoteEndpoint.Ipv6) != 0 {
+			span.AddTag("net.peer.ip", remoteEndpoint.Ipv6)
+		}
+		if remoteEndpoint.Port != 0 {
+			span.AddTag("net.peer.port", strconv.Itoa(remoteEndpoint.Port))
+		}
+	}
+
+	if len(span.ParentSpanID) != 0 {
+		span.SetReferences([]model.OtelSpanRef{{TraceId: traceId, SpanId: span.ParentSpanID, RefType: "CHILD_OF"}})
+	}
+
+	populateSpanColumns(&span, spanStatus)
+	return span
+}
+
+// padTraceId left pad 64 bit trace ids to the 128 bit hex format used by otlp
+func padTraceId(traceId string) string {
+	if len(traceId) == 16 {
+		return strings.Repeat("0", 16) + traceId
+	}
+	return traceId
+}
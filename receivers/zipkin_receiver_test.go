@@ -0,0 +1,32 @@
+package receivers
+
+import (
+	"github.com/stretchr/testify/assert"
+	model "goapm/domain"
+	"testing"
+)
+
+func TestParseZipkinJson(t *testing.T) {
+	body := `[{"traceId":"463ac35c9f6413ad","id":"a2fb4a1d1a96d312","parentId":"463ac35c9f6413ad","name":"get /api",
+		"kind":"CLIENT","timestamp":1556604172355737,"duration":1431,
+		"localEndpoint":{"serviceName":"frontend","ipv4":"192.168.99.1"},
+		"remoteEndpoint":{"serviceName":"backend","ipv4":"172.19.0.2","port":8080},
+		"tags":{"http.method":"GET","http.url":"http://backend/api","error":"connection refused"}}]`
+
+	spans, err := ParseZipkinJson([]byte(body))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "0000000000000000463ac35c9f6413ad", spans[0].TraceID)
+	assert.Equal(t, "463ac35c9f6413ad", spans[0].ParentSpanID)
+	assert.Equal(t, "frontend", spans[0].ServiceName)
+	assert.Equal(t, model.SpanKindClient, spans[0].Kind)
+	assert.Equal(t, uint64(1431000), spans[0].DurationNano)
+	assert.Equal(t, model.SpanStatusError, spans[0].StatusCode)
+	assert.Equal(t, "http://backend/api", *spans[0].ExternalHttpUrl)
+	assert.Equal(t, "backend", *spans[0].PeerService)
+	assert.Contains(t, spans[0].Tags, "error:true")
+	assert.Equal(t, `[{"traceId":"0000000000000000463ac35c9f6413ad","spanId":"463ac35c9f6413ad","refType":"CHILD_OF"}]`, spans[0].References)
+
+	_, err = ParseZipkinJson([]byte(`{"traceId":1}`))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"goapm/dao"
+	model "goapm/domain"
+	"goapm/logger"
+	redis_factory "goapm/redis"
+	"sync"
+	"time"
+)
+
+// alertRuleLockSeconds lock of rule expires before the next evaluation tick
+const alertRuleLockSeconds = 55
+
+var alertEvaluatorOnce sync.Once
+var alertEvaluator *AlertEvaluator
+
+type AlertEvaluator struct {
+	Logger       *zap.SugaredLogger
+	AlertDao     dao.AlertDao
+	ApmDao       dao.ApmDao
+	SLODao       dao.SLODao
+	RedisService redis_factory.SpecificRedisService
+}
+
+func NewAlertEvaluator(AlertDao dao.AlertDao, ApmDao dao.ApmDao, SLODao dao.SLODao, RedisService redis_factory.SpecificRedisService) *AlertEvaluator {
+	alertEvaluatorOnce.Do(func() {
+		alertEvaluator = &AlertEvaluator{
+			Logger:       logger.LOGGER,
+			AlertDao:     AlertDao,
+			ApmDao:       ApmDao,
+			SLODao:       SLODao,
+			RedisService: RedisService,
+		}
+	})
+	return alertEvaluator
+}
+
+// Run evaluate enabled rules once, it is called every minute on every replica and a redis lock per rule
+// lets only one replica evaluate the rule in that minute. State transitions are appended to rule history
+func (evaluator *AlertEvaluator) Run() {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+	now := time.Now()
+
+	rules, err := evaluator.AlertDao.GetRules(ctx)
+	if err != nil {
+		evaluator.Logger.Error("unable to get alert rules ", err)
+		return
+	}
+	statuses, err := evaluator.AlertDao.GetRuleStatuses(ctx)
+	if err != nil {
+		evaluator.Logger.Error("unable to get alert rule statuses ", err)
+		return
+	}
+	statusByRule := make(map[string]model.AlertStatus, len(statuses))
+	for _, status := range statuses {
+		statusByRule[status.RuleID] = status
+	}
+
+	var history []model.AlertHistoryItem
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Disabled || !evaluator.lock(ctx, rule, now) {
+			continue
+		}
+		transition, err := evaluator.evaluateRule(ctx, rule, statusByRule[rule.ID], now)
+		if err != nil {
+			evaluator.Logger.Error("unable to evaluate alert rule ", rule.ID, ", error = ", err)
+			continue
+		}
+		if transition != nil {
+			history = append(history, *transition)
+		}
+	}
+
+	if err := evaluator.AlertDao.InsertHistory(ctx, history); err != nil {
+		evaluator.Logger.Error("unable to insert alert history ", err)
+	}
+}
+
+func (evaluator *AlertEvaluator) lock(ctx context.Context, rule *model.AlertRule, now time.Time) bool {
+	locked, err := evaluator.RedisService.GetSpecificRedis().SetNX(ctx, "ALERT_RULE_"+rule.ID, now.Format(timeLayout), alertRuleLockSeconds)
+	if err != nil {
+		evaluator.Logger.Error("unable to lock alert rule ", rule.ID, ", error = ", err)
+		return false
+	}
+	return locked
+}
+
+// evaluateRule transition of rule caused by its current metric value, nil when state didn't change
+func (evaluator *AlertEvaluator) evaluateRule(ctx context.Context, rule *model.AlertRule, status model.AlertStatus, now time.Time) (*model.AlertHistoryItem, error) {
+	value, err := evaluator.metricValue(ctx, rule, now)
+	if err != nil {
+		return nil, err
+	}
+	state := model.NextAlertState(status.State, time.Unix(0, status.Since), rule.ConditionMet(value), now, time.Duration(rule.ForSeconds)*time.Second)
+	if state == status.State {
+		return nil, nil
+	}
+	return &model.AlertHistoryItem{RuleID: rule.ID, State: state, Value: value, Threshold: rule.Threshold, Timestamp: now.UnixNano()}, nil
+}
+
+// metricValue value of rule metric over window of rule ending at now, call rate drop is percentage drop of calls
+// against the window right before, negative when calls grew. Slo burn rate is scored by multi-window conditions of slo
+func (evaluator *AlertEvaluator) metricValue(ctx context.Context, rule *model.AlertRule, now time.Time) (float64, error) {
+	if rule.Metric == model.AlertMetricSLOBurnRate {
+		slo, err := evaluator.SLODao.GetSLO(ctx, rule.SLOID)
+		if err != nil {
+			return 0, err
+		}
+		if slo == nil {
+			return 0, fmt.Errorf("slo %s not found", rule.SLOID)
+		}
+		status, err := getSLOStatus(ctx, evaluator.ApmDao, slo, now)
+		if err != nil {
+			return 0, err
+		}
+		return burnRateAlertValue(status.BurnRates, slo.BurnRateConditions()), nil
+	}
+
+	window := time.Duration(rule.WindowSeconds) * time.Second
+	start := now.Add(-window)
+	metrics, err := evaluator.ApmDao.GetServiceMetrics(ctx, &model.GetServiceMetricsParams{
+		ServiceName: rule.ServiceName,
+		Apdex:       rule.Metric == model.AlertMetricApdex,
+		Start:       &start,
+		End:         &now,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	switch rule.Metric {
+	case model.AlertMetricErrorRate:
+		if metrics.NumCalls == 0 {
+			return 0, nil
+		}
+		return float64(metrics.NumErrors) * 100 / float64(metrics.NumCalls), nil
+	case model.AlertMetricP99Latency:
+		return metrics.P99 / float64(time.Millisecond), nil
+	case model.AlertMetricApdex:
+		// nobody is dissatisfied without calls, same as error rate without calls is 0
+		if metrics.Apdex.Apdex == nil {
+			return 1, nil
+		}
+		return *metrics.Apdex.Apdex, nil
+	case model.AlertMetricCallRateDrop:
+		baselineStart := start.Add(-window)
+		baseline, err := evaluator.ApmDao.GetServiceMetrics(ctx, &model.GetServiceMetricsParams{
+			ServiceName: rule.ServiceName,
+			Start:       &baselineStart,
+			End:         &start,
+		})
+		if err != nil {
+			return 0, err
+		}
+		if baseline.NumCalls == 0 {
+			return 0, nil
+		}
+		return (float64(baseline.NumCalls) - float64(metrics.NumCalls)) * 100 / float64(baseline.NumCalls), nil
+	}
+	return 0, fmt.Errorf("unknown alert metric %s", rule.Metric)
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"goapm/dao"
+	model "goapm/domain"
+	"goapm/logger"
+	redis_factory "goapm/redis"
+	"testing"
+	"time"
+)
+
+// serviceMetricsDao apm dao returning metrics of the window ending at end of time range
+type serviceMetricsDao struct {
+	dao.ApmDao
+	metrics func(params *model.GetServiceMetricsParams) *model.ServiceMetrics
+}
+
+func (apmDao *serviceMetricsDao) GetServiceMetrics(ctx context.Context, params *model.GetServiceMetricsParams) (*model.ServiceMetrics, error) {
+	return apmDao.metrics(params), nil
+}
+
+func newAlertEvaluatorTest(locked bool, metrics func(params *model.GetServiceMetricsParams) *model.ServiceMetrics) (*AlertEvaluator, *dao.MockAlertDao) {
+	redisMock := new(redis_factory.MockRedisFactory)
+	redisMock.On("SetNX", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(locked, nil)
+	specificRedisMock := new(redis_factory.MockSpecificRedisFactory)
+	specificRedisMock.On("GetSpecificRedis", mock.Anything).Return(redisMock)
+	alertDaoMock := new(dao.MockAlertDao)
+	return &AlertEvaluator{
+		Logger:       logger.LOGGER,
+		AlertDao:     alertDaoMock,
+		ApmDao:       &serviceMetricsDao{metrics: metrics},
+		RedisService: specificRedisMock,
+	}, alertDaoMock
+}
+
+func errorRateRule(forSeconds int) model.AlertRule {
+	return model.AlertRule{ID: "r1", Name: "errors", Metric: model.AlertMetricErrorRate, ServiceName: "cart",
+		Operator: model.AlertOperatorAbove, Threshold: 5, WindowSeconds: 300, ForSeconds: forSeconds}
+}
+
+func TestAlertEvaluatorTransitions(t *testing.T) {
+	errors := uint64(10)
+	evaluator, alertDaoMock := newAlertEvaluatorTest(true, func(params *model.GetServiceMetricsParams) *model.ServiceMetrics {
+		return &model.ServiceMetrics{NumCalls: 100, NumErrors: errors}
+	})
+	now := time.Now()
+	rule := errorRateRule(120)
+
+	transition, err := evaluator.evaluateRule(context.Background(), &rule, model.AlertStatus{}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, model.AlertStatePending, transition.State)
+	assert.Equal(t, float64(10), transition.Value)
+
+	pending := model.AlertStatus{RuleID: "r1", State: model.AlertStatePending, Since: now.Add(-time.Minute).UnixNano()}
+	transition, err = evaluator.evaluateRule(context.Background(), &rule, pending, now)
+	assert.Nil(t, err)
+	assert.Nil(t, transition)
+
+	pending.Since = now.Add(-2 * time.Minute).UnixNano()
+	transition, err = evaluator.evaluateRule(context.Background(), &rule, pending, now)
+	assert.Nil(t, err)
+	assert.Equal(t, model.AlertStateFiring, transition.State)
+
+	errors = 1
+	firing := model.AlertStatus{RuleID: "r1", State: model.AlertStateFiring, Since: now.Add(-time.Hour).UnixNano()}
+	transition, err = evaluator.evaluateRule(context.Background(), &rule, firing, now)
+	assert.Nil(t, err)
+	assert.Equal(t, model.AlertStateResolved, transition.State)
+	alertDaoMock.AssertExpectations(t)
+}
+
+func TestAlertEvaluatorCallRateDrop(t *testing.T) {
+	now := time.Now()
+	evaluator, _ := newAlertEvaluatorTest(true, func(params *model.GetServiceMetricsParams) *model.ServiceMetrics {
+		if params.End.Equal(now) {
+			return &model.ServiceMetrics{NumCalls: 40}
+		}
+		return &model.ServiceMetrics{NumCalls: 100}
+	})
+	rule := model.AlertRule{ID: "r2", Metric: model.AlertMetricCallRateDrop, ServiceName: "cart",
+		Operator: model.AlertOperatorAbove, Threshold: 50, WindowSeconds: 600}
+
+	value, err := evaluator.metricValue(context.Background(), &rule, now)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(60), value)
+}
+
+func TestAlertEvaluatorApdex(t *testing.T) {
+	apdex := 0.8
+	score := &model.ApdexScore{Apdex: &apdex}
+	evaluator, _ := newAlertEvaluatorTest(true, func(params *model.GetServiceMetricsParams) *model.ServiceMetrics {
+		assert.True(t, params.Apdex)
+		return &model.ServiceMetrics{Apdex: score}
+	})
+	rule := model.AlertRule{ID: "r3", Metric: model.AlertMetricApdex, ServiceName: "cart",
+		Operator: model.AlertOperatorBelow, Threshold: 0.9, WindowSeconds: 600}
+
+	value, err := evaluator.metricValue(context.Background(), &rule, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, 0.8, value)
+
+	// no calls in window
+	score.Apdex = nil
+	value, err = evaluator.metricValue(context.Background(), &rule, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, 1.0, value)
+}
+
+func TestAlertEvaluatorRun(t *testing.T) {
+	evaluator, alertDaoMock := newAlertEvaluatorTest(true, func(params *model.GetServiceMetricsParams) *model.ServiceMetrics {
+		return &model.ServiceMetrics{NumCalls: 100, NumErrors: 50}
+	})
+	disabled := errorRateRule(0)
+	disabled.ID = "r2"
+	disabled.Disabled = true
+	alertDaoMock.On("GetRules", mock.Anything).Return([]model.AlertRule{errorRateRule(0), disabled}, nil)
+	alertDaoMock.On("GetRuleStatuses", mock.Anything).Return([]model.AlertStatus{}, nil)
+	alertDaoMock.On("InsertHistory", mock.Anything, mock.MatchedBy(func(items []model.AlertHistoryItem) bool {
+		return len(items) == 1 && items[0].RuleID == "r1" && items[0].State == model.AlertStateFiring
+	})).Return(nil)
+
+	evaluator.Run()
+	alertDaoMock.AssertExpectations(t)
+}
+
+func TestAlertEvaluatorRunLocked(t *testing.T) {
+	evaluator, alertDaoMock := newAlertEvaluatorTest(false, func(params *model.GetServiceMetricsParams) *model.ServiceMetrics {
+		return &model.ServiceMetrics{NumCalls: 100, NumErrors: 50}
+	})
+	alertDaoMock.On("GetRules", mock.Anything).Return([]model.AlertRule{errorRateRule(0)}, nil)
+	alertDaoMock.On("GetRuleStatuses", mock.Anything).Return([]model.AlertStatus{}, nil)
+	alertDaoMock.On("InsertHistory", mock.Anything, mock.MatchedBy(func(items []model.AlertHistoryItem) bool {
+		return len(items) == 0
+	})).Return(nil)
+
+	evaluator.Run()
+	alertDaoMock.AssertExpectations(t)
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"goapm/dao"
+	model "goapm/domain"
+	"goapm/logger"
+	redis_factory "goapm/redis"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resolvedLookback resolved alerts older than that are not sent to groups which were never notified
+const resolvedLookback = 5 * time.Minute
+
+var alertNotifierOnce sync.Once
+var alertNotifier *AlertNotifier
+
+type AlertNotifier struct {
+	Logger       *zap.SugaredLogger
+	AlertDao     dao.AlertDao
+	ChannelDao   dao.ChannelDao
+	SilenceDao   dao.SilenceDao
+	RedisService redis_factory.SpecificRedisService
+	Sender       *NotificationSender
+}
+
+func NewAlertNotifier(AlertDao dao.AlertDao, ChannelDao dao.ChannelDao, SilenceDao dao.SilenceDao,
+	RedisService redis_factory.SpecificRedisService, Sender *NotificationSender) *AlertNotifier {
+	alertNotifierOnce.Do(func() {
+		alertNotifier = &AlertNotifier{
+			Logger:       logger.LOGGER,
+			AlertDao:     AlertDao,
+			ChannelDao:   ChannelDao,
+			SilenceDao:   SilenceDao,
+			RedisService: RedisService,
+			Sender:       Sender,
+		}
+	})
+	return alertNotifier
+}
+
+// notificationRecord last notification of group kept in redis for repeat interval of channel
+type notificationRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	SentAt      int64  `json:"sentAt"`
+}
+
+// Run send firing and resolved alerts to matching channels, it is called every minute and only one replica sends.
+// Group is sent when its firing alerts changed, when some of its alerts resolved since last notification,
+// or when repeat interval passed and group is still firing. Silenced alerts are left out, their state is still recorded
+// by evaluator, so alert still firing when silence ends is sent as new
+func (notifier *AlertNotifier) Run() {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+	now := time.Now()
+
+	locked, err := notifier.RedisService.GetSpecificRedis().SetNX(ctx, "ALERT_NOTIFIER", now.Format(timeLayout), alertRuleLockSeconds)
+	if err != nil || !locked {
+		return
+	}
+	notifications, err := notifier.currentNotifications(ctx, now)
+	if err != nil {
+		notifier.Logger.Error("unable to get alerts to notify ", err)
+		return
+	}
+	notifications, err = notifier.unsilenced(ctx, notifications, now)
+	if err != nil {
+		notifier.Logger.Error("unable to get silences ", err)
+		return
+	}
+	channels, err := notifier.ChannelDao.GetChannels(ctx)
+	if err != nil {
+		notifier.Logger.Error("unable to get notification channels ", err)
+		return
+	}
+
+	for i := range channels {
+		channel := &channels[i]
+		if channel.Disabled {
+			continue
+		}
+		for _, group := range groupNotifications(channel, notifications) {
+			if err := notifier.notifyGroup(ctx, channel, group, now); err != nil {
+				notifier.Logger.Error("unable to notify channel ", channel.ID, ", error = ", err)
+			}
+		}
+	}
+}
+
+// currentNotifications firing alerts of enabled rules and alerts resolved within resolvedLookback
+func (notifier *AlertNotifier) currentNotifications(ctx context.Context, now time.Time) ([]model.Notification, error) {
+	rules, err := notifier.AlertDao.GetRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses, err := notifier.AlertDao.GetRuleStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statusByRule := make(map[string]model.AlertStatus, len(statuses))
+	for _, status := range statuses {
+		statusByRule[status.RuleID] = status
+	}
+
+	var notifications []model.Notification
+	for i := range rules {
+		rule := &rules[i]
+		status, ok := statusByRule[rule.ID]
+		if !ok || rule.Disabled {
+			continue
+		}
+		if status.State != model.AlertStateFiring &&
+			(status.State != model.AlertStateResolved || status.Since < now.Add(-resolvedLookback).UnixNano()) {
+			continue
+		}
+		notifications = append(notifications, model.Notification{
+			RuleID:      rule.ID,
+			RuleName:    rule.Name,
+			ServiceName: rule.ServiceName,
+			Metric:      rule.Metric,
+			State:       status.State,
+			Value:       status.Value,
+			Threshold:   rule.Threshold,
+			Labels:      rule.NotificationLabels(),
+			Since:       status.Since,
+		})
+	}
+	model.SortNotifications(notifications)
+	return notifications, nil
+}
+
+// unsilenced notifications not matched by active silence or maintenance window
+func (notifier *AlertNotifier) unsilenced(ctx context.Context, notifications []model.Notification, now time.Time) ([]model.Notification, error) {
+	silences, err := notifier.SilenceDao.GetSilences(ctx)
+	if err != nil {
+		return nil, err
+	}
+	windows, err := notifier.SilenceDao.GetMaintenanceWindows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var activeMatchers []map[string]string
+	for i := range silences {
+		if silences[i].ActiveAt(now) {
+			activeMatchers = append(activeMatchers, silences[i].Matchers)
+		}
+	}
+	for i := range windows {
+		if windows[i].ActiveAt(now) {
+			activeMatchers = append(activeMatchers, windows[i].Matchers)
+		}
+	}
+
+	result := notifications[:0]
+	for _, notification := range notifications {
+		silenced := false
+		for _, matchers := range activeMatchers {
+			if model.MatchLabels(matchers, notification.Labels) {
+				silenced = true
+				break
+			}
+		}
+		if !silenced {
+			result = append(result, notification)
+		}
+	}
+	return result, nil
+}
+
+// groupNotifications alerts matching channel grouped by values of its group by labels
+func groupNotifications(channel *model.NotificationChannel, notifications []model.Notification) []*model.NotificationGroup {
+	var groups []*model.NotificationGroup
+	groupByKey := map[string]*model.NotificationGroup{}
+	for _, notification := range notifications {
+		if !channel.Matches(notification.Labels) {
+			continue
+		}
+		groupLabels := make(map[string]string, len(channel.GroupBy))
+		for _, label := range channel.GroupBy {
+			groupLabels[label] = notification.Labels[label]
+		}
+		key := groupKey(groupLabels)
+		group, ok := groupByKey[key]
+		if !ok {
+			group = &model.NotificationGroup{ChannelID: channel.ID, ChannelName: channel.Name, GroupLabels: groupLabels,
+				Firing: []model.Notification{}, Resolved: []model.Notification{}}
+			groupByKey[key] = group
+			groups = append(groups, group)
+		}
+		if notification.State == model.AlertStateFiring {
+			group.Firing = append(group.Firing, notification)
+		} else {
+			group.Resolved = append(group.Resolved, notification)
+		}
+	}
+	return groups
+}
+
+// notifyGroup send group unless the same firing alerts were already sent within repeat interval of channel,
+// resolved alerts are sent only once
+func (notifier *AlertNotifier) notifyGroup(ctx context.Context, channel *model.NotificationChannel, group *model.NotificationGroup, now time.Time) error {
+	redisClient := notifier.RedisService.GetSpecificRedis()
+	key := notificationKey(channel, group)
+	var record *notificationRecord
+	value, err := redisClient.Get(ctx, key)
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if err == nil {
+		record = &notificationRecord{}
+		if err := json.Unmarshal([]byte(value), record); err != nil {
+			record = nil
+		}
+	}
+
+	if record != nil {
+		resolved := group.Resolved[:0]
+		for _, notification := range group.Resolved {
+			if notification.Since > record.SentAt {
+				resolved = append(resolved, notification)
+			}
+		}
+		group.Resolved = resolved
+	}
+	fingerprint := firingFingerprint(group)
+	if len(group.Resolved) == 0 && (len(group.Firing) == 0 || (record != nil && record.Fingerprint == fingerprint)) {
+		return nil
+	}
+
+	if err := notifier.Sender.Send(ctx, channel, group); err != nil {
+		return err
+	}
+	repeatInterval := channel.RepeatIntervalSeconds
+	if repeatInterval <= 0 {
+		repeatInterval = model.DefaultRepeatIntervalSeconds
+	}
+	encoded, _ := json.Marshal(notificationRecord{Fingerprint: fingerprint, SentAt: now.UnixNano()})
+	return redisClient.SetEx(ctx, key, string(encoded), repeatInterval)
+}
+
+func notificationKey(channel *model.NotificationChannel, group *model.NotificationGroup) string {
+	hash := sha1.Sum([]byte(groupKey(group.GroupLabels)))
+	return "ALERT_NOTIFICATION_" + channel.ID + "_" + hex.EncodeToString(hash[:])
+}
+
+// groupKey json object with sorted keys, unlike formatted labels it can't be ambiguous
+func groupKey(labels map[string]string) string {
+	encoded, _ := json.Marshal(labels)
+	return string(encoded)
+}
+
+// firingFingerprint firing rules of group with time they started firing, rule firing again is a new alert
+func firingFingerprint(group *model.NotificationGroup) string {
+	alerts := make([]string, len(group.Firing))
+	for i, notification := range group.Firing {
+		alerts[i] = notification.RuleID + "@" + time.Unix(0, notification.Since).UTC().Format(time.RFC3339Nano)
+	}
+	sort.Strings(alerts)
+	hash := sha1.Sum([]byte(strings.Join(alerts, ",")))
+	return hex.EncodeToString(hash[:])
+}
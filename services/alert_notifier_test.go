@@ -0,0 +1,140 @@
+package services
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"goapm/dao"
+	model "goapm/domain"
+	"goapm/http"
+	"goapm/logger"
+	redis_factory "goapm/redis"
+	"testing"
+	"time"
+)
+
+func newAlertNotifierTest(t *testing.T, silences []model.Silence, windows []model.MaintenanceWindow) (*AlertNotifier, *dao.MockAlertDao,
+	*http.MockRestClient, *miniredis.Miniredis) {
+	redisServer, err := miniredis.Run()
+	assert.Nil(t, err)
+	t.Cleanup(redisServer.Close)
+	specificRedisMock := new(redis_factory.MockSpecificRedisFactory)
+	specificRedisMock.On("GetSpecificRedis", mock.Anything).
+		Return(redis_factory.NewRedisFactory().GetConnection(redisServer.Host(), redisServer.Port(), 0))
+
+	restClientMock := new(http.MockRestClient)
+	restClientMock.On("PostResponse", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(http.GenericHttpResponse{StatusCode: 200}, nil)
+	channelDaoMock := new(dao.MockChannelDao)
+	channelDaoMock.On("GetChannels", mock.Anything).Return([]model.NotificationChannel{
+		{ID: "c1", Name: "cart", Type: model.ChannelTypeSlack, URL: "http://slack", Matchers: map[string]string{"team": "cart"},
+			GroupBy: []string{"service"}, RepeatIntervalSeconds: 600},
+		{ID: "c2", Name: "muted", Type: model.ChannelTypeSlack, URL: "http://slack", Disabled: true},
+	}, nil)
+	silenceDaoMock := new(dao.MockSilenceDao)
+	silenceDaoMock.On("GetSilences", mock.Anything).Return(silences, nil)
+	silenceDaoMock.On("GetMaintenanceWindows", mock.Anything).Return(windows, nil)
+	alertDaoMock := new(dao.MockAlertDao)
+	alertDaoMock.On("GetRules", mock.Anything).Return([]model.AlertRule{
+		{ID: "r1", Name: "errors", ServiceName: "cart", Labels: map[string]string{"team": "cart"}},
+		{ID: "r2", Name: "latency", ServiceName: "cart", Labels: map[string]string{"team": "cart"}},
+		{ID: "r3", Name: "checkout", ServiceName: "checkout", Labels: map[string]string{"team": "cart"}},
+		{ID: "r4", Name: "other team", ServiceName: "cart", Labels: map[string]string{"team": "search"}},
+	}, nil)
+
+	return &AlertNotifier{
+		Logger:       logger.LOGGER,
+		AlertDao:     alertDaoMock,
+		ChannelDao:   channelDaoMock,
+		SilenceDao:   silenceDaoMock,
+		RedisService: specificRedisMock,
+		Sender:       newNotificationSender(restClientMock, nil),
+	}, alertDaoMock, restClientMock, redisServer
+}
+
+func setRuleStatuses(alertDaoMock *dao.MockAlertDao, statuses ...model.AlertStatus) {
+	expectedCalls := alertDaoMock.ExpectedCalls[:0]
+	for _, call := range alertDaoMock.ExpectedCalls {
+		if call.Method != "GetRuleStatuses" {
+			expectedCalls = append(expectedCalls, call)
+		}
+	}
+	alertDaoMock.ExpectedCalls = expectedCalls
+	alertDaoMock.On("GetRuleStatuses", mock.Anything).Return(statuses, nil)
+}
+
+// runNotifier run notifier as the next minute tick, lock of previous tick expires
+func runNotifier(notifier *AlertNotifier, redisServer *miniredis.Miniredis) {
+	notifier.Run()
+	redisServer.FastForward(time.Minute)
+}
+
+func TestAlertNotifierGroupsAndDeduplicates(t *testing.T) {
+	notifier, alertDaoMock, restClientMock, redisServer := newAlertNotifierTest(t, nil, nil)
+	since := time.Now().Add(-time.Minute).UnixNano()
+	setRuleStatuses(alertDaoMock,
+		model.AlertStatus{RuleID: "r1", State: model.AlertStateFiring, Since: since},
+		model.AlertStatus{RuleID: "r2", State: model.AlertStateFiring, Since: since},
+		model.AlertStatus{RuleID: "r3", State: model.AlertStateFiring, Since: since},
+		model.AlertStatus{RuleID: "r4", State: model.AlertStateFiring, Since: since})
+
+	// cart and checkout groups, r4 doesn't match channel and disabled channel gets nothing
+	runNotifier(notifier, redisServer)
+	assert.Equal(t, 2, len(restClientMock.Calls))
+
+	// same firing alerts aren't sent again within repeat interval
+	runNotifier(notifier, redisServer)
+	assert.Equal(t, 2, len(restClientMock.Calls))
+
+	// r2 resolves, only cart group changed
+	setRuleStatuses(alertDaoMock,
+		model.AlertStatus{RuleID: "r1", State: model.AlertStateFiring, Since: since},
+		model.AlertStatus{RuleID: "r2", State: model.AlertStateResolved, Since: time.Now().UnixNano()},
+		model.AlertStatus{RuleID: "r3", State: model.AlertStateFiring, Since: since})
+	runNotifier(notifier, redisServer)
+	assert.Equal(t, 3, len(restClientMock.Calls))
+	runNotifier(notifier, redisServer)
+	assert.Equal(t, 3, len(restClientMock.Calls))
+
+	// both groups repeat after repeat interval
+	redisServer.FastForward(10 * time.Minute)
+	runNotifier(notifier, redisServer)
+	assert.Equal(t, 5, len(restClientMock.Calls))
+}
+
+func TestAlertNotifierLocked(t *testing.T) {
+	notifier, alertDaoMock, restClientMock, redisServer := newAlertNotifierTest(t, nil, nil)
+	setRuleStatuses(alertDaoMock, model.AlertStatus{RuleID: "r1", State: model.AlertStateFiring, Since: time.Now().UnixNano()})
+	assert.Nil(t, redisServer.Set("ALERT_NOTIFIER", "other replica"))
+
+	notifier.Run()
+	assert.Equal(t, 0, len(restClientMock.Calls))
+}
+
+func TestAlertNotifierSilences(t *testing.T) {
+	now := time.Now()
+	silences := []model.Silence{
+		{ID: "s1", Matchers: map[string]string{"service": "cart"}, StartsAt: now.Add(-time.Hour).UnixNano(), EndsAt: now.Add(time.Hour).UnixNano()},
+		{ID: "s2", Matchers: map[string]string{"service": "checkout"}, StartsAt: now.Add(-time.Hour).UnixNano(), EndsAt: now.Add(-time.Minute).UnixNano()},
+	}
+	// window started by schedule firing every minute is always active
+	windows := []model.MaintenanceWindow{
+		{ID: "w1", Matchers: map[string]string{"alertname": "checkout"}, Schedule: "* * * * *", DurationSeconds: 60, Disabled: true},
+	}
+	notifier, alertDaoMock, restClientMock, redisServer := newAlertNotifierTest(t, silences, windows)
+	setRuleStatuses(alertDaoMock,
+		model.AlertStatus{RuleID: "r1", State: model.AlertStateFiring, Since: now.UnixNano()},
+		model.AlertStatus{RuleID: "r3", State: model.AlertStateFiring, Since: now.UnixNano()})
+
+	// cart is silenced, expired silence and disabled window don't apply to checkout
+	runNotifier(notifier, redisServer)
+	assert.Equal(t, 1, len(restClientMock.Calls))
+
+	// mock returns the same slice, enabled window mutes checkout resolution
+	windows[0].Disabled = false
+	setRuleStatuses(alertDaoMock,
+		model.AlertStatus{RuleID: "r1", State: model.AlertStateFiring, Since: now.UnixNano()},
+		model.AlertStatus{RuleID: "r3", State: model.AlertStateResolved, Since: now.Add(time.Second).UnixNano()})
+	runNotifier(notifier, redisServer)
+	assert.Equal(t, 1, len(restClientMock.Calls))
+}
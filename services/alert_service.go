@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+)
+
+type AlertService interface {
+	GetRules(ctx context.Context) ([]model.AlertRule, error)
+	GetRule(ctx context.Context, id string) (*model.AlertRule, error)
+	CreateRule(ctx context.Context, rule *model.AlertRule) (*model.AlertRule, error)
+	UpdateRule(ctx context.Context, rule *model.AlertRule) (*model.AlertRule, error)
+	DeleteRule(ctx context.Context, id string) error
+	GetRuleHistory(ctx context.Context, id string, limit int) ([]model.AlertHistoryItem, error)
+}
+
+type MockAlertService struct {
+	mock.Mock
+}
+
+func (service *MockAlertService) GetRules(ctx context.Context) ([]model.AlertRule, error) {
+	args := service.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.AlertRule), args.Error(1)
+}
+
+func (service *MockAlertService) GetRule(ctx context.Context, id string) (*model.AlertRule, error) {
+	args := service.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.AlertRule), args.Error(1)
+}
+
+func (service *MockAlertService) CreateRule(ctx context.Context, rule *model.AlertRule) (*model.AlertRule, error) {
+	args := service.Called(ctx, rule)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.AlertRule), args.Error(1)
+}
+
+func (service *MockAlertService) UpdateRule(ctx context.Context, rule *model.AlertRule) (*model.AlertRule, error) {
+	args := service.Called(ctx, rule)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.AlertRule), args.Error(1)
+}
+
+func (service *MockAlertService) DeleteRule(ctx context.Context, id string) error {
+	args := service.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (service *MockAlertService) GetRuleHistory(ctx context.Context, id string, limit int) ([]model.AlertHistoryItem, error) {
+	args := service.Called(ctx, id, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.AlertHistoryItem), args.Error(1)
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"go.uber.org/zap"
+	"goapm/dao"
+	model "goapm/domain"
+	"goapm/logger"
+	"sync"
+	"time"
+)
+
+var alertServiceOnce sync.Once
+var alertService *AlertServiceImpl
+
+type AlertServiceImpl struct {
+	Logger   *zap.SugaredLogger
+	AlertDao dao.AlertDao
+	SLODao   dao.SLODao
+}
+
+func NewAlertServiceImpl(AlertDao dao.AlertDao, SLODao dao.SLODao) *AlertServiceImpl {
+	alertServiceOnce.Do(func() {
+		alertService = &AlertServiceImpl{
+			Logger:   logger.LOGGER,
+			AlertDao: AlertDao,
+			SLODao:   SLODao,
+		}
+	})
+	return alertService
+}
+
+// GetRules all rules with their current state, rules which weren't evaluated yet have no state
+func (service *AlertServiceImpl) GetRules(ctx context.Context) ([]model.AlertRule, error) {
+	rules, err := service.AlertDao.GetRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses, err := service.AlertDao.GetRuleStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statusByRule := make(map[string]model.AlertStatus, len(statuses))
+	for _, status := range statuses {
+		statusByRule[status.RuleID] = status
+	}
+	for i := range rules {
+		if status, ok := statusByRule[rules[i].ID]; ok {
+			rules[i].State = status.State
+			rules[i].StateSince = status.Since
+		}
+	}
+	return rules, nil
+}
+
+func (service *AlertServiceImpl) GetRule(ctx context.Context, id string) (*model.AlertRule, error) {
+	rule, err := service.AlertDao.GetRule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, ruleNotFoundError(id)
+	}
+	return rule, nil
+}
+
+func (service *AlertServiceImpl) CreateRule(ctx context.Context, rule *model.AlertRule) (*model.AlertRule, error) {
+	if err := service.setRuleSLO(ctx, rule); err != nil {
+		return nil, err
+	}
+	id, err := newAlertRuleID()
+	if err != nil {
+		return nil, err
+	}
+	rule.ID = id
+	rule.UpdatedAt = time.Now().UnixNano()
+	if err := service.AlertDao.SaveRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (service *AlertServiceImpl) UpdateRule(ctx context.Context, rule *model.AlertRule) (*model.AlertRule, error) {
+	if _, err := service.GetRule(ctx, rule.ID); err != nil {
+		return nil, err
+	}
+	if err := service.setRuleSLO(ctx, rule); err != nil {
+		return nil, err
+	}
+	rule.UpdatedAt = time.Now().UnixNano()
+	if err := service.AlertDao.SaveRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (service *AlertServiceImpl) DeleteRule(ctx context.Context, id string) error {
+	rule, err := service.GetRule(ctx, id)
+	if err != nil {
+		return err
+	}
+	return service.AlertDao.DeleteRule(ctx, rule)
+}
+
+func (service *AlertServiceImpl) GetRuleHistory(ctx context.Context, id string, limit int) ([]model.AlertHistoryItem, error) {
+	if _, err := service.GetRule(ctx, id); err != nil {
+		return nil, err
+	}
+	return service.AlertDao.GetRuleHistory(ctx, id, limit)
+}
+
+// setRuleSLO burn rate rule takes service of its slo, other rules don't reference slo
+func (service *AlertServiceImpl) setRuleSLO(ctx context.Context, rule *model.AlertRule) error {
+	if rule.Metric != model.AlertMetricSLOBurnRate {
+		rule.SLOID = ""
+		return nil
+	}
+	slo, err := service.SLODao.GetSLO(ctx, rule.SLOID)
+	if err != nil {
+		return err
+	}
+	if slo == nil {
+		return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("slo %s not found", rule.SLOID)}
+	}
+	rule.ServiceName = slo.ServiceName
+	return nil
+}
+
+func ruleNotFoundError(id string) error {
+	return &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s not found", id)}
+}
+
+func newAlertRuleID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	model "goapm/domain"
+	"math"
+	"sort"
+	"time"
+)
+
+// madScale median absolute deviation of normal distribution is 1/1.4826 of its standard deviation
+const madScale = 1.4826
+
+// anomalyScoreThreshold robust z-score from which value is anomalous, as suggested by Iglewicz and Hoaglin
+const anomalyScoreThreshold = 3.5
+
+// minAnomalyBaselineWeeks previous weeks with traffic in the same window needed to judge the current one
+const minAnomalyBaselineWeeks = 3
+
+// serviceWindow RED metrics of service over one window
+type serviceWindow struct {
+	Seconds   float64
+	NumCalls  int
+	NumErrors int
+	P99       float64
+}
+
+// newServiceWindow service window from metrics of the whole window
+func newServiceWindow(metrics *model.ServiceMetrics, window time.Duration) serviceWindow {
+	return serviceWindow{Seconds: window.Seconds(), NumCalls: int(metrics.NumCalls), NumErrors: int(metrics.NumErrors), P99: metrics.P99}
+}
+
+// anomalyMetric how metric of service window is checked. Error rate and latency of few calls are noise, so windows
+// with less than minCalls are skipped. Deviation of baseline is raised to relativeFloor of its median or absoluteFloor,
+// so a baseline of nearly equal weeks doesn't turn every small change into an anomaly
+type anomalyMetric struct {
+	name          string
+	value         func(window serviceWindow) float64
+	minCalls      int
+	dropIsAnomaly bool
+	relativeFloor float64
+	absoluteFloor float64
+}
+
+var anomalyMetrics = []anomalyMetric{
+	{
+		name:          model.AnomalyMetricCallRate,
+		value:         func(window serviceWindow) float64 { return float64(window.NumCalls) / window.Seconds },
+		dropIsAnomaly: true,
+		relativeFloor: 0.1,
+		absoluteFloor: 0.01,
+	},
+	{
+		name:          model.AnomalyMetricErrorRate,
+		value:         func(window serviceWindow) float64 { return float64(window.NumErrors) * 100 / float64(window.NumCalls) },
+		minCalls:      50,
+		relativeFloor: 0.1,
+		absoluteFloor: 1,
+	},
+	{
+		name:          model.AnomalyMetricP99,
+		value:         func(window serviceWindow) float64 { return window.P99 },
+		minCalls:      50,
+		relativeFloor: 0.1,
+		absoluteFloor: float64(5 * time.Millisecond),
+	},
+}
+
+// detectAnomalies compare metrics of service window starting at start with the same window of previous weeks,
+// a week without traffic, e.g. before service existed, isn't part of baseline
+func detectAnomalies(serviceName string, start time.Time, current serviceWindow, previousWeeks []serviceWindow) []model.Anomaly {
+	var anomalies []model.Anomaly
+	for _, metric := range anomalyMetrics {
+		if current.NumCalls < metric.minCalls {
+			continue
+		}
+		var baseline []float64
+		for _, week := range previousWeeks {
+			if week.NumCalls > 0 && week.NumCalls >= metric.minCalls {
+				baseline = append(baseline, metric.value(week))
+			}
+		}
+		if len(baseline) < minAnomalyBaselineWeeks {
+			continue
+		}
+		value := metric.value(current)
+		median, deviation, score := robustZScore(value, baseline, metric.relativeFloor, metric.absoluteFloor)
+		if score < anomalyScoreThreshold && !(metric.dropIsAnomaly && score <= -anomalyScoreThreshold) {
+			continue
+		}
+		direction := model.AnomalyDirectionAbove
+		if score < 0 {
+			direction = model.AnomalyDirectionBelow
+		}
+		anomalies = append(anomalies, model.Anomaly{
+			ServiceName:   serviceName,
+			Metric:        metric.name,
+			Timestamp:     start.UnixNano(),
+			WindowSeconds: int(current.Seconds),
+			Value:         value,
+			Baseline:      median,
+			Deviation:     deviation,
+			Score:         score,
+			Direction:     direction,
+		})
+	}
+	return anomalies
+}
+
+// robustZScore distance of value from median of baseline in median absolute deviations scaled to standard deviation,
+// unlike mean and standard deviation it isn't skewed by an incident in one of the baseline weeks
+func robustZScore(value float64, baseline []float64, relativeFloor float64, absoluteFloor float64) (float64, float64, float64) {
+	center := median(baseline)
+	deviations := make([]float64, len(baseline))
+	for i, point := range baseline {
+		deviations[i] = math.Abs(point - center)
+	}
+	deviation := math.Max(madScale*median(deviations), math.Max(relativeFloor*math.Abs(center), absoluteFloor))
+	return center, deviation, (value - center) / deviation
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"goapm/dao"
+	model "goapm/domain"
+	"goapm/logger"
+	redis_factory "goapm/redis"
+	"goapm/utils"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxAnomalyBaselineWeeks signoz_index_aggregated keeps 30 days of rows
+const maxAnomalyBaselineWeeks = 4
+
+// anomalyDetectionDelay window is checked once late spans have been written
+const anomalyDetectionDelay = 2 * time.Minute
+
+var anomalyDetectorOnce sync.Once
+var anomalyDetector *AnomalyDetector
+
+// AnomalyDetector checks RED metrics of every service in the last finished window against the same window of
+// BaselineWeeks previous weeks. Window divides an hour, so windows of different weeks cover the same minutes
+type AnomalyDetector struct {
+	Logger        *zap.SugaredLogger
+	ApmDao        dao.ApmDao
+	AnomalyDao    dao.AnomalyDao
+	RedisService  redis_factory.SpecificRedisService
+	Window        time.Duration
+	BaselineWeeks int
+}
+
+func NewAnomalyDetector(ApmDao dao.ApmDao, AnomalyDao dao.AnomalyDao, RedisService redis_factory.SpecificRedisService) *AnomalyDetector {
+	anomalyDetectorOnce.Do(func() {
+		windowMinutes := utils.GetOrDefaultInt(viper.GetString("ANOMALY_WINDOW_MINUTES"), 15)
+		if windowMinutes < 1 || 60%windowMinutes != 0 {
+			logger.LOGGER.Warn("ANOMALY_WINDOW_MINUTES has to divide an hour, using 15 minutes instead of ", windowMinutes)
+			windowMinutes = 15
+		}
+		baselineWeeks := utils.GetOrDefaultInt(viper.GetString("ANOMALY_BASELINE_WEEKS"), maxAnomalyBaselineWeeks)
+		if baselineWeeks < minAnomalyBaselineWeeks || baselineWeeks > maxAnomalyBaselineWeeks {
+			baselineWeeks = maxAnomalyBaselineWeeks
+		}
+		anomalyDetector = &AnomalyDetector{
+			Logger:        logger.LOGGER,
+			ApmDao:        ApmDao,
+			AnomalyDao:    AnomalyDao,
+			RedisService:  RedisService,
+			Window:        time.Duration(windowMinutes) * time.Minute,
+			BaselineWeeks: baselineWeeks,
+		}
+	})
+	return anomalyDetector
+}
+
+// Run detect anomalies of the last finished window, it is called every minute on every replica and a redis lock
+// per window lets only one replica check the window
+func (detector *AnomalyDetector) Run() {
+	ctx, cancel := context.WithTimeout(context.Background(), detector.Window-10*time.Second)
+	defer cancel()
+	start := time.Now().Add(-anomalyDetectionDelay).Truncate(detector.Window).Add(-detector.Window)
+	if !detector.lock(ctx, start) {
+		return
+	}
+
+	services, err := detector.ApmDao.GetServicesList(ctx)
+	if err != nil {
+		detector.Logger.Error("unable to get services for anomaly detection ", err)
+		return
+	}
+	var anomalies []model.Anomaly
+	for _, serviceName := range *services {
+		serviceAnomalies, err := detector.detectServiceAnomalies(ctx, serviceName, start)
+		if err != nil {
+			detector.Logger.Error("unable to detect anomalies of service ", serviceName, ", error = ", err)
+			continue
+		}
+		anomalies = append(anomalies, serviceAnomalies...)
+	}
+
+	if err := detector.AnomalyDao.InsertAnomalies(ctx, anomalies); err != nil {
+		detector.Logger.Error("unable to insert anomalies ", err)
+	}
+}
+
+func (detector *AnomalyDetector) lock(ctx context.Context, start time.Time) bool {
+	key := "ANOMALY_DETECTOR_" + strconv.FormatInt(start.Unix(), 10)
+	locked, err := detector.RedisService.GetSpecificRedis().SetNX(ctx, key, time.Now().Format(timeLayout), int(2*detector.Window/time.Second))
+	if err != nil {
+		detector.Logger.Error("unable to lock anomaly detection window ", start, ", error = ", err)
+		return false
+	}
+	return locked
+}
+
+// detectServiceAnomalies read window starting at start and the same window of previous weeks by service metrics queries
+func (detector *AnomalyDetector) detectServiceAnomalies(ctx context.Context, serviceName string, start time.Time) ([]model.Anomaly, error) {
+	current, err := detector.serviceWindow(ctx, serviceName, start)
+	if err != nil {
+		return nil, err
+	}
+	previousWeeks := make([]serviceWindow, detector.BaselineWeeks)
+	for week := range previousWeeks {
+		previousWeeks[week], err = detector.serviceWindow(ctx, serviceName, start.Add(-time.Duration(week+1)*7*24*time.Hour))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return detectAnomalies(serviceName, start, current, previousWeeks), nil
+}
+
+func (detector *AnomalyDetector) serviceWindow(ctx context.Context, serviceName string, start time.Time) (serviceWindow, error) {
+	// range is inclusive and rows of aggregated table are per minute
+	end := start.Add(detector.Window - time.Second)
+	metrics, err := detector.ApmDao.GetServiceMetrics(ctx, &model.GetServiceMetricsParams{
+		ServiceName: serviceName,
+		Start:       &start,
+		End:         &end,
+	})
+	if err != nil {
+		return serviceWindow{}, err
+	}
+	return newServiceWindow(metrics, detector.Window), nil
+}
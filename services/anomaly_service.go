@@ -0,0 +1,23 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+)
+
+type AnomalyService interface {
+	GetAnomalies(ctx context.Context, params *model.GetAnomaliesParams) ([]model.Anomaly, error)
+}
+
+type MockAnomalyService struct {
+	mock.Mock
+}
+
+func (service *MockAnomalyService) GetAnomalies(ctx context.Context, params *model.GetAnomaliesParams) ([]model.Anomaly, error) {
+	args := service.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Anomaly), args.Error(1)
+}
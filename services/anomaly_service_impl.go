@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"goapm/dao"
+	model "goapm/domain"
+	"goapm/logger"
+	"sync"
+)
+
+var anomalyServiceOnce sync.Once
+var anomalyService *AnomalyServiceImpl
+
+type AnomalyServiceImpl struct {
+	Logger     *zap.SugaredLogger
+	AnomalyDao dao.AnomalyDao
+}
+
+func NewAnomalyServiceImpl(AnomalyDao dao.AnomalyDao) *AnomalyServiceImpl {
+	anomalyServiceOnce.Do(func() {
+		anomalyService = &AnomalyServiceImpl{
+			Logger:     logger.LOGGER,
+			AnomalyDao: AnomalyDao,
+		}
+	})
+	return anomalyService
+}
+
+func (service *AnomalyServiceImpl) GetAnomalies(ctx context.Context, params *model.GetAnomaliesParams) ([]model.Anomaly, error) {
+	return service.AnomalyDao.GetAnomalies(ctx, params)
+}
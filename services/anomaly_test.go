@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"goapm/dao"
+	model "goapm/domain"
+	"goapm/logger"
+	redis_factory "goapm/redis"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRobustZScore(t *testing.T) {
+	center, deviation, score := robustZScore(130, []float64{100, 104, 96, 101}, 0, 0.01)
+	assert.Equal(t, 100.5, center)
+	// deviations from median are 0.5, 3.5, 4.5, 0.5
+	assert.InDelta(t, 2*madScale, deviation, 1e-9)
+	assert.InDelta(t, 29.5/(2*madScale), score, 1e-9)
+
+	// one incident week doesn't move baseline
+	center, _, score = robustZScore(100, []float64{100, 102, 98, 1000}, 0, 0.01)
+	assert.Equal(t, 101.0, center)
+	assert.Less(t, math.Abs(score), anomalyScoreThreshold)
+
+	// identical weeks fall back to floors
+	_, deviation, _ = robustZScore(100, []float64{100, 100, 100}, 0.1, 0.01)
+	assert.Equal(t, 10.0, deviation)
+	_, deviation, _ = robustZScore(1, []float64{0, 0, 0}, 0.1, 0.01)
+	assert.Equal(t, 0.01, deviation)
+}
+
+// weekWindow window of 15 minutes with given call rate, error percent and p99 milliseconds
+func weekWindow(callRate float64, errorPercent float64, p99Millis float64) serviceWindow {
+	calls := int(callRate * 900)
+	return serviceWindow{Seconds: 900, NumCalls: calls, NumErrors: int(float64(calls) * errorPercent / 100), P99: p99Millis * float64(time.Millisecond)}
+}
+
+func TestDetectAnomalies(t *testing.T) {
+	start := time.Unix(1600000200, 0)
+	previousWeeks := []serviceWindow{weekWindow(100, 1, 200), weekWindow(104, 1.2, 210), weekWindow(96, 0.8, 190), weekWindow(101, 1, 205)}
+
+	assert.Empty(t, detectAnomalies("cart", start, weekWindow(98, 1.1, 215), previousWeeks))
+
+	anomalies := detectAnomalies("cart", start, weekWindow(30, 12, 900), previousWeeks)
+	assert.Equal(t, 3, len(anomalies))
+	assert.Equal(t, model.AnomalyMetricCallRate, anomalies[0].Metric)
+	assert.Equal(t, model.AnomalyDirectionBelow, anomalies[0].Direction)
+	assert.Equal(t, 30.0, anomalies[0].Value)
+	assert.Equal(t, 100.5, anomalies[0].Baseline)
+	assert.Equal(t, start.UnixNano(), anomalies[0].Timestamp)
+	assert.Equal(t, 900, anomalies[0].WindowSeconds)
+	assert.Equal(t, model.AnomalyMetricErrorRate, anomalies[1].Metric)
+	assert.Equal(t, model.AnomalyDirectionAbove, anomalies[1].Direction)
+	assert.Equal(t, model.AnomalyMetricP99, anomalies[2].Metric)
+	assert.Greater(t, anomalies[2].Score, anomalyScoreThreshold)
+
+	// lower error rate and latency are not anomalies, higher call rate is
+	anomalies = detectAnomalies("cart", start, weekWindow(300, 0, 50), previousWeeks)
+	assert.Equal(t, 1, len(anomalies))
+	assert.Equal(t, model.AnomalyDirectionAbove, anomalies[0].Direction)
+
+	// too few calls for error rate and latency
+	anomalies = detectAnomalies("cart", start, serviceWindow{Seconds: 900, NumCalls: 10, NumErrors: 10}, previousWeeks)
+	assert.Equal(t, 1, len(anomalies))
+	assert.Equal(t, model.AnomalyMetricCallRate, anomalies[0].Metric)
+
+	// service is new, weeks without traffic aren't baseline
+	newService := []serviceWindow{weekWindow(100, 1, 200), {Seconds: 900}, {Seconds: 900}, {Seconds: 900}}
+	assert.Empty(t, detectAnomalies("cart", start, weekWindow(10, 50, 5000), newService))
+}
+
+// metricsDao apm dao serving metrics of every service from synthetic series
+type metricsDao struct {
+	dao.ApmDao
+	services []string
+	calls    func(serviceName string, start time.Time) int
+	requests []model.GetServiceMetricsParams
+}
+
+func (apmDao *metricsDao) GetServicesList(ctx context.Context) (*[]string, error) {
+	return &apmDao.services, nil
+}
+
+func (apmDao *metricsDao) GetServiceMetrics(ctx context.Context, params *model.GetServiceMetricsParams) (*model.ServiceMetrics, error) {
+	apmDao.requests = append(apmDao.requests, *params)
+	calls := uint64(apmDao.calls(params.ServiceName, *params.Start))
+	if calls == 0 {
+		return &model.ServiceMetrics{}, nil
+	}
+	return &model.ServiceMetrics{NumCalls: calls, NumErrors: calls / 100, P99: 2e8}, nil
+}
+
+// seasonalCalls calls of 15 minutes window with daily cycle between 500 and 1500 calls and a little noise per week
+func seasonalCalls(start time.Time) int {
+	daily := math.Sin(2 * math.Pi * float64(start.Unix()%86400) / 86400)
+	week := start.Unix() / (7 * 86400)
+	return int(1000+500*daily) + int(week%3)*10
+}
+
+func newAnomalyDetectorTest(locked bool, apmDao *metricsDao) (*AnomalyDetector, *dao.MockAnomalyDao) {
+	redisMock := new(redis_factory.MockRedisFactory)
+	redisMock.On("SetNX", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(locked, nil)
+	specificRedisMock := new(redis_factory.MockSpecificRedisFactory)
+	specificRedisMock.On("GetSpecificRedis", mock.Anything).Return(redisMock)
+	anomalyDaoMock := new(dao.MockAnomalyDao)
+	return &AnomalyDetector{
+		Logger:        logger.LOGGER,
+		ApmDao:        apmDao,
+		AnomalyDao:    anomalyDaoMock,
+		RedisService:  specificRedisMock,
+		Window:        15 * time.Minute,
+		BaselineWeeks: 4,
+	}, anomalyDaoMock
+}
+
+func TestAnomalyDetectorSeasonality(t *testing.T) {
+	outage := time.Date(2020, 9, 14, 18, 0, 0, 0, time.UTC)
+	apmDao := &metricsDao{calls: func(serviceName string, start time.Time) int {
+		if serviceName == "cart" && start.Equal(outage) {
+			return 100
+		}
+		return seasonalCalls(start)
+	}}
+	detector, _ := newAnomalyDetectorTest(true, apmDao)
+
+	// night and peak traffic differ by 3 times, each matches its own hour of previous weeks
+	for _, start := range []time.Time{outage.Add(-12 * time.Hour), outage.Add(-6 * time.Hour), outage} {
+		anomalies, err := detector.detectServiceAnomalies(context.Background(), "orders", start)
+		assert.Nil(t, err)
+		assert.Empty(t, anomalies, start)
+	}
+	// current window and 4 previous weeks of the last start
+	requests := apmDao.requests[10:]
+	assert.Equal(t, outage.Add(-7*24*time.Hour), *requests[1].Start)
+	assert.Equal(t, outage.Add(-28*24*time.Hour+15*time.Minute-time.Second), *requests[4].End)
+	assert.False(t, requests[4].Apdex)
+
+	anomalies, err := detector.detectServiceAnomalies(context.Background(), "cart", outage)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(anomalies))
+	assert.Equal(t, model.AnomalyMetricCallRate, anomalies[0].Metric)
+	assert.Equal(t, model.AnomalyDirectionBelow, anomalies[0].Direction)
+	assert.Equal(t, "cart", anomalies[0].ServiceName)
+}
+
+func TestAnomalyDetectorRun(t *testing.T) {
+	apmDao := &metricsDao{services: []string{"cart", "orders"}, calls: func(serviceName string, start time.Time) int {
+		if serviceName == "cart" && start.After(time.Now().Add(-time.Hour)) {
+			return 0
+		}
+		return 1000
+	}}
+	detector, anomalyDaoMock := newAnomalyDetectorTest(true, apmDao)
+	anomalyDaoMock.On("InsertAnomalies", mock.Anything, mock.Anything).Return(nil)
+
+	detector.Run()
+	anomalies := anomalyDaoMock.Calls[0].Arguments.Get(1).([]model.Anomaly)
+	assert.Equal(t, 1, len(anomalies))
+	assert.Equal(t, "cart", anomalies[0].ServiceName)
+	assert.Equal(t, 0.0, anomalies[0].Value)
+	assert.Equal(t, 10, len(apmDao.requests))
+	assert.Zero(t, time.Unix(0, anomalies[0].Timestamp).Unix()%900)
+
+	lockedDao := &metricsDao{services: []string{"cart"}, calls: apmDao.calls}
+	detector, anomalyDaoMock = newAnomalyDetectorTest(false, lockedDao)
+	detector.Run()
+	assert.Empty(t, lockedDao.requests)
+	anomalyDaoMock.AssertNotCalled(t, "InsertAnomalies", mock.Anything, mock.Anything)
+}
@@ -2,23 +2,184 @@ package services
 
 import (
 	"context"
+	"github.com/stretchr/testify/mock"
 	model "goapm/domain"
 )
 
 type ApmService interface {
 	GetServiceOverview(ctx context.Context, query *model.GetServiceOverviewParams) (*[]model.ServiceOverviewItem, error)
 	GetServices(ctx context.Context, query *model.GetServicesParams) (*[]model.ServiceItem, error)
-	SearchSpans(ctx context.Context, query *model.SpanSearchParams) (*[]model.SearchSpansResult, error)
+	SearchSpans(ctx context.Context, query *model.SpanSearchParams) (*model.TraceResult, error)
 	GetServiceDBOverview(ctx context.Context, query *model.GetServiceOverviewParams) (*[]model.ServiceDBOverviewItem, error)
-	GetServiceExternalAvgDuration(ctx context.Context, query *model.GetServiceOverviewParams) (*[]model.ServiceExternalItem, error)
-	GetServiceExternalErrors(ctx context.Context, query *model.GetServiceOverviewParams) (*[]model.ServiceExternalItem, error)
-	GetServiceExternal(ctx context.Context, query *model.GetServiceOverviewParams) (*[]model.ServiceExternalItem, error)
+	GetServiceExternal(ctx context.Context, query *model.GetServiceExternalParams) (*[]model.ServiceExternalItem, error)
 	GetTopEndpoints(ctx context.Context, query *model.GetTopEndpointsParams) (*[]model.TopEndpointsItem, error)
+	GetLatencyHistogram(ctx context.Context, query *model.GetLatencyHistogramParams) (*model.LatencyHistogram, error)
+	GetLatencyHeatmap(ctx context.Context, query *model.GetLatencyHistogramParams) (*model.LatencyHeatmap, error)
 	GetUsage(ctx context.Context, query *model.GetUsageParams) (*[]model.UsageItem, error)
 	GetOperations(ctx context.Context, serviceName string) (*[]string, error)
 	GetTags(ctx context.Context, serviceName string) (*[]model.TagItem, error)
+	GetTagValues(ctx context.Context, queryParams *model.GetTagValuesParams) (*model.TagValuesResult, error)
 	GetServicesList(ctx context.Context) (*[]string, error)
 	SearchTraces(ctx context.Context, traceID string) (*[]model.SearchSpansResult, error)
-	GetServiceMapDependencies(ctx context.Context, query *model.GetServicesParams) (*[]model.ServiceMapDependencyResponseItem, error)
+	GetTraceTree(ctx context.Context, traceID string) (*model.TraceTree, error)
+	SearchTraceList(ctx context.Context, queryParams *model.SpanSearchParams) (*model.TraceResult, error)
+	GetServiceMapDependencies(ctx context.Context, query *model.GetServiceMapParams) (*[]model.ServiceMapDependencyResponseItem, error)
 	SearchSpansAggregate(ctx context.Context, queryParams *model.SpanSearchAggregatesParams) ([]model.SpanSearchAggregatesResponseItem, error)
+	SearchSpansAggregateGroups(ctx context.Context, queryParams *model.SpanSearchAggregatesParams) ([]model.SpanSearchAggregatesSeries, error)
+}
+
+type MockApmService struct {
+	mock.Mock
+}
+
+func (service *MockApmService) GetServiceOverview(ctx context.Context, query *model.GetServiceOverviewParams) (*[]model.ServiceOverviewItem, error) {
+	args := service.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*[]model.ServiceOverviewItem), args.Error(1)
+}
+
+func (service *MockApmService) GetServices(ctx context.Context, query *model.GetServicesParams) (*[]model.ServiceItem, error) {
+	args := service.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*[]model.ServiceItem), args.Error(1)
+}
+
+func (service *MockApmService) SearchSpans(ctx context.Context, query *model.SpanSearchParams) (*model.TraceResult, error) {
+	args := service.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TraceResult), args.Error(1)
+}
+
+func (service *MockApmService) GetServiceDBOverview(ctx context.Context, query *model.GetServiceOverviewParams) (*[]model.ServiceDBOverviewItem, error) {
+	args := service.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*[]model.ServiceDBOverviewItem), args.Error(1)
+}
+
+func (service *MockApmService) GetServiceExternal(ctx context.Context, query *model.GetServiceExternalParams) (*[]model.ServiceExternalItem, error) {
+	args := service.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*[]model.ServiceExternalItem), args.Error(1)
+}
+
+func (service *MockApmService) GetLatencyHistogram(ctx context.Context, query *model.GetLatencyHistogramParams) (*model.LatencyHistogram, error) {
+	args := service.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LatencyHistogram), args.Error(1)
+}
+
+func (service *MockApmService) GetLatencyHeatmap(ctx context.Context, query *model.GetLatencyHistogramParams) (*model.LatencyHeatmap, error) {
+	args := service.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LatencyHeatmap), args.Error(1)
+}
+
+func (service *MockApmService) GetTopEndpoints(ctx context.Context, query *model.GetTopEndpointsParams) (*[]model.TopEndpointsItem, error) {
+	args := service.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*[]model.TopEndpointsItem), args.Error(1)
+}
+
+func (service *MockApmService) GetUsage(ctx context.Context, query *model.GetUsageParams) (*[]model.UsageItem, error) {
+	args := service.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*[]model.UsageItem), args.Error(1)
+}
+
+func (service *MockApmService) GetOperations(ctx context.Context, serviceName string) (*[]string, error) {
+	args := service.Called(ctx, serviceName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*[]string), args.Error(1)
+}
+
+func (service *MockApmService) GetTags(ctx context.Context, serviceName string) (*[]model.TagItem, error) {
+	args := service.Called(ctx, serviceName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*[]model.TagItem), args.Error(1)
+}
+
+func (service *MockApmService) GetServicesList(ctx context.Context) (*[]string, error) {
+	args := service.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*[]string), args.Error(1)
+}
+
+func (service *MockApmService) SearchTraces(ctx context.Context, traceID string) (*[]model.SearchSpansResult, error) {
+	args := service.Called(ctx, traceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*[]model.SearchSpansResult), args.Error(1)
+}
+
+func (service *MockApmService) GetTraceTree(ctx context.Context, traceID string) (*model.TraceTree, error) {
+	args := service.Called(ctx, traceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TraceTree), args.Error(1)
+}
+
+func (service *MockApmService) SearchTraceList(ctx context.Context, queryParams *model.SpanSearchParams) (*model.TraceResult, error) {
+	args := service.Called(ctx, queryParams)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TraceResult), args.Error(1)
+}
+
+func (service *MockApmService) GetServiceMapDependencies(ctx context.Context, query *model.GetServiceMapParams) (*[]model.ServiceMapDependencyResponseItem, error) {
+	args := service.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*[]model.ServiceMapDependencyResponseItem), args.Error(1)
+}
+
+func (service *MockApmService) SearchSpansAggregate(ctx context.Context, queryParams *model.SpanSearchAggregatesParams) ([]model.SpanSearchAggregatesResponseItem, error) {
+	args := service.Called(ctx, queryParams)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.SpanSearchAggregatesResponseItem), args.Error(1)
+}
+
+func (service *MockApmService) SearchSpansAggregateGroups(ctx context.Context, queryParams *model.SpanSearchAggregatesParams) ([]model.SpanSearchAggregatesSeries, error) {
+	args := service.Called(ctx, queryParams)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.SpanSearchAggregatesSeries), args.Error(1)
+}
+
+func (service *MockApmService) GetTagValues(ctx context.Context, queryParams *model.GetTagValuesParams) (*model.TagValuesResult, error) {
+	args := service.Called(ctx, queryParams)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TagValuesResult), args.Error(1)
 }
@@ -7,6 +7,7 @@ import (
 	"goapm/dao"
 	model "goapm/domain"
 	"sync"
+	"time"
 )
 
 var apmServiceOnce sync.Once
@@ -27,8 +28,22 @@ func NewApmServiceImpl(ApmDao dao.ApmDao) *ApmServiceImpl {
 	return apmService
 }
 
+// GetServices with CompareTo set the same query is run CompareTo earlier and every service gets comparison against it
 func (service *ApmServiceImpl) GetServices(ctx context.Context, query *model.GetServicesParams) (*[]model.ServiceItem, error) {
-	return service.ApmDao.GetServices(ctx, query)
+	result, err := service.ApmDao.GetServices(ctx, query)
+	if err != nil || query.CompareTo <= 0 {
+		return result, err
+	}
+
+	baselineQuery := *query
+	baselineQuery.Start, baselineQuery.End = shiftTimeRange(query.Start, query.End, query.CompareTo)
+	baselineQuery.StartTime, baselineQuery.EndTime = baselineQuery.Start.Format(time.RFC3339Nano), baselineQuery.End.Format(time.RFC3339Nano)
+	baseline, err := service.ApmDao.GetServices(ctx, &baselineQuery)
+	if err != nil {
+		return nil, err
+	}
+	compareServices(*result, *baseline)
+	return result, nil
 }
 
 func (service *ApmServiceImpl) GetServicesList(ctx context.Context) (*[]string, error) {
@@ -36,10 +51,25 @@ func (service *ApmServiceImpl) GetServicesList(ctx context.Context) (*[]string,
 }
 
 func (service *ApmServiceImpl) GetServiceOverview(ctx context.Context, query *model.GetServiceOverviewParams) (*[]model.ServiceOverviewItem, error) {
-	return service.ApmDao.GetServiceOverview(ctx, query)
-}
-
-func (service *ApmServiceImpl) SearchSpans(ctx context.Context, query *model.SpanSearchParams) (*[]model.SearchSpansResult, error) {
+	result, err := service.ApmDao.GetServiceOverview(ctx, query)
+	if err != nil || query.CompareTo <= 0 {
+		return result, err
+	}
+
+	baselineQuery := *query
+	baselineQuery.Start, baselineQuery.End = shiftTimeRange(query.Start, query.End, query.CompareTo)
+	baselineQuery.StartTime, baselineQuery.EndTime = baselineQuery.Start.Format(time.RFC3339Nano), baselineQuery.End.Format(time.RFC3339Nano)
+	// comparison doesn't include apdex
+	baselineQuery.Apdex = false
+	baseline, err := service.ApmDao.GetServiceOverview(ctx, &baselineQuery)
+	if err != nil {
+		return nil, err
+	}
+	compareServiceOverview(*result, *baseline, query.CompareTo)
+	return result, nil
+}
+
+func (service *ApmServiceImpl) SearchSpans(ctx context.Context, query *model.SpanSearchParams) (*model.TraceResult, error) {
 	return service.ApmDao.SearchSpans(ctx, query)
 }
 
@@ -47,20 +77,33 @@ func (service *ApmServiceImpl) GetServiceDBOverview(ctx context.Context, query *
 	return service.ApmDao.GetServiceDBOverview(ctx, query)
 }
 
-func (service *ApmServiceImpl) GetServiceExternalAvgDuration(ctx context.Context, query *model.GetServiceOverviewParams) (*[]model.ServiceExternalItem, error) {
-	return service.ApmDao.GetServiceExternalAvgDuration(ctx, query)
+func (service *ApmServiceImpl) GetServiceExternal(ctx context.Context, query *model.GetServiceExternalParams) (*[]model.ServiceExternalItem, error) {
+	return service.ApmDao.GetServiceExternal(ctx, query)
 }
 
-func (service *ApmServiceImpl) GetServiceExternalErrors(ctx context.Context, query *model.GetServiceOverviewParams) (*[]model.ServiceExternalItem, error) {
-	return service.ApmDao.GetServiceExternalErrors(ctx, query)
+func (service *ApmServiceImpl) GetTopEndpoints(ctx context.Context, query *model.GetTopEndpointsParams) (*[]model.TopEndpointsItem, error) {
+	result, err := service.ApmDao.GetTopEndpoints(ctx, query)
+	if err != nil || query.CompareTo <= 0 {
+		return result, err
+	}
+
+	baselineQuery := *query
+	baselineQuery.Start, baselineQuery.End = shiftTimeRange(query.Start, query.End, query.CompareTo)
+	baselineQuery.StartTime, baselineQuery.EndTime = baselineQuery.Start.Format(time.RFC3339Nano), baselineQuery.End.Format(time.RFC3339Nano)
+	baseline, err := service.ApmDao.GetTopEndpoints(ctx, &baselineQuery)
+	if err != nil {
+		return nil, err
+	}
+	compareTopEndpoints(*result, *baseline)
+	return result, nil
 }
 
-func (service *ApmServiceImpl) GetServiceExternal(ctx context.Context, query *model.GetServiceOverviewParams) (*[]model.ServiceExternalItem, error) {
-	return service.ApmDao.GetServiceExternal(ctx, query)
+func (service *ApmServiceImpl) GetLatencyHistogram(ctx context.Context, query *model.GetLatencyHistogramParams) (*model.LatencyHistogram, error) {
+	return service.ApmDao.GetLatencyHistogram(ctx, query)
 }
 
-func (service *ApmServiceImpl) GetTopEndpoints(ctx context.Context, query *model.GetTopEndpointsParams) (*[]model.TopEndpointsItem, error) {
-	return service.ApmDao.GetTopEndpoints(ctx, query)
+func (service *ApmServiceImpl) GetLatencyHeatmap(ctx context.Context, query *model.GetLatencyHistogramParams) (*model.LatencyHeatmap, error) {
+	return service.ApmDao.GetLatencyHeatmap(ctx, query)
 }
 
 func (service *ApmServiceImpl) GetUsage(ctx context.Context, query *model.GetUsageParams) (*[]model.UsageItem, error) {
@@ -79,10 +122,35 @@ func (service *ApmServiceImpl) SearchTraces(ctx context.Context, traceID string)
 	return service.ApmDao.SearchTraces(ctx, traceID)
 }
 
-func (service *ApmServiceImpl) GetServiceMapDependencies(ctx context.Context, query *model.GetServicesParams) (*[]model.ServiceMapDependencyResponseItem, error) {
-	return service.ApmDao.GetServiceMapDependencies(ctx, query)
+func (service *ApmServiceImpl) GetTraceTree(ctx context.Context, traceID string) (*model.TraceTree, error) {
+	spans, err := service.ApmDao.GetTraceSpans(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	return buildTraceTree(traceID, spans), nil
+}
+
+func (service *ApmServiceImpl) SearchTraceList(ctx context.Context, queryParams *model.SpanSearchParams) (*model.TraceResult, error) {
+	return service.ApmDao.SearchTraceList(ctx, queryParams)
+}
+
+func (service *ApmServiceImpl) GetServiceMapDependencies(ctx context.Context, query *model.GetServiceMapParams) (*[]model.ServiceMapDependencyResponseItem, error) {
+	dependencies, err := service.ApmDao.GetServiceMapDependencies(ctx, query)
+	if err != nil || len(query.FocusService) == 0 {
+		return dependencies, err
+	}
+	focused := focusServiceMap(*dependencies, query.FocusService, query.Hops)
+	return &focused, nil
 }
 
 func (service *ApmServiceImpl) SearchSpansAggregate(ctx context.Context, queryParams *model.SpanSearchAggregatesParams) ([]model.SpanSearchAggregatesResponseItem, error) {
 	return service.ApmDao.SearchSpansAggregate(ctx, queryParams)
 }
+
+func (service *ApmServiceImpl) SearchSpansAggregateGroups(ctx context.Context, queryParams *model.SpanSearchAggregatesParams) ([]model.SpanSearchAggregatesSeries, error) {
+	return service.ApmDao.SearchSpansAggregateGroups(ctx, queryParams)
+}
+
+func (service *ApmServiceImpl) GetTagValues(ctx context.Context, queryParams *model.GetTagValuesParams) (*model.TagValuesResult, error) {
+	return service.ApmDao.GetTagValues(ctx, queryParams)
+}
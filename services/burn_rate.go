@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"goapm/dao"
+	model "goapm/domain"
+	"math"
+	"time"
+)
+
+// getSLOStatus events of slo over its rolling window and every burn rate window ending at now, read in one query
+func getSLOStatus(ctx context.Context, apmDao dao.ApmDao, slo *model.SLO, now time.Time) (*model.SLOStatus, error) {
+	starts := []time.Time{now.AddDate(0, 0, -slo.WindowDays)}
+	for _, window := range model.BurnRateWindows {
+		starts = append(starts, now.Add(-window.Duration))
+	}
+	events, err := apmDao.GetSLIEvents(ctx, &model.GetSLIEventsParams{
+		ServiceName:        slo.ServiceName,
+		Operation:          slo.Operation,
+		SLIType:            slo.SLIType,
+		LatencyThresholdMs: slo.LatencyThresholdMs,
+		Starts:             starts,
+		End:                now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newSLOStatus(slo, events[0], events[1:]), nil
+}
+
+// newSLOStatus status of slo from events over its window and events over each of BurnRateWindows.
+// Window without events has sli 100%, untouched budget and zero burn rate
+func newSLOStatus(slo *model.SLO, windowEvents model.SLIEvents, burnRateEvents []model.SLIEvents) *model.SLOStatus {
+	status := &model.SLOStatus{
+		SLO:                  slo,
+		SLI:                  100,
+		GoodEvents:           windowEvents.Good,
+		TotalEvents:          windowEvents.Total,
+		ErrorBudgetRemaining: 100,
+		BurnRates:            make([]model.BurnRate, len(model.BurnRateWindows)),
+	}
+	errorRate, _ := burnRate(windowEvents, slo.ErrorBudget())
+	if windowEvents.Total > 0 {
+		status.SLI = 100 - errorRate*100
+		status.ErrorBudgetRemaining = (1 - errorRate/slo.ErrorBudget()) * 100
+	}
+	for i, window := range model.BurnRateWindows {
+		events := burnRateEvents[i]
+		windowErrorRate, windowBurnRate := burnRate(events, slo.ErrorBudget())
+		status.BurnRates[i] = model.BurnRate{
+			Window:        window.Name,
+			WindowSeconds: int(window.Duration / time.Second),
+			GoodEvents:    events.Good,
+			TotalEvents:   events.Total,
+			ErrorRate:     windowErrorRate * 100,
+			BurnRate:      windowBurnRate,
+		}
+	}
+	status.Alerts = metBurnRateConditions(status.BurnRates, slo.BurnRateConditions())
+	return status
+}
+
+// burnRate fraction of bad events and how many times faster than allowed by error budget they spend it
+func burnRate(events model.SLIEvents, errorBudget float64) (float64, float64) {
+	if events.Total == 0 {
+		return 0, 0
+	}
+	good := events.Good
+	if good > events.Total {
+		good = events.Total
+	}
+	errorRate := float64(events.Total-good) / float64(events.Total)
+	// target of 100% leaves no budget, such slo is rejected by api and infinite rate couldn't be returned as json
+	if errorBudget <= 0 {
+		return errorRate, 0
+	}
+	return errorRate, errorRate / errorBudget
+}
+
+// metBurnRateConditions conditions whose long and short window burn rates are both above factor
+func metBurnRateConditions(burnRates []model.BurnRate, conditions []model.BurnRateCondition) []model.BurnRateCondition {
+	met := []model.BurnRateCondition{}
+	for _, condition := range conditions {
+		if burnRateConditionScore(burnRates, condition) > 1 {
+			met = append(met, condition)
+		}
+	}
+	return met
+}
+
+// burnRateAlertValue metric value of slo burn rate rules, above 1 when any condition is met. The value is the highest
+// score of conditions, so threshold of rule scales all factors
+func burnRateAlertValue(burnRates []model.BurnRate, conditions []model.BurnRateCondition) float64 {
+	value := 0.0
+	for _, condition := range conditions {
+		if score := burnRateConditionScore(burnRates, condition); score > value {
+			value = score
+		}
+	}
+	return value
+}
+
+// burnRateConditionScore lower of long and short window burn rates relative to factor of condition
+func burnRateConditionScore(burnRates []model.BurnRate, condition model.BurnRateCondition) float64 {
+	long, longOk := findBurnRate(burnRates, condition.LongWindow)
+	short, shortOk := findBurnRate(burnRates, condition.ShortWindow)
+	if !longOk || !shortOk || condition.Factor <= 0 {
+		return 0
+	}
+	return math.Min(long, short) / condition.Factor
+}
+
+func findBurnRate(burnRates []model.BurnRate, window string) (float64, bool) {
+	for _, rate := range burnRates {
+		if rate.Window == window {
+			return rate.BurnRate, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"goapm/dao"
+	model "goapm/domain"
+	"testing"
+	"time"
+)
+
+// burnRateEvents events of BurnRateWindows (5m, 30m, 1h, 6h, 3d) with given percent of bad events
+func burnRateEvents(badPercents ...uint64) []model.SLIEvents {
+	events := make([]model.SLIEvents, len(badPercents))
+	for i, bad := range badPercents {
+		events[i] = model.SLIEvents{Good: 10000 - bad*100, Total: 10000}
+	}
+	return events
+}
+
+func TestBurnRate(t *testing.T) {
+	errorRate, rate := burnRate(model.SLIEvents{Good: 990, Total: 1000}, 0.001)
+	assert.InDelta(t, 0.01, errorRate, 1e-9)
+	assert.InDelta(t, 10, rate, 1e-9)
+
+	errorRate, rate = burnRate(model.SLIEvents{}, 0.001)
+	assert.Equal(t, 0.0, errorRate)
+	assert.Equal(t, 0.0, rate)
+
+	_, rate = burnRate(model.SLIEvents{Good: 990, Total: 1000}, 0)
+	assert.Equal(t, 0.0, rate)
+}
+
+func TestNewSLOStatus(t *testing.T) {
+	slo := &model.SLO{ID: "s1", SLIType: model.SLITypeAvailability, Target: 99, WindowDays: 30}
+
+	// quarter of budget spent over slo window
+	status := newSLOStatus(slo, model.SLIEvents{Good: 99750, Total: 100000}, burnRateEvents(0, 0, 0, 0, 0))
+	assert.InDelta(t, 99.75, status.SLI, 1e-9)
+	assert.InDelta(t, 75, status.ErrorBudgetRemaining, 1e-9)
+	assert.Equal(t, []model.BurnRateCondition{}, status.Alerts)
+	assert.Equal(t, "5m", status.BurnRates[0].Window)
+	assert.Equal(t, 300, status.BurnRates[0].WindowSeconds)
+
+	// budget exhausted twice over
+	status = newSLOStatus(slo, model.SLIEvents{Good: 97000, Total: 100000}, burnRateEvents(0, 0, 0, 0, 0))
+	assert.InDelta(t, -200, status.ErrorBudgetRemaining, 1e-9)
+
+	status = newSLOStatus(slo, model.SLIEvents{}, burnRateEvents(0, 0, 0, 0, 0))
+	assert.Equal(t, 100.0, status.SLI)
+	assert.Equal(t, 100.0, status.ErrorBudgetRemaining)
+}
+
+func TestBurnRateConditions(t *testing.T) {
+	slo := &model.SLO{Target: 99, WindowDays: 30}
+	burnRates := func(badPercents ...uint64) []model.BurnRate {
+		return newSLOStatus(slo, model.SLIEvents{}, burnRateEvents(badPercents...)).BurnRates
+	}
+	fastPage, slowPage, ticket := model.DefaultBurnRateConditions[0], model.DefaultBurnRateConditions[1], model.DefaultBurnRateConditions[2]
+
+	// 20% errors over 5m and 1h burns 20x, fast page only
+	assert.Equal(t, []model.BurnRateCondition{fastPage}, metBurnRateConditions(burnRates(20, 20, 20, 1, 1), model.DefaultBurnRateConditions))
+	// spike already over, short window recovered
+	assert.Equal(t, []model.BurnRateCondition{}, metBurnRateConditions(burnRates(0, 0, 20, 1, 1), model.DefaultBurnRateConditions))
+	// short spike without long window burn
+	assert.Equal(t, []model.BurnRateCondition{}, metBurnRateConditions(burnRates(50, 10, 10, 1, 1), model.DefaultBurnRateConditions))
+	// steady 7% errors for hours
+	assert.Equal(t, []model.BurnRateCondition{slowPage}, metBurnRateConditions(burnRates(7, 7, 7, 7, 1), model.DefaultBurnRateConditions))
+	// slow 2% burn over days
+	assert.Equal(t, []model.BurnRateCondition{ticket}, metBurnRateConditions(burnRates(2, 2, 2, 2, 2), model.DefaultBurnRateConditions))
+
+	assert.InDelta(t, 2, burnRateAlertValue(burnRates(2, 2, 2, 2, 2), model.DefaultBurnRateConditions), 1e-9)
+	assert.InDelta(t, 20/14.4, burnRateAlertValue(burnRates(20, 20, 20, 1, 1), model.DefaultBurnRateConditions), 1e-9)
+	assert.Equal(t, 0.0, burnRateAlertValue(burnRates(0, 0, 0, 0, 0), model.DefaultBurnRateConditions))
+	assert.Equal(t, 0.0, burnRateConditionScore(burnRates(20, 20, 20, 20, 20), model.BurnRateCondition{LongWindow: "1d", ShortWindow: "5m", Factor: 1}))
+}
+
+func TestBurnRateConditionsScaleWithWindow(t *testing.T) {
+	assert.Equal(t, model.DefaultBurnRateConditions, (&model.SLO{WindowDays: 30}).BurnRateConditions())
+	conditions := (&model.SLO{WindowDays: 7}).BurnRateConditions()
+	assert.InDelta(t, 3.36, conditions[0].Factor, 1e-9)
+	assert.InDelta(t, 1.4, conditions[1].Factor, 1e-9)
+	assert.Equal(t, "1h", conditions[0].LongWindow)
+
+	// 5% errors burn 1% budget 5x, spending 2% of 7 day budget within an hour pages while 30 day slo doesn't
+	weekly := newSLOStatus(&model.SLO{Target: 99, WindowDays: 7}, model.SLIEvents{}, burnRateEvents(5, 5, 5, 5, 5))
+	assert.Len(t, weekly.Alerts, 3)
+	monthly := newSLOStatus(&model.SLO{Target: 99, WindowDays: 30}, model.SLIEvents{}, burnRateEvents(5, 5, 5, 5, 5))
+	assert.Equal(t, []model.BurnRateCondition{model.DefaultBurnRateConditions[2]}, monthly.Alerts)
+}
+
+// sliEventsDao apm dao returning the same events for slo window and every burn rate window
+type sliEventsDao struct {
+	dao.ApmDao
+	events model.SLIEvents
+}
+
+func (apmDao *sliEventsDao) GetSLIEvents(ctx context.Context, params *model.GetSLIEventsParams) ([]model.SLIEvents, error) {
+	events := make([]model.SLIEvents, len(params.Starts))
+	for i := range events {
+		events[i] = apmDao.events
+	}
+	return events, nil
+}
+
+func TestAlertEvaluatorSLOBurnRate(t *testing.T) {
+	sloDaoMock := new(dao.MockSLODao)
+	sloDaoMock.On("GetSLO", mock.Anything, "s1").Return(&model.SLO{ID: "s1", ServiceName: "cart", Target: 99.9, WindowDays: 30}, nil)
+	sloDaoMock.On("GetSLO", mock.Anything, "missing").Return(nil, nil)
+	evaluator := &AlertEvaluator{ApmDao: &sliEventsDao{events: model.SLIEvents{Good: 980, Total: 1000}}, SLODao: sloDaoMock}
+	rule := model.AlertRule{ID: "r1", Metric: model.AlertMetricSLOBurnRate, SLOID: "s1", Operator: model.AlertOperatorAbove, Threshold: 1}
+
+	transition, err := evaluator.evaluateRule(context.Background(), &rule, model.AlertStatus{}, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, model.AlertStateFiring, transition.State)
+	// 2% errors in every window burn 0.1% budget 20x, ticket condition with factor 1 scores highest
+	assert.InDelta(t, 20, transition.Value, 1e-6)
+
+	rule.SLOID = "missing"
+	_, err = evaluator.evaluateRule(context.Background(), &rule, model.AlertStatus{}, time.Now())
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+)
+
+type ChannelService interface {
+	GetChannels(ctx context.Context) ([]model.NotificationChannel, error)
+	GetChannel(ctx context.Context, id string) (*model.NotificationChannel, error)
+	CreateChannel(ctx context.Context, channel *model.NotificationChannel) (*model.NotificationChannel, error)
+	UpdateChannel(ctx context.Context, channel *model.NotificationChannel) (*model.NotificationChannel, error)
+	DeleteChannel(ctx context.Context, id string) error
+	TestChannel(ctx context.Context, id string) error
+}
+
+type MockChannelService struct {
+	mock.Mock
+}
+
+func (service *MockChannelService) GetChannels(ctx context.Context) ([]model.NotificationChannel, error) {
+	args := service.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.NotificationChannel), args.Error(1)
+}
+
+func (service *MockChannelService) GetChannel(ctx context.Context, id string) (*model.NotificationChannel, error) {
+	args := service.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.NotificationChannel), args.Error(1)
+}
+
+func (service *MockChannelService) CreateChannel(ctx context.Context, channel *model.NotificationChannel) (*model.NotificationChannel, error) {
+	args := service.Called(ctx, channel)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.NotificationChannel), args.Error(1)
+}
+
+func (service *MockChannelService) UpdateChannel(ctx context.Context, channel *model.NotificationChannel) (*model.NotificationChannel, error) {
+	args := service.Called(ctx, channel)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.NotificationChannel), args.Error(1)
+}
+
+func (service *MockChannelService) DeleteChannel(ctx context.Context, id string) error {
+	args := service.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (service *MockChannelService) TestChannel(ctx context.Context, id string) error {
+	args := service.Called(ctx, id)
+	return args.Error(0)
+}
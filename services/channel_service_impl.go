@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"goapm/dao"
+	model "goapm/domain"
+	"goapm/logger"
+	"sync"
+	"time"
+)
+
+var channelServiceOnce sync.Once
+var channelService *ChannelServiceImpl
+
+type ChannelServiceImpl struct {
+	Logger     *zap.SugaredLogger
+	ChannelDao dao.ChannelDao
+	Sender     *NotificationSender
+}
+
+func NewChannelServiceImpl(ChannelDao dao.ChannelDao, Sender *NotificationSender) *ChannelServiceImpl {
+	channelServiceOnce.Do(func() {
+		channelService = &ChannelServiceImpl{
+			Logger:     logger.LOGGER,
+			ChannelDao: ChannelDao,
+			Sender:     Sender,
+		}
+	})
+	return channelService
+}
+
+func (service *ChannelServiceImpl) GetChannels(ctx context.Context) ([]model.NotificationChannel, error) {
+	return service.ChannelDao.GetChannels(ctx)
+}
+
+func (service *ChannelServiceImpl) GetChannel(ctx context.Context, id string) (*model.NotificationChannel, error) {
+	channel, err := service.ChannelDao.GetChannel(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("channel %s not found", id)}
+	}
+	return channel, nil
+}
+
+func (service *ChannelServiceImpl) CreateChannel(ctx context.Context, channel *model.NotificationChannel) (*model.NotificationChannel, error) {
+	if err := validateChannelTemplate(channel); err != nil {
+		return nil, err
+	}
+	id, err := newAlertRuleID()
+	if err != nil {
+		return nil, err
+	}
+	channel.ID = id
+	channel.UpdatedAt = time.Now().UnixNano()
+	if err := service.ChannelDao.SaveChannel(ctx, channel); err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+func (service *ChannelServiceImpl) UpdateChannel(ctx context.Context, channel *model.NotificationChannel) (*model.NotificationChannel, error) {
+	if err := validateChannelTemplate(channel); err != nil {
+		return nil, err
+	}
+	if _, err := service.GetChannel(ctx, channel.ID); err != nil {
+		return nil, err
+	}
+	channel.UpdatedAt = time.Now().UnixNano()
+	if err := service.ChannelDao.SaveChannel(ctx, channel); err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+func (service *ChannelServiceImpl) DeleteChannel(ctx context.Context, id string) error {
+	channel, err := service.GetChannel(ctx, id)
+	if err != nil {
+		return err
+	}
+	return service.ChannelDao.DeleteChannel(ctx, channel)
+}
+
+// TestChannel send made up firing alert to channel, also disabled channel can be tested
+func (service *ChannelServiceImpl) TestChannel(ctx context.Context, id string) error {
+	channel, err := service.GetChannel(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = service.Sender.Send(ctx, channel, testNotificationGroup(channel))
+	if err != nil {
+		return &model.ApiError{Typ: model.ErrorUnavailable, Err: fmt.Errorf("unable to notify channel %s: %w", id, err)}
+	}
+	return nil
+}
+
+func testNotificationGroup(channel *model.NotificationChannel) *model.NotificationGroup {
+	notification := model.Notification{
+		RuleID:      "test",
+		RuleName:    "Test notification",
+		ServiceName: "goapm",
+		Metric:      model.AlertMetricErrorRate,
+		State:       model.AlertStateFiring,
+		Value:       10,
+		Threshold:   5,
+		Labels:      map[string]string{"ruleID": "test", "alertname": "Test notification", "service": "goapm", "metric": model.AlertMetricErrorRate},
+		Since:       time.Now().UnixNano(),
+	}
+	groupLabels := map[string]string{}
+	for _, label := range channel.GroupBy {
+		groupLabels[label] = notification.Labels[label]
+	}
+	return &model.NotificationGroup{ChannelID: channel.ID, ChannelName: channel.Name, GroupLabels: groupLabels,
+		Firing: []model.Notification{notification}, Resolved: []model.Notification{}}
+}
+
+// validateChannelTemplate webhook template has to render valid json for test notification
+func validateChannelTemplate(channel *model.NotificationChannel) error {
+	if channel.Type != model.ChannelTypeWebhook {
+		return nil
+	}
+	if _, err := renderWebhookBody(channel.Template, testNotificationGroup(channel)); err != nil {
+		return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid template: %w", err)}
+	}
+	return nil
+}
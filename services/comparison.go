@@ -0,0 +1,79 @@
+package services
+
+import (
+	model "goapm/domain"
+	"time"
+)
+
+// comparedMetrics metrics of an item which are compared against the baseline period
+type comparedMetrics struct {
+	p99       float32
+	errorRate float32
+	callRate  float32
+}
+
+// shiftTimeRange same range moved offset back in time
+func shiftTimeRange(start *time.Time, end *time.Time, offset time.Duration) (*time.Time, *time.Time) {
+	shiftedStart := start.Add(-offset)
+	shiftedEnd := end.Add(-offset)
+	return &shiftedStart, &shiftedEnd
+}
+
+// newMetricsComparison compare current metrics to baseline, item missing in baseline period is compared to zeros
+func newMetricsComparison(current comparedMetrics, baseline comparedMetrics) *model.MetricsComparison {
+	return &model.MetricsComparison{
+		BaselineP99:           baseline.p99,
+		BaselineErrorRate:     baseline.errorRate,
+		BaselineCallRate:      baseline.callRate,
+		P99Delta:              current.p99 - baseline.p99,
+		P99DeltaPercent:       deltaPercent(current.p99, baseline.p99),
+		ErrorRateDelta:        current.errorRate - baseline.errorRate,
+		ErrorRateDeltaPercent: deltaPercent(current.errorRate, baseline.errorRate),
+		CallRateDelta:         current.callRate - baseline.callRate,
+		CallRateDeltaPercent:  deltaPercent(current.callRate, baseline.callRate),
+	}
+}
+
+func deltaPercent(current float32, baseline float32) *float32 {
+	if baseline == 0 {
+		return nil
+	}
+	percent := (current - baseline) * 100 / baseline
+	return &percent
+}
+
+func compareServices(items []model.ServiceItem, baselineItems []model.ServiceItem) {
+	baselines := make(map[string]comparedMetrics, len(baselineItems))
+	for _, item := range baselineItems {
+		baselines[item.ServiceName] = comparedMetrics{p99: item.Percentile99, errorRate: item.ErrorRate, callRate: item.CallRate}
+	}
+	for i := range items {
+		current := comparedMetrics{p99: items[i].Percentile99, errorRate: items[i].ErrorRate, callRate: items[i].CallRate}
+		items[i].Comparison = newMetricsComparison(current, baselines[items[i].ServiceName])
+	}
+}
+
+// compareServiceOverview bucket is compared to the bucket starting offset earlier
+func compareServiceOverview(items []model.ServiceOverviewItem, baselineItems []model.ServiceOverviewItem, offset time.Duration) {
+	baselines := make(map[int64]comparedMetrics, len(baselineItems))
+	for _, item := range baselineItems {
+		baselines[item.Timestamp] = comparedMetrics{p99: item.Percentile99, errorRate: item.ErrorRate, callRate: item.CallRate}
+	}
+	for i := range items {
+		current := comparedMetrics{p99: items[i].Percentile99, errorRate: items[i].ErrorRate, callRate: items[i].CallRate}
+		baselineTimestamp := items[i].Timestamp - offset.Nanoseconds()
+		items[i].Comparison = newMetricsComparison(current, baselines[baselineTimestamp])
+		items[i].Comparison.BaselineTimestamp = baselineTimestamp
+	}
+}
+
+func compareTopEndpoints(items []model.TopEndpointsItem, baselineItems []model.TopEndpointsItem) {
+	baselines := make(map[string]comparedMetrics, len(baselineItems))
+	for _, item := range baselineItems {
+		baselines[item.Name] = comparedMetrics{p99: item.Percentile99, errorRate: item.ErrorRate, callRate: item.CallRate}
+	}
+	for i := range items {
+		current := comparedMetrics{p99: items[i].Percentile99, errorRate: items[i].ErrorRate, callRate: items[i].CallRate}
+		items[i].Comparison = newMetricsComparison(current, baselines[items[i].Name])
+	}
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	model "goapm/domain"
+	"testing"
+	"time"
+)
+
+func TestCompareServices(t *testing.T) {
+	items := []model.ServiceItem{
+		{ServiceName: "orders", Percentile99: 300, ErrorRate: 2, CallRate: 10},
+		{ServiceName: "payments", Percentile99: 100, CallRate: 5},
+	}
+	compareServices(items, []model.ServiceItem{{ServiceName: "orders", Percentile99: 200, ErrorRate: 0, CallRate: 20}})
+
+	orders := items[0].Comparison
+	assert.Equal(t, float32(200), orders.BaselineP99)
+	assert.Equal(t, float32(100), orders.P99Delta)
+	assert.Equal(t, float32(50), *orders.P99DeltaPercent)
+	assert.Equal(t, float32(2), orders.ErrorRateDelta)
+	assert.Nil(t, orders.ErrorRateDeltaPercent)
+	assert.Equal(t, float32(-50), *orders.CallRateDeltaPercent)
+
+	payments := items[1].Comparison
+	assert.Equal(t, float32(5), payments.CallRateDelta)
+	assert.Nil(t, payments.CallRateDeltaPercent)
+}
+
+func TestCompareServiceOverview(t *testing.T) {
+	day := 24 * time.Hour
+	now := time.Unix(1600000000, 0).UnixNano()
+	items := []model.ServiceOverviewItem{{Timestamp: now, Percentile99: 150}}
+	compareServiceOverview(items, []model.ServiceOverviewItem{{Timestamp: now - day.Nanoseconds(), Percentile99: 100}}, day)
+
+	assert.Equal(t, now-day.Nanoseconds(), items[0].Comparison.BaselineTimestamp)
+	assert.Equal(t, float32(50), items[0].Comparison.P99Delta)
+}
+
+func TestShiftTimeRange(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	end := start.Add(time.Hour)
+	shiftedStart, shiftedEnd := shiftTimeRange(&start, &end, 7*24*time.Hour)
+	assert.Equal(t, start.Add(-7*24*time.Hour), *shiftedStart)
+	assert.Equal(t, end.Add(-7*24*time.Hour), *shiftedEnd)
+	assert.Equal(t, time.Unix(1600000000, 0), start)
+}
@@ -0,0 +1,23 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+)
+
+type DatabaseService interface {
+	GetDBStatements(ctx context.Context, queryParams *model.GetDBStatementsParams) (*model.DBStatementsResult, error)
+}
+
+type MockDatabaseService struct {
+	mock.Mock
+}
+
+func (service *MockDatabaseService) GetDBStatements(ctx context.Context, queryParams *model.GetDBStatementsParams) (*model.DBStatementsResult, error) {
+	args := service.Called(ctx, queryParams)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.DBStatementsResult), args.Error(1)
+}
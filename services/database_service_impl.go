@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"goapm/dao"
+	model "goapm/domain"
+	"goapm/logger"
+	"sync"
+)
+
+var databaseServiceOnce sync.Once
+var databaseService *DatabaseServiceImpl
+
+// DatabaseServiceImpl analytics of database client calls, complements service db overview with per statement breakdown
+type DatabaseServiceImpl struct {
+	Logger *zap.SugaredLogger
+	ApmDao dao.ApmDao
+}
+
+func NewDatabaseServiceImpl(ApmDao dao.ApmDao) *DatabaseServiceImpl {
+	databaseServiceOnce.Do(func() {
+		databaseService = &DatabaseServiceImpl{
+			Logger: logger.LOGGER,
+			ApmDao: ApmDao,
+		}
+	})
+	return databaseService
+}
+
+func (service *DatabaseServiceImpl) GetDBStatements(ctx context.Context, queryParams *model.GetDBStatementsParams) (*model.DBStatementsResult, error) {
+	return service.ApmDao.GetDBStatements(ctx, queryParams)
+}
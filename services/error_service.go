@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+)
+
+type ErrorService interface {
+	GetErrorGroups(ctx context.Context, queryParams *model.GetErrorsParams) (*model.ErrorGroupsResult, error)
+	GetErrorGroup(ctx context.Context, queryParams *model.GetErrorParams) (*model.ErrorDetail, error)
+}
+
+type MockErrorService struct {
+	mock.Mock
+}
+
+func (service *MockErrorService) GetErrorGroups(ctx context.Context, queryParams *model.GetErrorsParams) (*model.ErrorGroupsResult, error) {
+	args := service.Called(ctx, queryParams)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ErrorGroupsResult), args.Error(1)
+}
+
+func (service *MockErrorService) GetErrorGroup(ctx context.Context, queryParams *model.GetErrorParams) (*model.ErrorDetail, error) {
+	args := service.Called(ctx, queryParams)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ErrorDetail), args.Error(1)
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"goapm/dao"
+	model "goapm/domain"
+	"goapm/logger"
+	"sync"
+)
+
+var errorServiceOnce sync.Once
+var errorService *ErrorServiceImpl
+
+type ErrorServiceImpl struct {
+	Logger   *zap.SugaredLogger
+	ErrorDao dao.ErrorDao
+}
+
+func NewErrorServiceImpl(ErrorDao dao.ErrorDao) *ErrorServiceImpl {
+	errorServiceOnce.Do(func() {
+		errorService = &ErrorServiceImpl{
+			Logger:   logger.LOGGER,
+			ErrorDao: ErrorDao,
+		}
+	})
+	return errorService
+}
+
+func (service *ErrorServiceImpl) GetErrorGroups(ctx context.Context, queryParams *model.GetErrorsParams) (*model.ErrorGroupsResult, error) {
+	return service.ErrorDao.GetErrorGroups(ctx, queryParams)
+}
+
+func (service *ErrorServiceImpl) GetErrorGroup(ctx context.Context, queryParams *model.GetErrorParams) (*model.ErrorDetail, error) {
+	return service.ErrorDao.GetErrorGroup(ctx, queryParams)
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	model "goapm/domain"
+	"goapm/http"
+	"goapm/logger"
+	"goapm/utils"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+var notificationSenderOnce sync.Once
+var notificationSender *NotificationSender
+
+// notifier deliver notification group to channel of one type
+type notifier interface {
+	notify(ctx context.Context, channel *model.NotificationChannel, group *model.NotificationGroup) error
+}
+
+// NotificationSender send notification groups through notifier registered for type of channel.
+// Http channels go through RestClient so its retries and timeouts apply, email is sent through smtp server from config
+type NotificationSender struct {
+	Logger    *zap.SugaredLogger
+	notifiers map[string]notifier
+}
+
+func NewNotificationSender(RestClient http.RestClientInterface) *NotificationSender {
+	notificationSenderOnce.Do(func() {
+		notificationSender = newNotificationSender(RestClient, sendMail)
+	})
+	return notificationSender
+}
+
+func newNotificationSender(restClient http.RestClientInterface, sendMail sendMailFunc) *NotificationSender {
+	return &NotificationSender{
+		Logger: logger.LOGGER,
+		notifiers: map[string]notifier{
+			model.ChannelTypeWebhook: &webhookNotifier{restClient: restClient},
+			model.ChannelTypeSlack:   &slackNotifier{restClient: restClient},
+			model.ChannelTypeEmail:   &emailNotifier{sendMail: sendMail},
+		},
+	}
+}
+
+func (sender *NotificationSender) Send(ctx context.Context, channel *model.NotificationChannel, group *model.NotificationGroup) error {
+	channelNotifier, ok := sender.notifiers[channel.Type]
+	if !ok {
+		return fmt.Errorf("unknown channel type %s", channel.Type)
+	}
+	return channelNotifier.notify(ctx, channel, group)
+}
+
+type webhookNotifier struct {
+	restClient http.RestClientInterface
+}
+
+func (webhook *webhookNotifier) notify(ctx context.Context, channel *model.NotificationChannel, group *model.NotificationGroup) error {
+	body, err := renderWebhookBody(channel.Template, group)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{"Content-Type": "application/json"}
+	for key, value := range channel.Headers {
+		headers[key] = value
+	}
+	return post(ctx, webhook.restClient, channel.URL, body, headers)
+}
+
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// renderWebhookBody execute template of channel on group, channel without template gets group itself as json.
+// Rendered body must be valid json, values should be written through json function to be escaped
+func renderWebhookBody(text string, group *model.NotificationGroup) ([]byte, error) {
+	if len(text) == 0 {
+		return json.Marshal(group)
+	}
+	bodyTemplate, err := template.New("webhook").Funcs(webhookTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	if err := bodyTemplate.Execute(&body, group); err != nil {
+		return nil, err
+	}
+	if !json.Valid(body.Bytes()) {
+		return nil, fmt.Errorf("template didn't render valid json")
+	}
+	return body.Bytes(), nil
+}
+
+// slackNotifier incoming webhook payload accepted by slack and mattermost
+type slackNotifier struct {
+	restClient http.RestClientInterface
+}
+
+func (slack *slackNotifier) notify(ctx context.Context, channel *model.NotificationChannel, group *model.NotificationGroup) error {
+	body := map[string]string{"text": notificationSubject(group) + "\n" + notificationText(group)}
+	return post(ctx, slack.restClient, channel.URL, body, map[string]string{"Content-Type": "application/json"})
+}
+
+func post(ctx context.Context, restClient http.RestClientInterface, url string, body interface{}, headers map[string]string) error {
+	response, err := restClient.PostResponse(ctx, url, body, headers)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("channel responded with status %d", response.StatusCode)
+	}
+	return nil
+}
+
+type sendMailFunc func(ctx context.Context, addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+
+// sendMail smtp.SendMail bounded by deadline of ctx, so a hanging smtp server can't hold the notifier past its lock
+func sendMail(ctx context.Context, addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(msg); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// emailNotifier plain text email, smtp server is read from config on every send so config reload applies
+type emailNotifier struct {
+	sendMail sendMailFunc
+}
+
+func (email *emailNotifier) notify(ctx context.Context, channel *model.NotificationChannel, group *model.NotificationGroup) error {
+	host := viper.GetString("SMTP_HOST")
+	if len(host) == 0 {
+		return fmt.Errorf("smtp server is not configured")
+	}
+	port := utils.GetOrDefault(viper.GetString("SMTP_PORT"), "25")
+	from := viper.GetString("SMTP_FROM")
+	var auth smtp.Auth
+	if username := viper.GetString("SMTP_USERNAME"); len(username) > 0 {
+		auth = smtp.PlainAuth("", username, viper.GetString("SMTP_PASSWORD"), host)
+	}
+
+	var message strings.Builder
+	message.WriteString("From: " + from + "\r\n")
+	message.WriteString("To: " + strings.Join(channel.Recipients, ", ") + "\r\n")
+	// subject is built from names and labels given by users, line breaks would let them add headers
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(notificationSubject(group))
+	message.WriteString("Subject: " + subject + "\r\n")
+	message.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(notificationText(group), "\n", "\r\n"))
+	return email.sendMail(ctx, host+":"+port, auth, from, channel.Recipients, []byte(message.String()))
+}
+
+// notificationSubject e.g. [FIRING:2, RESOLVED:1] service=cart
+func notificationSubject(group *model.NotificationGroup) string {
+	var counts []string
+	if len(group.Firing) > 0 {
+		counts = append(counts, fmt.Sprintf("FIRING:%d", len(group.Firing)))
+	}
+	if len(group.Resolved) > 0 {
+		counts = append(counts, fmt.Sprintf("RESOLVED:%d", len(group.Resolved)))
+	}
+	subject := "[" + strings.Join(counts, ", ") + "] " + group.ChannelName
+	if labels := formatLabels(group.GroupLabels); len(labels) > 0 {
+		subject += " " + labels
+	}
+	return subject
+}
+
+// notificationText one line per alert
+func notificationText(group *model.NotificationGroup) string {
+	var text strings.Builder
+	for _, notifications := range [][]model.Notification{group.Firing, group.Resolved} {
+		for _, notification := range notifications {
+			text.WriteString(fmt.Sprintf("%s %s: %s of %s is %.2f, threshold %.2f, since %s\n", strings.ToUpper(notification.State),
+				notification.RuleName, notification.Metric, notification.ServiceName, notification.Value, notification.Threshold,
+				time.Unix(0, notification.Since).UTC().Format(time.RFC3339)))
+		}
+	}
+	return text.String()
+}
+
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + labels[key]
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jarcoal/httpmock"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	model "goapm/domain"
+	"goapm/http"
+	"io/ioutil"
+	"net"
+	nethttp "net/http"
+	"net/smtp"
+	"testing"
+	"time"
+)
+
+func newTestNotificationGroup() *model.NotificationGroup {
+	return &model.NotificationGroup{
+		ChannelID:   "c1",
+		ChannelName: "oncall",
+		GroupLabels: map[string]string{"service": "cart"},
+		Firing: []model.Notification{{RuleID: "r1", RuleName: "cart \"errors\"", ServiceName: "cart", Metric: model.AlertMetricErrorRate,
+			State: model.AlertStateFiring, Value: 12.5, Threshold: 5, Since: time.Date(2021, 9, 1, 10, 0, 0, 0, time.UTC).UnixNano()}},
+		Resolved: []model.Notification{},
+	}
+}
+
+func newHttpNotificationSender(t *testing.T) *NotificationSender {
+	restClient := http.NewRestClient()
+	http.RestClientService.Client.SetRetryCount(0)
+	httpmock.ActivateNonDefault(http.RestClientService.Client.GetClient())
+	t.Cleanup(httpmock.Reset)
+	return newNotificationSender(restClient, nil)
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	sender := newHttpNotificationSender(t)
+	var body []byte
+	var token string
+	httpmock.RegisterResponder("POST", "http://hooks.example.com/alerts", func(request *nethttp.Request) (*nethttp.Response, error) {
+		body, _ = ioutil.ReadAll(request.Body)
+		token = request.Header.Get("X-Token")
+		return httpmock.NewStringResponse(200, "{}"), nil
+	})
+	channel := &model.NotificationChannel{ID: "c1", Type: model.ChannelTypeWebhook, URL: "http://hooks.example.com/alerts",
+		Headers:  map[string]string{"X-Token": "secret"},
+		Template: `{"summary": {{ json (index .Firing 0).RuleName }}, "firing": {{ len .Firing }}}`}
+
+	err := sender.Send(context.Background(), channel, newTestNotificationGroup())
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"summary": "cart \"errors\"", "firing": 1}`, string(body))
+	assert.Equal(t, "secret", token)
+
+	channel.Template = ""
+	err = sender.Send(context.Background(), channel, newTestNotificationGroup())
+	assert.Nil(t, err)
+	var group model.NotificationGroup
+	assert.Nil(t, json.Unmarshal(body, &group))
+	assert.Equal(t, "r1", group.Firing[0].RuleID)
+
+	channel.Template = `{"summary": "{{ .Missing }}"}`
+	assert.NotNil(t, sender.Send(context.Background(), channel, newTestNotificationGroup()))
+	channel.Template = `{"summary": {{ (index .Firing 0).RuleName }}}`
+	assert.NotNil(t, sender.Send(context.Background(), channel, newTestNotificationGroup()))
+}
+
+func TestSlackNotifier(t *testing.T) {
+	sender := newHttpNotificationSender(t)
+	var payload map[string]string
+	httpmock.RegisterResponder("POST", "https://slack.example.com/hook", func(request *nethttp.Request) (*nethttp.Response, error) {
+		_ = json.NewDecoder(request.Body).Decode(&payload)
+		return httpmock.NewStringResponse(200, "ok"), nil
+	})
+	channel := &model.NotificationChannel{ID: "c2", Type: model.ChannelTypeSlack, URL: "https://slack.example.com/hook"}
+
+	err := sender.Send(context.Background(), channel, newTestNotificationGroup())
+	assert.Nil(t, err)
+	assert.Equal(t, "[FIRING:1] oncall service=cart\nFIRING cart \"errors\": error_rate of cart is 12.50, threshold 5.00, since 2021-09-01T10:00:00Z\n",
+		payload["text"])
+
+	httpmock.RegisterResponder("POST", "https://slack.example.com/hook", httpmock.NewStringResponder(404, "no_team"))
+	assert.NotNil(t, sender.Send(context.Background(), channel, newTestNotificationGroup()))
+}
+
+func TestEmailNotifier(t *testing.T) {
+	var addr, from string
+	var to []string
+	var message []byte
+	sender := newNotificationSender(nil, func(ctx context.Context, a string, auth smtp.Auth, f string, recipients []string, msg []byte) error {
+		addr, from, to, message = a, f, recipients, msg
+		return nil
+	})
+	channel := &model.NotificationChannel{ID: "c3", Name: "oncall\r\nBcc: evil@example.com", Type: model.ChannelTypeEmail,
+		Recipients: []string{"ops@example.com"}}
+
+	viper.Set("SMTP_HOST", "")
+	assert.NotNil(t, sender.Send(context.Background(), channel, newTestNotificationGroup()))
+
+	viper.Set("SMTP_HOST", "smtp.example.com")
+	viper.Set("SMTP_FROM", "apm@example.com")
+	defer viper.Set("SMTP_HOST", "")
+	group := newTestNotificationGroup()
+	group.ChannelName = channel.Name
+	err := sender.Send(context.Background(), channel, group)
+	assert.Nil(t, err)
+	assert.Equal(t, "smtp.example.com:25", addr)
+	assert.Equal(t, "apm@example.com", from)
+	assert.Equal(t, []string{"ops@example.com"}, to)
+	assert.Contains(t, string(message), "Subject: [FIRING:1] oncall  Bcc: evil@example.com service=cart\r\n")
+	assert.NotContains(t, string(message), "\r\nBcc:")
+}
+
+func TestSendMailStopsAtDeadline(t *testing.T) {
+	// server accepting connections and never sending its greeting
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	err = sendMail(ctx, listener.Addr().String(), nil, "apm@example.com", []string{"ops@example.com"}, []byte("test"))
+	assert.NotNil(t, err)
+	assert.Less(t, int64(time.Since(started)), int64(2*time.Second))
+}
+
+func TestUnknownChannelType(t *testing.T) {
+	sender := newNotificationSender(nil, nil)
+	assert.NotNil(t, sender.Send(context.Background(), &model.NotificationChannel{Type: "pager"}, newTestNotificationGroup()))
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"goapm/dao"
+	"goapm/logger"
+	redis_factory "goapm/redis"
+	"sync"
+	"time"
+)
+
+// serviceDependencyLag minute is aggregated once spans of its traces were exported, parents finish after their children
+const serviceDependencyLag = 5 * time.Minute
+
+// maxServiceDependencyBatch minutes aggregated by one run when the job is behind
+const maxServiceDependencyBatch = 15 * time.Minute
+
+// maxServiceDependencyBacklog signoz_index_tmp keeps spans for a day, older minutes can't be aggregated anymore
+const maxServiceDependencyBacklog = 23 * time.Hour
+
+var serviceDependencyJobOnce sync.Once
+var serviceDependencyJob *ServiceDependencyJob
+
+type ServiceDependencyJob struct {
+	Logger               *zap.SugaredLogger
+	ServiceDependencyDao dao.ServiceDependencyDao
+	RedisService         redis_factory.SpecificRedisService
+}
+
+func NewServiceDependencyJob(ServiceDependencyDao dao.ServiceDependencyDao, RedisService redis_factory.SpecificRedisService) *ServiceDependencyJob {
+	serviceDependencyJobOnce.Do(func() {
+		serviceDependencyJob = &ServiceDependencyJob{
+			Logger:               logger.LOGGER,
+			ServiceDependencyDao: ServiceDependencyDao,
+			RedisService:         RedisService,
+		}
+	})
+	return serviceDependencyJob
+}
+
+// Run aggregate service dependencies of minutes finished since the last run, it is called every minute on every
+// replica and a redis lock lets only one replica aggregate. When saving progress fails the same minutes are aggregated
+// again by the next run, which replaces their rows
+func (job *ServiceDependencyJob) Run() {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+	now := time.Now()
+
+	locked, err := job.RedisService.GetSpecificRedis().SetNX(ctx, "SERVICE_DEPENDENCIES", now.Format(timeLayout), 55)
+	if err != nil {
+		job.Logger.Error("unable to lock service dependencies aggregation ", err)
+		return
+	}
+	if !locked {
+		return
+	}
+
+	until, err := job.ServiceDependencyDao.GetAggregatedUntil(ctx)
+	if err != nil {
+		job.Logger.Error("unable to get service dependencies progress ", err)
+		return
+	}
+	start, end := serviceDependencyWindow(until, now)
+	if !end.After(start) {
+		return
+	}
+	if err := job.ServiceDependencyDao.AggregateDependencies(ctx, start, end); err != nil {
+		job.Logger.Error("unable to aggregate service dependencies ", err)
+		return
+	}
+	if err := job.ServiceDependencyDao.SetAggregatedUntil(ctx, end); err != nil {
+		job.Logger.Error("unable to save service dependencies progress ", err)
+	}
+}
+
+// serviceDependencyWindow minutes to aggregate after until, at most maxServiceDependencyBatch of them. Minutes whose
+// spans already expired from signoz_index_tmp are skipped
+func serviceDependencyWindow(until *time.Time, now time.Time) (time.Time, time.Time) {
+	end := now.Add(-serviceDependencyLag).Truncate(time.Minute)
+	start := end.Add(-time.Minute)
+	if until != nil {
+		start = *until
+	}
+	if oldest := end.Add(-maxServiceDependencyBacklog); start.Before(oldest) {
+		start = oldest
+	}
+	if end.After(start.Add(maxServiceDependencyBatch)) {
+		end = start.Add(maxServiceDependencyBatch)
+	}
+	return start, end
+}
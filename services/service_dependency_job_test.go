@@ -0,0 +1,67 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"goapm/dao"
+	"goapm/logger"
+	redis_factory "goapm/redis"
+	"testing"
+	"time"
+)
+
+func TestServiceDependencyWindow(t *testing.T) {
+	now := time.Date(2020, 9, 14, 18, 30, 20, 0, time.UTC)
+	end := time.Date(2020, 9, 14, 18, 25, 0, 0, time.UTC)
+
+	until := end.Add(-3 * time.Minute)
+	start, windowEnd := serviceDependencyWindow(&until, now)
+	assert.Equal(t, until, start)
+	assert.Equal(t, end, windowEnd)
+
+	start, windowEnd = serviceDependencyWindow(nil, now)
+	assert.Equal(t, end.Add(-time.Minute), start)
+	assert.Equal(t, end, windowEnd)
+
+	// behind, catch up in batches
+	until = end.Add(-2 * time.Hour)
+	start, windowEnd = serviceDependencyWindow(&until, now)
+	assert.Equal(t, until, start)
+	assert.Equal(t, until.Add(maxServiceDependencyBatch), windowEnd)
+
+	// spans of minutes older than a day are gone
+	until = end.Add(-48 * time.Hour)
+	start, _ = serviceDependencyWindow(&until, now)
+	assert.Equal(t, end.Add(-maxServiceDependencyBacklog), start)
+
+	// already up to date
+	until = end
+	start, windowEnd = serviceDependencyWindow(&until, now)
+	assert.False(t, windowEnd.After(start))
+}
+
+func newServiceDependencyJobTest(locked bool) (*ServiceDependencyJob, *dao.MockServiceDependencyDao) {
+	redisMock := new(redis_factory.MockRedisFactory)
+	redisMock.On("SetNX", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(locked, nil)
+	specificRedisMock := new(redis_factory.MockSpecificRedisFactory)
+	specificRedisMock.On("GetSpecificRedis", mock.Anything).Return(redisMock)
+	dependencyDaoMock := new(dao.MockServiceDependencyDao)
+	return &ServiceDependencyJob{Logger: logger.LOGGER, ServiceDependencyDao: dependencyDaoMock, RedisService: specificRedisMock}, dependencyDaoMock
+}
+
+func TestServiceDependencyJobRun(t *testing.T) {
+	job, dependencyDaoMock := newServiceDependencyJobTest(true)
+	until := time.Now().Add(-10 * time.Minute).Truncate(time.Minute)
+	dependencyDaoMock.On("GetAggregatedUntil", mock.Anything).Return(&until, nil)
+	dependencyDaoMock.On("AggregateDependencies", mock.Anything, until, mock.Anything).Return(nil)
+	dependencyDaoMock.On("SetAggregatedUntil", mock.Anything, mock.Anything).Return(nil)
+
+	job.Run()
+	end := dependencyDaoMock.Calls[1].Arguments.Get(2).(time.Time)
+	assert.True(t, end.After(until))
+	assert.Equal(t, end, dependencyDaoMock.Calls[2].Arguments.Get(1))
+
+	job, dependencyDaoMock = newServiceDependencyJobTest(false)
+	job.Run()
+	dependencyDaoMock.AssertNotCalled(t, "GetAggregatedUntil", mock.Anything)
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	model "goapm/domain"
+)
+
+// focusServiceMap keep edges reachable from service in at most hops calls, direction of calls is ignored
+// so both callers and callees of the service are kept
+func focusServiceMap(dependencies []model.ServiceMapDependencyResponseItem, service string, hops int) []model.ServiceMapDependencyResponseItem {
+	neighbours := map[string][]string{}
+	for _, dependency := range dependencies {
+		neighbours[dependency.Parent] = append(neighbours[dependency.Parent], dependency.Child)
+		neighbours[dependency.Child] = append(neighbours[dependency.Child], dependency.Parent)
+	}
+
+	distances := map[string]int{service: 0}
+	queue := []string{service}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if distances[current] == hops {
+			continue
+		}
+		for _, neighbour := range neighbours[current] {
+			if _, ok := distances[neighbour]; !ok {
+				distances[neighbour] = distances[current] + 1
+				queue = append(queue, neighbour)
+			}
+		}
+	}
+
+	focused := []model.ServiceMapDependencyResponseItem{}
+	for _, dependency := range dependencies {
+		parentDistance, parentOk := distances[dependency.Parent]
+		childDistance, childOk := distances[dependency.Child]
+		if parentOk && childOk && (parentDistance < hops || childDistance < hops) {
+			focused = append(focused, dependency)
+		}
+	}
+	return focused
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	model "goapm/domain"
+	"testing"
+)
+
+func TestFocusServiceMap(t *testing.T) {
+	// frontend -> orders -> payments -> bank, frontend -> users, orders -> orders
+	dependencies := []model.ServiceMapDependencyResponseItem{
+		{Parent: "frontend", Child: "orders"},
+		{Parent: "orders", Child: "payments"},
+		{Parent: "payments", Child: "bank"},
+		{Parent: "frontend", Child: "users"},
+		{Parent: "orders", Child: "orders"},
+	}
+	edges := func(items []model.ServiceMapDependencyResponseItem) []string {
+		result := []string{}
+		for _, item := range items {
+			result = append(result, item.Parent+"->"+item.Child)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"frontend->orders", "orders->payments", "orders->orders"}, edges(focusServiceMap(dependencies, "orders", 1)))
+	assert.Equal(t, []string{"frontend->orders", "orders->payments", "payments->bank", "frontend->users", "orders->orders"},
+		edges(focusServiceMap(dependencies, "orders", 2)))
+	assert.Equal(t, []string{}, edges(focusServiceMap(dependencies, "unknown", 3)))
+}
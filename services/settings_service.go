@@ -0,0 +1,29 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+)
+
+type SettingsService interface {
+	GetApdexSettings(ctx context.Context) ([]model.ApdexSetting, error)
+	SetApdexSetting(ctx context.Context, setting *model.ApdexSetting) error
+}
+
+type MockSettingsService struct {
+	mock.Mock
+}
+
+func (service *MockSettingsService) GetApdexSettings(ctx context.Context) ([]model.ApdexSetting, error) {
+	args := service.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.ApdexSetting), args.Error(1)
+}
+
+func (service *MockSettingsService) SetApdexSetting(ctx context.Context, setting *model.ApdexSetting) error {
+	args := service.Called(ctx, setting)
+	return args.Error(0)
+}
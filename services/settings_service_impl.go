@@ -0,0 +1,36 @@
+package services
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"goapm/dao"
+	model "goapm/domain"
+	"goapm/logger"
+	"sync"
+)
+
+var settingsServiceOnce sync.Once
+var settingsService *SettingsServiceImpl
+
+type SettingsServiceImpl struct {
+	Logger      *zap.SugaredLogger
+	SettingsDao dao.SettingsDao
+}
+
+func NewSettingsServiceImpl(SettingsDao dao.SettingsDao) *SettingsServiceImpl {
+	settingsServiceOnce.Do(func() {
+		settingsService = &SettingsServiceImpl{
+			Logger:      logger.LOGGER,
+			SettingsDao: SettingsDao,
+		}
+	})
+	return settingsService
+}
+
+func (service *SettingsServiceImpl) GetApdexSettings(ctx context.Context) ([]model.ApdexSetting, error) {
+	return service.SettingsDao.GetApdexSettings(ctx)
+}
+
+func (service *SettingsServiceImpl) SetApdexSetting(ctx context.Context, setting *model.ApdexSetting) error {
+	return service.SettingsDao.SetApdexSetting(ctx, setting)
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+)
+
+type SilenceService interface {
+	GetSilences(ctx context.Context) ([]model.Silence, error)
+	GetSilence(ctx context.Context, id string) (*model.Silence, error)
+	CreateSilence(ctx context.Context, silence *model.Silence) (*model.Silence, error)
+	UpdateSilence(ctx context.Context, silence *model.Silence) (*model.Silence, error)
+	DeleteSilence(ctx context.Context, id string) error
+	GetMaintenanceWindows(ctx context.Context) ([]model.MaintenanceWindow, error)
+	GetMaintenanceWindow(ctx context.Context, id string) (*model.MaintenanceWindow, error)
+	CreateMaintenanceWindow(ctx context.Context, window *model.MaintenanceWindow) (*model.MaintenanceWindow, error)
+	UpdateMaintenanceWindow(ctx context.Context, window *model.MaintenanceWindow) (*model.MaintenanceWindow, error)
+	DeleteMaintenanceWindow(ctx context.Context, id string) error
+}
+
+type MockSilenceService struct {
+	mock.Mock
+}
+
+func (service *MockSilenceService) GetSilences(ctx context.Context) ([]model.Silence, error) {
+	args := service.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Silence), args.Error(1)
+}
+
+func (service *MockSilenceService) GetSilence(ctx context.Context, id string) (*model.Silence, error) {
+	args := service.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Silence), args.Error(1)
+}
+
+func (service *MockSilenceService) CreateSilence(ctx context.Context, silence *model.Silence) (*model.Silence, error) {
+	args := service.Called(ctx, silence)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Silence), args.Error(1)
+}
+
+func (service *MockSilenceService) UpdateSilence(ctx context.Context, silence *model.Silence) (*model.Silence, error) {
+	args := service.Called(ctx, silence)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Silence), args.Error(1)
+}
+
+func (service *MockSilenceService) DeleteSilence(ctx context.Context, id string) error {
+	args := service.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (service *MockSilenceService) GetMaintenanceWindows(ctx context.Context) ([]model.MaintenanceWindow, error) {
+	args := service.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.MaintenanceWindow), args.Error(1)
+}
+
+func (service *MockSilenceService) GetMaintenanceWindow(ctx context.Context, id string) (*model.MaintenanceWindow, error) {
+	args := service.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.MaintenanceWindow), args.Error(1)
+}
+
+func (service *MockSilenceService) CreateMaintenanceWindow(ctx context.Context, window *model.MaintenanceWindow) (*model.MaintenanceWindow, error) {
+	args := service.Called(ctx, window)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.MaintenanceWindow), args.Error(1)
+}
+
+func (service *MockSilenceService) UpdateMaintenanceWindow(ctx context.Context, window *model.MaintenanceWindow) (*model.MaintenanceWindow, error) {
+	args := service.Called(ctx, window)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.MaintenanceWindow), args.Error(1)
+}
+
+func (service *MockSilenceService) DeleteMaintenanceWindow(ctx context.Context, id string) error {
+	args := service.Called(ctx, id)
+	return args.Error(0)
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"goapm/dao"
+	model "goapm/domain"
+	"goapm/logger"
+	"sync"
+	"time"
+)
+
+var silenceServiceOnce sync.Once
+var silenceService *SilenceServiceImpl
+
+type SilenceServiceImpl struct {
+	Logger     *zap.SugaredLogger
+	SilenceDao dao.SilenceDao
+}
+
+func NewSilenceServiceImpl(SilenceDao dao.SilenceDao) *SilenceServiceImpl {
+	silenceServiceOnce.Do(func() {
+		silenceService = &SilenceServiceImpl{
+			Logger:     logger.LOGGER,
+			SilenceDao: SilenceDao,
+		}
+	})
+	return silenceService
+}
+
+func (service *SilenceServiceImpl) GetSilences(ctx context.Context) ([]model.Silence, error) {
+	silences, err := service.SilenceDao.GetSilences(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range silences {
+		silences[i].Active = silences[i].ActiveAt(now)
+	}
+	return silences, nil
+}
+
+func (service *SilenceServiceImpl) GetSilence(ctx context.Context, id string) (*model.Silence, error) {
+	silence, err := service.SilenceDao.GetSilence(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if silence == nil {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("silence %s not found", id)}
+	}
+	silence.Active = silence.ActiveAt(time.Now())
+	return silence, nil
+}
+
+func (service *SilenceServiceImpl) CreateSilence(ctx context.Context, silence *model.Silence) (*model.Silence, error) {
+	id, err := newAlertRuleID()
+	if err != nil {
+		return nil, err
+	}
+	silence.ID = id
+	return service.saveSilence(ctx, silence)
+}
+
+func (service *SilenceServiceImpl) UpdateSilence(ctx context.Context, silence *model.Silence) (*model.Silence, error) {
+	if _, err := service.GetSilence(ctx, silence.ID); err != nil {
+		return nil, err
+	}
+	return service.saveSilence(ctx, silence)
+}
+
+func (service *SilenceServiceImpl) saveSilence(ctx context.Context, silence *model.Silence) (*model.Silence, error) {
+	now := time.Now()
+	silence.UpdatedAt = now.UnixNano()
+	if err := service.SilenceDao.SaveSilence(ctx, silence); err != nil {
+		return nil, err
+	}
+	silence.Active = silence.ActiveAt(now)
+	return silence, nil
+}
+
+func (service *SilenceServiceImpl) DeleteSilence(ctx context.Context, id string) error {
+	silence, err := service.GetSilence(ctx, id)
+	if err != nil {
+		return err
+	}
+	return service.SilenceDao.DeleteSilence(ctx, silence)
+}
+
+func (service *SilenceServiceImpl) GetMaintenanceWindows(ctx context.Context) ([]model.MaintenanceWindow, error) {
+	windows, err := service.SilenceDao.GetMaintenanceWindows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range windows {
+		windows[i].Active = windows[i].ActiveAt(now)
+	}
+	return windows, nil
+}
+
+func (service *SilenceServiceImpl) GetMaintenanceWindow(ctx context.Context, id string) (*model.MaintenanceWindow, error) {
+	window, err := service.SilenceDao.GetMaintenanceWindow(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if window == nil {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("maintenance window %s not found", id)}
+	}
+	window.Active = window.ActiveAt(time.Now())
+	return window, nil
+}
+
+func (service *SilenceServiceImpl) CreateMaintenanceWindow(ctx context.Context, window *model.MaintenanceWindow) (*model.MaintenanceWindow, error) {
+	id, err := newAlertRuleID()
+	if err != nil {
+		return nil, err
+	}
+	window.ID = id
+	return service.saveMaintenanceWindow(ctx, window)
+}
+
+func (service *SilenceServiceImpl) UpdateMaintenanceWindow(ctx context.Context, window *model.MaintenanceWindow) (*model.MaintenanceWindow, error) {
+	if _, err := service.GetMaintenanceWindow(ctx, window.ID); err != nil {
+		return nil, err
+	}
+	return service.saveMaintenanceWindow(ctx, window)
+}
+
+func (service *SilenceServiceImpl) saveMaintenanceWindow(ctx context.Context, window *model.MaintenanceWindow) (*model.MaintenanceWindow, error) {
+	now := time.Now()
+	window.UpdatedAt = now.UnixNano()
+	if err := service.SilenceDao.SaveMaintenanceWindow(ctx, window); err != nil {
+		return nil, err
+	}
+	window.Active = window.ActiveAt(now)
+	return window, nil
+}
+
+func (service *SilenceServiceImpl) DeleteMaintenanceWindow(ctx context.Context, id string) error {
+	window, err := service.GetMaintenanceWindow(ctx, id)
+	if err != nil {
+		return err
+	}
+	return service.SilenceDao.DeleteMaintenanceWindow(ctx, window)
+}
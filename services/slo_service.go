@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+)
+
+type SLOService interface {
+	GetSLOs(ctx context.Context) ([]model.SLO, error)
+	GetSLOStatus(ctx context.Context, id string) (*model.SLOStatus, error)
+	CreateSLO(ctx context.Context, slo *model.SLO) (*model.SLO, error)
+	UpdateSLO(ctx context.Context, slo *model.SLO) (*model.SLO, error)
+	DeleteSLO(ctx context.Context, id string) error
+}
+
+type MockSLOService struct {
+	mock.Mock
+}
+
+func (service *MockSLOService) GetSLOs(ctx context.Context) ([]model.SLO, error) {
+	args := service.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.SLO), args.Error(1)
+}
+
+func (service *MockSLOService) GetSLOStatus(ctx context.Context, id string) (*model.SLOStatus, error) {
+	args := service.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.SLOStatus), args.Error(1)
+}
+
+func (service *MockSLOService) CreateSLO(ctx context.Context, slo *model.SLO) (*model.SLO, error) {
+	args := service.Called(ctx, slo)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.SLO), args.Error(1)
+}
+
+func (service *MockSLOService) UpdateSLO(ctx context.Context, slo *model.SLO) (*model.SLO, error) {
+	args := service.Called(ctx, slo)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.SLO), args.Error(1)
+}
+
+func (service *MockSLOService) DeleteSLO(ctx context.Context, id string) error {
+	args := service.Called(ctx, id)
+	return args.Error(0)
+}
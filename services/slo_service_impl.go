@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"goapm/dao"
+	model "goapm/domain"
+	"goapm/logger"
+	"sync"
+	"time"
+)
+
+var sloServiceOnce sync.Once
+var sloService *SLOServiceImpl
+
+type SLOServiceImpl struct {
+	Logger *zap.SugaredLogger
+	SLODao dao.SLODao
+	ApmDao dao.ApmDao
+}
+
+func NewSLOServiceImpl(SLODao dao.SLODao, ApmDao dao.ApmDao) *SLOServiceImpl {
+	sloServiceOnce.Do(func() {
+		sloService = &SLOServiceImpl{
+			Logger: logger.LOGGER,
+			SLODao: SLODao,
+			ApmDao: ApmDao,
+		}
+	})
+	return sloService
+}
+
+func (service *SLOServiceImpl) GetSLOs(ctx context.Context) ([]model.SLO, error) {
+	return service.SLODao.GetSLOs(ctx)
+}
+
+// GetSLOStatus slo with remaining error budget and burn rates up to now
+func (service *SLOServiceImpl) GetSLOStatus(ctx context.Context, id string) (*model.SLOStatus, error) {
+	slo, err := service.getSLO(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return getSLOStatus(ctx, service.ApmDao, slo, time.Now())
+}
+
+func (service *SLOServiceImpl) CreateSLO(ctx context.Context, slo *model.SLO) (*model.SLO, error) {
+	id, err := newAlertRuleID()
+	if err != nil {
+		return nil, err
+	}
+	slo.ID = id
+	slo.UpdatedAt = time.Now().UnixNano()
+	if err := service.SLODao.SaveSLO(ctx, slo); err != nil {
+		return nil, err
+	}
+	return slo, nil
+}
+
+func (service *SLOServiceImpl) UpdateSLO(ctx context.Context, slo *model.SLO) (*model.SLO, error) {
+	if _, err := service.getSLO(ctx, slo.ID); err != nil {
+		return nil, err
+	}
+	slo.UpdatedAt = time.Now().UnixNano()
+	if err := service.SLODao.SaveSLO(ctx, slo); err != nil {
+		return nil, err
+	}
+	return slo, nil
+}
+
+func (service *SLOServiceImpl) DeleteSLO(ctx context.Context, id string) error {
+	slo, err := service.getSLO(ctx, id)
+	if err != nil {
+		return err
+	}
+	return service.SLODao.DeleteSLO(ctx, slo)
+}
+
+func (service *SLOServiceImpl) getSLO(ctx context.Context, id string) (*model.SLO, error) {
+	slo, err := service.SLODao.GetSLO(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if slo == nil {
+		return nil, sloNotFoundError(id)
+	}
+	return slo, nil
+}
+
+func sloNotFoundError(id string) error {
+	return &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("slo %s not found", id)}
+}
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"goapm/dao"
+	model "goapm/domain"
+	"goapm/logger"
+	"goapm/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var spanBatchWriterOnce sync.Once
+var spanBatchWriter *SpanBatchWriter
+
+// span writer metrics, exposed on /actuator/prometheus through the default registry
+var (
+	spanWriterQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "goapm_span_writer_queue_depth",
+		Help: "Number of spans waiting in span writer buffer.",
+	})
+	spanWriterFlushDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "goapm_span_writer_flush_duration_seconds",
+		Help: "Duration of span batch inserts into clickhouse.",
+	})
+	spanWriterWrittenSpans = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "goapm_span_writer_written_spans_total",
+		Help: "Spans inserted into clickhouse, including replayed spans.",
+	})
+	spanWriterDroppedSpans = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "goapm_span_writer_dropped_spans_total",
+		Help: "Spans not accepted or lost by span writer, by reason.",
+	}, []string{"reason"})
+	spanWriterSpilledSpans = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "goapm_span_writer_spilled_spans_total",
+		Help: "Spans written to the spill file because clickhouse was unavailable.",
+	})
+	spanWriterReplayedSpans = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "goapm_span_writer_replayed_spans_total",
+		Help: "Spans replayed from the spill file into clickhouse.",
+	})
+	spanWriterSpillBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "goapm_span_writer_spill_file_bytes",
+		Help: "Size of the span writer spill file.",
+	})
+)
+
+type SpanBatchWriterConfig struct {
+	BatchSize     int
+	MaxQueueSize  int
+	FlushInterval time.Duration
+	// RetryBackoff time to skip clickhouse after a failed insert, batches are spilled to disk meanwhile
+	RetryBackoff  time.Duration
+	SpillFile     string
+	MaxSpillBytes int64
+}
+
+// SpanBatchWriter buffer spans in memory and insert them in batches, flush happens when buffer reaches batch size
+// or every flush interval. When clickhouse is unavailable batches are appended to a local spill file, which is replayed
+// once inserts succeed again
+type SpanBatchWriter struct {
+	Logger  *zap.SugaredLogger
+	SpanDao dao.SpanDao
+	Config  SpanBatchWriterConfig
+
+	mutex       sync.Mutex
+	buffer      []model.Span
+	flushSignal chan struct{}
+	stop        chan struct{}
+	done        chan struct{}
+	closeOnce   sync.Once
+
+	// accessed only by the flush goroutine
+	unavailableUntil time.Time
+	spillBytes       int64
+}
+
+// NewSpanBatchWriter create singleton span writer and start its flush goroutine, configured by SPAN_WRITER_* properties
+func NewSpanBatchWriter(SpanDao dao.SpanDao) *SpanBatchWriter {
+	spanBatchWriterOnce.Do(func() {
+		spanBatchWriter = newSpanBatchWriter(SpanDao, SpanBatchWriterConfig{
+			BatchSize:     utils.GetOrDefaultInt(viper.GetString("SPAN_WRITER_BATCH_SIZE"), 5000),
+			MaxQueueSize:  utils.GetOrDefaultInt(viper.GetString("SPAN_WRITER_MAX_QUEUE_SIZE"), 100000),
+			FlushInterval: time.Duration(utils.GetOrDefaultInt(viper.GetString("SPAN_WRITER_FLUSH_INTERVAL_MILLIS"), 1000)) * time.Millisecond,
+			RetryBackoff:  time.Duration(utils.GetOrDefaultInt(viper.GetString("SPAN_WRITER_RETRY_BACKOFF_MILLIS"), 10000)) * time.Millisecond,
+			SpillFile:     utils.GetOrDefault(viper.GetString("SPAN_WRITER_SPILL_FILE"), filepath.Join(os.TempDir(), "goapm-spans.spill")),
+			MaxSpillBytes: int64(utils.GetOrDefaultInt(viper.GetString("SPAN_WRITER_MAX_SPILL_MB"), 1024)) * 1024 * 1024,
+		})
+	})
+	return spanBatchWriter
+}
+
+func newSpanBatchWriter(spanDao dao.SpanDao, config SpanBatchWriterConfig) *SpanBatchWriter {
+	writer := &SpanBatchWriter{
+		Logger:      logger.LOGGER,
+		SpanDao:     spanDao,
+		Config:      config,
+		flushSignal: make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if info, err := os.Stat(config.SpillFile); err == nil {
+		writer.spillBytes = info.Size()
+		spanWriterSpillBytes.Set(float64(writer.spillBytes))
+	}
+	go writer.run()
+	return writer
+}
+
+// Write add spans to buffer without blocking, ErrSpanQueueFull is returned if spans don't fit into the buffer
+func (writer *SpanBatchWriter) Write(spans []model.Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	writer.mutex.Lock()
+	if len(writer.buffer)+len(spans) > writer.Config.MaxQueueSize {
+		writer.mutex.Unlock()
+		spanWriterDroppedSpans.WithLabelValues("queue_full").Add(float64(len(spans)))
+		return ErrSpanQueueFull
+	}
+	writer.buffer = append(writer.buffer, spans...)
+	queueDepth := len(writer.buffer)
+	writer.mutex.Unlock()
+
+	spanWriterQueueDepth.Set(float64(queueDepth))
+	if queueDepth >= writer.Config.BatchSize {
+		select {
+		case writer.flushSignal <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// RetryAfter time clients should wait before resending rejected spans
+func (writer *SpanBatchWriter) RetryAfter() time.Duration {
+	if writer.Config.FlushInterval < time.Second {
+		return time.Second
+	}
+	return writer.Config.FlushInterval.Round(time.Second)
+}
+
+// Close stop flush goroutine, buffered spans are flushed(or spilled) before returning
+func (writer *SpanBatchWriter) Close() {
+	writer.closeOnce.Do(func() {
+		close(writer.stop)
+		<-writer.done
+	})
+}
+
+func (writer *SpanBatchWriter) run() {
+	ticker := time.NewTicker(writer.Config.FlushInterval)
+	defer ticker.Stop()
+	defer close(writer.done)
+	for {
+		select {
+		case <-writer.stop:
+			writer.flush()
+			return
+		case <-writer.flushSignal:
+			writer.flush()
+		case <-ticker.C:
+			writer.flush()
+			writer.replaySpill()
+		}
+	}
+}
+
+func (writer *SpanBatchWriter) flush() {
+	for {
+		batch := writer.takeBatch()
+		if len(batch) == 0 {
+			return
+		}
+		writer.writeBatch(batch)
+	}
+}
+
+func (writer *SpanBatchWriter) takeBatch() []model.Span {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	size := len(writer.buffer)
+	if size > writer.Config.BatchSize {
+		size = writer.Config.BatchSize
+	}
+	batch := writer.buffer[:size:size]
+	if size == len(writer.buffer) {
+		writer.buffer = nil
+	} else {
+		writer.buffer = append([]model.Span(nil), writer.buffer[size:]...)
+	}
+	spanWriterQueueDepth.Set(float64(len(writer.buffer)))
+	return batch
+}
+
+func (writer *SpanBatchWriter) writeBatch(batch []model.Span) {
+	if time.Now().Before(writer.unavailableUntil) {
+		writer.spill(batch)
+		return
+	}
+	if err := writer.insert(batch); err != nil {
+		writer.Logger.Error("unable to insert spans, spilling batch to disk, error = ", err)
+		writer.spill(batch)
+	}
+}
+
+func (writer *SpanBatchWriter) insert(batch []model.Span) error {
+	start := time.Now()
+	err := writer.SpanDao.InsertSpans(context.Background(), batch)
+	spanWriterFlushDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		writer.unavailableUntil = time.Now().Add(writer.Config.RetryBackoff)
+		return err
+	}
+	spanWriterWrittenSpans.Add(float64(len(batch)))
+	return nil
+}
+
+// spill append batch to spill file, one json encoded span per line
+func (writer *SpanBatchWriter) spill(batch []model.Span) {
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	for i := range batch {
+		_ = encoder.Encode(&batch[i])
+	}
+	if writer.spillBytes+int64(encoded.Len()) > writer.Config.MaxSpillBytes {
+		writer.Logger.Error("span spill file reached max size, dropping spans, count = ", len(batch))
+		spanWriterDroppedSpans.WithLabelValues("spill_full").Add(float64(len(batch)))
+		return
+	}
+	file, err := os.OpenFile(writer.Config.SpillFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		writer.Logger.Error("unable to open span spill file, dropping spans, error = ", err)
+		spanWriterDroppedSpans.WithLabelValues("spill_error").Add(float64(len(batch)))
+		return
+	}
+	written, err := file.Write(encoded.Bytes())
+	_ = file.Close()
+	writer.spillBytes += int64(written)
+	spanWriterSpillBytes.Set(float64(writer.spillBytes))
+	if err != nil {
+		writer.Logger.Error("unable to write span spill file, dropping spans, error = ", err)
+		spanWriterDroppedSpans.WithLabelValues("spill_error").Add(float64(len(batch)))
+		return
+	}
+	spanWriterSpilledSpans.Add(float64(len(batch)))
+}
+
+// replaySpill insert spilled spans back into clickhouse, replayed part of the file is removed even if replay fails midway
+func (writer *SpanBatchWriter) replaySpill() {
+	if writer.spillBytes == 0 || time.Now().Before(writer.unavailableUntil) {
+		return
+	}
+	file, err := os.Open(writer.Config.SpillFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			writer.spillBytes = 0
+			spanWriterSpillBytes.Set(0)
+		}
+		return
+	}
+
+	reader := bufio.NewReader(file)
+	var replayedBytes, batchBytes int64
+	var batch []model.Span
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		// partial last line is a batch that was cut while writing, it can't be decoded
+		if readErr == nil {
+			batchBytes += int64(len(line))
+			var span model.Span
+			if err = json.Unmarshal(line, &span); err != nil {
+				writer.Logger.Error("unable to decode spilled span, skipping it, error = ", err)
+			} else {
+				batch = append(batch, span)
+			}
+		}
+		if len(batch) >= writer.Config.BatchSize || (readErr != nil && len(batch) > 0) {
+			if err = writer.insert(batch); err != nil {
+				_ = file.Close()
+				writer.Logger.Error("unable to replay spilled spans, error = ", err)
+				writer.truncateSpill(replayedBytes)
+				return
+			}
+			spanWriterReplayedSpans.Add(float64(len(batch)))
+			replayedBytes += batchBytes
+			batchBytes = 0
+			batch = nil
+		}
+		if readErr != nil {
+			if readErr != io.EOF {
+				writer.Logger.Error("unable to read span spill file, error = ", readErr)
+			}
+			break
+		}
+	}
+	_ = file.Close()
+	if err = os.Remove(writer.Config.SpillFile); err != nil {
+		writer.Logger.Error("unable to remove span spill file, error = ", err)
+		return
+	}
+	writer.spillBytes = 0
+	spanWriterSpillBytes.Set(0)
+}
+
+// truncateSpill remove first offset bytes of spill file
+func (writer *SpanBatchWriter) truncateSpill(offset int64) {
+	if offset == 0 {
+		return
+	}
+	source, err := os.Open(writer.Config.SpillFile)
+	if err != nil {
+		writer.Logger.Error("unable to open span spill file, error = ", err)
+		return
+	}
+	defer source.Close()
+	if _, err = source.Seek(offset, io.SeekStart); err != nil {
+		writer.Logger.Error("unable to seek span spill file, error = ", err)
+		return
+	}
+	tmpFile := writer.Config.SpillFile + ".tmp"
+	target, err := os.Create(tmpFile)
+	if err != nil {
+		writer.Logger.Error("unable to create span spill file, error = ", err)
+		return
+	}
+	remaining, err := io.Copy(target, source)
+	_ = target.Close()
+	if err == nil {
+		err = os.Rename(tmpFile, writer.Config.SpillFile)
+	}
+	if err != nil {
+		writer.Logger.Error("unable to truncate span spill file, error = ", err)
+		return
+	}
+	writer.spillBytes = remaining
+	spanWriterSpillBytes.Set(float64(remaining))
+}
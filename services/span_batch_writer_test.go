@@ -0,0 +1,75 @@
+package services
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"goapm/dao"
+	model "goapm/domain"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newSpanBatchWriterTest(t *testing.T, spanDao dao.SpanDao, batchSize int, maxQueueSize int) *SpanBatchWriter {
+	return newSpanBatchWriter(spanDao, SpanBatchWriterConfig{
+		BatchSize:     batchSize,
+		MaxQueueSize:  maxQueueSize,
+		FlushInterval: 10 * time.Millisecond,
+		RetryBackoff:  0,
+		SpillFile:     filepath.Join(t.TempDir(), "spans.spill"),
+		MaxSpillBytes: 1024 * 1024,
+	})
+}
+
+func TestSpanBatchWriterFlush(t *testing.T) {
+	spanDaoMock := new(dao.MockSpanDao)
+	spanDaoMock.On("InsertSpans", mock.Anything, mock.Anything).Return(nil)
+	classUnderTest := newSpanBatchWriterTest(t, spanDaoMock, 2, 10)
+
+	err := classUnderTest.Write([]model.Span{{SpanID: "1"}, {SpanID: "2"}, {SpanID: "3"}})
+	assert.Nil(t, err)
+	classUnderTest.Close()
+
+	spanDaoMock.AssertNumberOfCalls(t, "InsertSpans", 2)
+	assert.Equal(t, 2, len(spanDaoMock.Calls[0].Arguments.Get(1).([]model.Span)))
+	assert.Equal(t, 1, len(spanDaoMock.Calls[1].Arguments.Get(1).([]model.Span)))
+}
+
+func TestSpanBatchWriterQueueFull(t *testing.T) {
+	spanDaoMock := new(dao.MockSpanDao)
+	spanDaoMock.On("InsertSpans", mock.Anything, mock.Anything).Return(nil)
+	classUnderTest := newSpanBatchWriterTest(t, spanDaoMock, 100, 2)
+	defer classUnderTest.Close()
+
+	err := classUnderTest.Write([]model.Span{{SpanID: "1"}, {SpanID: "2"}, {SpanID: "3"}})
+	assert.Equal(t, ErrSpanQueueFull, err)
+	assert.Equal(t, time.Second, classUnderTest.RetryAfter())
+}
+
+func TestSpanBatchWriterSpillAndReplay(t *testing.T) {
+	inserted := make(chan []model.Span, 10)
+	spanDaoMock := new(dao.MockSpanDao)
+	spanDaoMock.On("InsertSpans", mock.Anything, mock.Anything).Return(errors.New("clickhouse is down")).Once()
+	spanDaoMock.On("InsertSpans", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		inserted <- args.Get(1).([]model.Span)
+	})
+	classUnderTest := newSpanBatchWriterTest(t, spanDaoMock, 10, 10)
+
+	err := classUnderTest.Write([]model.Span{{SpanID: "1", ServiceName: "frontend"}, {SpanID: "2"}})
+	assert.Nil(t, err)
+
+	select {
+	case replayedSpans := <-inserted:
+		assert.Equal(t, 2, len(replayedSpans))
+		assert.Equal(t, "frontend", replayedSpans[0].ServiceName)
+	case <-time.After(2 * time.Second):
+		t.Fatal("spilled spans were not replayed")
+	}
+	classUnderTest.Close()
+
+	_, err = os.Stat(classUnderTest.Config.SpillFile)
+	assert.True(t, os.IsNotExist(err))
+	spanDaoMock.AssertNumberOfCalls(t, "InsertSpans", 2)
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+	"time"
+)
+
+type SpanIngestionService interface {
+	IngestSpans(ctx context.Context, spans []model.Span) error
+	RetryAfter() time.Duration
+}
+
+type MockSpanIngestionService struct {
+	mock.Mock
+}
+
+func (service *MockSpanIngestionService) IngestSpans(ctx context.Context, spans []model.Span) error {
+	args := service.Called(ctx, spans)
+	return args.Error(0)
+}
+
+func (service *MockSpanIngestionService) RetryAfter() time.Duration {
+	args := service.Called()
+	return args.Get(0).(time.Duration)
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"go.uber.org/zap"
+	model "goapm/domain"
+	"goapm/logger"
+	"sync"
+	"time"
+)
+
+var spanIngestionServiceOnce sync.Once
+var spanIngestionService *SpanIngestionServiceImpl
+
+type SpanIngestionServiceImpl struct {
+	Logger     *zap.SugaredLogger
+	SpanWriter SpanWriter
+}
+
+func NewSpanIngestionServiceImpl(SpanWriter SpanWriter) *SpanIngestionServiceImpl {
+	spanIngestionServiceOnce.Do(func() {
+		spanIngestionService = &SpanIngestionServiceImpl{
+			Logger:     logger.LOGGER,
+			SpanWriter: SpanWriter,
+		}
+	})
+	return spanIngestionService
+}
+
+// IngestSpans hand received spans to span writer which stores them in signoz_index_tmp,
+// TraceFilterJob moves relevant traces to signoz_index_final
+func (service *SpanIngestionServiceImpl) IngestSpans(ctx context.Context, spans []model.Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	return service.SpanWriter.Write(spans)
+}
+
+// RetryAfter time clients should wait when spans are rejected because span writer is full
+func (service *SpanIngestionServiceImpl) RetryAfter() time.Duration {
+	return service.SpanWriter.RetryAfter()
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"errors"
+	"github.com/stretchr/testify/mock"
+	model "goapm/domain"
+	"time"
+)
+
+// ErrSpanQueueFull returned by span writer when its buffer is full, ingestion handlers should ask clients to retry later
+var ErrSpanQueueFull = errors.New("span writer queue is full")
+
+type SpanWriter interface {
+	Write(spans []model.Span) error
+	RetryAfter() time.Duration
+	Close()
+}
+
+type MockSpanWriter struct {
+	mock.Mock
+}
+
+func (writer *MockSpanWriter) Write(spans []model.Span) error {
+	args := writer.Called(spans)
+	return args.Error(0)
+}
+
+func (writer *MockSpanWriter) RetryAfter() time.Duration {
+	args := writer.Called()
+	return args.Get(0).(time.Duration)
+}
+
+func (writer *MockSpanWriter) Close() {
+	writer.Called()
+}
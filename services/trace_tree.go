@@ -0,0 +1,176 @@
+package services
+
+import (
+	model "goapm/domain"
+	"sort"
+	"time"
+)
+
+// buildTraceTree link spans to their parents and compute depth, self time and critical path.
+// Spans whose parent is missing from the trace, or which are part of a reference cycle, become orphan roots
+func buildTraceTree(traceID string, items []model.SearchSpanReponseItem) *model.TraceTree {
+	tree := &model.TraceTree{TraceID: traceID, SpanCount: len(items), CriticalPath: []string{}, Roots: []*model.TraceTreeSpan{}}
+	if len(items) == 0 {
+		return tree
+	}
+
+	spans := make(map[string]*model.TraceTreeSpan, len(items))
+	ordered := make([]*model.TraceTreeSpan, 0, len(items))
+	for i := range items {
+		item := &items[i]
+		if _, ok := spans[item.SpanID]; ok {
+			// duplicated span, keep the first one
+			continue
+		}
+		timeObj, _ := time.Parse(time.RFC3339Nano, item.Timestamp)
+		span := &model.TraceTreeSpan{
+			SpanID:       item.SpanID,
+			ParentSpanID: item.GetParentSpanID(),
+			ServiceName:  item.ServiceName,
+			Name:         item.Name,
+			Kind:         item.Kind,
+			StartTime:    timeObj.UnixNano(),
+			DurationNano: item.DurationNano,
+			TagsKeys:     item.TagsKeys,
+			TagsValues:   item.TagsValues,
+			References:   item.GetReferences(),
+			Children:     []*model.TraceTreeSpan{},
+		}
+		spans[span.SpanID] = span
+		ordered = append(ordered, span)
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].StartTime < ordered[j].StartTime
+	})
+
+	end := int64(0)
+	tree.StartTime = ordered[0].StartTime
+	for _, span := range ordered {
+		if spanEnd(span) > end {
+			end = spanEnd(span)
+		}
+		parent, ok := spans[span.ParentSpanID]
+		if len(span.ParentSpanID) == 0 {
+			tree.Roots = append(tree.Roots, span)
+		} else if !ok {
+			span.Orphan = true
+			tree.Roots = append(tree.Roots, span)
+		} else {
+			parent.Children = append(parent.Children, span)
+		}
+	}
+	tree.DurationNano = end - tree.StartTime
+
+	visited := make(map[string]bool, len(ordered))
+	for _, root := range tree.Roots {
+		visitTraceTree(root, 0, visited)
+	}
+	// spans not reachable from any root reference each other in a cycle, detach earliest one to break it
+	for _, span := range ordered {
+		if visited[span.SpanID] {
+			continue
+		}
+		parent := spans[span.ParentSpanID]
+		for i, child := range parent.Children {
+			if child == span {
+				parent.Children = append(parent.Children[:i], parent.Children[i+1:]...)
+				break
+			}
+		}
+		span.Orphan = true
+		tree.Roots = append(tree.Roots, span)
+		visitTraceTree(span, 0, visited)
+	}
+	for _, root := range tree.Roots {
+		if root.Orphan {
+			tree.OrphanCount++
+		}
+	}
+
+	if main := mainRoot(tree.Roots); main != nil {
+		markCriticalPath(main, &tree.CriticalPath)
+	}
+	return tree
+}
+
+// visitTraceTree set depth and self time, self time is span duration not covered by any of its children
+func visitTraceTree(span *model.TraceTreeSpan, depth int, visited map[string]bool) {
+	visited[span.SpanID] = true
+	span.Depth = depth
+
+	type interval struct{ start, end int64 }
+	var intervals []interval
+	for _, child := range span.Children {
+		visitTraceTree(child, depth+1, visited)
+		start, end := child.StartTime, spanEnd(child)
+		if start < span.StartTime {
+			start = span.StartTime
+		}
+		if end > spanEnd(span) {
+			end = spanEnd(span)
+		}
+		if start < end {
+			intervals = append(intervals, interval{start, end})
+		}
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].start < intervals[j].start
+	})
+
+	covered := int64(0)
+	coveredUntil := span.StartTime
+	for _, childInterval := range intervals {
+		if childInterval.start > coveredUntil {
+			coveredUntil = childInterval.start
+		}
+		if childInterval.end > coveredUntil {
+			covered += childInterval.end - coveredUntil
+			coveredUntil = childInterval.end
+		}
+	}
+	span.SelfTimeNano = span.DurationNano - covered
+}
+
+// markCriticalPath walk back from the end of span, the child finishing last is on the critical path,
+// then the child finishing last before that child started and so on. Children overlapping an already
+// chosen child ran in parallel and didn't delay the parent
+func markCriticalPath(span *model.TraceTreeSpan, path *[]string) {
+	span.OnCriticalPath = true
+	*path = append(*path, span.SpanID)
+
+	children := make([]*model.TraceTreeSpan, len(span.Children))
+	copy(children, span.Children)
+	sort.SliceStable(children, func(i, j int) bool {
+		return spanEnd(children[i]) > spanEnd(children[j])
+	})
+
+	cursor := spanEnd(span)
+	for i, child := range children {
+		// last finishing child is always taken, even when it outlives the parent (async child)
+		if i > 0 && spanEnd(child) > cursor {
+			continue
+		}
+		markCriticalPath(child, path)
+		cursor = child.StartTime
+		if cursor <= span.StartTime {
+			break
+		}
+	}
+}
+
+// mainRoot root without parent which started first, trace without such root falls back to the first orphan
+func mainRoot(roots []*model.TraceTreeSpan) *model.TraceTreeSpan {
+	for _, root := range roots {
+		if !root.Orphan {
+			return root
+		}
+	}
+	if len(roots) > 0 {
+		return roots[0]
+	}
+	return nil
+}
+
+func spanEnd(span *model.TraceTreeSpan) int64 {
+	return span.StartTime + span.DurationNano
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	model "goapm/domain"
+	"testing"
+)
+
+func traceTreeSpan(spanID string, parentSpanID string, startMillis int, durationMillis int) model.SearchSpanReponseItem {
+	references := "[]"
+	if len(parentSpanID) > 0 {
+		references = `[{"traceId":"t1","spanId":"` + parentSpanID + `","refType":"CHILD_OF"}]`
+	}
+	return model.SearchSpanReponseItem{
+		TraceID:      "t1",
+		SpanID:       spanID,
+		Timestamp:    "2020-09-13T12:26:40." + []string{"000", "010", "020", "030", "040", "050", "060", "070", "080", "090", "100"}[startMillis/10] + "Z",
+		DurationNano: int64(durationMillis) * 1000000,
+		References:   references,
+	}
+}
+
+func TestBuildTraceTree(t *testing.T) {
+	// root 0-100ms, a 10-50ms and b 20-40ms run in parallel, c 60-90ms, a1 10-30ms is child of a
+	tree := buildTraceTree("t1", []model.SearchSpanReponseItem{
+		traceTreeSpan("c", "root", 60, 30),
+		traceTreeSpan("root", "", 0, 100),
+		traceTreeSpan("a", "root", 10, 40),
+		traceTreeSpan("b", "root", 20, 20),
+		traceTreeSpan("a1", "a", 10, 20),
+	})
+
+	assert.Equal(t, 5, tree.SpanCount)
+	assert.Equal(t, int64(100000000), tree.DurationNano)
+	assert.Equal(t, 0, tree.OrphanCount)
+	assert.Equal(t, 1, len(tree.Roots))
+	root := tree.Roots[0]
+	assert.Equal(t, "root", root.SpanID)
+	assert.Equal(t, 3, len(root.Children))
+	assert.Equal(t, int64(30000000), root.SelfTimeNano)
+
+	a := root.Children[0]
+	assert.Equal(t, "a", a.SpanID)
+	assert.Equal(t, 1, a.Depth)
+	assert.Equal(t, int64(20000000), a.SelfTimeNano)
+	assert.Equal(t, 2, a.Children[0].Depth)
+
+	assert.Equal(t, []string{"root", "c", "a", "a1"}, tree.CriticalPath)
+	assert.False(t, root.Children[1].OnCriticalPath)
+}
+
+func TestBuildTraceTreeOrphans(t *testing.T) {
+	tree := buildTraceTree("t1", []model.SearchSpanReponseItem{
+		traceTreeSpan("root", "", 0, 100),
+		traceTreeSpan("lost", "missing", 10, 10),
+		traceTreeSpan("x", "y", 20, 10),
+		traceTreeSpan("y", "x", 30, 10),
+	})
+
+	assert.Equal(t, 2, tree.OrphanCount)
+	assert.Equal(t, []string{"root", "lost", "x"}, []string{tree.Roots[0].SpanID, tree.Roots[1].SpanID, tree.Roots[2].SpanID})
+	assert.Equal(t, "missing", tree.Roots[1].ParentSpanID)
+	assert.Equal(t, "y", tree.Roots[2].Children[0].SpanID)
+	assert.Equal(t, 1, tree.Roots[2].Children[0].Depth)
+	assert.Equal(t, []string{"root"}, tree.CriticalPath)
+}
+
+func TestBuildTraceTreeEmpty(t *testing.T) {
+	tree := buildTraceTree("t1", nil)
+	assert.Equal(t, 0, tree.SpanCount)
+	assert.Empty(t, tree.Roots)
+}
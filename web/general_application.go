@@ -20,6 +20,9 @@ import (
 
 var HealthChecksToRun = make(map[string]utils.HealthCheckService)
 
+// ErrorHandler used by the web application for errors returned from handlers, applications may replace it before InitApp
+var ErrorHandler fiber.ErrorHandler = fiber.DefaultErrorHandler
+
 // InitApp create new web application using - (https://github.com/gofiber/fiber)
 // Initialize logger, config server properties and the following routes -
 // /actuator/prometheus - metrics collector - using (https://github.com/ansrivas/fiberprometheus)
@@ -30,37 +33,9 @@ var HealthChecksToRun = make(map[string]utils.HealthCheckService)
 // /actuator/loggers - changes the log level to chosen one, if we choose to create specific logger for specific service, we can only change it
 // /actuator/refresh - reload config server properties
 func InitApp(appName, version, build, commit, buildTime string) *fiber.App {
-	logger.InitLogger()
-
-	profile := "prod"
-	configBranch := "master"
-
-	if len(os.Getenv("profile")) > 0 {
-		profile = os.Getenv("profile")
-	}
-
-	if len(os.Getenv("configBranch")) > 0 {
-		configBranch = os.Getenv("configBranch")
-	}
+	confService := InitConfiguration(appName)
 
-	viper.Set("profile", profile)
-	viper.Set("configServerUrl", os.Getenv("confsrvDomain"))
-	viper.Set("configBranch", configBranch)
-
-	restClient := http.NewRestClient()
-	confService := config.NewConfSrvService(restClient)
-	confService.LoadConfigurationFromBranch(
-		viper.GetString("configServerUrl"),
-		appName,
-		viper.GetString("profile"),
-		viper.GetString("configBranch"))
-	logger.SetLevel(viper.GetString("LOG_LEVEL"))
-
-	location, err := time.LoadLocation("UTC")
-	if err == nil {
-		time.Local = location
-	}
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 	app.Use(recover2.New())
 	app.Use(web_filters.NewXssFilter())
 	app.Use(pprof.New())
@@ -123,6 +98,42 @@ func InitApp(appName, version, build, commit, buildTime string) *fiber.App {
 	return app
 }
 
+// InitConfiguration initialize logger and load config server properties(confsrvDomain, profile, configBranch env variables),
+// used by InitApp and by commands which don't start the web application
+func InitConfiguration(appName string) *config.ConfServerService {
+	logger.InitLogger()
+
+	profile := "prod"
+	configBranch := "master"
+
+	if len(os.Getenv("profile")) > 0 {
+		profile = os.Getenv("profile")
+	}
+
+	if len(os.Getenv("configBranch")) > 0 {
+		configBranch = os.Getenv("configBranch")
+	}
+
+	viper.Set("profile", profile)
+	viper.Set("configServerUrl", os.Getenv("confsrvDomain"))
+	viper.Set("configBranch", configBranch)
+
+	restClient := http.NewRestClient()
+	confService := config.NewConfSrvService(restClient)
+	confService.LoadConfigurationFromBranch(
+		viper.GetString("configServerUrl"),
+		appName,
+		viper.GetString("profile"),
+		viper.GetString("configBranch"))
+	logger.SetLevel(viper.GetString("LOG_LEVEL"))
+
+	location, err := time.LoadLocation("UTC")
+	if err == nil {
+		time.Local = location
+	}
+	return confService
+}
+
 func getMemUsage() map[string]interface{} {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)